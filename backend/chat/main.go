@@ -11,6 +11,8 @@ import (
 	"syscall"
 	"time"
 
+	auditController "chat/module/audit/controller"
+	auditService "chat/module/audit/service"
 	chatController "chat/module/chat/controller"
 	uploadController "chat/module/chat/controller"
 	chatService "chat/module/chat/service"
@@ -19,8 +21,15 @@ import (
 	restrictionService "chat/module/restriction/service"
 	groupController "chat/module/room/group/controller"
 	groupService "chat/module/room/group/service"
+	inviteController "chat/module/room/invite/controller"
+	inviteService "chat/module/room/invite/service"
 	roomController "chat/module/room/room/controller"
 	roomService "chat/module/room/room/service"
+	privacyController "chat/module/privacy/controller"
+	privacyService "chat/module/privacy/service"
+	settingsController "chat/module/settings/controller"
+	settingsModel "chat/module/settings/model"
+	settingsService "chat/module/settings/service"
 	evoucherController "chat/module/sendEvoucher/controller"
 	evoucherService "chat/module/sendEvoucher/service"
 	stickerController "chat/module/sticker/controller"
@@ -28,16 +37,24 @@ import (
 	userController "chat/module/user/controller"
 	userService "chat/module/user/service"
 	"chat/pkg/config"
+	"chat/pkg/core/admission"
+	"chat/pkg/core/audit"
+	"chat/pkg/core/circuitbreaker"
 	mananger "chat/pkg/core/connection"
 	"chat/pkg/core/kafka"
+	"chat/pkg/core/lifecycle"
+	"chat/pkg/core/logging"
+	"chat/pkg/core/metrics"
+	"chat/pkg/core/tracing"
 	"chat/pkg/middleware"
+	pkgUtils "chat/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/websocket/v2"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -57,7 +74,7 @@ var connManager *mananger.ConnectionManager
 // Helper functions for database connections
 func connectMongoDB(cfg *config.Config) (*mongo.Database, error) {
 	ctx := context.Background()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.Mongo.URI))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.Mongo.URI).SetMonitor(tracing.NewMongoMonitor()))
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +92,7 @@ func connectRedis(cfg *config.Config) (*redis.Client, error) {
 		Password: cfg.Redis.Password,
 		DB:       cfg.Redis.DB,
 	})
+	client.AddHook(tracing.RedisHook{})
 
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		return nil, err
@@ -90,9 +108,34 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Setup logging
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-	log.SetPrefix("[SERVER] ")
+	// Setup logging (JSON + redaction, log.Printf เดิมถูกส่งผ่าน logger นี้ด้วย)
+	if err := logging.Init(cfg.Logging); err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+
+	// Circuit breakers ใช้ค่าจาก AsyncFlow.CircuitBreaker
+	circuitbreaker.Configure(circuitbreaker.SettingsFromConfig(cfg))
+	metrics.Configure(cfg.AsyncFlow.Monitoring.MetricsEnabled, cfg.AsyncFlow.Monitoring.RoomLabels)
+	admission.Configure(admission.Settings{
+		Enabled:            cfg.Admission.Enabled,
+		ElevatedQueueRatio: cfg.Admission.ElevatedQueueRatio,
+		OverloadQueueRatio: cfg.Admission.OverloadQueueRatio,
+		ElevatedLag:        cfg.Admission.ElevatedLag,
+		OverloadLag:        cfg.Admission.OverloadLag,
+		RetryAfter:         cfg.Admission.RetryAfter,
+	})
+
+	// **NEW: CLI mode สำหรับ operator: chat migrate status|up|down [steps]**
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(cfg, os.Args[2:]))
+	}
+
+	// Tracing (ต้องตั้งก่อนสร้าง client ของ Mongo/Redis/Kafka)
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 
 	// Add test log handler
 	testLogChan := make(chan string, 1000)
@@ -108,6 +151,11 @@ func main() {
 		log.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
 
+	// **NEW: Schema migrations (มี lock จึงรันแค่ instance เดียว ที่เหลือรอจนเสร็จ)**
+	if cfg.Migration.OnStartup {
+		runStartupMigrations(db, cfg)
+	}
+
 	// Connect to Redis
 	redis, err := connectRedis(cfg)
 	if err != nil {
@@ -139,6 +187,10 @@ func main() {
 	// Initialize connection manager with default config
 	connManager = mananger.NewConnectionManager(mananger.DefaultConfig())
 
+	// **NEW: Runtime settings (ปรับ limit ได้โดยไม่ต้อง restart)**
+	settingsSvc := settingsService.NewSettingsService(db, redis)
+	settingsSvc.OnChange(applyRuntimeSettings)
+
 	// Setup middleware
 	setupMiddleware(app)
 	
@@ -157,40 +209,68 @@ func main() {
 		},
 	})
 
+	// Prometheus metrics (อยู่นอก API base path เพื่อให้ scraper เรียกได้ตรง)
+	if metrics.Enabled() {
+		app.Get("/metrics", metrics.Handler())
+	}
+
 	// Initialize services
 	chatSvc := chatService.NewChatService(db, redis, kafkaBus, cfg)
 	chatHub := chatSvc.GetHub()
 
+	// Probes สำหรับ orchestrator (อยู่นอก API base path เหมือน /metrics)
+	app.Get("/livez", lifecycle.LivenessHandler())
+	app.Get("/readyz", chatSvc.GetReadiness().Handler())
+
 	// Initialize all services
 	schoolSvc := userService.NewSchoolService(db)
 	majorSvc := userService.NewMajorService(db)
 	roleSvc := userService.NewRoleService(db)
 	userSvc := userService.NewUserService(db)
 	roomSvc := roomService.NewRoomService(db, redis, cfg, chatHub)
+	// **NEW: เปิด/ปิดห้องตามตาราง และ warm cache ของห้องที่ใกล้เปิด**
+	roomScheduler := roomService.NewRoomScheduler(db, roomSvc, chatHub, cfg.RoomSchedule)
+	roomScheduler.Start(context.Background())
+	chatSvc.GetCacheWarmer().AddUpcomingSource(roomScheduler.UpcomingRooms)
 	
 	groupRoomSvc := groupService.NewGroupRoomService(db, redis, cfg, chatHub, roomSvc, kafkaBus)
+	inviteSvc := inviteService.NewInviteService(db, redis, roomSvc, cfg.RoomInvite)
 	stickerSvc := stickerService.NewStickerService(db)
 	chatEmitter := utils.NewChatEventEmitter(chatHub, kafkaBus, redis, db)
 	restrictionSvc := restrictionService.NewRestrictionService(db, chatHub, chatEmitter, chatSvc.GetNotificationService(), kafkaBus)
+	privacySvc := privacyService.NewPrivacyService(db, redis, chatSvc.GetMessageArchive())
 	evoucherSvc := evoucherService.NewEvoucherService(db, redis, restrictionSvc, chatSvc.GetNotificationService(), chatHub, kafkaBus)
 
 	// Initialize RBAC middleware
 	rbacMiddleware := middleware.NewRBACMiddleware(db)
 
+	// **NEW: Admin audit log (ต้อง Use ก่อน controller ลงทะเบียน route)**
+	audit.Configure(db)
+	apiGroup.Use(audit.Middleware(rbacMiddleware.AuditActor))
+
 	userController.NewUserController(usersGroup, userSvc, rbacMiddleware)
 	userController.NewRoleController(rolesGroup, roleSvc, rbacMiddleware)
 	userController.NewSchoolController(schoolsGroup, schoolSvc)
 	userController.NewMajorController(majorsGroup, majorSvc)
 	roomController.NewRoomController(roomsGroup, roomSvc, rbacMiddleware, db)
 	groupController.NewGroupRoomController(roomsGroup, groupRoomSvc, roomSvc, rbacMiddleware)
+	inviteController.NewInviteController(roomsGroup, inviteSvc, rbacMiddleware)
 	stickerController.NewStickerController(stickersGroup, stickerSvc, rbacMiddleware)
 	chatController.NewChatController(chatGroup, chatSvc, roomSvc, stickerSvc, restrictionSvc, rbacMiddleware, connManager, roleSvc, db)
 	uploadController.NewUploadController(uploadsGroup, rbacMiddleware, chatSvc, userSvc)
 	evoucherController.NewEvoucherController(evouchersGroup, evoucherSvc, roomSvc, rbacMiddleware)
 	// Restriction controller (was moderation)
-	restrictionController.NewModerationController(restrictionGroup, restrictionSvc, rbacMiddleware)
+	restrictionController.NewModerationController(restrictionGroup, restrictionSvc, rbacMiddleware, roomSvc)
 	// Health controller
 	chatController.NewHealthController(chatGroup, chatSvc, rbacMiddleware)
+	// Runtime settings controller
+	settingsController.NewSettingsController(chatGroup, settingsSvc, rbacMiddleware)
+	// Transcript export (admin)
+	chatController.NewTranscriptController(chatGroup, chatSvc.GetTranscriptService(), rbacMiddleware)
+	// User data export / erasure (admin)
+	privacyController.NewPrivacyController(chatGroup, privacySvc, rbacMiddleware)
+	// Admin audit log query / CSV export
+	auditController.NewAuditController(chatGroup, auditService.NewAuditService(db), rbacMiddleware)
 
 	// Create WebSocket controller using wsChatGroup
 	chatController.NewChatController(wsChatGroup, chatSvc, roomSvc, stickerSvc, restrictionSvc, rbacMiddleware, connManager, roleSvc, db)
@@ -216,27 +296,72 @@ func main() {
 	<-c
 	log.Printf("🛑 Shutdown signal received, starting graceful shutdown...")
 
+	// 1. Drain mode: health ตอบ 503 และไม่รับ WebSocket ใหม่
+	lifecycle.BeginDrain()
+	log.Printf("🚰 Marked instance as not ready, waiting %s for load balancer...", cfg.Shutdown.ReadinessDelay)
+	time.Sleep(cfg.Shutdown.ReadinessDelay)
+
+	// แจ้ง server_restarting แล้วทยอยปิด socket ภายใน drain window
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Shutdown.DrainWindow+5*time.Second)
+	chatHub.Drain(drainCtx, cfg.Shutdown.DrainWindow)
+	drainCancel()
+
 	// 2. Stop accepting new connections
 	log.Printf("📡 Stopping HTTP server...")
-	if err := app.Shutdown(); err != nil {
+	if err := app.ShutdownWithTimeout(5 * time.Second); err != nil {
 		log.Printf("❌ Error shutting down HTTP server: %v", err)
 	}
 
-	// 3. Shutdown chat service worker pools
-	log.Printf("👷 Shutting down worker pools...")
+	// 3. Flush worker queues (ภายใน SHUTDOWN_QUEUE_FLUSH_TIMEOUT) แล้วหยุด worker pools
+	log.Printf("👷 Flushing and shutting down worker pools...")
 	chatSvc.Shutdown()
 
+	settingsSvc.Shutdown()
+
 	// 4. Stop Kafka bus
 	log.Printf("📤 Stopping Kafka bus...")
 	kafkaBus.Stop()
 
+	// 5. Flush span ที่ยังค้างใน batcher
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Printf("❌ Error flushing traces: %v", err)
+	}
+	tracingCancel()
+
 	log.Printf("✅ Graceful shutdown completed")
 }
 
+// applyRuntimeSettings ส่งค่าจาก runtime settings ไปยัง component ที่อ่าน limit แบบ in-process
+func applyRuntimeSettings(settings *settingsModel.RuntimeSettings) {
+	conns := settings.Connections
+	connManager.UpdateLimits(
+		conns.MaxConnections,
+		conns.MaxConnectionsPerIP,
+		rate.Limit(conns.ConnectRatePerSecond),
+		conns.ConnectBurst,
+		conns.MaxRoomsPerSession,
+	)
+
+	uploads := make(map[string]pkgUtils.UploadLimits, len(settings.Uploads))
+	for module, upload := range settings.Uploads {
+		uploads[module] = pkgUtils.UploadLimits{MaxSize: upload.MaxSizeBytes, AllowedTypes: upload.AllowedTypes}
+	}
+	pkgUtils.SetUploadLimits(uploads)
+
+	log.Printf("⚙️ Runtime settings v%d applied", settings.Version)
+}
+
 func setupMiddleware(app *fiber.App) {
 	// Recovery middleware
 	app.Use(recover.New())
 
+	// Access log แบบ structured พร้อม requestId (ต้องอยู่ก่อน tracing เพื่อให้ span เห็น requestId ใน context)
+	app.Use(logging.Middleware())
+
+	// Tracing: server span ต่อ request เก็บไว้ใน c.UserContext()
+	app.Use(tracing.Middleware())
+
 	// CORS middleware with cookie support
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000,http://localhost:8080,http://localhost:3001", // ระบุ domain ที่อนุญาต
@@ -246,14 +371,20 @@ func setupMiddleware(app *fiber.App) {
 		ExposeHeaders:    "Set-Cookie",
 	}))
 
-	// Logger middleware
-	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${latency} ${method} ${path}\n",
-	}))
 
 	// WebSocket middleware - apply to all WebSocket routes
 	app.Use("/chat/ws/*", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
+			// ระหว่าง drain ให้ client ไปต่อ instance อื่น
+			if lifecycle.IsDraining() {
+				return fiber.NewError(fiber.StatusServiceUnavailable, "server is restarting")
+			}
+
+			// **NEW: ตอน overload ปฏิเสธ connection ใหม่พร้อม Retry-After แทนการรับแล้วทำงานไม่ไหว**
+			if ok, _ := admission.AllowConnection(); !ok {
+				return admission.RespondBusy(c, "server is busy, please reconnect later")
+			}
+
 			// Check connection limits
 			if err := connManager.HandleNewConnection(c.IP()); err != nil {
 				return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
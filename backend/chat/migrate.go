@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"chat/pkg/config"
+	"chat/pkg/database/migrations"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// runStartupMigrations รัน migration ที่ค้างอยู่ก่อนเปิดรับ traffic แล้วตรวจว่า index ยังครบ
+func runStartupMigrations(db *mongo.Database, cfg *config.Config) {
+	ctx := context.Background()
+	runner := migrations.NewRunner(db, cfg.Migration.LockTTL, cfg.Migration.LockWait)
+
+	applied, err := runner.Up(ctx)
+	if err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+	if applied > 0 {
+		log.Printf("[Migrate] Applied %d migration(s)", applied)
+	}
+
+	missing, err := runner.Verify(ctx)
+	if err != nil {
+		log.Printf("[Migrate] ⚠️ Failed to verify indexes: %v", err)
+		return
+	}
+	for _, index := range missing {
+		log.Printf("[Migrate] ⚠️ Index %s is missing (dropped manually?) - run 'migrate down 1' and 'migrate up' for its migration to recreate it", index)
+	}
+}
+
+// runMigrateCommand คือ `chat migrate status|up|down [steps]` คืน exit code
+func runMigrateCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: migrate status|up|down [steps]")
+		return 2
+	}
+
+	db, err := connectMongoDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+	runner := migrations.NewRunner(db, cfg.Migration.LockTTL, cfg.Migration.LockWait)
+
+	switch args[0] {
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tREVERSIBLE\tMISSING INDEXES")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			missing := "-"
+			if len(status.MissingIdx) > 0 {
+				missing = strings.Join(status.MissingIdx, ", ")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n", status.Version, status.Name, appliedAt, status.Reversible, missing)
+		}
+		w.Flush()
+		return 0
+
+	case "up":
+		applied, err := runner.Up(ctx)
+		fmt.Printf("Applied %d migration(s)\n", applied)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return 2
+			}
+		}
+		reverted, err := runner.Down(ctx, steps)
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q (expected status, up or down)\n", args[0])
+		return 2
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"chat/pkg/core/audit"
+	"chat/pkg/database/queries"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxExportRows จำนวนแถวสูงสุดของ CSV หนึ่งไฟล์ (แคบช่วงเวลาลงถ้าเกิน)
+const MaxExportRows = 100000
+
+var csvHeader = []string{
+	"timestamp", "actor_id", "actor_role", "action", "target_type", "target_id", "outcome",
+	"changes", "details", "error", "request_id", "method", "path", "status", "ip", "user_agent",
+}
+
+type (
+	// AuditService อ่าน audit log อย่างเดียว (การเขียนทำผ่าน audit.Record เท่านั้น)
+	AuditService struct {
+		entries    *queries.BaseService[audit.Entry]
+		collection *mongo.Collection
+	}
+
+	// AuditFilter เงื่อนไขค้นหา (ค่าว่างคือไม่กรอง) Action ลงท้าย ".*" คือกรองตาม prefix เช่น "room.*"
+	AuditFilter struct {
+		ActorID    string
+		Action     string
+		TargetType string
+		TargetID   string
+		Outcome    string
+		From       *time.Time
+		To         *time.Time
+	}
+)
+
+func NewAuditService(db *mongo.Database) *AuditService {
+	collection := db.Collection(audit.CollectionName)
+	return &AuditService{
+		entries:    queries.NewBaseService[audit.Entry](collection),
+		collection: collection,
+	}
+}
+
+// Query คืน entry ใหม่สุดก่อน แบ่งหน้า
+func (s *AuditService) Query(ctx context.Context, filter AuditFilter, page, limit int) (*queries.Response[audit.Entry], error) {
+	return s.entries.FindAll(ctx, queries.QueryOptions{
+		Page:   page,
+		Limit:  limit,
+		Sort:   "-timestamp",
+		Filter: filter.toBSON(),
+	})
+}
+
+// ExportCSV เขียน entry ที่ตรงเงื่อนไขเป็น CSV แบบ stream (ใหม่สุดก่อน ไม่เกิน MaxExportRows)
+func (s *AuditService) ExportCSV(ctx context.Context, w io.Writer, filter AuditFilter) (int, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetLimit(MaxExportRows)
+	cursor, err := s.collection.Find(ctx, filter.toBSON(), opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for cursor.Next(ctx) {
+		var entry audit.Entry
+		if err := cursor.Decode(&entry); err != nil {
+			return count, err
+		}
+		if err := cw.Write(csvRow(&entry)); err != nil {
+			return count, err
+		}
+		count++
+		if count%1000 == 0 {
+			cw.Flush()
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return count, err
+	}
+	return count, cursor.Err()
+}
+
+func (f AuditFilter) toBSON() map[string]interface{} {
+	filter := make(map[string]interface{})
+	if f.ActorID != "" {
+		filter["actor_id"] = f.ActorID
+	}
+	if prefix, ok := strings.CutSuffix(f.Action, ".*"); ok {
+		filter["action"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix+".")}
+	} else if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if f.TargetType != "" {
+		filter["target_type"] = f.TargetType
+	}
+	if f.TargetID != "" {
+		filter["target_id"] = f.TargetID
+	}
+	if f.Outcome != "" {
+		filter["outcome"] = f.Outcome
+	}
+	if f.From != nil || f.To != nil {
+		timestamp := bson.M{}
+		if f.From != nil {
+			timestamp["$gte"] = *f.From
+		}
+		if f.To != nil {
+			timestamp["$lt"] = *f.To
+		}
+		filter["timestamp"] = timestamp
+	}
+	return filter
+}
+
+func csvRow(e *audit.Entry) []string {
+	changes := make([]string, 0, len(e.Changes))
+	for _, change := range e.Changes {
+		changes = append(changes, fmt.Sprintf("%s: %v -> %v", change.Field, change.From, change.To))
+	}
+	details := ""
+	if len(e.Details) > 0 {
+		details = fmt.Sprintf("%v", e.Details)
+	}
+
+	row := []string{
+		e.Timestamp.UTC().Format(time.RFC3339),
+		e.ActorID,
+		e.ActorRole,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		e.Outcome,
+		strings.Join(changes, "; "),
+		details,
+		e.Error,
+		"", "", "", "", "", "",
+	}
+	if r := e.Request; r != nil {
+		status := ""
+		if r.Status != 0 {
+			status = strconv.Itoa(r.Status)
+		}
+		copy(row[10:], []string{r.RequestID, r.Method, r.Path, status, r.IP, r.UserAgent})
+	}
+	return row
+}
@@ -7,15 +7,20 @@ import (
 	chatService "chat/module/chat/service"
 	"chat/module/chat/utils"
 	restrictionService "chat/module/restriction/service"
+	settingsService "chat/module/settings/service"
 	roomModel "chat/module/room/room/model"
 	stickerModel "chat/module/sticker/model"
 	userModel "chat/module/user/model"
+	"chat/pkg/core/admission"
 	"chat/pkg/core/connection"
 	"chat/pkg/decorators"
 	"chat/pkg/middleware"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,15 +37,19 @@ type (
 	ChatService interface {
 		GetHub() *utils.Hub
 		GetChatHistoryByRoom(ctx context.Context, roomID string, limit int64) ([]model.ChatMessageEnriched, error)
+		GetRoomMessagesBySeq(ctx context.Context, roomID string, afterSeq, beforeSeq int64, limit int) ([]model.ChatMessageEnriched, error)
+		GetRoomSeq(ctx context.Context, roomID primitive.ObjectID) (int64, error)
 		SendMessage(ctx context.Context, msg *model.ChatMessage, metadata interface{}) error
 		UnsendMessage(ctx context.Context, messageID, userID primitive.ObjectID) error
 		SubscribeToRoom(ctx context.Context, roomID string) error
 		UnsubscribeFromRoom(ctx context.Context, roomID string) error
 		DeleteRoomMessages(ctx context.Context, roomID string) error
+		ClearRoomCache(ctx context.Context, roomID string) error
 		GetUserById(ctx context.Context, userID string) (*userModel.User, error)
 		GetRedis() *redis.Client
 		GetMongo() *mongo.Database
 		GetRestrictionService() *restrictionService.RestrictionService
+		CheckSlowMode(ctx context.Context, roomID, userID primitive.ObjectID, source string) (time.Duration, bool)
 	}
 
 	RoomService interface {
@@ -57,6 +66,7 @@ type (
 		CanUserSendReaction(ctx context.Context, roomID primitive.ObjectID, userID string) (bool, error)
 		DisconnectAllUsersFromRoom(ctx context.Context, roomID primitive.ObjectID) error
 		SetStatusChangeCallback(func(ctx context.Context, roomID string, newStatus string))
+		GetMemberRole(ctx context.Context, roomID, userID primitive.ObjectID) (string, error)
 	}
 
 	StickerService interface {
@@ -113,6 +123,9 @@ func (c *ChatController) setupRoutes() {
 	// Add the SetUserRoleInContext middleware to all routes
 	c.App.Use(c.rbac.SetUserRoleInContext())
 	
+	// **NEW: Multiplexed session - socket เดียว subscribe ได้หลายห้อง (ต้องลงทะเบียนก่อน /ws/:roomId)**
+	c.Get("/ws/session", c.rbac.RequireReadOnlyAccess(), websocket.New(c.WsHandler.HandleSessionWebSocket))
+
 	// Add RBAC middleware to WebSocket route
 	c.Get("/ws/:roomId", c.rbac.RequireReadOnlyAccess(), websocket.New(c.WsHandler.HandleWebSocket))
 
@@ -120,8 +133,15 @@ func (c *ChatController) setupRoutes() {
 	c.Get("/ws/mc/:roomId", c.rbac.RequireRoleParam(), websocket.New(c.WsHandler.HandleWebSocket))
 
 	c.Post("/rooms/:roomId/stickers", c.handleSendSticker, c.rbac.RequireReadOnlyAccess())
+	// **NEW: history แบบใช้ seq เป็น cursor (?after=<seq> สำหรับ resync, ?before=<seq> สำหรับข้อความเก่า)**
+	c.Get("/rooms/:roomId/messages", c.handleGetMessagesBySeq, c.rbac.RequireReadOnlyAccess())
+	// **NEW: สถานะการส่งข้อความของตัวเอง (accepted / delivered / persisted / failed)**
+	c.Get("/messages/:messageId/status", c.handleGetDeliveryStatus, c.rbac.RequireReadOnlyAccess())
 	// **NEW: Cache management endpoints**
 	c.Delete("/rooms/:roomId/cache", c.handleClearCache, c.rbac.RequireAdministrator())
+	// **NEW: owner/moderator ของห้องลบข้อความของสมาชิกได้ (Administrator ได้ทุกห้อง)**
+	c.Delete("/rooms/:roomId/messages/:messageId", c.handleModeratorDeleteMessage,
+		c.rbac.RequireRoomRole(c.roomService, middleware.RoomIDFromParam("roomId"), roomModel.ModeratorRoomRoles...))
 	
 	c.SetupRoutes()
 }
@@ -136,7 +156,134 @@ func (c *ChatController) SetupWebSocketHandler() {
 	}
 }
 
+func (c *ChatController) handleGetMessagesBySeq(ctx *fiber.Ctx) error {
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid authentication token",
+		})
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID format",
+		})
+	}
+	roomObjID, err := primitive.ObjectIDFromHex(ctx.Params("roomId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	afterSeq := int64(ctx.QueryInt("after", 0))
+	beforeSeq := int64(ctx.QueryInt("before", 0))
+	limit := ctx.QueryInt("limit", 50)
+	if afterSeq < 0 || beforeSeq < 0 || limit <= 0 || limit > ResyncMaxMessages {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("after/before must be >= 0 and limit must be 1-%d", ResyncMaxMessages),
+		})
+	}
+
+	inRoom, err := c.roomService.IsUserInRoom(ctx.UserContext(), roomObjID, userID)
+	if err != nil || !inRoom {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "You are not a member of this room",
+		})
+	}
+
+	messages, err := c.chatService.GetRoomMessagesBySeq(ctx.UserContext(), roomObjID.Hex(), afterSeq, beforeSeq, limit)
+	if err != nil {
+		log.Printf("[Controller] Failed to get messages by seq for room %s: %v", roomObjID.Hex(), err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get messages",
+		})
+	}
+
+	// MC room ใช้กฎการมองเห็นเดียวกับ history ทาง WebSocket
+	mcHelper := utils.NewMCRoomHelper(c.chatService.GetMongo())
+	if mcHelper.IsMCRoom(ctx.UserContext(), roomObjID) {
+		visible := make([]model.ChatMessageEnriched, 0, len(messages))
+		for _, msg := range messages {
+			if show, err := mcHelper.ShouldShowMessage(ctx.UserContext(), msg.ChatMessage.UserID, userObjID, roomObjID); err == nil && show {
+				visible = append(visible, msg)
+			}
+		}
+		messages = visible
+	}
+
+	latestSeq, err := c.chatService.GetRoomSeq(ctx.UserContext(), roomObjID)
+	if err != nil {
+		log.Printf("[Controller] Failed to get latest seq for room %s: %v", roomObjID.Hex(), err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"messages":  messages,
+			"latestSeq": latestSeq,
+		},
+	})
+}
+
+func (c *ChatController) handleGetDeliveryStatus(ctx *fiber.Ctx) error {
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid authentication token",
+		})
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID format",
+		})
+	}
+	messageObjID, err := primitive.ObjectIDFromHex(ctx.Params("messageId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid message ID",
+		})
+	}
+
+	status, err := c.chatService.GetDeliveryStatus(ctx.UserContext(), messageObjID, userObjID)
+	if err != nil {
+		if errors.Is(err, chatService.ErrMessageStatusNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "Message status not found",
+			})
+		}
+		log.Printf("[Controller] Failed to get delivery status for message %s: %v", messageObjID.Hex(), err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get message status",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    status,
+	})
+}
+
 func (c *ChatController) handleSendSticker(ctx *fiber.Ctx) error {
+	if !settingsService.Current().Features.Stickers {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Stickers are currently disabled",
+		})
+	}
+
 	roomID := ctx.Params("roomId")
 	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
 	if err != nil {
@@ -210,6 +357,11 @@ func (c *ChatController) handleSendSticker(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// **NEW: slow mode ของห้อง**
+	if remaining, ok := c.chatService.CheckSlowMode(ctx.Context(), roomObjID, userObjID, "sticker"); !ok {
+		return respondSlowMode(ctx, remaining)
+	}
+
 	// Create message
 	msg := &model.ChatMessage{
 		RoomID:    roomObjID,
@@ -219,7 +371,10 @@ func (c *ChatController) handleSendSticker(ctx *fiber.Ctx) error {
 		Timestamp: time.Now(),
 	}
 	// Send message
-	if err := c.chatService.SendMessage(ctx.Context(), msg, nil); err != nil {
+	if err := c.chatService.SendMessage(ctx.UserContext(), msg, nil); err != nil {
+		if errors.Is(err, admission.ErrServerBusy) {
+			return admission.RespondBusy(ctx, "Server is busy, please retry shortly")
+		}
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to send sticker",
@@ -261,7 +416,7 @@ func (c *ChatController) handleClearCache(ctx *fiber.Ctx) error {
 	roomID := ctx.Params("roomId")
 	
 	// **ENHANCED: Clear cache only, not delete messages**
-	if err := c.chatService.GetRedis().Del(ctx.Context(), fmt.Sprintf("chat:room:%s:messages", roomID)).Err(); err != nil {
+	if err := c.chatService.ClearRoomCache(ctx.Context(), roomID); err != nil {
 		log.Printf("[ChatController] Failed to clear cache for room %s: %v", roomID, err)
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -282,3 +437,111 @@ func (c *ChatController) handleClearCache(ctx *fiber.Ctx) error {
 }
 
 
+// handleModeratorDeleteMessage ลบข้อความในห้องโดย moderator (soft delete + unsend event เหมือนการ unsend)
+func (c *ChatController) handleModeratorDeleteMessage(ctx *fiber.Ctx) error {
+	roomObjID, err := primitive.ObjectIDFromHex(ctx.Params("roomId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+	messageObjID, err := primitive.ObjectIDFromHex(ctx.Params("messageId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid message ID",
+		})
+	}
+	moderatorID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+	moderatorObjID, err := primitive.ObjectIDFromHex(moderatorID)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	canModerate := func(authorID primitive.ObjectID) error {
+		return c.canModerateAuthor(ctx, roomObjID, authorID)
+	}
+	if err := c.chatService.DeleteMessageAsModerator(ctx.UserContext(), roomObjID, messageObjID, moderatorObjID, canModerate); err != nil {
+		switch {
+		case errors.Is(err, chatService.ErrProtectedMessageAuthor):
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+				"error":   "INSUFFICIENT_PERMISSIONS",
+			})
+		case errors.Is(err, chatService.ErrMessageNotFound):
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		case errors.Is(err, chatService.ErrMessageAlreadyDeleted):
+			return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+		log.Printf("[ChatController] Failed to delete message %s in room %s: %v", messageObjID.Hex(), roomObjID.Hex(), err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete message",
+			"error":   err.Error(),
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Message deleted successfully",
+		"data": fiber.Map{
+			"roomId":    roomObjID.Hex(),
+			"messageId": messageObjID.Hex(),
+			"deletedBy": moderatorObjID.Hex(),
+		},
+	})
+}
+
+// canModerateAuthor ตรวจเจ้าของข้อความแบบเดียวกับการ restrict: Administrator ลบได้ทุกข้อความ
+// ส่วน owner/moderator ของห้องลบข้อความของ owner, moderator หรือ Administrator ไม่ได้
+func (c *ChatController) canModerateAuthor(ctx *fiber.Ctx, roomID, authorID primitive.ObjectID) error {
+	if middleware.IsGlobalAdministrator(ctx) {
+		return nil
+	}
+
+	authorRoomRole, err := c.roomService.GetMemberRole(ctx.UserContext(), roomID, authorID)
+	if err != nil {
+		return fmt.Errorf("failed to check author room role: %w", err)
+	}
+	// ถ้าหา global role ของเจ้าของข้อความไม่ได้ ให้ปฏิเสธไว้ก่อน (อาจเป็น Administrator)
+	authorRole, err := c.rbac.GetUserRole(authorID.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to check author role: %w", err)
+	}
+
+	if authorRoomRole == roomModel.RoomRoleOwner || authorRoomRole == roomModel.RoomRoleModerator || authorRole == middleware.RoleAdministrator {
+		return chatService.ErrProtectedMessageAuthor
+	}
+	return nil
+}
+
+// respondSlowMode ตอบ 429 พร้อมเวลาที่ต้องรอ เมื่อ user ส่งเร็วกว่า slow mode ของห้อง (ใช้ทั้ง sticker และ upload)
+func respondSlowMode(ctx *fiber.Ctx, remaining time.Duration) error {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(seconds, 1)))
+	return ctx.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"success": false,
+		"message": fmt.Sprintf("Slow mode is on, you can send again in %d seconds", max(seconds, 1)),
+		"data": fiber.Map{
+			"reason":       "slow_mode",
+			"retryAfterMs": remaining.Milliseconds(),
+		},
+	})
+}
@@ -1,9 +1,17 @@
 package controller
 
 import (
+	"chat/module/chat/model"
 	"chat/module/chat/service"
+	"chat/module/chat/utils"
+	"chat/pkg/config"
+	"chat/pkg/core/admission"
+	"chat/pkg/core/audit"
+	"chat/pkg/core/circuitbreaker"
+	"chat/pkg/core/lifecycle"
 	"chat/pkg/decorators"
 	"chat/pkg/middleware"
+	"context"
 	"log"
 	"time"
 
@@ -26,6 +34,10 @@ type (
 		GetMongo() *mongo.Database
 		GetWorkerPoolStatus() map[string]interface{}
 		TriggerPhantomMessageFix() error
+		GetOutboxMessageStatus(ctx context.Context, messageID primitive.ObjectID) (*utils.OutboxMessageStatus, error)
+		RetrieveMessage(messageID primitive.ObjectID) (*model.ChatMessage, error)
+		GetConfig() *config.Config
+		GetReadiness() *lifecycle.Readiness
 	}
 )
 
@@ -51,8 +63,10 @@ func (c *HealthController) setupRoutes() {
 	// Admin-only endpoints
 	c.Get("/health/worker-pools", c.handleWorkerPoolStatus, c.rbac.RequireAdministrator())
 	c.Get("/health/phantom-messages", c.handlePhantomMessageStatus, c.rbac.RequireAdministrator())
+	c.Get("/health/dependencies", c.handleDependencyStatus, c.rbac.RequireAdministrator())
 	c.Post("/admin/fix-phantom-messages", c.handleFixPhantomMessages, c.rbac.RequireAdministrator())
 	c.Get("/admin/message-status/:messageId", c.handleGetMessageStatus, c.rbac.RequireAdministrator())
+	c.Get("/admin/config", c.handleGetConfig, c.rbac.RequireAdministrator())
 	
 	c.SetupRoutes()
 }
@@ -91,6 +105,18 @@ func (c *HealthController) handleHealthCheck(ctx *fiber.Ctx) error {
 		}
 	}
 
+	// **NEW: Circuit breaker states (breaker ที่เปิดอยู่ = dependency กำลังถูกข้าม)**
+	health["circuitBreakers"] = circuitbreaker.Snapshots()
+	if circuitbreaker.AnyOpen() && health["status"] == "healthy" {
+		health["status"] = "degraded"
+	}
+
+	// **NEW: ระหว่าง drain ตอบ 503 เพื่อให้ load balancer หยุดส่ง traffic ใหม่มา**
+	if lifecycle.IsDraining() {
+		health["status"] = "draining"
+		health["drainStartedAt"] = lifecycle.DrainStartedAt()
+	}
+
 	// Return appropriate status code
 	statusCode := c.getHealthStatusCode(health["status"].(string))
 	return ctx.Status(statusCode).JSON(health)
@@ -106,6 +132,21 @@ func (c *HealthController) handleWorkerPoolStatus(ctx *fiber.Ctx) error {
 	})
 }
 
+// **NEW: readiness แบบละเอียด (latency ต่อ dependency + worker pools + circuit breakers)**
+func (c *HealthController) handleDependencyStatus(ctx *fiber.Ctx) error {
+	report := c.healthService.GetReadiness().Check(ctx.Context())
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"readiness":       report,
+			"workerPools":     c.healthService.GetWorkerPoolStatus(),
+			"circuitBreakers": circuitbreaker.Snapshots(),
+			"admission":       admission.CurrentSnapshot(),
+		},
+	})
+}
+
 func (c *HealthController) handlePhantomMessageStatus(ctx *fiber.Ctx) error {
 	// Get query parameters
 	timeRange := ctx.Query("timeRange", "1h")
@@ -162,7 +203,29 @@ func (c *HealthController) handlePhantomMessageStatus(ctx *fiber.Ctx) error {
 	})
 }
 
+// **NEW: ค่า config ที่มีผลจริง (secret ถูกซ่อน)**
+func (c *HealthController) handleGetConfig(ctx *fiber.Ctx) error {
+	cfg := c.healthService.GetConfig()
+	if cfg == nil {
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"message": "Configuration not loaded",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    cfg.Redacted(),
+	})
+}
+
 func (c *HealthController) handleFixPhantomMessages(ctx *fiber.Ctx) error {
+	// fix ทำงานเบื้องหลัง audit จึงบันทึกแค่ว่าใครสั่งเมื่อไร
+	audit.Record(ctx.Context(), audit.Entry{
+		Action:     audit.ActionPhantomFix,
+		TargetType: audit.TargetRoute,
+		TargetID:   ctx.Path(),
+	})
 	go func() {
 		log.Printf("[Admin] Manual phantom message fix triggered")
 		if err := c.healthService.TriggerPhantomMessageFix(); err != nil {
@@ -186,6 +249,17 @@ func (c *HealthController) handleGetMessageStatus(ctx *fiber.Ctx) error {
 		return c.buildErrorResponse(ctx, fiber.StatusBadRequest, err.Error(), nil)
 	}
 	
+	// **NEW: ข้อความที่ยังอยู่ใน outbox ใช้สถานะจาก pending list ของ stream**
+	outboxStatus, err := c.healthService.GetOutboxMessageStatus(ctx.Context(), msgObjID)
+	if err != nil {
+		log.Printf("[Health] Failed to read outbox status for message %s: %v", messageID, err)
+	} else if outboxStatus != nil && outboxStatus.Status != utils.OutboxStatusPersisted {
+		return ctx.JSON(fiber.Map{
+			"success": true,
+			"data":    outboxStatus,
+		})
+	}
+
 	// Query message status
 	statusCollection := c.healthService.GetMongo().Collection("message-status")
 	filter := bson.M{"message_id": msgObjID}
@@ -194,6 +268,15 @@ func (c *HealthController) handleGetMessageStatus(ctx *fiber.Ctx) error {
 	err = statusCollection.FindOne(ctx.Context(), filter).Decode(&messageStatus)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
+			// ข้อความที่ผ่าน outbox ไม่มี record ใน message-status
+			if outboxStatus != nil {
+				if _, err := c.healthService.RetrieveMessage(msgObjID); err == nil {
+					return ctx.JSON(fiber.Map{
+						"success": true,
+						"data":    outboxStatus,
+					})
+				}
+			}
 			return c.buildErrorResponse(ctx, fiber.StatusNotFound, "Message status not found", nil)
 		}
 		return c.buildErrorResponse(ctx, fiber.StatusInternalServerError, "Failed to query message status", err)
@@ -208,7 +291,7 @@ func (c *HealthController) handleGetMessageStatus(ctx *fiber.Ctx) error {
 // Helper methods
 func (c *HealthController) getHealthStatusCode(status string) int {
 	switch status {
-	case "unhealthy":
+	case "unhealthy", "draining":
 		return fiber.StatusServiceUnavailable
 	case "degraded":
 		return fiber.StatusPartialContent
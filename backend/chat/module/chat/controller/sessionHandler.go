@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"chat/module/chat/model"
+	"chat/module/chat/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session ops ที่ client ส่งมาผ่าน /ws/session
+const (
+	SessionOpSubscribe   = "subscribe"
+	SessionOpUnsubscribe = "unsubscribe"
+	SessionOpSend        = "send"
+	SessionOpResync      = ControlFrameResync
+)
+
+// sessionFrame คือ frame ที่ client ส่งมาใน multiplexed session
+//
+//	{"op":"subscribe","roomId":"..."}
+//	{"op":"unsubscribe","roomId":"..."}
+//	{"op":"send","roomId":"...","message":"hello"} (รองรับ /reply และ /unsend เหมือน socket ต่อห้อง)
+//	{"op":"resync","roomId":"...","afterSeq":42}
+type sessionFrame struct {
+	Op       string `json:"op"`
+	RoomID   string `json:"roomId"`
+	Message  string `json:"message,omitempty"`
+	AfterSeq *int64 `json:"afterSeq,omitempty"`
+}
+
+// HandleSessionWebSocket รับ socket เดียวต่อ user แล้วให้ subscribe/unsubscribe ได้หลายห้อง
+// สิทธิ์ (membership, ban, MC) ถูกตรวจแยกในแต่ละ subscription และทุก event จะถูก tag ด้วย roomId
+func (h *WebSocketHandler) HandleSessionWebSocket(conn *websocket.Conn) {
+	// Setup ping/pong handlers
+	h.connManager.SetupPingPong(conn)
+
+	// session นับเป็น 1 connection ไม่ว่าจะ subscribe กี่ห้อง
+	clientIP := conn.RemoteAddr().String()
+	defer h.connManager.RemoveConnection(clientIP)
+
+	ctx := context.Background()
+
+	// Extract userID from JWT token
+	userID, err := h.rbacMiddleware.ExtractUserIDFromContext(conn)
+	if err != nil {
+		log.Printf("[WebSocket] Failed to extract userID from token: %v", err)
+		conn.WriteMessage(websocket.TextMessage, []byte("Invalid authentication token"))
+		conn.Close()
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		conn.WriteMessage(websocket.TextMessage, []byte("Invalid user ID"))
+		conn.Close()
+		return
+	}
+
+	ctx = context.WithValue(ctx, "userRole", h.resolveUserRole(ctx, userID))
+
+	hub := h.chatService.GetHub()
+	session := hub.RegisterSession(conn, userObjID)
+	log.Printf("[WebSocket] 🔌 User %s opened multiplexed session", userID)
+
+	defer func() {
+		for _, roomID := range session.Rooms() {
+			h.unsubscribeSessionRoom(ctx, session, roomID)
+		}
+		hub.UnregisterSession(conn)
+		log.Printf("[WebSocket] 🔌 User %s closed multiplexed session", userID)
+	}()
+
+	h.writeSessionEvent(session, "session_ready", "", nil)
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			log.Printf("[WebSocket] ❌ User %s session error: %v", userID, err)
+			break
+		}
+
+		var frame sessionFrame
+		if err := json.Unmarshal(msg, &frame); err != nil {
+			h.writeSessionEvent(session, "error", "", map[string]interface{}{
+				"message": "Invalid frame format",
+			})
+			continue
+		}
+
+		switch frame.Op {
+		case SessionOpSubscribe:
+			if err := h.subscribeSessionRoom(ctx, session, frame.RoomID); err != nil {
+				log.Printf("[WebSocket] ❌ User %s failed to subscribe room %s: %v", userID, frame.RoomID, err)
+				h.writeSessionEvent(session, "subscribe_error", frame.RoomID, map[string]interface{}{
+					"message": err.Error(),
+				})
+			}
+
+		case SessionOpUnsubscribe:
+			if h.unsubscribeSessionRoom(ctx, session, frame.RoomID) {
+				h.writeSessionEvent(session, "unsubscribed", frame.RoomID, nil)
+			}
+
+		case SessionOpSend:
+			if !session.HasRoom(frame.RoomID) {
+				h.writeSessionEvent(session, "error", frame.RoomID, map[string]interface{}{
+					"message": "Not subscribed to this room",
+				})
+				continue
+			}
+			roomObjID, _ := primitive.ObjectIDFromHex(frame.RoomID)
+			frameCtx, span := startFrameSpan(ctx, frame.RoomID, userID, frame.Op)
+			h.handleRoomMessage(frameCtx, model.ClientObject{
+				RoomID: roomObjID,
+				UserID: userObjID,
+				Conn:   conn,
+			}, strings.TrimSpace(frame.Message))
+			span.End()
+
+		case SessionOpResync:
+			if !session.HasRoom(frame.RoomID) {
+				h.writeSessionEvent(session, "error", frame.RoomID, map[string]interface{}{
+					"message": "Not subscribed to this room",
+				})
+				continue
+			}
+			if frame.AfterSeq == nil {
+				h.writeSessionEvent(session, "error", frame.RoomID, map[string]interface{}{
+					"message": "afterSeq is required",
+				})
+				continue
+			}
+			roomObjID, _ := primitive.ObjectIDFromHex(frame.RoomID)
+			frameCtx, span := startFrameSpan(ctx, frame.RoomID, userID, frame.Op)
+			h.handleResync(frameCtx, model.ClientObject{
+				RoomID: roomObjID,
+				UserID: userObjID,
+				Conn:   conn,
+			}, *frame.AfterSeq)
+			span.End()
+
+		default:
+			h.writeSessionEvent(session, "error", frame.RoomID, map[string]interface{}{
+				"message": "Unknown op: " + frame.Op,
+			})
+		}
+	}
+}
+
+// subscribeSessionRoom ตรวจสิทธิ์และเพิ่มห้องเข้า session (ใช้ขั้นตอนเดียวกับ socket ต่อห้อง)
+func (h *WebSocketHandler) subscribeSessionRoom(ctx context.Context, session *utils.Session, roomID string) error {
+	if session.HasRoom(roomID) {
+		return errors.New("already subscribed to this room")
+	}
+
+	// ห้องที่เคยถูกถอด (kick/room ปิด) ต้อง cleanup ก่อน subscribe ใหม่
+	if session.IsDetached(roomID) {
+		h.unsubscribeSessionRoom(ctx, session, roomID)
+	}
+
+	if err := h.connManager.CanSubscribe(session.RoomCount()); err != nil {
+		return err
+	}
+
+	roomObjID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return errors.New("invalid room ID")
+	}
+
+	userObjID := session.UserID
+	userID := userObjID.Hex()
+
+	// Validate and track connection using RoomService
+	if err := h.roomService.ValidateAndTrackConnection(ctx, roomObjID, userID); err != nil {
+		return err
+	}
+
+	// ตรวจสอบ BAN status ของห้องนี้
+	if h.restrictionService.IsUserBanned(ctx, userObjID, roomObjID) {
+		h.roomService.RemoveConnection(ctx, roomObjID, userID)
+		return errors.New("you are banned from this room")
+	}
+
+	room, err := h.roomService.GetRoomById(ctx, roomObjID)
+	if err != nil {
+		h.roomService.RemoveConnection(ctx, roomObjID, userID)
+		return errors.New("failed to get room information")
+	}
+
+	isMember := false
+	for _, memberID := range room.Members {
+		if memberID == userObjID {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		h.roomService.RemoveConnection(ctx, roomObjID, userID)
+		return errors.New("you are not a member of this room")
+	}
+
+	// Sub ไปยัง Kafka Topic Room
+	if err := h.chatService.SubscribeToRoom(ctx, roomID); err != nil {
+		log.Printf("[WARN] Failed to subscribe to room topic (continuing without Kafka): %v", err)
+	}
+
+	session.AddRoom(roomID)
+	h.connManager.AddSubscription()
+
+	h.writeSessionEvent(session, "subscribed", roomID, map[string]interface{}{
+		"roomType": room.Type,
+	})
+	h.sendRoomStatus(ctx, session.Conn, roomObjID)
+
+	// history ถูกกรองตาม MC rules ของห้องนี้ใน sendChatHistory
+	h.sendChatHistory(ctx, session.Conn, roomID, userID)
+
+	h.chatService.GetHub().Register(utils.Client{
+		Conn:   session.Conn,
+		RoomID: roomObjID,
+		UserID: userObjID,
+	})
+
+	log.Printf("[WebSocket] ✅ User %s subscribed to room %s via session (%d rooms)", userID, roomID, session.RoomCount())
+	return nil
+}
+
+// unsubscribeSessionRoom ถอดห้องออกจาก session และ cleanup เหมือนตอน socket ต่อห้องปิด
+// คืนค่า false ถ้า session ไม่ได้ subscribe ห้องนี้
+func (h *WebSocketHandler) unsubscribeSessionRoom(ctx context.Context, session *utils.Session, roomID string) bool {
+	roomObjID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return false
+	}
+
+	// ห้องอาจถูกถอดไปแล้วโดย hub (kick/room ปิด) แต่ยังต้อง cleanup connection tracking
+	if !session.RemoveRoom(roomID) {
+		return false
+	}
+
+	h.chatService.GetHub().Unregister(utils.Client{
+		Conn:   session.Conn,
+		RoomID: roomObjID,
+		UserID: session.UserID,
+	})
+	h.roomService.RemoveConnection(ctx, roomObjID, session.UserID.Hex())
+	h.connManager.RemoveSubscription()
+
+	// Unsubscribe from room's Kafka topic if no more clients
+	if count, err := h.roomService.GetActiveConnectionsCount(ctx, roomObjID); err == nil && count == 0 {
+		if err := h.chatService.UnsubscribeFromRoom(ctx, roomID); err != nil {
+			log.Printf("[WARN] Failed to unsubscribe from room topic: %v", err)
+		}
+	}
+
+	log.Printf("[WebSocket] User %s unsubscribed from room %s via session", session.UserID.Hex(), roomID)
+	return true
+}
+
+// writeSessionEvent ส่ง control event ของ session (subscribed, error, ...) ไปยัง client
+func (h *WebSocketHandler) writeSessionEvent(session *utils.Session, eventType string, roomID string, data map[string]interface{}) {
+	event := map[string]interface{}{
+		"type":      eventType,
+		"roomId":    roomID,
+		"timestamp": time.Now(),
+	}
+	if data != nil {
+		event["data"] = data
+	}
+
+	if eventBytes, err := json.Marshal(event); err == nil {
+		session.WriteRaw(websocket.TextMessage, eventBytes)
+	}
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"bufio"
+	"chat/module/chat/service"
+	"chat/module/chat/utils"
+	"chat/pkg/decorators"
+	"chat/pkg/middleware"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// transcriptStreamTimeout เวลาสูงสุดของการ export แบบ stream (ห้องใหญ่ควรใช้ async)
+const transcriptStreamTimeout = 5 * time.Minute
+
+type (
+	TranscriptController struct {
+		*decorators.BaseController
+		transcriptService *service.TranscriptService
+		rbac              middleware.IRBACMiddleware
+	}
+)
+
+func NewTranscriptController(
+	app fiber.Router,
+	transcriptService *service.TranscriptService,
+	rbac middleware.IRBACMiddleware,
+) *TranscriptController {
+	controller := &TranscriptController{
+		BaseController:    decorators.NewBaseController(app, ""),
+		transcriptService: transcriptService,
+		rbac:              rbac,
+	}
+
+	controller.setupRoutes()
+	return controller
+}
+
+func (c *TranscriptController) setupRoutes() {
+	staff := c.rbac.RequireRoles(middleware.RoleAdministrator, middleware.RoleStaff)
+
+	// ?format=json|csv|html&from=<RFC3339>&to=<RFC3339>&includeUnsent=true&async=true
+	c.Get("/admin/rooms/:roomId/transcript", c.handleExportTranscript, staff)
+	c.Get("/admin/transcripts/:jobId", c.handleGetTranscriptJob, staff)
+	c.Get("/admin/transcripts/:jobId/download", c.handleDownloadTranscript, staff)
+
+	c.SetupRoutes()
+}
+
+// handleExportTranscript stream transcript กลับทันที หรือสร้าง job (202) ถ้าห้องใหญ่/ขอ async
+func (c *TranscriptController) handleExportTranscript(ctx *fiber.Ctx) error {
+	roomID, err := primitive.ObjectIDFromHex(ctx.Params("roomId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid authentication token",
+		})
+	}
+
+	opts := service.TranscriptOptions{
+		Format:      ctx.Query("format", utils.TranscriptFormatJSON),
+		RequestedBy: userID,
+	}
+	if !utils.IsTranscriptFormat(opts.Format) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "format must be json, csv or html",
+		})
+	}
+	if opts.From, err = parseTranscriptTime(ctx.Query("from")); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "from must be an RFC3339 timestamp",
+		})
+	}
+	if opts.To, err = parseTranscriptTime(ctx.Query("to")); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "to must be an RFC3339 timestamp",
+		})
+	}
+	if opts.From != nil && opts.To != nil && !opts.From.Before(*opts.To) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "from must be before to",
+		})
+	}
+
+	// ข้อความที่ unsend แล้วเห็นได้เฉพาะ moderator (Administrator)
+	if ctx.QueryBool("includeUnsent", false) {
+		role, err := c.rbac.GetUserRole(userID)
+		if err != nil || role != middleware.RoleAdministrator {
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Only moderators can export unsent messages",
+			})
+		}
+		opts.IncludeUnsent = true
+	}
+
+	async := ctx.QueryBool("async", false)
+	if !async {
+		async, err = c.transcriptService.ShouldExportAsync(ctx.UserContext(), roomID, opts)
+		if err != nil {
+			return c.respondExportError(ctx, roomID, err)
+		}
+	}
+
+	if async {
+		job, err := c.transcriptService.StartExportJob(ctx.UserContext(), roomID, opts)
+		if err != nil {
+			return c.respondExportError(ctx, roomID, err)
+		}
+		return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"success": true,
+			"message": "Transcript export started",
+			"data":    job,
+		})
+	}
+
+	ctx.Set(fiber.HeaderContentType, utils.TranscriptContentType(opts.Format))
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s_transcript.%s"`, roomID.Hex(), opts.Format))
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		exportCtx, cancel := context.WithTimeout(context.Background(), transcriptStreamTimeout)
+		defer cancel()
+
+		// header ส่งไปแล้ว error ระหว่างทางทำได้แค่ log (ไฟล์จะไม่ครบ)
+		count, err := c.transcriptService.Export(exportCtx, utils.NewTranscriptWriter(opts.Format, w), roomID, opts)
+		if err != nil {
+			log.Printf("[Transcript] ❌ Streaming export of room %s failed after %d messages: %v", roomID.Hex(), count, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+func (c *TranscriptController) handleGetTranscriptJob(ctx *fiber.Ctx) error {
+	job, handled, err := c.getJob(ctx)
+	if handled {
+		return err
+	}
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+	})
+}
+
+func (c *TranscriptController) handleDownloadTranscript(ctx *fiber.Ctx) error {
+	job, handled, err := c.getJob(ctx)
+	if handled {
+		return err
+	}
+	if job.Status != service.TranscriptJobDone {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Transcript export is %s", job.Status),
+			"data":    job,
+		})
+	}
+
+	ctx.Set(fiber.HeaderContentType, utils.TranscriptContentType(job.Format))
+	return ctx.Download(c.transcriptService.JobFilePath(job), job.FileName)
+}
+
+// getJob อ่าน job จาก :jobId (handled = true คือส่ง response error ไปแล้ว)
+func (c *TranscriptController) getJob(ctx *fiber.Ctx) (*service.TranscriptJob, bool, error) {
+	jobID, err := primitive.ObjectIDFromHex(ctx.Params("jobId"))
+	if err != nil {
+		return nil, true, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid job ID",
+		})
+	}
+	job, err := c.transcriptService.GetJob(ctx.UserContext(), jobID)
+	if errors.Is(err, service.ErrTranscriptJobNotFound) {
+		return nil, true, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		return nil, true, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get transcript export",
+		})
+	}
+
+	// job (และไฟล์ที่อาจมีข้อความ unsend) เห็นได้เฉพาะผู้สร้างและ Administrator
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return nil, true, ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid authentication token",
+		})
+	}
+	if job.RequestedBy != userID {
+		role, err := c.rbac.GetUserRole(userID)
+		if err != nil || role != middleware.RoleAdministrator {
+			return nil, true, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Only the requester or an administrator can access this transcript export",
+			})
+		}
+	}
+	return job, false, nil
+}
+
+func (c *TranscriptController) respondExportError(ctx *fiber.Ctx, roomID primitive.ObjectID, err error) error {
+	if errors.Is(err, service.ErrTranscriptRoomNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	log.Printf("[Transcript] Failed to export room %s: %v", roomID.Hex(), err)
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": "Failed to export transcript",
+	})
+}
+
+func parseTranscriptTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
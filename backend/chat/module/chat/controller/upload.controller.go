@@ -5,10 +5,12 @@ import (
 	chatutil "chat/module/chat/utils"
 	restrictionService "chat/module/restriction/service"
 	userModel "chat/module/user/model"
+	"chat/pkg/core/admission"
 	"chat/pkg/decorators"
 	"chat/pkg/middleware"
 	"chat/pkg/utils"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -32,6 +34,7 @@ type (
 		GetUserById(ctx context.Context, userID string) (*userModel.User, error)
 		SendNotifications(ctx context.Context, msg *model.ChatMessage, onlineUsers []string) error
 		GetRestrictionService() *restrictionService.RestrictionService
+		CheckSlowMode(ctx context.Context, roomID, userID primitive.ObjectID, source string) (time.Duration, bool)
 	}
 
 	UserService interface {
@@ -100,6 +103,17 @@ func (c *UploadController) handleUpload(ctx *fiber.Ctx) error {
 		})
 	}
 	log.Printf("[Controller] User %s passed restriction check for upload in room %s", userObjID.Hex(), roomObjID.Hex())
+	// ไฟล์ที่ไม่ผ่านการตรวจต้องไม่เริ่ม slow mode cooldown
+	if err := c.uploadHandler.ValidateFormFile(ctx, "file"); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Invalid file: %v", err),
+		})
+	}
+	// **NEW: slow mode ของห้อง (ตรวจหลัง validate แต่ก่อนบันทึกไฟล์)**
+	if remaining, ok := c.chatService.CheckSlowMode(ctx.Context(), roomObjID, userObjID, "upload"); !ok {
+		return respondSlowMode(ctx, remaining)
+	}
 	// Upload file
 	filename, err := c.uploadHandler.HandleFileUpload(ctx, "file")
 	if err != nil {
@@ -122,7 +136,10 @@ func (c *UploadController) handleUpload(ctx *fiber.Ctx) error {
 	}
 
 	// Send message (broadcastMsg = nil, emitter will build correct payload)
-	if err := c.chatService.SendMessage(ctx.Context(), msg, nil); err != nil {
+	if err := c.chatService.SendMessage(ctx.UserContext(), msg, nil); err != nil {
+		if errors.Is(err, admission.ErrServerBusy) {
+			return admission.RespondBusy(ctx, "Server is busy, please retry shortly")
+		}
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Error sending message",
@@ -3,22 +3,65 @@ package controller
 import (
 	"chat/module/chat/model"
 	"chat/module/chat/utils"
+	"chat/pkg/core/admission"
 	"chat/pkg/core/connection"
+	"chat/pkg/core/logging"
+	"chat/pkg/core/tracing"
 	"chat/pkg/middleware"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
 	mentionService "chat/module/chat/service"
+	settingsService "chat/module/settings/service"
 	userService "chat/module/user/service"
 
 	"github.com/gofiber/websocket/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// MessageNackEvent แจ้งผู้ส่งว่าข้อความไม่ถูกรับ (เช่น server busy) เพื่อให้ client แสดงสถานะและส่งใหม่ได้
+const MessageNackEvent = "message_nack"
+
+// ResyncCompleteEvent ส่งหลังข้อความ resync ครบแล้ว พร้อม seq ล่าสุดของห้อง
+const ResyncCompleteEvent = "resync_complete"
+
+// ResyncMaxMessages จำนวนข้อความสูงสุดต่อคำขอ resync
+const ResyncMaxMessages = 200
+
+// ControlFrameResync ขอข้อความที่ seq มากกว่า afterSeq (socket ต่อห้อง: {"type":"resync","afterSeq":42})
+const ControlFrameResync = "resync"
+
+// controlFrame คือ frame ควบคุมแบบ JSON บน socket ต่อห้อง (ข้อความอื่นทั้งหมดเป็นข้อความแชต)
+type controlFrame struct {
+	Type     string `json:"type"`
+	AfterSeq *int64 `json:"afterSeq"`
+}
+
+// parseControlFrame แยก control frame ออกจากข้อความแชต
+// ต้องเป็น JSON ที่มีเฉพาะ field ที่รู้จักและ type ที่รองรับเท่านั้น ข้อความที่ user พิมพ์ (รวมถึง "/resync 5") จึงไม่ถูกกลืน
+func parseControlFrame(messageText string) (controlFrame, bool) {
+	var frame controlFrame
+	if !strings.HasPrefix(messageText, "{") {
+		return frame, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(messageText))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&frame); err != nil || decoder.More() {
+		return controlFrame{}, false
+	}
+	if frame.Type != ControlFrameResync || frame.AfterSeq == nil {
+		return controlFrame{}, false
+	}
+	return frame, true
+}
+
 type (
 	WebSocketHandler struct {
 		chatService        ChatService
@@ -62,7 +105,8 @@ func NewWebSocketHandler(
 func (h *WebSocketHandler) sendChatHistory(ctx context.Context, conn *websocket.Conn, roomID string, userID string) {
 	log.Printf("[WebSocket] 🔍 Fetching chat history for room %s", roomID)
 
-	messages, err := h.chatService.GetChatHistoryByRoom(ctx, roomID, 50)
+	historyLength := int64(settingsService.Current().Messages.HistoryLength)
+	messages, err := h.chatService.GetChatHistoryByRoom(ctx, roomID, historyLength)
 	if err != nil {
 		log.Printf("[WebSocket] ❌ Failed to get chat history for room %s: %v", roomID, err)
 		return
@@ -112,6 +156,12 @@ func (h *WebSocketHandler) sendChatHistory(ctx context.Context, conn *websocket.
 		reversedMessages[i] = messages[j]
 	}
 
+	h.writeHistoryMessages(ctx, conn, roomID, userID, reversedMessages)
+}
+
+// writeHistoryMessages ส่งข้อความ (เรียงเก่าไปใหม่) ให้ connection ในรูปแบบเดียวกับ event สด
+// ใช้ทั้งตอนส่ง history และตอน resync ตาม seq
+func (h *WebSocketHandler) writeHistoryMessages(ctx context.Context, conn *websocket.Conn, roomID string, userID string, reversedMessages []model.ChatMessageEnriched) {
 	// ===== เพิ่มโค้ด filter เฉพาะ MC room =====
 	userObjID, _ := primitive.ObjectIDFromHex(userID)
 	mcHelper := utils.NewMCRoomHelper(h.chatService.GetMongo())
@@ -132,39 +182,34 @@ func (h *WebSocketHandler) sendChatHistory(ctx context.Context, conn *websocket.
 
 	log.Printf("[WebSocket] 📤 Sending %d chat messages for room %s (oldest first for proper display)", len(filteredMessages), roomID)
 
+	// **NEW: Resolve user/role ของทุกข้อความ (รวม reply) ในครั้งเดียวแทน query ทีละข้อความ**
+	userIDs := make([]primitive.ObjectID, 0, len(filteredMessages)*2)
+	for _, msg := range filteredMessages {
+		userIDs = append(userIDs, msg.ChatMessage.UserID)
+		if msg.ReplyTo != nil {
+			userIDs = append(userIDs, msg.ReplyTo.UserID)
+		}
+	}
+	userInfos := h.userInfoResolver().ResolveUsers(ctx, userIDs)
+
 	messagesSent := 0
 	for _, msg := range filteredMessages {
 		// Get user details with role populated
-		var userData map[string]interface{}
-		if user, err := h.chatService.GetUserById(ctx, msg.ChatMessage.UserID.Hex()); err == nil {
-			userData = map[string]interface{}{
-				"_id":      user.ID.Hex(),
-				"username": user.Username,
-				"name": map[string]interface{}{
-					"first":  user.Name.First,
-					"middle": user.Name.Middle,
-					"last":   user.Name.Last,
-				},
-			}
-
-			// Add role information (excluding permissions)
-			if user.Role != primitive.NilObjectID {
-				roleObj, err := h.roleService.GetRoleById(ctx, user.Role.Hex())
-				if err == nil && roleObj != nil {
-					userData["role"] = map[string]interface{}{
-						"_id":  roleObj.ID.Hex(),
-						"name": roleObj.Name,
-					}
-				} else {
-					userData["role"] = map[string]interface{}{
-						"_id": user.Role.Hex(),
-					}
-				}
+		userInfo := userInfos[msg.ChatMessage.UserID.Hex()]
+		userData := map[string]interface{}{
+			"_id":      userInfo.ID,
+			"username": userInfo.Username,
+			"name":     userInfo.Name,
+		}
+		// Add role information (excluding permissions)
+		if userInfo.Role != nil {
+			roleData := map[string]interface{}{
+				"_id": userInfo.Role.ID,
 			}
-		} else {
-			userData = map[string]interface{}{
-				"_id": msg.ChatMessage.UserID.Hex(),
+			if userInfo.Role.Name != "" {
+				roleData["name"] = userInfo.Role.Name
 			}
+			userData["role"] = roleData
 		}
 
 		// Determine event type and message type (same logic as ChatEventEmitter)
@@ -199,6 +244,7 @@ func (h *WebSocketHandler) sendChatHistory(ctx context.Context, conn *websocket.
 				"_id":       msg.ChatMessage.ID.Hex(),
 				"type":      messageType,
 				"message":   msg.ChatMessage.Message,
+				"seq":       msg.ChatMessage.Seq,
 				"timestamp": msg.ChatMessage.Timestamp,
 			},
 			"timestamp": msg.ChatMessage.Timestamp,
@@ -240,17 +286,11 @@ func (h *WebSocketHandler) sendChatHistory(ctx context.Context, conn *websocket.
 		if msg.ReplyTo != nil {
 			log.Printf("[DEBUG] History message is a reply: messageID=%s, replyToID=%s", msg.ChatMessage.ID.Hex(), msg.ReplyTo.ID.Hex())
 			// Get reply user data
-			var replyUserData map[string]interface{}
-			if replyUser, err := h.chatService.GetUserById(ctx, msg.ReplyTo.UserID.Hex()); err == nil {
-				replyUserData = map[string]interface{}{
-					"_id":      replyUser.ID.Hex(),
-					"username": replyUser.Username,
-					"name":     replyUser.Name,
-				}
-			} else {
-				replyUserData = map[string]interface{}{
-					"_id": msg.ReplyTo.UserID.Hex(),
-				}
+			replyUser := userInfos[msg.ReplyTo.UserID.Hex()]
+			replyUserData := map[string]interface{}{
+				"_id":      replyUser.ID,
+				"username": replyUser.Username,
+				"name":     replyUser.Name,
 			}
 			payload["replyTo"] = map[string]interface{}{
 				"message": map[string]interface{}{
@@ -266,12 +306,13 @@ func (h *WebSocketHandler) sendChatHistory(ctx context.Context, conn *websocket.
 		event := model.Event{
 			Type:      eventType,
 			Payload:   payload,
+			Seq:       msg.ChatMessage.Seq,
 			Timestamp: msg.ChatMessage.Timestamp,
 		}
 
 		// Send event to client
 		if eventBytes, err := json.Marshal(event); err == nil {
-			if err := conn.WriteMessage(websocket.TextMessage, eventBytes); err != nil {
+			if err := h.chatService.GetHub().WriteToConn(roomID, conn, eventBytes); err != nil {
 				log.Printf("[WebSocket] ❌ Failed to send history message %s to client: %v", msg.ChatMessage.ID.Hex(), err)
 				break
 			}
@@ -332,18 +373,7 @@ func (h *WebSocketHandler) HandleWebSocket(conn *websocket.Conn) {
 	log.Printf("[WebSocket] 🔌 User %s connecting to room %s", userID, roomID)
 
 	// --- Set userRole in context for permission checks ---
-	user, err := h.chatService.GetUserById(ctx, userID)
-	var userRole string
-	if err == nil && user != nil {
-		userRole = ""
-		if user.Role != primitive.NilObjectID {
-			roleObj, err := h.roleService.GetRoleById(ctx, user.Role.Hex())
-			if err == nil && roleObj != nil {
-				userRole = roleObj.Name
-			}
-		}
-	}
-	ctx = context.WithValue(ctx, "userRole", userRole)
+	ctx = context.WithValue(ctx, "userRole", h.resolveUserRole(ctx, userID))
 	// --- End set userRole ---
 
 	roomObjID, err := primitive.ObjectIDFromHex(roomID)
@@ -409,14 +439,7 @@ func (h *WebSocketHandler) HandleWebSocket(conn *websocket.Conn) {
 	}
 
 	// Send room status
-	if status, err := h.roomService.GetRoomStatus(ctx, roomObjID); err == nil {
-		if statusBytes, err := json.Marshal(map[string]interface{}{
-			"type": "room_status",
-			"data": status,
-		}); err == nil {
-			conn.WriteMessage(websocket.TextMessage, statusBytes)
-		}
-	}
+	h.sendRoomStatus(ctx, conn, roomObjID)
 
 	// **ENHANCED: Send chat history with better logging**
 	log.Printf("[WebSocket] 📚 Sending chat history to user %s for room %s", userID, roomID)
@@ -468,83 +491,265 @@ func (h *WebSocketHandler) HandleWebSocket(conn *websocket.Conn) {
 
 		messageText := strings.TrimSpace(string(msg))
 
-		// **IMPROVED: Enhanced WebSocket command handling**
-
-		// ให้มัน support action ต่างๆใน socket message เช่น /reply /react /unsend
-		switch {
-		case strings.HasPrefix(messageText, "/reply "):
-			h.handleReplyMessage(messageText, *client, ctx)
-
-		case strings.HasPrefix(messageText, "/unsend "):
-			h.handleUnsendMessage(messageText, *client, ctx)
-		default:
-			// **NEW: Check for kick events and handle disconnection**
-			if strings.Contains(messageText, "\"type\":\"user_kicked\"") {
-				log.Printf("[WS] User %s received kick event, disconnecting", userID)
-				conn.WriteMessage(websocket.TextMessage, []byte("You have been kicked from this room"))
-				conn.Close()
-				return
-			}
-			// Check if room is still active (prevent messages in inactive rooms)
-			room, err := h.roomService.GetRoomById(ctx, roomObjID)
-			if err != nil {
-				log.Printf("[WS] Failed to get room %s: %v", roomObjID.Hex(), err)
-				continue
-			}
+		// **NEW: Check for kick events and handle disconnection**
+		if strings.Contains(messageText, "\"type\":\"user_kicked\"") {
+			log.Printf("[WS] User %s received kick event, disconnecting", userID)
+			conn.WriteMessage(websocket.TextMessage, []byte("You have been kicked from this room"))
+			conn.Close()
+			return
+		}
 
-			if room.IsInactive() {
-				conn.WriteMessage(websocket.TextMessage, []byte("This room is inactive and not accepting messages"))
-				continue
-			}
+		if frame, ok := parseControlFrame(messageText); ok {
+			frameCtx, span := startFrameSpan(ctx, roomID, userID, frame.Type)
+			h.handleResync(frameCtx, *client, *frame.AfterSeq)
+			span.End()
+			continue
+		}
 
-			// ตรวจสอบสิทธิ์การส่งข้อความ (restriction + room type)
-			canSend, err := h.roomService.CanUserSendMessage(ctx, roomObjID, userID)
-			if err != nil || !canSend {
-				conn.WriteMessage(websocket.TextMessage, []byte("You cannot send messages in this room (read-only or restricted)"))
-				continue
-			}
+		frameCtx, span := startFrameSpan(ctx, roomID, userID, "message")
+		h.handleRoomMessage(frameCtx, *client, messageText)
+		span.End()
+	}
+}
 
-			// ตรวจสอบ restriction status เพิ่มเติม
-			if !h.restrictionService.CanUserSendMessages(ctx, userObjID, roomObjID) {
-				if h.restrictionService.IsUserBanned(ctx, userObjID, roomObjID) {
-					conn.WriteMessage(websocket.TextMessage, []byte("You are banned from this room"))
-				} else if h.restrictionService.IsUserMuted(ctx, userObjID, roomObjID) {
-					conn.WriteMessage(websocket.TextMessage, []byte("You are muted in this room"))
-				} else {
-					conn.WriteMessage(websocket.TextMessage, []byte("You cannot send messages in this room"))
-				}
-				continue
-			}
+// startFrameSpan เริ่ม trace ใหม่ต่อ WebSocket frame ที่ client ส่งเข้ามา
+// (socket อยู่ได้หลายชั่วโมง จึงไม่ผูกทุก frame ไว้ใต้ span ของ connection)
+func startFrameSpan(ctx context.Context, roomID, userID, op string) (context.Context, trace.Span) {
+	ctx = logging.WithRoom(logging.WithUser(ctx, userID), roomID)
+	return tracing.Tracer().Start(ctx, "ws.frame "+op,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("chat.room_id", roomID),
+			attribute.String("chat.user_id", userID),
+		),
+	)
+}
 
-			// Check if message contains mentions (detected by @ symbol)
-			if strings.Contains(messageText, "@") {
-				// Send as mention message if contains @ symbols
-				if _, err := h.mentionService.SendMentionMessage(ctx, userObjID, roomObjID, messageText); err != nil {
-					log.Printf("[ERROR] Failed to send mention message: %v", err)
-					continue
-				}
-			} else {
-				// Create regular message and send it once
-				chatMsg := &model.ChatMessage{
-					RoomID:    roomObjID,
-					UserID:    userObjID,
-					Message:   messageText,
-					Timestamp: time.Now(),
-				}
-
-				// Always save to DB via SendMessage (this ensures DB persistence)
-				metadata := map[string]interface{}{
-					"type": "message",
-				}
-				if err := h.chatService.SendMessage(ctx, chatMsg, metadata); err != nil {
-					log.Printf("[ERROR] Failed to send message: %v", err)
-					continue
-				}
-			}
+// resolveUserRole ดึงชื่อ role ของ user สำหรับใส่ใน context (ใช้ตรวจสิทธิ์ read-only room)
+func (h *WebSocketHandler) resolveUserRole(ctx context.Context, userID string) string {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ""
+	}
+	userInfo := h.userInfoResolver().ResolveUser(ctx, userObjID)
+	if userInfo.Role == nil {
+		return ""
+	}
+	return userInfo.Role.Name
+}
+
+// userInfoResolver คืน resolver ที่ใช้ cache ร่วมกับ ChatEventEmitter
+func (h *WebSocketHandler) userInfoResolver() *utils.UserInfoResolver {
+	return utils.GetUserInfoResolver(h.chatService.GetMongo(), h.chatService.GetRedis())
+}
+
+// sendRoomStatus ส่งสถานะห้องให้ client ตอนเริ่มเชื่อมต่อ/subscribe
+func (h *WebSocketHandler) sendRoomStatus(ctx context.Context, conn *websocket.Conn, roomObjID primitive.ObjectID) {
+	if status, err := h.roomService.GetRoomStatus(ctx, roomObjID); err == nil {
+		if statusBytes, err := json.Marshal(map[string]interface{}{
+			"type": "room_status",
+			"data": status,
+		}); err == nil {
+			h.chatService.GetHub().WriteToConn(roomObjID.Hex(), conn, statusBytes)
 		}
 	}
 }
 
+// writeToClient ส่งข้อความกลับไปยัง client ในบริบทของห้อง (session จะได้ roomId tag)
+func (h *WebSocketHandler) writeToClient(client model.ClientObject, payload []byte) {
+	h.chatService.GetHub().WriteToConn(client.RoomID.Hex(), client.Conn, payload)
+}
+
+// handleRoomMessage จัดการข้อความที่ user ส่งเข้าห้อง ใช้ร่วมกันทั้ง socket ต่อห้อง และ session
+func (h *WebSocketHandler) handleRoomMessage(ctx context.Context, client model.ClientObject, messageText string) {
+	// ให้มัน support action ต่างๆใน socket message เช่น /reply /react /unsend
+	features := settingsService.Current().Features
+	switch {
+	case strings.HasPrefix(messageText, "/reply "):
+		if !features.Replies {
+			h.writeToClient(client, []byte("Replies are currently disabled"))
+			return
+		}
+		h.handleReplyMessage(messageText, client, ctx)
+		return
+
+	case strings.HasPrefix(messageText, "/unsend "):
+		if !features.Unsend {
+			h.writeToClient(client, []byte("Unsending messages is currently disabled"))
+			return
+		}
+		h.handleUnsendMessage(messageText, client, ctx)
+		return
+	}
+
+	roomObjID := client.RoomID
+	userObjID := client.UserID
+
+	// Check if room is still active (prevent messages in inactive rooms)
+	room, err := h.roomService.GetRoomById(ctx, roomObjID)
+	if err != nil {
+		log.Printf("[WS] Failed to get room %s: %v", roomObjID.Hex(), err)
+		return
+	}
+
+	if room.IsInactive() {
+		h.writeToClient(client, []byte("This room is inactive and not accepting messages"))
+		return
+	}
+
+	// ตรวจสอบสิทธิ์การส่งข้อความ (restriction + room type)
+	canSend, err := h.roomService.CanUserSendMessage(ctx, roomObjID, userObjID.Hex())
+	if err != nil || !canSend {
+		h.writeToClient(client, []byte("You cannot send messages in this room (read-only or restricted)"))
+		return
+	}
+
+	// ตรวจสอบ restriction status เพิ่มเติม
+	if !h.restrictionService.CanUserSendMessages(ctx, userObjID, roomObjID) {
+		if h.restrictionService.IsUserBanned(ctx, userObjID, roomObjID) {
+			h.writeToClient(client, []byte("You are banned from this room"))
+		} else if h.restrictionService.IsUserMuted(ctx, userObjID, roomObjID) {
+			h.writeToClient(client, []byte("You are muted in this room"))
+		} else {
+			h.writeToClient(client, []byte("You cannot send messages in this room"))
+		}
+		return
+	}
+
+	// **NEW: ความยาว, rate limit และคำต้องห้ามตาม runtime settings**
+	messageText, ok := h.applyMessagePolicy(ctx, client, room.Type, messageText)
+	if !ok {
+		return
+	}
+
+	// Check if message contains mentions (detected by @ symbol)
+	if features.Mentions && strings.Contains(messageText, "@") {
+		// Send as mention message if contains @ symbols
+		if _, err := h.mentionService.SendMentionMessage(ctx, userObjID, roomObjID, messageText); err != nil {
+			log.Printf("[ERROR] Failed to send mention message: %v", err)
+			h.nackIfBusy(client, messageText, err)
+		}
+		return
+	}
+
+	// Create regular message and send it once
+	chatMsg := &model.ChatMessage{
+		RoomID:    roomObjID,
+		UserID:    userObjID,
+		Message:   messageText,
+		Timestamp: time.Now(),
+	}
+
+	// Always save to DB via SendMessage (this ensures DB persistence)
+	metadata := map[string]interface{}{
+		"type": "message",
+	}
+	if err := h.chatService.SendMessage(ctx, chatMsg, metadata); err != nil {
+		log.Printf("[ERROR] Failed to send message: %v", err)
+		h.nackIfBusy(client, messageText, err)
+	}
+}
+
+// handleResync ส่งข้อความที่ seq มากกว่าค่าที่ client มี (control frame "resync") ใช้เมื่อ client ตรวจเจอ gap
+// ส่งครั้งละไม่เกิน ResyncMaxMessages ถ้ายังไม่ครบ client ขอต่อจาก seq สุดท้ายที่ได้รับ
+func (h *WebSocketHandler) handleResync(ctx context.Context, client model.ClientObject, afterSeq int64) {
+	if afterSeq < 0 {
+		h.writeToClient(client, []byte("Invalid resync sequence"))
+		return
+	}
+
+	roomID := client.RoomID.Hex()
+	messages, err := h.chatService.GetRoomMessagesBySeq(ctx, roomID, afterSeq, 0, ResyncMaxMessages)
+	if err != nil {
+		log.Printf("[WS] Failed to resync room %s after seq %d: %v", roomID, afterSeq, err)
+		return
+	}
+	h.writeHistoryMessages(ctx, client.Conn, roomID, client.UserID.Hex(), messages)
+
+	latestSeq, err := h.chatService.GetRoomSeq(ctx, client.RoomID)
+	if err != nil {
+		log.Printf("[WS] Failed to read latest seq of room %s: %v", roomID, err)
+		return
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type": ResyncCompleteEvent,
+		"payload": map[string]interface{}{
+			"roomId":    roomID,
+			"afterSeq":  afterSeq,
+			"count":     len(messages),
+			"latestSeq": latestSeq,
+			"hasMore":   len(messages) == ResyncMaxMessages,
+		},
+	})
+	h.writeToClient(client, payload)
+}
+
+// nackIfBusy แจ้ง client ว่าข้อความไม่ถูกรับเพราะ server busy (client ควรส่งใหม่หลัง retryAfterMs)
+func (h *WebSocketHandler) nackIfBusy(client model.ClientObject, messageText string, err error) {
+	if !errors.Is(err, admission.ErrServerBusy) {
+		return
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type": MessageNackEvent,
+		"payload": map[string]interface{}{
+			"reason":       "server_busy",
+			"message":      messageText,
+			"retryAfterMs": admission.RetryAfter().Milliseconds(),
+			"timestamp":    time.Now(),
+		},
+	})
+	h.writeToClient(client, payload)
+}
+
+// applyMessagePolicy ตรวจข้อความตาม runtime settings ของประเภทห้อง
+// คืนข้อความที่ผ่านการ mask แล้ว และ false ถ้าข้อความถูกปฏิเสธ (แจ้ง client แล้ว)
+func (h *WebSocketHandler) applyMessagePolicy(ctx context.Context, client model.ClientObject, roomType, text string) (string, bool) {
+	settings := settingsService.Current()
+
+	if settings.ExceedsMaxLength(roomType, text) {
+		h.writeToClient(client, []byte(fmt.Sprintf("Message is too long (max %d characters)", settings.LimitsFor(roomType).MaxLength)))
+		return "", false
+	}
+
+	perMinute := settings.LimitsFor(roomType).PerUserPerMinute
+	if !utils.AllowRoomMessage(ctx, h.chatService.GetRedis(), client.RoomID.Hex(), client.UserID.Hex(), perMinute) {
+		h.writeToClient(client, []byte(fmt.Sprintf("You are sending messages too fast (max %d per minute)", perMinute)))
+		return "", false
+	}
+
+	filtered, matched, blocked := settings.FilterMessage(text)
+	if blocked {
+		log.Printf("[WS] Message from user %s in room %s rejected by word filter", client.UserID.Hex(), client.RoomID.Hex())
+		h.writeToClient(client, []byte("Your message contains blocked words"))
+		return "", false
+	}
+	if matched {
+		log.Printf("[WS] Masked blocked words in message from user %s in room %s", client.UserID.Hex(), client.RoomID.Hex())
+	}
+
+	// **NEW: slow mode ตรวจเป็นขั้นสุดท้าย เพื่อให้ข้อความที่ถูกปฏิเสธด้วยเหตุอื่นไม่เริ่ม cooldown**
+	if remaining, ok := h.chatService.CheckSlowMode(ctx, client.RoomID, client.UserID, "ws"); !ok {
+		h.nackSlowMode(client, text, remaining)
+		return "", false
+	}
+	return filtered, true
+}
+
+// nackSlowMode แจ้งผู้ส่งว่ายังอยู่ใน cooldown ของ slow mode (client ส่งใหม่ได้หลัง retryAfterMs)
+func (h *WebSocketHandler) nackSlowMode(client model.ClientObject, messageText string, remaining time.Duration) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type": MessageNackEvent,
+		"payload": map[string]interface{}{
+			"reason":       "slow_mode",
+			"message":      messageText,
+			"retryAfterMs": remaining.Milliseconds(),
+			"timestamp":    time.Now(),
+		},
+	})
+	h.writeToClient(client, payload)
+}
+
 // Helper methods for WebSocket message handling
 func (h *WebSocketHandler) handleReplyMessage(messageText string, client model.ClientObject, ctx context.Context) {
 	// Check if room is still active
@@ -555,14 +760,14 @@ func (h *WebSocketHandler) handleReplyMessage(messageText string, client model.C
 	}
 
 	if room.IsInactive() {
-		client.Conn.WriteMessage(websocket.TextMessage, []byte("This room is inactive and not accepting messages"))
+		h.writeToClient(client, []byte("This room is inactive and not accepting messages"))
 		return
 	}
 
 	// ตรวจสอบสิทธิ์การส่งข้อความก่อน
 	canSend, err := h.roomService.CanUserSendMessage(ctx, client.RoomID, client.UserID.Hex())
 	if err != nil || !canSend {
-		client.Conn.WriteMessage(websocket.TextMessage, []byte("You cannot send messages in this room (read-only or restricted)"))
+		h.writeToClient(client, []byte("You cannot send messages in this room (read-only or restricted)"))
 		return
 	}
 
@@ -578,11 +783,16 @@ func (h *WebSocketHandler) handleReplyMessage(messageText string, client model.C
 		return
 	}
 
+	replyText, ok := h.applyMessagePolicy(ctx, client, room.Type, parts[2])
+	if !ok {
+		return
+	}
+
 	// สร้าง message ใหม่
 	msg := &model.ChatMessage{
 		RoomID:    client.RoomID,
 		UserID:    client.UserID,
-		Message:   parts[2],
+		Message:   replyText,
 		ReplyToID: &replyToID,
 		Timestamp: time.Now(),
 	}
@@ -592,6 +802,7 @@ func (h *WebSocketHandler) handleReplyMessage(messageText string, client model.C
 	}
 	if err := h.chatService.SendMessage(ctx, msg, metadata); err != nil {
 		log.Printf("[ERROR] Failed to send reply message: %v", err)
+		h.nackIfBusy(client, messageText, err)
 	}
 }
 
@@ -605,7 +816,7 @@ func (h *WebSocketHandler) handleUnsendMessage(messageText string, client model.
 	}
 
 	if room.IsInactive() {
-		client.Conn.WriteMessage(websocket.TextMessage, []byte("This room is inactive and not accepting messages"))
+		h.writeToClient(client, []byte("This room is inactive and not accepting messages"))
 		return
 	}
 
@@ -656,7 +867,7 @@ func (h *WebSocketHandler) handleUnsendMessage(messageText string, client model.
 		}
 
 		if eventData, err := json.Marshal(errorEvent); err == nil {
-			client.Conn.WriteMessage(websocket.TextMessage, eventData)
+			h.writeToClient(client, eventData)
 		}
 		return
 	}
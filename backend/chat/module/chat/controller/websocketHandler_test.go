@@ -0,0 +1,34 @@
+package controller
+
+import "testing"
+
+func TestParseControlFrame(t *testing.T) {
+	tests := []struct {
+		text      string
+		wantOK    bool
+		wantAfter int64
+	}{
+		{text: `{"type":"resync","afterSeq":42}`, wantOK: true, wantAfter: 42},
+		{text: `{"type":"resync","afterSeq":0}`, wantOK: true, wantAfter: 0},
+		{text: "/resync 42"},                                   // ข้อความแชตธรรมดา
+		{text: "/resync 42 is where the bug started"},          // ข้อความแชตธรรมดา
+		{text: `{"type":"resync"}`},                            // ไม่มี afterSeq
+		{text: `{"type":"typing","afterSeq":1}`},               // type ที่ไม่รองรับ
+		{text: `{"type":"resync","afterSeq":1,"note":"hi"}`},   // field ที่ไม่รู้จัก
+		{text: `{"type":"resync","afterSeq":1} and more text`}, // มีข้อความต่อท้าย
+		{text: `{"type":"resync","afterSeq":"1"}`},
+		{text: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			frame, ok := parseControlFrame(tt.text)
+			if ok != tt.wantOK {
+				t.Fatalf("parseControlFrame(%q) ok = %v, want %v", tt.text, ok, tt.wantOK)
+			}
+			if ok && *frame.AfterSeq != tt.wantAfter {
+				t.Errorf("AfterSeq = %d, want %d", *frame.AfterSeq, tt.wantAfter)
+			}
+		})
+	}
+}
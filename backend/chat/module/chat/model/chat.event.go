@@ -50,6 +50,7 @@ type (
 	Event struct {
 		Type      string      `json:"type"`
 		Payload   interface{} `json:"payload"`
+		Seq       int64       `json:"seq,omitempty"` // ลำดับของข้อความในห้อง (เฉพาะ event ของข้อความ)
 		Timestamp time.Time   `json:"timestamp"`
 	}
 	
@@ -83,6 +84,7 @@ type (
 		ID        string    `json:"_id"`
 		Type      string    `json:"type"` // text, reply, sticker, mention
 		Message   string    `json:"message,omitempty"`
+		Seq       int64     `json:"seq,omitempty"`
 		Timestamp time.Time `json:"timestamp"`
 	}
 
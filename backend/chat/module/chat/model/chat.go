@@ -17,6 +17,7 @@ type (
 		ReplyToID *primitive.ObjectID `bson:"reply_to_id,omitempty" json:"reply_to_id,omitempty"`
 		FileName  string              `bson:"file_name,omitempty" json:"file_name,omitempty"`
 		Timestamp time.Time           `bson:"timestamp" json:"timestamp"`
+		Seq       int64               `bson:"seq,omitempty" json:"seq,omitempty"` // **NEW: ลำดับต่อห้อง (0 = ข้อความก่อนมี seq)**
 		StickerID *primitive.ObjectID `bson:"sticker_id,omitempty" json:"stickerId,omitempty"`
 		Image     string              `bson:"image,omitempty" json:"image,omitempty"`
 		
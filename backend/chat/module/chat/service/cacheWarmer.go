@@ -0,0 +1,245 @@
+package service
+
+import (
+	"chat/module/chat/utils"
+	roomModel "chat/module/room/room/model"
+	roomCache "chat/module/room/shared/cache"
+	"chat/pkg/config"
+	"chat/pkg/core/metrics"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Cache warm-up**
+// โหลด history, room และ members ของห้องที่ active (มีข้อความล่าสุด) หรือกำลังจะเปิดเข้า cache ล่วงหน้า
+// เพื่อให้ผู้ใช้กลุ่มแรกที่เข้าห้องหลัง Redis restart / TTL หมดไม่ต้องอ่าน Mongo พร้อมกัน
+// ทุก instance รัน job นี้ แต่ lock ต่อรอบทำให้มีแค่ instance เดียวที่ warm ในแต่ละรอบ
+const cacheWarmupLockKey = "chat:cache:warmup:lock"
+
+const (
+	warmupWarmed  = "warmed"
+	warmupSkipped = "skipped"
+	warmupError   = "error"
+)
+
+type (
+	// UpcomingRoomsSource คืนห้องที่จะเปิดภายใน within (เช่นห้องที่มีตารางเวลา) เพื่อ warm ก่อนผู้ใช้เข้า
+	UpcomingRoomsSource func(ctx context.Context, within time.Duration) ([]primitive.ObjectID, error)
+
+	CacheWarmer struct {
+		history  *HistoryService
+		rooms    *roomCache.RoomCacheService
+		mongo    *mongo.Database
+		redis    *redis.Client
+		cfg      config.CacheConfig
+		upcoming []UpcomingRoomsSource
+		mu       sync.Mutex
+	}
+)
+
+func NewCacheWarmer(db *mongo.Database, redisClient *redis.Client, history *HistoryService, cfg config.CacheConfig) *CacheWarmer {
+	return &CacheWarmer{
+		history: history,
+		rooms:   roomCache.NewRoomCacheService(redisClient),
+		mongo:   db,
+		redis:   redisClient,
+		cfg:     cfg,
+	}
+}
+
+// AddUpcomingSource เพิ่มแหล่งของห้องที่กำลังจะเปิด (มีผลตั้งแต่รอบถัดไป)
+func (w *CacheWarmer) AddUpcomingSource(source UpcomingRoomsSource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.upcoming = append(w.upcoming, source)
+}
+
+// Start warm ทันทีหนึ่งรอบ (cache อาจว่างเพราะ Redis เพิ่ง restart) แล้ววนทุก WarmupInterval จน ctx ถูก cancel
+func (w *CacheWarmer) Start(ctx context.Context) {
+	if !w.cfg.WarmupEnabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.cfg.WarmupInterval)
+		defer ticker.Stop()
+
+		for {
+			w.runOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runOnce warm หนึ่งรอบ ถ้า instance อื่นถือ lock ของรอบนี้อยู่จะข้าม
+func (w *CacheWarmer) runOnce(ctx context.Context) {
+	acquired, err := w.redis.SetNX(ctx, cacheWarmupLockKey, "1", w.cfg.WarmupInterval/2).Result()
+	if err != nil {
+		log.Printf("[CacheWarmer] ⚠️ Failed to acquire warm-up lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	rooms, err := w.candidateRooms(ctx)
+	if err != nil {
+		log.Printf("[CacheWarmer] ⚠️ Failed to list rooms to warm: %v", err)
+		return
+	}
+
+	warmed := 0
+	for _, room := range rooms {
+		if ctx.Err() != nil {
+			return
+		}
+		result := w.warmRoom(ctx, &room)
+		metrics.CacheWarmupRooms.Inc(result)
+		if result == warmupWarmed {
+			warmed++
+		}
+	}
+
+	if warmed > 0 {
+		log.Printf("[CacheWarmer] 🔥 Warmed cache for %d of %d active/upcoming rooms", warmed, len(rooms))
+	}
+}
+
+// candidateRooms คืนห้องที่ status active และมีข้อความใน WarmupActiveWindow หรือกำลังจะเปิด (ไม่เกิน WarmupMaxRooms)
+// ห้องที่กำลังจะเปิดยัง inactive อยู่จนถึงเวลาเปิด จึงไม่กรองด้วย status
+func (w *CacheWarmer) candidateRooms(ctx context.Context) ([]roomModel.Room, error) {
+	ids, err := w.recentlyActiveRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	upcomingIDs := make(map[primitive.ObjectID]bool)
+	w.mu.Lock()
+	sources := append([]UpcomingRoomsSource(nil), w.upcoming...)
+	w.mu.Unlock()
+
+	for _, source := range sources {
+		upcoming, err := source(ctx, w.cfg.WarmupLookahead)
+		if err != nil {
+			log.Printf("[CacheWarmer] ⚠️ Failed to list upcoming rooms: %v", err)
+			continue
+		}
+		for _, id := range upcoming {
+			upcomingIDs[id] = true
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > w.cfg.WarmupMaxRooms {
+		ids = ids[:w.cfg.WarmupMaxRooms]
+	}
+
+	activeIDs := make([]primitive.ObjectID, 0, len(ids)) // $in ต้องเป็น array เสมอ (nil slice จะกลายเป็น null)
+	scheduledIDs := make([]primitive.ObjectID, 0, len(upcomingIDs))
+	for _, id := range ids {
+		if upcomingIDs[id] {
+			scheduledIDs = append(scheduledIDs, id)
+		} else {
+			activeIDs = append(activeIDs, id)
+		}
+	}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"_id": bson.M{"$in": activeIDs}, "status": roomModel.RoomStatusActive},
+		bson.M{"_id": bson.M{"$in": scheduledIDs}},
+	}}
+
+	cursor, err := w.mongo.Collection("rooms").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var rooms []roomModel.Room
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// recentlyActiveRooms คืนห้องที่มีข้อความใน WarmupActiveWindow (ห้องที่เพิ่งมีข้อความก่อน)
+func (w *CacheWarmer) recentlyActiveRooms(ctx context.Context) ([]primitive.ObjectID, error) {
+	since := time.Now().Add(-w.cfg.WarmupActiveWindow)
+	cursor, err := w.mongo.Collection("chat-messages").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"timestamp": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$room_id", "last": bson.M{"$max": "$timestamp"}}}},
+		{{Key: "$sort", Value: bson.M{"last": -1}}},
+		{{Key: "$limit", Value: w.cfg.WarmupMaxRooms}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(groups))
+	for _, g := range groups {
+		ids = append(ids, g.ID)
+	}
+	return ids, nil
+}
+
+// warmRoom เติมเฉพาะ cache ที่ยังว่าง (history ผ่าน HistoryService จึงได้ single-flight ไปด้วย)
+func (w *CacheWarmer) warmRoom(ctx context.Context, room *roomModel.Room) string {
+	roomID := room.ID.Hex()
+	result := warmupSkipped
+
+	cached, err := w.redis.Exists(ctx, utils.RoomMessageIndexKey(roomID)).Result()
+	if err != nil {
+		log.Printf("[CacheWarmer] ⚠️ Failed to check message cache of room %s: %v", roomID, err)
+		return warmupError
+	}
+	if cached == 0 {
+		if _, err := w.history.GetChatHistoryByRoom(ctx, roomID, int64(w.cfg.WarmupHistoryLimit)); err != nil {
+			log.Printf("[CacheWarmer] ⚠️ Failed to warm history of room %s: %v", roomID, err)
+			return warmupError
+		}
+		result = warmupWarmed
+	}
+
+	if cachedRoom, err := w.rooms.GetRoom(ctx, roomID); err == nil && cachedRoom == nil {
+		if err := w.rooms.SaveRoom(ctx, room); err != nil {
+			log.Printf("[CacheWarmer] ⚠️ Failed to warm room %s: %v", roomID, err)
+			return warmupError
+		}
+		result = warmupWarmed
+	}
+
+	if members, err := w.rooms.GetMembers(ctx, roomID); err == nil && members == nil {
+		if err := w.rooms.SaveMembers(ctx, roomID, room.Members); err != nil {
+			log.Printf("[CacheWarmer] ⚠️ Failed to warm members of room %s: %v", roomID, err)
+			return warmupError
+		}
+		result = warmupWarmed
+	}
+
+	return result
+}
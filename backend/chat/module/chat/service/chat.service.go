@@ -8,17 +8,23 @@ import (
 	userModel "chat/module/user/model"
 	userService "chat/module/user/service"
 	"chat/pkg/config"
+	"chat/pkg/core/admission"
+	"chat/pkg/core/audit"
+	"chat/pkg/core/lifecycle"
+	"chat/pkg/core/circuitbreaker"
 	"chat/pkg/core/kafka"
+	"chat/pkg/core/metrics"
 	"chat/pkg/database/queries"
 	"chat/pkg/helpers/service"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	roomModel "chat/module/room/room/model"
+	roomCache "chat/module/room/shared/cache"
 
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson"
@@ -59,7 +65,16 @@ type (
 
 		// **NEW: Async helper for worker pools and error handling**
 		asyncHelper      *utils.AsyncHelper
+		outbox           *utils.MessageOutbox // durable persistence (nil = ใช้ in-memory queue แบบเดิม)
+		delivery         *utils.DeliveryTracker
+		sequencer        *utils.RoomSequencer
+		warmer           *CacheWarmer
+		retention        *RetentionWorker
+		transcripts      *TranscriptService
+		archive          utils.MessageArchive
+		readiness        *lifecycle.Readiness
 		statusCollection *mongo.Collection
+		roomCache        *roomCache.RoomCacheService
 		mu               sync.RWMutex
 	}
 )
@@ -73,6 +88,9 @@ func NewChatService(
 	collection := db.Collection("chat-messages")
 	statusCollection := db.Collection("message-status")
 
+	// **NEW: ล้าง cache ของ user/role เมื่อมี event update (handler ต้องลงทะเบียนก่อน Start)**
+	utils.GetUserInfoResolver(db, redis).ConsumeUpdateEvents(kafkaBus, cfg.Kafka.Topics.UserEvents)
+
 	if err := kafkaBus.Start(); err != nil {
 		log.Printf("[ERROR] Failed to start Kafka bus: %v", err)
 	}
@@ -86,12 +104,15 @@ func NewChatService(
 		log.Printf("[INFO] Successfully created notification topics")
 	}
 
-	hub := utils.NewHub()
+	hub := utils.NewHub(cfg.ReliabilityThresholds.BroadcastTimeout)
 	emitter := utils.NewChatEventEmitter(hub, kafkaBus, redis, db)
 
 	// Create role service
 	roleService := userService.NewRoleService(db)
 
+	// archive ตัวเดียวกันทั้งฝั่งอ่าน (history) และฝั่งย้าย (retention worker)
+	archive := utils.NewMessageArchive(db, cfg.Retention.ArchiveMode, cfg.Retention.ArchivePath)
+
 	chatService := &ChatService{
 		BaseService:         queries.NewBaseService[model.ChatMessage](collection),
 		cache:               utils.NewChatCacheService(redis),
@@ -104,13 +125,49 @@ func NewChatService(
 		redis:               redis,
 		Config:              cfg,
 		notificationService: notificationService.NewNotificationService(db, kafkaBus, roleService),
-		historyService:      NewHistoryService(db, utils.NewChatCacheService(redis)),
+		historyService:      NewHistoryService(
+			db,
+			utils.NewChatCacheService(redis),
+			utils.NewCacheFill(redis, cfg.Cache.FillLockTTL, cfg.Cache.FillWait),
+			archive,
+		),
 		statusCollection:    statusCollection,
+		archive:             archive,
+		sequencer:           utils.GetRoomSequencer(db, redis),
+		roomCache:           roomCache.NewRoomCacheService(redis),
 	}
 
 	// **NEW: Initialize async helper**
 	chatService.asyncHelper = utils.NewAsyncHelper(db, cfg)
 	chatService.asyncHelper.SetPhantomDetectorHandler(chatService)
+	admission.SetQueueFill(chatService.asyncHelper.QueueFillRatio)
+
+	// **NEW: Durable outbox (Redis Stream) สำหรับบันทึกข้อความลง DB**
+	outbox := utils.NewMessageOutbox(redis, chatService, cfg.ReliabilityThresholds.DatabaseTimeout)
+	if err := outbox.Start(context.Background()); err != nil {
+		log.Printf("[ChatService] ⚠️ Failed to start message outbox, falling back to in-memory queue: %v", err)
+	} else {
+		chatService.outbox = outbox
+		chatService.asyncHelper.SetOutbox(outbox)
+	}
+
+	// **NEW: สถานะการส่งข้อความสำหรับผู้ส่ง (socket event + REST lookup)**
+	chatService.delivery = utils.NewDeliveryTracker(redis, hub, cfg.AsyncFlow.MessageStatus.TTL, cfg.AsyncFlow.MessageStatus.Enabled)
+	chatService.delivery.Start(context.Background())
+
+	// **NEW: warm cache ของห้องที่ active/กำลังจะเปิด**
+	chatService.warmer = NewCacheWarmer(db, redis, chatService.historyService, cfg.Cache)
+	chatService.warmer.Start(context.Background())
+
+	// **NEW: retention (ลบ unsend เก่า / ย้ายข้อความเก่าไป archive) เริ่มเฉพาะเมื่อ RETENTION_ENABLED**
+	chatService.retention = NewRetentionWorker(db, redis, archive, cfg.Retention)
+	chatService.retention.Start(context.Background())
+
+	// **NEW: export transcript ของห้อง (อ่าน archive เดียวกับ history)**
+	chatService.transcripts = NewTranscriptService(db, redis, archive, cfg.Transcript)
+	chatService.transcripts.Start(context.Background())
+
+	chatService.readiness = chatService.newReadiness()
 
 	chatService.restrictionService = restrictionService.NewRestrictionService(db, chatService.hub, chatService.emitter, chatService.notificationService, kafkaBus)
 
@@ -135,10 +192,22 @@ func (s *ChatService) SaveMessageToDB(ctx context.Context, msg *model.ChatMessag
 		return nil
 	}
 
-	_, err := s.Create(ctx, *msg)
+	err := mongoWriteBreaker().Execute(func() error {
+		_, err := s.Create(ctx, *msg)
+		return err
+	})
+	if err == nil {
+		metrics.MessagesPersisted.Inc(s.determineMessageType(msg))
+	}
 	return err
 }
 
+// mongoWriteBreaker คืน breaker ของการเขียนข้อความลง Mongo
+// (duplicate key ไม่นับเป็น failure ดู circuitbreaker.breakerOptions)
+func mongoWriteBreaker() *circuitbreaker.Breaker {
+	return circuitbreaker.Get(circuitbreaker.MongoWrite)
+}
+
 /* Prevent save empty message to database */
 func (s *ChatService) SaveMessageToCache(ctx context.Context, msg *model.ChatMessage) error {
 	if !isValidChatMessage(msg) {
@@ -200,7 +269,17 @@ func (s *ChatService) SaveMessageBatch(ctx context.Context, msgs []*model.ChatMe
 	}
 
 	// Bulk insert valid messages
-	_, err := s.collection.InsertMany(ctx, validMsgs)
+	err := mongoWriteBreaker().Execute(func() error {
+		_, err := s.collection.InsertMany(ctx, validMsgs)
+		return err
+	})
+	if err == nil {
+		for _, msg := range msgs {
+			if isValidChatMessage(msg) {
+				metrics.MessagesPersisted.Inc(s.determineMessageType(msg))
+			}
+		}
+	}
 	return err
 }
 
@@ -209,32 +288,33 @@ func (s *ChatService) SaveMessageBatchToCache(ctx context.Context, roomID string
 		return nil
 	}
 
-	pipeline := s.redis.Pipeline()
-	key := fmt.Sprintf("chat:room:%s:messages", roomID)
-
+	batch := make([]*model.ChatMessageEnriched, 0, len(msgs))
 	for _, msg := range msgs {
 		if !isValidChatMessage(msg) {
 			log.Printf("[ChatService] Skipping empty message in batch cache for room %s", roomID)
 			continue
 		}
 
-		enriched := model.ChatMessageEnriched{
+		batch = append(batch, &model.ChatMessageEnriched{
 			ChatMessage: *msg,
-		}
-
-		jsonData, err := json.Marshal(enriched)
-		if err != nil {
-			return fmt.Errorf("failed to marshal message: %w", err)
-		}
-
-		score := float64(msg.Timestamp.UnixNano())
-		pipeline.ZAdd(ctx, key, redis.Z{
-			Score:  score,
-			Member: jsonData,
 		})
 	}
 
-	_, err := pipeline.Exec(ctx)
+	return s.cache.SaveMessages(ctx, roomID, batch)
+}
+
+// ClearRoomCache ลบ message cache ของห้อง (ไม่ลบข้อความใน DB)
+func (s *ChatService) ClearRoomCache(ctx context.Context, roomID string) error {
+	err := s.cache.DeleteRoomMessages(ctx, roomID)
+	entry := audit.Entry{
+		Action:     audit.ActionRoomCacheClear,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	audit.Record(ctx, entry)
 	return err
 }
 
@@ -400,6 +480,17 @@ func (s *ChatService) UpdateMessageStatusWithError(messageID primitive.ObjectID,
 	s.updateMessageStatusWithError(messageID, errorMsg, retryCount)
 }
 
+// MarkMessagePersisted แจ้งผู้ส่งว่าข้อความถูกบันทึกแล้ว (outbox ไม่มี record ใน message-status)
+func (s *ChatService) MarkMessagePersisted(messageID primitive.ObjectID) {
+	s.delivery.Persisted(context.Background(), messageID)
+}
+
+// MarkMessageFailed บันทึกว่าข้อความบันทึกไม่สำเร็จถาวร และแจ้งผู้ส่ง
+func (s *ChatService) MarkMessageFailed(messageID primitive.ObjectID, reason string) {
+	s.updateMessageStatus(messageID, "status", "failed")
+	s.delivery.Failed(context.Background(), messageID, reason)
+}
+
 func (s *ChatService) RetrieveMessage(messageID primitive.ObjectID) (*model.ChatMessage, error) {
 	filter := bson.M{"_id": messageID}
 	var message model.ChatMessage
@@ -473,6 +564,11 @@ func (s *ChatService) updateMessageStatus(messageID primitive.ObjectID, field st
 		},
 	}
 
+	// ข้อความถูกบันทึกแล้วจริง (เรียกหลัง save สำเร็จ) แจ้งผู้ส่งได้เลยโดยไม่ต้องถือ lock
+	if field == "saved_to_db" && value == true {
+		s.delivery.Persisted(context.Background(), messageID)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -503,7 +599,11 @@ func (s *ChatService) updateMessageStatusWithError(messageID primitive.ObjectID,
 
 func (s *ChatService) Shutdown() {
 	log.Printf("[ChatService] Starting graceful shutdown...")
-	s.asyncHelper.Shutdown()
+	// flush DB/notification queue ก่อน แล้วจึงหยุด outbox (ข้อความที่ยังไม่ persist ยังอยู่ใน Redis stream)
+	s.asyncHelper.Drain(s.Config.Shutdown.QueueFlushTimeout)
+	if s.outbox != nil {
+		s.outbox.Shutdown()
+	}
 	log.Printf("[ChatService] Graceful shutdown completed")
 }
 
@@ -519,6 +619,48 @@ func (s *ChatService) GetMongo() *mongo.Database {
 	return s.mongo
 }
 
+// newReadiness ลงทะเบียน dependency check สำหรับ /readyz
+// Kafka ไม่ critical: emit อยู่หลัง circuit breaker และ Kafka ล่มทั้ง cluster การถอดทุก pod ออกไม่ช่วยอะไร
+func (s *ChatService) newReadiness() *lifecycle.Readiness {
+	readiness := lifecycle.NewReadiness(2*time.Second, 2*time.Second)
+	readiness.AddCheck("mongodb", true, func(ctx context.Context) error {
+		return s.mongo.Client().Ping(ctx, nil)
+	})
+	readiness.AddCheck("redis", true, func(ctx context.Context) error {
+		return s.redis.Ping(ctx).Err()
+	})
+	readiness.AddCheck("kafka", false, s.kafkaBus.Ping)
+	readiness.AddCheck("worker_queues", true, func(ctx context.Context) error {
+		return s.asyncHelper.CheckQueueSaturation()
+	})
+	return readiness
+}
+
+// GetCacheWarmer ใช้เพิ่มแหล่งของห้องที่กำลังจะเปิด (UpcomingRoomsSource)
+func (s *ChatService) GetCacheWarmer() *CacheWarmer {
+	return s.warmer
+}
+
+// GetMessageArchive คืน archive ของข้อความเก่า (ใช้ร่วมกับงาน export/erasure ข้อมูลผู้ใช้)
+func (s *ChatService) GetMessageArchive() utils.MessageArchive {
+	return s.archive
+}
+
+// GetTranscriptService ใช้โดย transcript controller
+func (s *ChatService) GetTranscriptService() *TranscriptService {
+	return s.transcripts
+}
+
+// GetReadiness คืน readiness checker ของ instance นี้
+func (s *ChatService) GetReadiness() *lifecycle.Readiness {
+	return s.readiness
+}
+
+// GetConfig คืน config ที่ service ใช้อยู่
+func (s *ChatService) GetConfig() *config.Config {
+	return s.Config
+}
+
 func (s *ChatService) GetUserById(ctx context.Context, userID string) (*userModel.User, error) {
 	log.Printf("[DEBUG] GetUserById called for user: %s", userID)
 
@@ -557,13 +699,66 @@ func (s *ChatService) GetChatHistoryByRoom(ctx context.Context, roomID string, l
 	return s.historyService.GetChatHistoryByRoom(ctx, roomID, limit)
 }
 
+// GetRoomMessagesBySeq คืนข้อความตาม seq cursor (ดู HistoryService.GetRoomMessagesBySeq)
+func (s *ChatService) GetRoomMessagesBySeq(ctx context.Context, roomID string, afterSeq, beforeSeq int64, limit int) ([]model.ChatMessageEnriched, error) {
+	return s.historyService.GetRoomMessagesBySeq(ctx, roomID, afterSeq, beforeSeq, limit)
+}
+
+// GetRoomSeq คืน seq ล่าสุดของห้อง
+func (s *ChatService) GetRoomSeq(ctx context.Context, roomID primitive.ObjectID) (int64, error) {
+	return s.sequencer.Current(ctx, roomID)
+}
+
 func (s *ChatService) DeleteRoomMessages(ctx context.Context, roomID string) error {
 	return s.historyService.DeleteRoomMessages(ctx, roomID)
 }
 
 // **NEW: Health check methods for HealthController**
 func (s *ChatService) GetWorkerPoolStatus() map[string]interface{} {
-	return s.asyncHelper.GetWorkerPoolStatus()
+	status := s.asyncHelper.GetWorkerPoolStatus()
+	if s.outbox != nil {
+		status["outbox"] = s.outbox.Stats(context.Background())
+	}
+	return status
+}
+
+// ErrMessageStatusNotFound ไม่มีสถานะของข้อความ หรือผู้ขอไม่ใช่ผู้ส่ง
+var ErrMessageStatusNotFound = errors.New("message status not found")
+
+// GetDeliveryStatus คืนสถานะการส่งข้อความให้ผู้ส่ง
+// record ใน Redis หมดอายุแล้วแต่ข้อความอยู่ใน DB ถือว่า persisted
+func (s *ChatService) GetDeliveryStatus(ctx context.Context, messageID, userID primitive.ObjectID) (*utils.DeliveryStatus, error) {
+	status, err := s.delivery.Get(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if status != nil {
+		if status.SenderID != userID.Hex() {
+			return nil, ErrMessageStatusNotFound
+		}
+		return status, nil
+	}
+
+	msg, err := s.RetrieveMessage(messageID)
+	if err != nil || msg.UserID != userID {
+		return nil, ErrMessageStatusNotFound
+	}
+	return &utils.DeliveryStatus{
+		MessageID: messageID.Hex(),
+		RoomID:    msg.RoomID.Hex(),
+		SenderID:  msg.UserID.Hex(),
+		Status:    utils.DeliveryPersisted,
+		Persisted: true,
+		UpdatedAt: msg.UpdatedAt,
+	}, nil
+}
+
+// GetOutboxMessageStatus คืนสถานะของข้อความจาก outbox (nil ถ้าไม่ได้เปิดใช้ outbox)
+func (s *ChatService) GetOutboxMessageStatus(ctx context.Context, messageID primitive.ObjectID) (*utils.OutboxMessageStatus, error) {
+	if s.outbox == nil {
+		return nil, nil
+	}
+	return s.outbox.Status(ctx, messageID)
 }
 
 // ถ้าเกิด message  สร้างไม่เสร็จ ไป trigger ให้มัน retry 3 รอบ
@@ -3,27 +3,34 @@ package service
 import (
 	"chat/module/chat/model"
 	"chat/module/chat/utils"
+	"chat/pkg/core/metrics"
 	"chat/pkg/database/queries"
 	"context"
 	"fmt"
 	"log"
+	"sort"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type HistoryService struct {
 	*queries.BaseService[model.ChatMessage]
 	cache  *utils.ChatCacheService
-	mongo  *mongo.Database
+	fill    *utils.CacheFill
+	archive utils.MessageArchive
+	mongo   *mongo.Database
 }
 
-func NewHistoryService(db *mongo.Database, cache *utils.ChatCacheService) *HistoryService {
+func NewHistoryService(db *mongo.Database, cache *utils.ChatCacheService, fill *utils.CacheFill, archive utils.MessageArchive) *HistoryService {
 	collection := db.Collection("chat-messages")
 	return &HistoryService{
 		BaseService: queries.NewBaseService[model.ChatMessage](collection),
 		cache:       cache,
+		fill:        fill,
+		archive:     archive,
 		mongo:       db,
 	}
 }
@@ -63,6 +70,20 @@ func (h *HistoryService) GetChatHistoryByRoom(ctx context.Context, roomID string
 
 	log.Printf("[HistoryService] Cache miss for room %s, querying database", roomID)
 
+	// **NEW: loader เดียวต่อห้อง (ข้าม instance ด้วย) คนที่เหลือรอผลหรือรอ cache ถูกเติม แทนการยิง Mongo พร้อมกัน**
+	return utils.FillCache(ctx, h.fill, metrics.CacheChatMessages, fmt.Sprintf("history:%s:%d", roomID, limit),
+		func(ctx context.Context) ([]model.ChatMessageEnriched, bool) {
+			cached, err := h.cache.GetRoomMessages(ctx, roomID, int(limit))
+			return cached, err == nil && len(cached) > 0
+		},
+		func(ctx context.Context) ([]model.ChatMessageEnriched, error) {
+			return h.loadChatHistory(ctx, roomID, limit)
+		},
+	)
+}
+
+// loadChatHistory อ่าน history จาก DB (ใหม่สุดก่อน) แล้วเติม cache
+func (h *HistoryService) loadChatHistory(ctx context.Context, roomID string, limit int64) ([]model.ChatMessageEnriched, error) {
 	// Convert roomID to ObjectID
 	roomObjID, err := primitive.ObjectIDFromHex(roomID)
 	if err != nil {
@@ -90,8 +111,18 @@ func (h *HistoryService) GetChatHistoryByRoom(ctx context.Context, roomID string
 	}
 
 	// **ENHANCED: Convert to enriched messages with complete data**
-	enrichedMessages := make([]model.ChatMessageEnriched, len(result.Data))
-	for i, msg := range result.Data {
+	// **NEW: ห้องที่ข้อความถูกย้ายไป archive แล้ว อ่านส่วนที่ขาดต่อจาก archive**
+	stored := result.Data
+	if len(stored) < int(limit) {
+		query := utils.ArchiveQuery{Limit: int(limit) - len(stored)}
+		if len(stored) > 0 {
+			query.Before = stored[len(stored)-1].Timestamp
+		}
+		stored = append(stored, h.loadArchived(ctx, roomObjID, query)...)
+	}
+
+	enrichedMessages := make([]model.ChatMessageEnriched, len(stored))
+	for i, msg := range stored {
 		enriched := model.ChatMessageEnriched{
 			ChatMessage: msg,
 		}
@@ -131,13 +162,15 @@ func (h *HistoryService) GetChatHistoryByRoom(ctx context.Context, roomID string
 			enrichedMessages[len(enrichedMessages)-1].ChatMessage.Timestamp)
 	}
 
-	// **ENHANCED: Cache the complete enriched messages**
-	for _, enriched := range enrichedMessages {
-		if err := h.cache.SaveMessage(ctx, roomID, &enriched); err != nil {
-			log.Printf("[HistoryService] Failed to cache message %s: %v", enriched.ChatMessage.ID.Hex(), err)
-		} else {
-			log.Printf("[HistoryService] Successfully cached enriched message %s", enriched.ChatMessage.ID.Hex())
-		}
+	// **ENHANCED: Cache the complete enriched messages (pipeline เดียวทั้งชุด)**
+	toCache := make([]*model.ChatMessageEnriched, len(enrichedMessages))
+	for i := range enrichedMessages {
+		toCache[i] = &enrichedMessages[i]
+	}
+	if err := h.cache.SaveMessages(ctx, roomID, toCache); err != nil {
+		log.Printf("[HistoryService] Failed to cache %d messages for room %s: %v", len(toCache), roomID, err)
+	} else {
+		log.Printf("[HistoryService] Successfully cached %d enriched messages for room %s", len(toCache), roomID)
 	}
 
 	log.Printf("[HistoryService] Successfully retrieved %d messages from database for room %s (newest first)", len(enrichedMessages), roomID)
@@ -145,6 +178,124 @@ func (h *HistoryService) GetChatHistoryByRoom(ctx context.Context, roomID string
 }
 
 
+// GetRoomMessagesBySeq ใช้ seq เป็น cursor ของ history (เรียงเก่าไปใหม่เสมอ)
+//   - afterSeq > 0: ข้อความหลัง afterSeq (resync หลัง client ตรวจเจอ gap)
+//   - afterSeq = 0: ข้อความก่อน beforeSeq ที่ใหม่สุด limit รายการ (เลื่อนดูข้อความเก่า)
+//
+// รวมผลจาก DB กับ cache เพราะข้อความล่าสุดอาจยังอยู่ใน outbox และยังไม่ถูกบันทึกลง DB
+// ข้อความที่ไม่มี seq (ก่อนเปิดใช้ seq) จะไม่ถูกคืนจาก cursor นี้
+func (h *HistoryService) GetRoomMessagesBySeq(ctx context.Context, roomID string, afterSeq, beforeSeq int64, limit int) ([]model.ChatMessageEnriched, error) {
+	roomObjID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid room ID: %w", err)
+	}
+	newestFirst := afterSeq <= 0
+
+	seqFilter := bson.M{"$gt": max(afterSeq, 0)}
+	if beforeSeq > 0 {
+		seqFilter["$lt"] = beforeSeq
+	}
+	sortDir := 1
+	if newestFirst {
+		sortDir = -1
+	}
+	cursor, err := h.mongo.Collection("chat-messages").Find(ctx, bson.M{
+		"room_id": roomObjID,
+		"seq":     seqFilter,
+		"$or": []bson.M{
+			{"is_deleted": nil},
+			{"is_deleted": false},
+		},
+	}, options.Find().SetSort(bson.D{{Key: "seq", Value: sortDir}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages by seq: %w", err)
+	}
+	var stored []model.ChatMessage
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode messages by seq: %w", err)
+	}
+
+	// เลื่อนดูข้อความเก่าจนสุด chat-messages แล้ว อ่านต่อจาก archive
+	if newestFirst && len(stored) < limit {
+		query := utils.ArchiveQuery{BeforeSeq: beforeSeq, Limit: limit - len(stored)}
+		if len(stored) > 0 {
+			query.BeforeSeq = stored[len(stored)-1].Seq
+		}
+		for _, msg := range h.loadArchived(ctx, roomObjID, query) {
+			if msg.Seq > 0 {
+				stored = append(stored, msg)
+			}
+		}
+	}
+
+	cached, err := h.cache.GetRoomMessagesBySeq(ctx, roomID, afterSeq, beforeSeq, limit, newestFirst)
+	if err != nil {
+		log.Printf("[HistoryService] Failed to read messages by seq from cache for room %s: %v", roomID, err)
+	}
+
+	merged := mergeMessagesBySeq(cached, stored, limit, newestFirst)
+
+	messages := make([]model.ChatMessageEnriched, 0, len(merged))
+	for _, msg := range merged {
+		if msg.ChatMessage.ReplyToID != nil && msg.ReplyTo == nil {
+			if replyTo, err := h.getReplyToMessageWithUser(ctx, *msg.ChatMessage.ReplyToID); err == nil {
+				msg.ReplyTo = replyTo
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// mergeMessagesBySeq รวมข้อความจาก cache กับ DB (ใช้ message ID กันซ้ำ cache มาก่อนเพราะมีข้อมูล user ครบ)
+// ข้ามข้อความที่ไม่มี seq แล้วเรียงตาม seq เก่าไปใหม่ ตัดเหลือ limit รายการ (newestFirst = เก็บฝั่งใหม่สุด)
+func mergeMessagesBySeq(cached []model.ChatMessageEnriched, stored []model.ChatMessage, limit int, newestFirst bool) []model.ChatMessageEnriched {
+	byID := make(map[primitive.ObjectID]model.ChatMessageEnriched, len(stored)+len(cached))
+	for _, msg := range cached {
+		if msg.ChatMessage.Seq > 0 {
+			byID[msg.ChatMessage.ID] = msg
+		}
+	}
+	for _, msg := range stored {
+		if _, ok := byID[msg.ID]; !ok && msg.Seq > 0 {
+			byID[msg.ID] = model.ChatMessageEnriched{ChatMessage: msg}
+		}
+	}
+
+	merged := make([]model.ChatMessageEnriched, 0, len(byID))
+	for _, msg := range byID {
+		merged = append(merged, msg)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := merged[i].ChatMessage, merged[j].ChatMessage
+		if a.Seq != b.Seq {
+			return a.Seq < b.Seq
+		}
+		return a.ID.Hex() < b.ID.Hex()
+	})
+	if limit > 0 && len(merged) > limit {
+		if newestFirst {
+			merged = merged[len(merged)-limit:]
+		} else {
+			merged = merged[:limit]
+		}
+	}
+	return merged
+}
+
+// loadArchived อ่านข้อความจาก archive (ใหม่ไปเก่า) error ไม่ทำให้ history ล้ม แค่ได้ข้อความน้อยลง
+func (h *HistoryService) loadArchived(ctx context.Context, roomID primitive.ObjectID, query utils.ArchiveQuery) []model.ChatMessage {
+	if h.archive == nil || query.Limit <= 0 {
+		return nil
+	}
+	archived, err := h.archive.Load(ctx, roomID, query)
+	if err != nil {
+		log.Printf("[HistoryService] Failed to read archived messages of room %s: %v", roomID.Hex(), err)
+		return nil
+	}
+	return archived
+}
+
 // getReplyToMessageWithUser gets the reply-to message with user data
 func (h *HistoryService) getReplyToMessageWithUser(ctx context.Context, replyToID primitive.ObjectID) (*model.ChatMessage, error) {
 	// Use simple FindOne without populate to avoid decoding issues
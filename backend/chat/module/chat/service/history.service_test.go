@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+
+	"chat/module/chat/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergeMessagesBySeq(t *testing.T) {
+	ids := make([]primitive.ObjectID, 6)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+	}
+	msg := func(i int, seq int64) model.ChatMessage {
+		return model.ChatMessage{ID: ids[i], Seq: seq}
+	}
+	enriched := func(i int, seq int64, username string) model.ChatMessageEnriched {
+		return model.ChatMessageEnriched{ChatMessage: msg(i, seq), Username: username}
+	}
+
+	tests := []struct {
+		name        string
+		cached      []model.ChatMessageEnriched
+		stored      []model.ChatMessage
+		limit       int
+		newestFirst bool
+		wantIDs     []int
+	}{
+		{
+			name:    "cache and db overlap",
+			cached:  []model.ChatMessageEnriched{enriched(1, 2, "cached"), enriched(2, 3, "cached")},
+			stored:  []model.ChatMessage{msg(0, 1), msg(1, 2)},
+			limit:   10,
+			wantIDs: []int{0, 1, 2},
+		},
+		{
+			name:    "legacy messages without seq are skipped",
+			cached:  []model.ChatMessageEnriched{enriched(0, 0, ""), enriched(1, 0, ""), enriched(2, 5, "")},
+			stored:  []model.ChatMessage{msg(3, 0), msg(4, 4)},
+			limit:   10,
+			wantIDs: []int{4, 2},
+		},
+		{
+			name:    "limit keeps oldest when paging forward",
+			stored:  []model.ChatMessage{msg(0, 1), msg(1, 2), msg(2, 3)},
+			limit:   2,
+			wantIDs: []int{0, 1},
+		},
+		{
+			name:        "limit keeps newest when paging back",
+			stored:      []model.ChatMessage{msg(2, 3), msg(1, 2), msg(0, 1)},
+			limit:       2,
+			newestFirst: true,
+			wantIDs:     []int{1, 2},
+		},
+		{
+			name:    "empty",
+			limit:   10,
+			wantIDs: []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeMessagesBySeq(tt.cached, tt.stored, tt.limit, tt.newestFirst)
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("got %d messages, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, want := range tt.wantIDs {
+				if got[i].ChatMessage.ID != ids[want] {
+					t.Errorf("message %d = seq %d, want message %d", i, got[i].ChatMessage.Seq, want)
+				}
+			}
+		})
+	}
+
+	// ข้อความที่อยู่ทั้งใน cache และ DB ใช้ฉบับจาก cache (มีข้อมูล user แล้ว)
+	got := mergeMessagesBySeq([]model.ChatMessageEnriched{enriched(1, 2, "cached")}, []model.ChatMessage{msg(1, 2)}, 10, false)
+	if len(got) != 1 || got[0].Username != "cached" {
+		t.Errorf("expected cached copy to win, got %+v", got)
+	}
+}
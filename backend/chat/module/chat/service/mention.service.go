@@ -3,6 +3,7 @@ package service
 import (
 	chatModel "chat/module/chat/model"
 	"chat/module/chat/utils"
+	"chat/pkg/core/admission"
 	"chat/pkg/database/queries"
 	"context"
 	"fmt"
@@ -38,6 +39,11 @@ func (s *ChatService) SendMentionMessage(ctx context.Context, userID, roomID pri
 		return nil, fmt.Errorf("user cannot send messages in this room")
 	}
 
+	// **NEW: admission control (mention ไม่ผ่าน SendMessage จึงต้องตรวจเอง)**
+	if err := admission.AllowMessage(); err != nil {
+		return nil, err
+	}
+
 	// Initialize mention parser
 	mentionParser := utils.NewMentionParser(s.mongo)
 	
@@ -127,11 +133,17 @@ func (s *ChatService) SendMentionMessage(ctx context.Context, userID, roomID pri
 
 	log.Printf("[ChatService] Created mention message with %d mentions: %+v", len(mentionInfo), mentionInfo)
 
+	s.sequencer.Assign(ctx, msg)
+	s.delivery.Accepted(ctx, msg)
+
 	// **IMMEDIATE: Broadcast mention message first**
 	if err := s.emitter.EmitMentionMessage(ctx, msg, mentionInfo); err != nil {
 		log.Printf("[ChatService] Failed to emit mention message: %v", err)
+		s.delivery.Failed(ctx, msg.ID, utils.DeliveryReasonBroadcastFailed)
 	} else {
 		log.Printf("[ChatService] ✅ Mention message broadcasted immediately ID=%s", msg.ID.Hex())
+		onlineUsers := s.hub.GetOnlineUsersInRoom(roomID.Hex())
+		s.delivery.Delivered(ctx, msg.ID, countRecipients(onlineUsers, userID.Hex()))
 	}
 
 	// **ASYNC: Save to DB and cache in background**
@@ -141,8 +153,10 @@ func (s *ChatService) SendMentionMessage(ctx context.Context, userID, roomID pri
 		// Save to database (async)
 		if _, err := s.Create(bgCtx, *msg); err != nil {
 			log.Printf("[ChatService] ❌ Failed to save mention message to DB (async): %v", err)
+			s.delivery.Failed(bgCtx, msg.ID, utils.DeliveryReasonPersistFailed)
 		} else {
 			log.Printf("[ChatService] ✅ Mention message saved to DB (async) ID=%s", msg.ID.Hex())
+			s.delivery.Persisted(bgCtx, msg.ID)
 		}
 
 		// Cache the message (async)
@@ -2,13 +2,21 @@ package service
 
 import (
 	"chat/module/chat/model"
+	"chat/module/chat/utils"
+	"chat/pkg/core/admission"
+	"chat/pkg/core/logging"
+	"chat/pkg/core/metrics"
+	"chat/pkg/core/tracing"
 	"context"
 	"fmt"
-	"log"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// chatLog ใช้ใน hot path ของการส่งข้อความ: รายละเอียดต่อข้อความอยู่ที่ debug (เปิดด้วย LOG_MODULE_LEVELS=chatservice=debug)
+var chatLog = logging.Module("chatservice")
+
 // CanUserSendMessages checks if a user can send messages in a room
 func (s *ChatService) CanUserSendMessages(ctx context.Context, userID, roomID primitive.ObjectID) bool {
 	// Check if user is banned or muted
@@ -30,8 +38,15 @@ func (s *ChatService) CanUserViewMessages(ctx context.Context, userID, roomID pr
 // **ENHANCED: SendMessage with robust error handling and phantom prevention**
 
 // Optimize ให้ Support SendMsg ให้ handling phantom message (ข้อความผี = ไม่ได้ save ลงใน DB)
-func (s *ChatService) SendMessage(ctx context.Context, msg *model.ChatMessage, metadata interface{}) error {
-	log.Printf("[ChatService] SendMessage called for room %s by user %s", msg.RoomID.Hex(), msg.UserID.Hex())
+func (s *ChatService) SendMessage(ctx context.Context, msg *model.ChatMessage, metadata interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "chat.send_message",
+		attribute.String("chat.room_id", msg.RoomID.Hex()),
+		attribute.String("chat.user_id", msg.UserID.Hex()),
+	)
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithRoom(logging.WithUser(ctx, msg.UserID.Hex()), msg.RoomID.Hex())
+
+	chatLog.DebugContext(ctx, "SendMessage called")
 	if !isValidChatMessage(msg) {
 		return fmt.Errorf("invalid message: empty or unsupported content")
 	}
@@ -55,26 +70,61 @@ func (s *ChatService) SendMessage(ctx context.Context, msg *model.ChatMessage, m
 		return fmt.Errorf("foreign key validation failed: %w", err)
 	}
 
+	// **NEW: admission control - ปฏิเสธอย่างชัดเจนแทนการรับแล้วทำหายเมื่อ queue ใกล้เต็ม**
+	if err := admission.AllowMessage(); err != nil {
+		chatLog.WarnContext(ctx, "🚦 rejecting message: server busy", "error", err)
+		return err
+	}
+
 	// สร้าง ID ก่อน (เพื่อ tracking)
 	msg.ID = primitive.NewObjectID()
-	log.Printf("[ChatService] Generated message ID: %s", msg.ID.Hex())
+	span.SetAttributes(attribute.String("chat.message_id", msg.ID.Hex()))
+	ctx = logging.WithMessage(ctx, msg.ID.Hex())
+
+	// **NEW: ลำดับต่อห้อง (ต้องได้ก่อน outbox/broadcast เพื่อให้ทุกสำเนาของข้อความมี seq เดียวกัน)**
+	s.sequencer.Assign(ctx, msg)
+	span.SetAttributes(attribute.Int64("chat.seq", msg.Seq))
+	chatLog.DebugContext(ctx, "generated message ID", "seq", msg.Seq)
+
+	// **NEW: แจ้งผู้ส่งว่า server รับข้อความแล้ว (sending)**
+	// ต้องมาก่อน outbox.Append เพราะ outbox อาจบันทึกเสร็จ (persisted) ก่อนที่ Accepted จะสร้าง record
+	s.delivery.Accepted(ctx, msg)
+
+	// **NEW: บันทึกลง durable outbox ก่อน broadcast (สถานะดูได้จาก pending list ของ stream)**
+	outboxEntryID := ""
+	if s.outbox != nil {
+		entryID, err := s.outbox.Append(ctx, msg)
+		if err != nil {
+			chatLog.WarnContext(ctx, "⚠️ failed to append message to outbox, using in-memory queue", "error", err)
+		} else {
+			outboxEntryID = entryID
+		}
+	}
 
-	// สร้าง tracking record ก่อน broadcast
-	if err := s.createMessageStatus(msg.ID, msg.RoomID); err != nil {
-		log.Printf("[ChatService] ⚠️ Failed to create message status tracking: %v", err)
-		// Continue anyway - this is not critical for UX
+	// สร้าง tracking record ก่อน broadcast (เฉพาะกรณีที่ไม่ได้ใช้ outbox)
+	if outboxEntryID == "" {
+		if err := s.createMessageStatus(msg.ID, msg.RoomID); err != nil {
+			chatLog.WarnContext(ctx, "⚠️ failed to create message status tracking", "error", err)
+			// Continue anyway - this is not critical for UX
+		}
 	}
 
 	// BROADCAST: ส่งไป WebSocket ทันที **สำคัญโครตพ่อโครตแม่**
-	log.Printf("[ChatService] 🚀 Broadcasting message ID=%s immediately to WebSocket", msg.ID.Hex())
+	chatLog.DebugContext(ctx, "🚀 broadcasting message to WebSocket")
 	broadcastStart := primitive.NewObjectID().Timestamp()
 
 	// ถ้า error จะต้องลบ message ออกจาก cache ด้วย
 	if err := s.emitter.EmitMessage(ctx, msg, metadata); err != nil {
-		log.Printf("[ChatService] ❌ CRITICAL: Failed to emit message to WebSocket: %v", err)
+		chatLog.ErrorContext(ctx, "❌ CRITICAL: failed to emit message to WebSocket", "error", err)
 
 		// Fallback ถ้า  message ส่งไม่ได้ จะต้องลบ message ออกจาก cache ด้วย
-		s.updateMessageStatusWithError(msg.ID, fmt.Sprintf("broadcast failed: %v", err), 0)
+		if outboxEntryID != "" {
+			// ผู้ส่งได้รับ error แล้ว จึงไม่ควรบันทึกข้อความนี้ลง DB
+			s.outbox.Discard(context.Background(), msg.ID, outboxEntryID)
+		} else {
+			s.updateMessageStatusWithError(msg.ID, fmt.Sprintf("broadcast failed: %v", err), 0)
+		}
+		s.delivery.Failed(ctx, msg.ID, utils.DeliveryReasonBroadcastFailed)
 
 		// This is critical - return error if we can't broadcast
 		return fmt.Errorf("failed to broadcast message: %w", err)
@@ -82,11 +132,16 @@ func (s *ChatService) SendMessage(ctx context.Context, msg *model.ChatMessage, m
 
 	// broadcast สำเร็จ จะต้องลบ message ออกจาก cache ด้วย
 	broadcastDuration := primitive.NewObjectID().Timestamp().Sub(broadcastStart)
-	log.Printf("[ChatService] ✅ WebSocket broadcast successful in %v for message ID=%s",
-		broadcastDuration, msg.ID.Hex())
+	chatLog.DebugContext(ctx, "✅ WebSocket broadcast successful", "duration", broadcastDuration)
+	metrics.MessagesSent.Inc(s.determineMessageType(msg))
+	metrics.ObserveRoomMessage(msg.RoomID.Hex())
+
+	onlineUsers := s.hub.GetOnlineUsersInRoom(msg.RoomID.Hex())
+	s.delivery.Delivered(ctx, msg.ID, countRecipients(onlineUsers, msg.UserID.Hex()))
 
 	// Async ทำงานแบบ pararel ด้วย อยู่ใน Background **สำคัญโครตพ่อโครตแม่**
-	bgCtx := context.Background()
+	// ตัด cancel/deadline ของ request ออกแต่เก็บ trace ไว้ให้ job ใน worker ต่อ span เดียวกันได้
+	bgCtx := context.WithoutCancel(ctx)
 
 	// **ENHANCED: Submit jobs with better error handling**
 	jobsSubmitted := 0 // จำนวน jobs ที่ส่งไป
@@ -95,21 +150,22 @@ func (s *ChatService) SendMessage(ctx context.Context, msg *model.ChatMessage, m
 	// Submit database save job (highest priority for persistence)
 
 	// save ลงใน Database **สำคัญโครตพ่อโครตแม่**
-	if s.SubmitDatabaseJob("save_message", msg, bgCtx) {
+	if outboxEntryID != "" {
+		jobsSubmitted++
+		chatLog.DebugContext(ctx, "✅ DB save queued in outbox", "outboxEntry", outboxEntryID)
+	} else if s.SubmitDatabaseJob("save_message", msg, bgCtx) {
 		jobsSubmitted++ // เพิ้ม job submit
-		log.Printf("[ChatService] ✅ DB save job submitted for message %s", msg.ID.Hex())
+		chatLog.DebugContext(ctx, "✅ DB save job submitted")
 	} else {
-		log.Printf("[ChatService] ⚠️ CRITICAL: DB save job queue full for message %s", msg.ID.Hex())
+		chatLog.WarnContext(ctx, "⚠️ CRITICAL: DB save job queue full, saving directly")
 
 		// Fallback กลับมา ถ้า ส่งไม่ได้ จะต้องลบ message ออกจาก cache ด้วย
 		go func() {
 			if _, err := s.Create(bgCtx, *msg); err != nil {
-				log.Printf("[ChatService] ❌ FALLBACK: Direct DB save failed for message %s: %v",
-					msg.ID.Hex(), err)
+				chatLog.ErrorContext(bgCtx, "❌ FALLBACK: direct DB save failed", "error", err)
 				s.updateMessageStatusWithError(msg.ID, "fallback db save failed", 0)
 			} else {
-				log.Printf("[ChatService] ✅ FALLBACK: Direct DB save successful for message %s",
-					msg.ID.Hex())
+				chatLog.InfoContext(bgCtx, "✅ FALLBACK: direct DB save successful")
 				s.updateMessageStatus(msg.ID, "saved_to_db", true)
 			}
 		}()
@@ -118,35 +174,42 @@ func (s *ChatService) SendMessage(ctx context.Context, msg *model.ChatMessage, m
 	// Save บวใน cache ด้วย (สำคัญ) ถ้าเกิดข้อผิดพลาดจะต้องลบ message ออกจาก cache ด้วย
 	if s.SubmitDatabaseJob("cache_message", msg, bgCtx) {
 		jobsSubmitted++
-		log.Printf("[ChatService] ✅ Cache job submitted for message %s", msg.ID.Hex())
+		chatLog.DebugContext(ctx, "✅ cache job submitted")
 	} else {
-		log.Printf("[ChatService] ⚠️ Cache job queue full for message %s", msg.ID.Hex())
+		chatLog.WarnContext(ctx, "⚠️ cache job queue full")
 	}
 
 	//  ส่งการแจ้งเตือนไปยังผู้ใช้งานที่ออนไลน์ในห้อง (สำคัญกลาง)
-	onlineUsers := s.hub.GetOnlineUsersInRoom(msg.RoomID.Hex())
+	// notification job ส่ง push ให้เฉพาะคนที่ offline จึงไม่ถูก shed ตอน load สูง (shed เฉพาะ presence/typing)
 	if s.SubmitNotificationJob(msg, onlineUsers, bgCtx) {
 		jobsSubmitted++
-		log.Printf("[ChatService] ✅ Notification job submitted for message %s (%d online users)",
-			msg.ID.Hex(), len(onlineUsers))
+		chatLog.DebugContext(ctx, "✅ notification job submitted", "onlineUsers", len(onlineUsers))
 	} else {
-		log.Printf("[ChatService] ⚠️ Notification job queue full for message %s", msg.ID.Hex())
+		chatLog.WarnContext(ctx, "⚠️ notification job queue full")
 	}
 
 	// ตรวจสอบว่า message ส่งเสร็จจริงไหม ถ้าไม่ได้ส่งเสร็จจริงไหม จะต้องลบ message ออกจาก cache ด้วย
 	successRate := float64(jobsSubmitted) / float64(totalJobs) * 100
-	log.Printf("[ChatService] 📊 Message %s processing: Broadcast=✅, Jobs=%d/%d (%.1f%% success)",
-		msg.ID.Hex(), jobsSubmitted, totalJobs, successRate)
+	chatLog.DebugContext(ctx, "📊 message processing summary", "jobsSubmitted", jobsSubmitted, "totalJobs", totalJobs, "successRate", successRate)
 
 	// ตรวจสอบว่า rate ต่ำกว่่า 66 ไหม เตือนถ้า success rate ต่ำ
 	if successRate < 66.0 { // น้อยกว่า 2/3 jobs submitted
-		log.Printf("[ChatService] ⚠️ WARNING: Low job submission rate %.1f%% for message %s - system may be under high load",
-			successRate, msg.ID.Hex())
+		chatLog.WarnContext(ctx, "⚠️ low job submission rate - system may be under high load", "successRate", successRate)
 	}
 
 	// **SUCCESS: Message ส่งสำเร็จ (broadcast แล้ว, jobs queued)**
-	log.Printf("[ChatService] ✅ Message %s processed successfully - UX immediate, background jobs queued",
-		msg.ID.Hex())
+	chatLog.DebugContext(ctx, "✅ message processed - broadcast done, background jobs queued")
 
 	return nil
 }
+
+// countRecipients นับสมาชิกที่ออนไลน์ในห้องโดยไม่นับผู้ส่ง
+func countRecipients(onlineUsers []string, senderID string) int {
+	count := 0
+	for _, userID := range onlineUsers {
+		if userID != senderID {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"chat/module/chat/model"
+	"chat/module/chat/utils"
+	settingsModel "chat/module/settings/model"
+	settingsService "chat/module/settings/service"
+	"chat/pkg/config"
+	"chat/pkg/core/metrics"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Message retention**
+// ทำตามกฎ retention ของแต่ละประเภทห้องใน runtime settings:
+//   - ลบถาวรข้อความที่ unsend ไปแล้วนานเกินกำหนด (backup จาก UnsendMessage)
+//   - ย้ายข้อความเก่า หรือทั้งห้องที่ไม่มีความเคลื่อนไหว ไปยัง archive (ยังอ่านผ่าน history API ได้)
+//     ข้อความที่ unsend ไม่ถูกย้าย อยู่ใน chat-messages จนถึงกำหนด purge_unsent
+//
+// ทุกการลบ/ย้ายบันทึกใน retention-audit และมี lock ต่อรอบให้รันแค่ instance เดียว
+const (
+	RetentionAuditCollection = "retention-audit"
+	retentionLockKey         = "chat:retention:lock"
+
+	RetentionActionPurgeUnsent     = "purge_unsent"
+	RetentionActionArchiveOld      = "archive_old"
+	RetentionActionArchiveInactive = "archive_inactive"
+)
+
+type (
+	// RetentionAudit คือบันทึกการลบ/ย้ายข้อความของห้องหนึ่งในรอบหนึ่ง
+	RetentionAudit struct {
+		ID            primitive.ObjectID `bson:"_id" json:"id"`
+		RunID         string             `bson:"run_id" json:"runId"`
+		RoomID        primitive.ObjectID `bson:"room_id" json:"roomId"`
+		RoomType      string             `bson:"room_type" json:"roomType"`
+		Action        string             `bson:"action" json:"action"`
+		Cutoff        time.Time          `bson:"cutoff" json:"cutoff"`
+		Count         int64              `bson:"count" json:"count"`
+		ArchiveTarget string             `bson:"archive_target,omitempty" json:"archiveTarget,omitempty"`
+		Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+		StartedAt     time.Time          `bson:"started_at" json:"startedAt"`
+		FinishedAt    time.Time          `bson:"finished_at" json:"finishedAt"`
+	}
+
+	RetentionWorker struct {
+		messages *mongo.Collection
+		rooms    *mongo.Collection
+		audits   *mongo.Collection
+		redis    *redis.Client
+		cache    *utils.ChatCacheService
+		archive  utils.MessageArchive
+		cfg      config.RetentionConfig
+	}
+)
+
+func NewRetentionWorker(db *mongo.Database, redisClient *redis.Client, archive utils.MessageArchive, cfg config.RetentionConfig) *RetentionWorker {
+	return &RetentionWorker{
+		messages: db.Collection("chat-messages"),
+		rooms:    db.Collection("rooms"),
+		audits:   db.Collection(RetentionAuditCollection),
+		redis:    redisClient,
+		cache:    utils.NewChatCacheService(redisClient),
+		archive:  archive,
+		cfg:      cfg,
+	}
+}
+
+// Start รันหนึ่งรอบทันทีแล้ววนทุก RETENTION_INTERVAL จน ctx ถูก cancel (ไม่ทำอะไรถ้าปิดอยู่)
+func (w *RetentionWorker) Start(ctx context.Context) {
+	if !w.cfg.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(ctx); err != nil {
+				log.Printf("[Retention] ⚠️ Retention run failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce ทำตามกฎ retention หนึ่งรอบ (ข้ามถ้า instance อื่นกำลังรันอยู่)
+func (w *RetentionWorker) RunOnce(ctx context.Context) error {
+	acquired, err := w.redis.SetNX(ctx, retentionLockKey, "1", w.cfg.Interval).Result()
+	if err != nil {
+		return fmt.Errorf("failed to acquire retention lock: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+
+	runID := uuid.NewString()
+	settings := settingsService.Current()
+	for roomType, policy := range settings.Retention {
+		if policy == (settingsModel.RetentionPolicy{}) {
+			continue
+		}
+
+		roomIDs, err := w.roomsOfType(ctx, roomType)
+		if err != nil {
+			return err
+		}
+		for _, roomID := range roomIDs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.applyPolicy(ctx, runID, roomID, roomType, policy)
+		}
+	}
+	return nil
+}
+
+func (w *RetentionWorker) roomsOfType(ctx context.Context, roomType string) ([]primitive.ObjectID, error) {
+	cursor, err := w.rooms.Find(ctx, bson.M{"type": roomType}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s rooms: %w", roomType, err)
+	}
+	var rooms []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, fmt.Errorf("failed to decode %s rooms: %w", roomType, err)
+	}
+	ids := make([]primitive.ObjectID, len(rooms))
+	for i, room := range rooms {
+		ids[i] = room.ID
+	}
+	return ids, nil
+}
+
+// applyPolicy ทำกฎของห้องเดียว (ห้องที่ inactive ถูกย้ายทั้งห้อง จึงไม่ต้องดู ArchiveAfterDays ต่อ)
+func (w *RetentionWorker) applyPolicy(ctx context.Context, runID string, roomID primitive.ObjectID, roomType string, policy settingsModel.RetentionPolicy) {
+	now := time.Now()
+
+	if policy.PurgeUnsentAfterDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.PurgeUnsentAfterDays)
+		audit := w.newAudit(runID, roomID, roomType, RetentionActionPurgeUnsent, cutoff)
+		result, err := w.messages.DeleteMany(ctx, bson.M{
+			"room_id":    roomID,
+			"is_deleted": true,
+			"deleted_at": bson.M{"$lt": cutoff},
+		})
+		if result != nil {
+			audit.Count = result.DeletedCount
+		}
+		metrics.RetentionMessages.Add(float64(audit.Count), "purged")
+		w.recordAudit(ctx, audit, err)
+	}
+
+	if policy.ArchiveInactiveAfterDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.ArchiveInactiveAfterDays)
+		inactive, err := w.inactiveSince(ctx, roomID, cutoff)
+		if err != nil {
+			log.Printf("[Retention] ⚠️ Failed to check activity of room %s: %v", roomID.Hex(), err)
+		} else if inactive {
+			audit := w.newAudit(runID, roomID, roomType, RetentionActionArchiveInactive, cutoff)
+			audit.Count, err = w.archiveMessages(ctx, roomID, visibleMessages(bson.M{"room_id": roomID}))
+			w.recordAudit(ctx, audit, err)
+			return
+		}
+	}
+
+	if policy.ArchiveAfterDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.ArchiveAfterDays)
+		audit := w.newAudit(runID, roomID, roomType, RetentionActionArchiveOld, cutoff)
+		var err error
+		audit.Count, err = w.archiveMessages(ctx, roomID, visibleMessages(bson.M{"room_id": roomID, "timestamp": bson.M{"$lt": cutoff}}))
+		w.recordAudit(ctx, audit, err)
+	}
+}
+
+// inactiveSince ตรวจว่าข้อความล่าสุดของห้องเก่ากว่า cutoff (ห้องที่ไม่มีข้อความเหลือไม่นับ ข้อความที่ unsend ไม่นับเป็นความเคลื่อนไหว)
+func (w *RetentionWorker) inactiveSince(ctx context.Context, roomID primitive.ObjectID, cutoff time.Time) (bool, error) {
+	var latest model.ChatMessage
+	err := w.messages.FindOne(ctx, visibleMessages(bson.M{"room_id": roomID}),
+		options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetProjection(bson.M{"timestamp": 1}),
+	).Decode(&latest)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return latest.Timestamp.Before(cutoff), nil
+}
+
+// visibleMessages เพิ่มเงื่อนไขตัดข้อความที่ unsend ออก
+// ข้อความที่ unsend ไม่ถูกย้ายไป archive เพื่อให้ purge_unsent ลบจาก chat-messages ได้ครบ
+func visibleMessages(filter bson.M) bson.M {
+	filter["$or"] = []bson.M{
+		{"is_deleted": nil},
+		{"is_deleted": false},
+	}
+	return filter
+}
+
+// archiveMessages ย้ายข้อความที่ตรง filter ไป archive ทีละ batch (เขียน archive ให้สำเร็จก่อนลบเสมอ)
+func (w *RetentionWorker) archiveMessages(ctx context.Context, roomID primitive.ObjectID, filter bson.M) (int64, error) {
+	var moved int64
+	for {
+		cursor, err := w.messages.Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetLimit(int64(w.cfg.BatchSize)))
+		if err != nil {
+			return moved, err
+		}
+		var batch []model.ChatMessage
+		if err := cursor.All(ctx, &batch); err != nil {
+			return moved, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := w.archive.Store(ctx, roomID, batch); err != nil {
+			return moved, err
+		}
+		ids := make([]primitive.ObjectID, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+		}
+		result, err := w.messages.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return moved, err
+		}
+		moved += result.DeletedCount
+		metrics.RetentionMessages.Add(float64(result.DeletedCount), "archived")
+
+		if len(batch) < w.cfg.BatchSize {
+			break
+		}
+	}
+
+	// ข้อความที่ย้ายแล้วอาจยังอยู่ใน cache (unsend/claim จะหาใน chat-messages ไม่เจอ) ให้ history โหลดใหม่
+	if moved > 0 {
+		if err := w.cache.DeleteRoomMessages(ctx, roomID.Hex()); err != nil {
+			log.Printf("[Retention] ⚠️ Failed to clear cache of archived room %s: %v", roomID.Hex(), err)
+		}
+	}
+	return moved, nil
+}
+
+func (w *RetentionWorker) newAudit(runID string, roomID primitive.ObjectID, roomType, action string, cutoff time.Time) *RetentionAudit {
+	audit := &RetentionAudit{
+		ID:        primitive.NewObjectID(),
+		RunID:     runID,
+		RoomID:    roomID,
+		RoomType:  roomType,
+		Action:    action,
+		Cutoff:    cutoff,
+		StartedAt: time.Now(),
+	}
+	if action != RetentionActionPurgeUnsent {
+		audit.ArchiveTarget = w.archive.Target()
+	}
+	return audit
+}
+
+// recordAudit บันทึกเฉพาะรอบที่มีการลบ/ย้ายจริง หรือมี error
+func (w *RetentionWorker) recordAudit(ctx context.Context, audit *RetentionAudit, err error) {
+	if err != nil {
+		audit.Error = err.Error()
+		log.Printf("[Retention] ⚠️ %s failed for room %s after %d messages: %v", audit.Action, audit.RoomID.Hex(), audit.Count, err)
+	}
+	if audit.Count == 0 && err == nil {
+		return
+	}
+	audit.FinishedAt = time.Now()
+
+	if _, insertErr := w.audits.InsertOne(ctx, audit); insertErr != nil {
+		log.Printf("[Retention] ⚠️ Failed to record audit of %s for room %s: %v", audit.Action, audit.RoomID.Hex(), insertErr)
+		return
+	}
+	if err == nil {
+		log.Printf("[Retention] 🧹 %s: %d messages of room %s (cutoff %s)", audit.Action, audit.Count, audit.RoomID.Hex(), audit.Cutoff.Format(time.RFC3339))
+	}
+}
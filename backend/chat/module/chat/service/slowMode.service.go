@@ -0,0 +1,56 @@
+package service
+
+import (
+	"chat/module/chat/utils"
+	roomModel "chat/module/room/room/model"
+	"chat/pkg/core/metrics"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: Slow mode**
+// ตรวจระยะห่างระหว่างข้อความของ user ในห้องที่เปิด slow mode ใช้ร่วมกันทั้ง WebSocket, sticker และ upload
+// cooldown เก็บใน Redis จึงมีผลข้ามทุก instance
+
+var slowModeRejections = metrics.NewCounterVec(
+	"chat_slow_mode_rejections_total",
+	"Messages rejected because the sender is still in the room's slow-mode cooldown.",
+	"source",
+)
+
+// CheckSlowMode คืน false พร้อมเวลาที่ต้องรอถ้า user ยังส่งไม่ได้ (source ใช้เป็น label ของ metric เช่น ws, sticker, upload)
+// การผ่านแต่ละครั้งเริ่ม cooldown ใหม่ จึงควรเรียกหลังตรวจเงื่อนไขอื่นครบแล้ว
+func (s *ChatService) CheckSlowMode(ctx context.Context, roomID, userID primitive.ObjectID, source string) (time.Duration, bool) {
+	room, err := s.slowModeRoom(ctx, roomID)
+	if err != nil || room.SlowMode == nil {
+		return 0, true
+	}
+	// owner/moderator ของห้องไม่ถูกจำกัด
+	if room.CanModerate(userID) {
+		return 0, true
+	}
+
+	role := ""
+	if info := utils.GetUserInfoResolver(s.mongo, s.redis).ResolveUser(ctx, userID); info.Role != nil {
+		role = info.Role.Name
+	}
+	if room.SlowMode.IsExempt(role) {
+		return 0, true
+	}
+
+	remaining, ok := utils.TakeSlowModeSlot(ctx, s.redis, roomID.Hex(), userID.Hex(), room.SlowMode.Interval())
+	if !ok {
+		slowModeRejections.Inc(source)
+	}
+	return remaining, ok
+}
+
+// slowModeRoom อ่านห้องจาก room cache ก่อน (ถูกเรียกทุกข้อความ) แล้วค่อย fallback ไป Mongo
+func (s *ChatService) slowModeRoom(ctx context.Context, roomID primitive.ObjectID) (*roomModel.Room, error) {
+	if room, err := s.roomCache.GetRoom(ctx, roomID.Hex()); err == nil && room != nil {
+		return room, nil
+	}
+	return s.getFullRoomById(ctx, roomID)
+}
@@ -0,0 +1,578 @@
+package service
+
+import (
+	"chat/module/chat/model"
+	"chat/module/chat/utils"
+	roomModel "chat/module/room/room/model"
+	stickerModel "chat/module/sticker/model"
+	"chat/pkg/config"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Room transcript export**
+// export ประวัติทั้งห้อง (รวมข้อความที่ถูกย้ายไป archive) เป็น JSON / CSV / HTML ทีละ batch
+// ห้องเล็ก stream กลับใน response ทันที ห้องใหญ่สร้าง job เขียนไฟล์ลง TRANSCRIPT_EXPORT_DIR แล้วให้ดาวน์โหลดภายหลัง
+const (
+	TranscriptExportCollection = "transcript-exports"
+	transcriptBatchSize        = 500
+	transcriptJanitorInterval  = time.Hour
+	// job ที่ไม่ได้ heartbeat นานเกิน transcriptStaleAfter ถือว่า instance ที่รันอยู่ตายไปแล้ว
+	transcriptHeartbeatInterval = 30 * time.Second
+	transcriptStaleAfter        = 4 * transcriptHeartbeatInterval
+
+	TranscriptJobPending = "pending"
+	TranscriptJobRunning = "running"
+	TranscriptJobDone    = "done"
+	TranscriptJobFailed  = "failed"
+)
+
+var (
+	ErrTranscriptRoomNotFound = errors.New("room not found")
+	ErrTranscriptJobNotFound  = errors.New("transcript export not found")
+)
+
+type (
+	// TranscriptOptions เงื่อนไขการ export (From รวม, To ไม่รวม)
+	TranscriptOptions struct {
+		Format        string     `bson:"format" json:"format"`
+		From          *time.Time `bson:"from,omitempty" json:"from,omitempty"`
+		To            *time.Time `bson:"to,omitempty" json:"to,omitempty"`
+		IncludeUnsent bool       `bson:"include_unsent" json:"includeUnsent"`
+		RequestedBy   string     `bson:"requested_by" json:"requestedBy"`
+	}
+
+	// TranscriptJob สถานะของการ export แบบ async
+	TranscriptJob struct {
+		ID                primitive.ObjectID `bson:"_id" json:"id"`
+		RoomID            primitive.ObjectID `bson:"room_id" json:"roomId"`
+		TranscriptOptions `bson:",inline"`
+		Status            string     `bson:"status" json:"status"`
+		MessageCount      int        `bson:"message_count" json:"messageCount"`
+		FileName          string     `bson:"file_name,omitempty" json:"fileName,omitempty"`
+		Size              int64      `bson:"size,omitempty" json:"size,omitempty"`
+		Error             string     `bson:"error,omitempty" json:"error,omitempty"`
+		CreatedAt         time.Time  `bson:"created_at" json:"createdAt"`
+		StartedAt         *time.Time `bson:"started_at,omitempty" json:"startedAt,omitempty"`
+		FinishedAt        *time.Time `bson:"finished_at,omitempty" json:"finishedAt,omitempty"`
+		ExpiresAt         time.Time  `bson:"expires_at" json:"expiresAt"`
+		HeartbeatAt       time.Time  `bson:"heartbeat_at" json:"-"`
+	}
+
+	TranscriptService struct {
+		messages *mongo.Collection
+		rooms    *mongo.Collection
+		stickers *mongo.Collection
+		jobs     *mongo.Collection
+		users    *utils.UserInfoResolver
+		archive  utils.MessageArchive
+		cfg      config.TranscriptConfig
+		slots    chan struct{}
+	}
+)
+
+func NewTranscriptService(db *mongo.Database, redisClient *redis.Client, archive utils.MessageArchive, cfg config.TranscriptConfig) *TranscriptService {
+	return &TranscriptService{
+		messages: db.Collection("chat-messages"),
+		rooms:    db.Collection("rooms"),
+		stickers: db.Collection("stickers"),
+		jobs:     db.Collection(TranscriptExportCollection),
+		users:    utils.GetUserInfoResolver(db, redisClient),
+		archive:  archive,
+		cfg:      cfg,
+		slots:    make(chan struct{}, cfg.MaxConcurrent),
+	}
+}
+
+// Start เริ่ม janitor ที่ลบไฟล์และ job ที่หมดอายุ
+// job ที่ค้าง pending/running จาก instance ที่ restart ไป (ไม่มี heartbeat) ถูกปิดเป็น failed ทันทีและทุกรอบ janitor
+func (s *TranscriptService) Start(ctx context.Context) {
+	s.failStaleJobs(ctx)
+	go func() {
+		ticker := time.NewTicker(transcriptJanitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.failStaleJobs(ctx)
+				s.removeExpiredJobs(ctx)
+			}
+		}
+	}()
+}
+
+// ShouldExportAsync ตรวจว่าห้องมีข้อความเกิน TRANSCRIPT_ASYNC_THRESHOLD หรือไม่ (รวมข้อความใน archive)
+func (s *TranscriptService) ShouldExportAsync(ctx context.Context, roomID primitive.ObjectID, opts TranscriptOptions) (bool, error) {
+	if err := s.checkRoom(ctx, roomID); err != nil {
+		return false, err
+	}
+	limit := int64(s.cfg.AsyncThreshold) + 1
+	count, err := s.messages.CountDocuments(ctx, s.messageFilter(roomID, opts), options.Count().SetLimit(limit))
+	if err != nil {
+		return false, fmt.Errorf("failed to count messages: %w", err)
+	}
+	if count < limit {
+		archived, err := s.archive.Count(ctx, roomID, archiveQuery(opts), limit-count)
+		if err != nil {
+			return false, err
+		}
+		count += archived
+	}
+	return count > int64(s.cfg.AsyncThreshold), nil
+}
+
+// Export เขียน transcript ของห้องลง writer คืนจำนวนข้อความที่เขียน
+func (s *TranscriptService) Export(ctx context.Context, w utils.TranscriptWriter, roomID primitive.ObjectID, opts TranscriptOptions) (int, error) {
+	var room roomModel.Room
+	if err := s.rooms.FindOne(ctx, bson.M{"_id": roomID}).Decode(&room); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, ErrTranscriptRoomNotFound
+		}
+		return 0, fmt.Errorf("failed to load room: %w", err)
+	}
+
+	roomName := room.Name.En
+	if roomName == "" {
+		roomName = room.Name.Th
+	}
+	if err := w.Begin(utils.TranscriptHeader{
+		RoomID:        roomID.Hex(),
+		RoomName:      roomName,
+		RoomType:      room.Type,
+		From:          opts.From,
+		To:            opts.To,
+		IncludeUnsent: opts.IncludeUnsent,
+		ExportedAt:    time.Now(),
+		ExportedBy:    opts.RequestedBy,
+	}); err != nil {
+		return 0, err
+	}
+
+	// 1. ข้อความใน archive (เก่ากว่าข้อความใน chat-messages เสมอ)
+	// retention ไม่ย้ายข้อความที่ unsend แล้ว แต่ archive ที่เขียนก่อนหน้านั้นอาจมี จึงกรองตาม IncludeUnsent เหมือน chat-messages
+	count := 0
+	archivedIDs := make(map[primitive.ObjectID]bool)
+	err := s.archive.Stream(ctx, roomID, archiveQuery(opts), transcriptBatchSize, func(batch []model.ChatMessage) error {
+		for i := range batch {
+			archivedIDs[batch[i].ID] = true
+		}
+		n, err := s.writeBatch(ctx, w, batch, true)
+		count += n
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+
+	// 2. ข้อความใน chat-messages เรียงตามเวลา
+	cursor, err := s.messages.Find(ctx, s.messageFilter(roomID, opts),
+		options.Find().
+			SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "seq", Value: 1}}).
+			SetBatchSize(transcriptBatchSize))
+	if err != nil {
+		return count, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	batch := make([]model.ChatMessage, 0, transcriptBatchSize)
+	for cursor.Next(ctx) {
+		var msg model.ChatMessage
+		if err := cursor.Decode(&msg); err != nil {
+			log.Printf("[Transcript] ⚠️ Failed to decode message in room %s: %v", roomID.Hex(), err)
+			continue
+		}
+		// worker retention ล้มหลังเขียน archive แต่ก่อนลบ ข้อความจะอยู่ทั้งสองที่
+		if archivedIDs[msg.ID] {
+			continue
+		}
+		batch = append(batch, msg)
+		if len(batch) == transcriptBatchSize {
+			n, err := s.writeBatch(ctx, w, batch, false)
+			count += n
+			if err != nil {
+				return count, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, fmt.Errorf("failed to read messages: %w", err)
+	}
+	n, err := s.writeBatch(ctx, w, batch, false)
+	count += n
+	if err != nil {
+		return count, err
+	}
+
+	return count, w.End(count)
+}
+
+// StartExportJob สร้าง job แล้ว export ลงไฟล์ใน background
+func (s *TranscriptService) StartExportJob(ctx context.Context, roomID primitive.ObjectID, opts TranscriptOptions) (*TranscriptJob, error) {
+	if err := s.checkRoom(ctx, roomID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &TranscriptJob{
+		ID:                primitive.NewObjectID(),
+		RoomID:            roomID,
+		TranscriptOptions: opts,
+		Status:            TranscriptJobPending,
+		CreatedAt:         now,
+		HeartbeatAt:       now,
+		ExpiresAt:         now.Add(s.cfg.ExportTTL),
+	}
+	if _, err := s.jobs.InsertOne(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	go s.runJob(*job)
+	return job, nil
+}
+
+// GetJob คืนสถานะของ job
+func (s *TranscriptService) GetJob(ctx context.Context, jobID primitive.ObjectID) (*TranscriptJob, error) {
+	var job TranscriptJob
+	if err := s.jobs.FindOne(ctx, bson.M{"_id": jobID}).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrTranscriptJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// JobFilePath คืน path ของไฟล์ที่ export เสร็จแล้ว
+func (s *TranscriptService) JobFilePath(job *TranscriptJob) string {
+	return filepath.Join(s.cfg.ExportDir, job.FileName)
+}
+
+func (s *TranscriptService) runJob(job TranscriptJob) {
+	ctx := context.Background()
+	stopHeartbeat := s.heartbeat(job.ID)
+	defer stopHeartbeat()
+
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	startedAt := time.Now()
+	s.updateJob(ctx, job.ID, bson.M{"status": TranscriptJobRunning, "started_at": startedAt})
+	log.Printf("[Transcript] 📝 Exporting room %s as %s (job %s)", job.RoomID.Hex(), job.Format, job.ID.Hex())
+
+	fileName := fmt.Sprintf("%s_%s.%s", job.RoomID.Hex(), job.ID.Hex(), job.Format)
+	count, size, err := s.exportToFile(ctx, filepath.Join(s.cfg.ExportDir, fileName), job)
+	finishedAt := time.Now()
+	if err != nil {
+		log.Printf("[Transcript] ❌ Export job %s failed: %v", job.ID.Hex(), err)
+		s.updateJob(ctx, job.ID, bson.M{"status": TranscriptJobFailed, "error": err.Error(), "finished_at": finishedAt})
+		return
+	}
+
+	s.updateJob(ctx, job.ID, bson.M{
+		"status":        TranscriptJobDone,
+		"message_count": count,
+		"file_name":     fileName,
+		"size":          size,
+		"finished_at":   finishedAt,
+	})
+	log.Printf("[Transcript] ✅ Exported %d messages of room %s in %v (job %s)", count, job.RoomID.Hex(), finishedAt.Sub(startedAt), job.ID.Hex())
+}
+
+// exportToFile เขียนลงไฟล์ชั่วคราวก่อนแล้วค่อย rename (ไม่มีใครดาวน์โหลดไฟล์ที่เขียนไม่ครบ)
+func (s *TranscriptService) exportToFile(ctx context.Context, path string, job TranscriptJob) (int, int64, error) {
+	if err := os.MkdirAll(s.cfg.ExportDir, 0o750); err != nil {
+		return 0, 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	count, err := s.Export(ctx, utils.NewTranscriptWriter(job.Format, file), job.RoomID, job.TranscriptOptions)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return count, 0, err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return count, 0, fmt.Errorf("failed to finalize export file: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return count, 0, err
+	}
+	return count, info.Size(), nil
+}
+
+func (s *TranscriptService) checkRoom(ctx context.Context, roomID primitive.ObjectID) error {
+	err := s.rooms.FindOne(ctx, bson.M{"_id": roomID}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err == mongo.ErrNoDocuments {
+		return ErrTranscriptRoomNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load room: %w", err)
+	}
+	return nil
+}
+
+func (s *TranscriptService) updateJob(ctx context.Context, jobID primitive.ObjectID, set bson.M) {
+	if _, err := s.jobs.UpdateByID(ctx, jobID, bson.M{"$set": set}); err != nil {
+		log.Printf("[Transcript] ⚠️ Failed to update export job %s: %v", jobID.Hex(), err)
+	}
+}
+
+// heartbeat บอกว่า job ยังมี instance ดูแลอยู่ (รวมช่วงที่รอ slot) คืนฟังก์ชันหยุด
+func (s *TranscriptService) heartbeat(jobID primitive.ObjectID) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(transcriptHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				s.updateJob(context.Background(), jobID, bson.M{"heartbeat_at": now})
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// failStaleJobs ปิด job pending/running ที่ไม่มี heartbeat แล้ว (instance ที่รันอยู่ restart หรือตาย)
+func (s *TranscriptService) failStaleJobs(ctx context.Context) {
+	now := time.Now()
+	result, err := s.jobs.UpdateMany(ctx,
+		bson.M{
+			"status": bson.M{"$in": bson.A{TranscriptJobPending, TranscriptJobRunning}},
+			"$or": bson.A{
+				bson.M{"heartbeat_at": bson.M{"$lt": now.Add(-transcriptStaleAfter)}},
+				bson.M{"heartbeat_at": bson.M{"$exists": false}, "created_at": bson.M{"$lt": now.Add(-transcriptStaleAfter)}},
+			},
+		},
+		bson.M{"$set": bson.M{
+			"status":      TranscriptJobFailed,
+			"error":       "export was interrupted by a service restart",
+			"finished_at": now,
+		}})
+	if err != nil {
+		log.Printf("[Transcript] ⚠️ Failed to close interrupted export jobs: %v", err)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("[Transcript] ⚠️ Marked %d interrupted export jobs as failed", result.ModifiedCount)
+	}
+}
+
+func (s *TranscriptService) removeExpiredJobs(ctx context.Context) {
+	cursor, err := s.jobs.Find(ctx, bson.M{"expires_at": bson.M{"$lt": time.Now()}})
+	if err != nil {
+		log.Printf("[Transcript] ⚠️ Failed to list expired export jobs: %v", err)
+		return
+	}
+	var jobs []TranscriptJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		log.Printf("[Transcript] ⚠️ Failed to decode expired export jobs: %v", err)
+		return
+	}
+
+	for i := range jobs {
+		if jobs[i].FileName != "" {
+			if err := os.Remove(s.JobFilePath(&jobs[i])); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("[Transcript] ⚠️ Failed to remove export file %s: %v", jobs[i].FileName, err)
+				continue
+			}
+		}
+		if _, err := s.jobs.DeleteOne(ctx, bson.M{"_id": jobs[i].ID}); err != nil {
+			log.Printf("[Transcript] ⚠️ Failed to delete export job %s: %v", jobs[i].ID.Hex(), err)
+		}
+	}
+}
+
+func (s *TranscriptService) messageFilter(roomID primitive.ObjectID, opts TranscriptOptions) bson.M {
+	filter := bson.M{"room_id": roomID}
+	if !opts.IncludeUnsent {
+		filter["is_deleted"] = bson.M{"$ne": true}
+	}
+	timeRange := bson.M{}
+	if opts.From != nil {
+		timeRange["$gte"] = *opts.From
+	}
+	if opts.To != nil {
+		timeRange["$lt"] = *opts.To
+	}
+	if len(timeRange) > 0 {
+		filter["timestamp"] = timeRange
+	}
+	return filter
+}
+
+// archiveQuery ช่วงเวลาเดียวกับ messageFilter สำหรับ archive
+func archiveQuery(opts TranscriptOptions) utils.ArchiveQuery {
+	query := utils.ArchiveQuery{IncludeUnsent: opts.IncludeUnsent}
+	if opts.From != nil {
+		query.After = *opts.From
+	}
+	if opts.To != nil {
+		query.Before = *opts.To
+	}
+	return query
+}
+
+// writeBatch resolve ชื่อผู้ใช้/role/sticker ของทั้ง batch ในครั้งเดียวแล้วเขียนทีละข้อความ
+func (s *TranscriptService) writeBatch(ctx context.Context, w utils.TranscriptWriter, batch []model.ChatMessage, archived bool) (int, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	var userIDs []primitive.ObjectID
+	var stickerIDs []primitive.ObjectID
+	for i := range batch {
+		userIDs = append(userIDs, batch[i].UserID)
+		if batch[i].ModerationInfo != nil {
+			userIDs = append(userIDs, batch[i].ModerationInfo.UserID)
+		}
+		if batch[i].StickerID != nil {
+			stickerIDs = append(stickerIDs, *batch[i].StickerID)
+		}
+	}
+	users := s.users.ResolveUsers(ctx, userIDs)
+	stickers := s.loadStickers(ctx, stickerIDs)
+
+	for i := range batch {
+		entry := renderTranscriptEntry(&batch[i], users, stickers)
+		entry.Archived = archived
+		if err := w.Write(entry); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+func (s *TranscriptService) loadStickers(ctx context.Context, ids []primitive.ObjectID) map[primitive.ObjectID]stickerModel.Sticker {
+	result := make(map[primitive.ObjectID]stickerModel.Sticker)
+	if len(ids) == 0 {
+		return result
+	}
+	cursor, err := s.stickers.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		log.Printf("[Transcript] ⚠️ Failed to load %d stickers: %v", len(ids), err)
+		return result
+	}
+	var stickers []stickerModel.Sticker
+	if err := cursor.All(ctx, &stickers); err != nil {
+		log.Printf("[Transcript] ⚠️ Failed to decode stickers: %v", err)
+		return result
+	}
+	for _, sticker := range stickers {
+		result[sticker.ID] = sticker
+	}
+	return result
+}
+
+// renderTranscriptEntry แปลงข้อความเป็นรูปที่คนอ่านได้ตามประเภทข้อความ
+func renderTranscriptEntry(msg *model.ChatMessage, users map[string]model.UserInfo, stickers map[primitive.ObjectID]stickerModel.Sticker) utils.TranscriptEntry {
+	sender := users[msg.UserID.Hex()]
+	entry := utils.TranscriptEntry{
+		MessageID: msg.ID.Hex(),
+		Seq:       msg.Seq,
+		Timestamp: msg.Timestamp,
+		UserID:    msg.UserID.Hex(),
+		Username:  sender.Username,
+		Name:      transcriptDisplayName(sender),
+		Text:      msg.Message,
+	}
+	if sender.Role != nil {
+		entry.Role = sender.Role.Name
+	}
+	if msg.ReplyToID != nil {
+		entry.ReplyToID = msg.ReplyToID.Hex()
+	}
+	if msg.IsDeleted != nil && *msg.IsDeleted {
+		entry.Unsent = true
+		entry.UnsentAt = msg.DeletedAt
+	}
+
+	switch {
+	case msg.Image != "" && msg.StickerID == nil:
+		entry.Type = model.MessageTypeUpload
+		entry.Attachment = msg.Image
+		if entry.Text == "" {
+			entry.Text = "[upload]"
+		}
+	case msg.StickerID != nil:
+		entry.Type = model.MessageTypeSticker
+		sticker, ok := stickers[*msg.StickerID]
+		name := sticker.Name.En
+		if name == "" {
+			name = sticker.Name.Th
+		}
+		if !ok || name == "" {
+			name = msg.StickerID.Hex()
+		}
+		entry.Text = fmt.Sprintf("[sticker: %s]", name)
+		entry.Attachment = msg.Image
+		if entry.Attachment == "" {
+			entry.Attachment = sticker.Image
+		}
+	case msg.EvoucherInfo != nil:
+		entry.Type = model.MessageTypeEvoucher
+		text := msg.EvoucherInfo.Message.En
+		if text == "" {
+			text = msg.EvoucherInfo.Message.Th
+		}
+		entry.Text = fmt.Sprintf("[evoucher] %s (claimed by %d)", text, len(msg.EvoucherInfo.ClaimedBy))
+		entry.Attachment = msg.EvoucherInfo.ClaimURL
+	case len(msg.MentionInfo) > 0:
+		entry.Type = model.MessageTypeMention
+		for _, mention := range msg.MentionInfo {
+			entry.Mentions = append(entry.Mentions, "@"+mention.Username)
+		}
+	case msg.ModerationInfo != nil:
+		entry.Type = model.MessageTypeRestriction
+		target := users[msg.ModerationInfo.UserID.Hex()]
+		notice := fmt.Sprintf("[%s] %s (@%s)", msg.ModerationInfo.Restriction, transcriptDisplayName(target), target.Username)
+		if msg.Message != "" {
+			notice += ": " + msg.Message
+		}
+		entry.Text = notice
+	case msg.ReplyToID != nil:
+		entry.Type = model.MessageTypeReply
+	default:
+		entry.Type = model.MessageTypeText
+	}
+	return entry
+}
+
+func transcriptDisplayName(user model.UserInfo) string {
+	var parts []string
+	for _, key := range []string{"first", "middle", "last"} {
+		if part, ok := user.Name[key].(string); ok && part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return user.Username
+	}
+	return strings.Join(parts, " ")
+}
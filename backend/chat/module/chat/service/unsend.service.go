@@ -2,8 +2,10 @@ package service
 
 import (
 	"chat/module/chat/model"
+	"chat/pkg/core/audit"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -12,6 +14,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var (
+	ErrMessageNotFound       = errors.New("message not found")
+	ErrMessageAlreadyDeleted = errors.New("message has already been unsent")
+	// ErrProtectedMessageAuthor moderator ลบข้อความของ owner/moderator/Administrator ไม่ได้ (แบบเดียวกับการ restrict)
+	ErrProtectedMessageAuthor = errors.New("room moderators cannot delete messages of room owners, moderators or administrators")
+)
+
 // UnsendMessage ทำ soft delete ข้อความที่ส่งแล้ว (เฉพาะเจ้าของข้อความเท่านั้น)
 func (s *ChatService) UnsendMessage(ctx context.Context, messageID, userID primitive.ObjectID) error {
 	log.Printf("[ChatService] UnsendMessage called for message %s by user %s", messageID.Hex(), userID.Hex())
@@ -42,6 +51,50 @@ func (s *ChatService) UnsendMessage(ctx context.Context, messageID, userID primi
 		return fmt.Errorf("you can only unsend your own messages")
 	}
 
+	return s.softDeleteMessage(ctx, &messageData, userID)
+}
+
+// **NEW: DeleteMessageAsModerator ลบข้อความของสมาชิกในห้อง (soft delete แบบเดียวกับ unsend)**
+// สิทธิ์ owner/moderator ของห้องตรวจที่ route แล้ว ที่นี่ตรวจว่าข้อความอยู่ในห้องนั้นจริง
+// และให้ canModerate ตรวจเจ้าของข้อความ (คืน ErrProtectedMessageAuthor ถ้าเป็นผู้ที่ moderator แตะไม่ได้)
+func (s *ChatService) DeleteMessageAsModerator(ctx context.Context, roomID, messageID, moderatorID primitive.ObjectID, canModerate func(authorID primitive.ObjectID) error) error {
+	msg, err := s.FindOneById(ctx, messageID.Hex())
+	if err != nil || len(msg.Data) == 0 || msg.Data[0].RoomID != roomID {
+		return ErrMessageNotFound
+	}
+
+	messageData := msg.Data[0]
+	if messageData.IsDeleted != nil && *messageData.IsDeleted {
+		return ErrMessageAlreadyDeleted
+	}
+	if messageData.UserID != moderatorID {
+		if err := canModerate(messageData.UserID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.softDeleteMessage(ctx, &messageData, moderatorID); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionMessageModeratorDelete,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Details: map[string]interface{}{
+			"messageId": messageID.Hex(),
+			"authorId":  messageData.UserID.Hex(),
+		},
+	})
+	log.Printf("[ChatService] 🛡️ Moderator %s deleted message %s of user %s in room %s",
+		moderatorID.Hex(), messageID.Hex(), messageData.UserID.Hex(), roomID.Hex())
+	return nil
+}
+
+// softDeleteMessage ทำเครื่องหมายว่าข้อความถูกลบ, ลบออกจาก cache และแจ้ง client (deletedBy = ผู้ที่ลบ)
+func (s *ChatService) softDeleteMessage(ctx context.Context, messageData *model.ChatMessage, userID primitive.ObjectID) error {
+	messageID := messageData.ID
+
 	// **Soft Delete** - ทำเครื่องหมายว่าข้อความถูก delete แต่เก็บไว้ใน database เป็น backup
 	now := time.Now()
 	isDeleted := true
@@ -72,7 +125,7 @@ func (s *ChatService) UnsendMessage(ctx context.Context, messageID, userID primi
 	}
 
 	// **สำคัญ**: ยังคงส่ง unsend event ไป WebSocket เพื่อให้ frontend ลบข้อความออกจาก UI
-	if err := s.emitUnsendEvent(ctx, &messageData, userID); err != nil {
+	if err := s.emitUnsendEvent(ctx, messageData, userID); err != nil {
 		log.Printf("[ChatService] Failed to emit unsend event: %v", err)
 	} else {
 		log.Printf("[ChatService] Successfully emitted unsend event for message %s", messageID.Hex())
@@ -84,7 +137,7 @@ func (s *ChatService) UnsendMessage(ctx context.Context, messageID, userID primi
 		onlineUsers := s.hub.GetOnlineUsersInRoom(messageData.RoomID.Hex())
 		
 		// Send notifications to offline users using the proper notification service
-		s.notificationService.NotifyUsersInRoom(ctx, &messageData, onlineUsers)
+		s.notificationService.NotifyUsersInRoom(ctx, messageData, onlineUsers)
 	}
 
 	return nil
@@ -92,13 +145,12 @@ func (s *ChatService) UnsendMessage(ctx context.Context, messageID, userID primi
 
 // removeMessageFromCache ลบข้อความจาก Redis cache
 func (s *ChatService) removeMessageFromCache(ctx context.Context, roomID, messageID string) error {
-	// **FIXED: Simply clear the cache completely to force rebuild from database**
-	// This ensures the cache only contains non-unsent messages from the database
-	if err := s.cache.DeleteRoomMessages(ctx, roomID); err != nil {
-		return fmt.Errorf("failed to clear cache: %w", err)
+	// ลบเฉพาะข้อความนี้ออกจาก index + hash ข้อความอื่นในห้องยังอยู่ใน cache ตามเดิม
+	if err := s.cache.RemoveMessage(ctx, roomID, messageID); err != nil {
+		return fmt.Errorf("failed to remove message from cache: %w", err)
 	}
 
-	log.Printf("[ChatService] Successfully cleared cache for room %s after unsending message %s", roomID, messageID)
+	log.Printf("[ChatService] Removed unsent message %s from cache of room %s", messageID, roomID)
 	return nil
 }
 
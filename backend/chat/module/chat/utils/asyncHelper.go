@@ -3,14 +3,22 @@ package utils
 import (
 	"chat/module/chat/model"
 	"chat/pkg/config"
+	"chat/pkg/core/circuitbreaker"
+	"chat/pkg/core/metrics"
+	"chat/pkg/core/tracing"
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // **AsyncHelper Configuration**
@@ -89,6 +97,7 @@ type DatabaseJobHandler interface {
 	CreateMessageStatus(messageID primitive.ObjectID, roomID primitive.ObjectID) error
 	UpdateMessageStatus(messageID primitive.ObjectID, field string, value interface{})
 	UpdateMessageStatusWithError(messageID primitive.ObjectID, errorMsg string, retryCount int)
+	MarkMessageFailed(messageID primitive.ObjectID, reason string)
 	RetrieveMessage(messageID primitive.ObjectID) (*model.ChatMessage, error)
 }
 
@@ -119,6 +128,10 @@ type AsyncHelper struct {
 	retryConfig      RetryConfig
 	config           *config.Config
 	mu               sync.RWMutex
+	outbox           atomic.Pointer[MessageOutbox] // backlog ของ outbox นับรวมใน saturation check
+
+	activeJobs   atomic.Int64 // job ที่ worker หยิบไปแล้วแต่ยังทำไม่เสร็จ (ใช้ตอน drain)
+	shutdownOnce sync.Once
 }
 
 const (
@@ -132,40 +145,61 @@ const (
 
 // **Constructor**
 func NewAsyncHelper(mongo *mongo.Database, cfg *config.Config) *AsyncHelper {
+	// **NEW: ขนาด pool / queue / retry มาจาก AsyncFlow config (ค่า Default* ใช้เมื่อไม่ได้ตั้งค่า)**
+	af := cfg.AsyncFlow
 	helper := &AsyncHelper{
 		statusCollection: mongo.Collection("message-status"),
 		config:           cfg,
 		retryConfig: RetryConfig{
-			MaxRetries:    DefaultMaxRetries,
-			InitialDelay:  DefaultInitialDelay,
-			MaxDelay:      DefaultMaxDelay,
-			BackoffFactor: DefaultBackoffFactor,
+			MaxRetries:    af.Retry.MaxRetries,
+			InitialDelay:  orDefaultDuration(af.Retry.InitialDelay, DefaultInitialDelay),
+			MaxDelay:      orDefaultDuration(af.Retry.MaxDelay, DefaultMaxDelay),
+			BackoffFactor: af.Retry.BackoffFactor,
 		},
 	}
+	if helper.retryConfig.BackoffFactor < 1 {
+		helper.retryConfig.BackoffFactor = DefaultBackoffFactor
+	}
+	retryQueueSize := orDefaultInt(af.Retry.RetryQueueSize, DefaultQueueSize/2)
 
 	// Initialize worker pools with larger capacity
 	helper.dbWorkerPool = &DatabaseWorkerPool{
-		jobs:        make(chan DatabaseJob, DefaultQueueSize),
-		workerCount: DefaultWorkerCount,
+		jobs:        make(chan DatabaseJob, orDefaultInt(af.DatabaseWorkers.QueueSize, DefaultQueueSize)),
+		workerCount: orDefaultInt(af.DatabaseWorkers.WorkerCount, DefaultWorkerCount),
 		quit:        make(chan bool),
-		retryQueue:  make(chan DatabaseJob, DefaultQueueSize/2),
+		retryQueue:  make(chan DatabaseJob, retryQueueSize),
 		config:      helper.retryConfig,
 	}
 
 	helper.notifyWorkerPool = &NotificationWorkerPool{
-		jobs:        make(chan NotificationJob, DefaultQueueSize),
-		workerCount: DefaultWorkerCount,
+		jobs:        make(chan NotificationJob, orDefaultInt(af.NotificationWorkers.QueueSize, DefaultQueueSize)),
+		workerCount: orDefaultInt(af.NotificationWorkers.WorkerCount, DefaultWorkerCount),
 		quit:        make(chan bool),
-		retryQueue:  make(chan NotificationJob, DefaultQueueSize/2),
+		retryQueue:  make(chan NotificationJob, retryQueueSize),
 		config:      helper.retryConfig,
 	}
 
 	helper.initializeWorkerPools()
 	helper.initializePhantomDetector()
+	metricsAsyncHelper.Store(helper)
 
 	return helper
 }
 
+func orDefaultInt(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func orDefaultDuration(value, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
 // **Worker Pool Initialization**
 func (h *AsyncHelper) initializeWorkerPools() {
 	// Start workers
@@ -209,7 +243,9 @@ func (h *AsyncHelper) startDatabaseWorker(workerID int) {
 	for {
 		select {
 		case job := <-h.dbWorkerPool.jobs:
+			h.activeJobs.Add(1)
 			h.processDatabaseJob(job, workerID)
+			h.activeJobs.Add(-1)
 		case <-h.dbWorkerPool.quit:
 			log.Printf("[AsyncHelper] Database worker %d stopped", workerID)
 			return
@@ -223,7 +259,9 @@ func (h *AsyncHelper) startNotificationWorker(workerID int) {
 	for {
 		select {
 		case job := <-h.notifyWorkerPool.jobs:
+			h.activeJobs.Add(1)
 			h.processNotificationJob(job, workerID)
+			h.activeJobs.Add(-1)
 		case <-h.notifyWorkerPool.quit:
 			log.Printf("[AsyncHelper] Notification worker %d stopped", workerID)
 			return
@@ -238,6 +276,7 @@ func (h *AsyncHelper) startDatabaseRetryWorker() {
 	for {
 		select {
 		case job := <-h.dbWorkerPool.retryQueue:
+			h.activeJobs.Add(1)
 			delay := h.calculateRetryDelay(job.RetryCount)
 			log.Printf("[AsyncHelper] Retrying database job %s for message %s after %v (attempt %d/%d)",
 				job.Type, job.Message.ID.Hex(), delay, job.RetryCount+1, h.retryConfig.MaxRetries)
@@ -245,6 +284,7 @@ func (h *AsyncHelper) startDatabaseRetryWorker() {
 			time.Sleep(delay)
 			job.RetryCount++
 			h.processDatabaseJob(job, -1) // -1 indicates retry worker
+			h.activeJobs.Add(-1)
 		case <-h.dbWorkerPool.quit:
 			log.Printf("[AsyncHelper] Database retry worker stopped")
 			return
@@ -258,6 +298,7 @@ func (h *AsyncHelper) startNotificationRetryWorker() {
 	for {
 		select {
 		case job := <-h.notifyWorkerPool.retryQueue:
+			h.activeJobs.Add(1)
 			delay := h.calculateRetryDelay(job.RetryCount)
 			log.Printf("[AsyncHelper] Retrying notification job for message %s after %v (attempt %d/%d)",
 				job.Message.ID.Hex(), delay, job.RetryCount+1, h.retryConfig.MaxRetries)
@@ -265,6 +306,7 @@ func (h *AsyncHelper) startNotificationRetryWorker() {
 			time.Sleep(delay)
 			job.RetryCount++
 			h.processNotificationJob(job, -1) // -1 indicates retry worker
+			h.activeJobs.Add(-1)
 		case <-h.notifyWorkerPool.quit:
 			log.Printf("[AsyncHelper] Notification retry worker stopped")
 			return
@@ -317,6 +359,13 @@ func (h *AsyncHelper) processDatabaseJob(job DatabaseJob, workerID int) {
 		log.Printf("[ChatService] Skipping empty message from user %s in room %s", job.Message.UserID.Hex(), job.Message.RoomID.Hex())
 		return
 	}
+
+	// span ต่อจาก trace ของผู้ส่ง (SendMessage ส่ง context ที่ตัด cancel แล้วมากับ job)
+	ctx, span := tracing.Start(jobContext(job.Context), "async.db_job "+job.Type,
+		attribute.String("chat.message_id", job.Message.ID.Hex()),
+		attribute.Int("chat.retry_count", job.RetryCount),
+	)
+	defer func() { tracing.End(span, err) }()
 	// Start batch processing if available
 	if job.Type == "save_message" || job.Type == "cache_message" {
 		batchSize := h.config.AsyncFlow.DatabaseWorkers.BatchSize
@@ -348,19 +397,31 @@ func (h *AsyncHelper) processDatabaseJob(job DatabaseJob, workerID int) {
 
 		// Process batch
 		if len(batch) > 1 {
+			writeCtx, cancel := context.WithTimeout(ctx, h.jobTimeout(job.Type))
+			defer cancel()
+			span.SetAttributes(attribute.Int("chat.batch_size", len(batch)))
+			for _, j := range batch[1:] {
+				if link, ok := tracing.LinkFrom(jobContext(j.Context)); ok {
+					span.AddLink(link)
+				}
+			}
 			log.Printf("[AsyncHelper] Worker %d processing batch of %d %s jobs",
 				workerID, len(batch), job.Type)
 
 			switch job.Type {
 			case "save_message":
-				err = h.processSaveMessageBatch(batch)
+				err = h.processSaveMessageBatch(writeCtx, batch)
 			case "cache_message":
-				err = h.processCacheMessageBatch(batch)
+				err = h.processCacheMessageBatch(writeCtx, batch)
 			}
 
 			if err != nil {
 				// On batch failure, retry individual messages
 				for _, j := range batch {
+					if isCircuitOpenError(err) {
+						h.deferJobUntilCircuitCloses(j, err)
+						continue
+					}
 					h.handleJobFailure(j, err.Error(), workerID)
 				}
 				return
@@ -385,20 +446,26 @@ func (h *AsyncHelper) processDatabaseJob(job DatabaseJob, workerID int) {
 	}
 
 	// Single job processing (for non-batchable jobs or single items)
+	writeCtx, cancel := context.WithTimeout(ctx, h.jobTimeout(job.Type))
+	defer cancel()
 	switch job.Type {
 	case "save_message":
-		err = job.Service.SaveMessageToDB(job.Context, job.Message)
+		err = job.Service.SaveMessageToDB(writeCtx, job.Message)
 		if err == nil {
 			job.Service.UpdateMessageStatus(job.Message.ID, "saved_to_db", true)
 		}
 	case "cache_message":
-		err = job.Service.SaveMessageToCache(job.Context, job.Message)
+		err = job.Service.SaveMessageToCache(writeCtx, job.Message)
 		if err == nil {
 			job.Service.UpdateMessageStatus(job.Message.ID, "saved_to_cache", true)
 		}
 	}
 
 	if err != nil {
+		if isCircuitOpenError(err) {
+			h.deferJobUntilCircuitCloses(job, err)
+			return
+		}
 		h.handleJobFailure(job, err.Error(), workerID)
 		return
 	}
@@ -412,8 +479,24 @@ func (h *AsyncHelper) processDatabaseJob(job DatabaseJob, workerID int) {
 	}
 }
 
+// jobTimeout เวลาสูงสุดของงานหนึ่งครั้งตาม RELIABILITY_* (cache ใช้ CacheTimeout นอกนั้นคือ Mongo)
+func (h *AsyncHelper) jobTimeout(jobType string) time.Duration {
+	if jobType == "cache_message" {
+		return h.config.ReliabilityThresholds.CacheTimeout
+	}
+	return h.config.ReliabilityThresholds.DatabaseTimeout
+}
+
+// jobContext คืน context ของ job (job ที่ระบบสร้างเองอาจไม่มี)
+func jobContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
 // Batch processing helpers
-func (h *AsyncHelper) processSaveMessageBatch(batch []DatabaseJob) error {
+func (h *AsyncHelper) processSaveMessageBatch(ctx context.Context, batch []DatabaseJob) error {
 	if len(batch) == 0 {
 		return nil
 	}
@@ -430,10 +513,10 @@ func (h *AsyncHelper) processSaveMessageBatch(batch []DatabaseJob) error {
 	}
 
 	// Perform bulk insert using interface method
-	return batch[0].Service.SaveMessageBatch(context.Background(), messages)
+	return batch[0].Service.SaveMessageBatch(ctx, messages)
 }
 
-func (h *AsyncHelper) processCacheMessageBatch(batch []DatabaseJob) error {
+func (h *AsyncHelper) processCacheMessageBatch(ctx context.Context, batch []DatabaseJob) error {
 	if len(batch) == 0 {
 		return nil
 	}
@@ -456,7 +539,7 @@ func (h *AsyncHelper) processCacheMessageBatch(batch []DatabaseJob) error {
 	// Cache messages by room using interface method
 	for roomID, messages := range messagesByRoom {
 		if err := batch[0].Service.SaveMessageBatchToCache(
-			context.Background(),
+			ctx,
 			roomID,
 			messages,
 		); err != nil {
@@ -473,7 +556,15 @@ func (h *AsyncHelper) processNotificationJob(job NotificationJob, workerID int)
 		log.Printf("[ChatService] Skipping empty message from user %s in room %s", job.Message.UserID.Hex(), job.Message.RoomID.Hex())
 		return
 	}
-	err := job.Service.SendNotifications(job.Context, job.Message, job.OnlineUsers)
+	ctx, span := tracing.Start(jobContext(job.Context), "async.notification_job",
+		attribute.String("chat.message_id", job.Message.ID.Hex()),
+		attribute.Int("chat.retry_count", job.RetryCount),
+		attribute.Int("chat.online_users", len(job.OnlineUsers)),
+	)
+	sendCtx, cancel := context.WithTimeout(ctx, h.config.ReliabilityThresholds.NotificationTimeout)
+	err := job.Service.SendNotifications(sendCtx, job.Message, job.OnlineUsers)
+	cancel()
+	tracing.End(span, err)
 
 	if err != nil {
 		h.handleNotificationJobFailure(job, err.Error(), workerID)
@@ -498,18 +589,61 @@ func (h *AsyncHelper) handleJobFailure(job DatabaseJob, errorMsg string, workerI
 		// Try to requeue the job
 		select {
 		case h.dbWorkerPool.retryQueue <- job:
+			metrics.WorkerRetries.Inc("database")
 			log.Printf("[RETRY] Job requeued for retry %d/%d after %v delay",
 				job.RetryCount, h.retryConfig.MaxRetries, delay)
 		default:
+			metrics.WorkerJobsFailed.Inc("database")
 			log.Printf("[ERROR] Failed to requeue job after %d retries: %s",
 				job.RetryCount, errorMsg)
+			h.markPersistFailed(job)
 		}
 	} else {
+		metrics.WorkerJobsFailed.Inc("database")
 		log.Printf("[ERROR] Job failed permanently after %d retries: %s",
 			h.retryConfig.MaxRetries, errorMsg)
+		h.markPersistFailed(job)
+	}
+}
+
+// markPersistFailed แจ้งผู้ส่งเมื่อบันทึกข้อความไม่สำเร็จถาวร (cache ล้มไม่นับ เพราะ history อ่านจาก DB ได้)
+func (h *AsyncHelper) markPersistFailed(job DatabaseJob) {
+	if job.Type == "save_message" && job.Service != nil {
+		job.Service.MarkMessageFailed(job.Message.ID, DeliveryReasonPersistFailed)
 	}
 }
 
+// **NEW: Circuit breaker handling**
+// isCircuitOpenError ตรวจว่า job ล้มเพราะ breaker ปฏิเสธ (ยังไม่ได้เรียก dependency จริง)
+func isCircuitOpenError(err error) bool {
+	return errors.Is(err, circuitbreaker.ErrOpen) || errors.Is(err, circuitbreaker.ErrTooManyCalls)
+}
+
+// deferJobUntilCircuitCloses พัก job ไว้จนกว่า breaker จะเข้าสู่ half-open โดยไม่นับเป็น retry
+// cache_message ถูกทิ้งเพราะ cache เป็น best-effort (history จะ fallback ไปอ่านจาก DB)
+func (h *AsyncHelper) deferJobUntilCircuitCloses(job DatabaseJob, err error) {
+	if job.Type == "cache_message" {
+		log.Printf("[AsyncHelper] ⚡ Skipping cache for message %s: %v", job.Message.ID.Hex(), err)
+		return
+	}
+
+	delay := circuitbreaker.Get(circuitbreaker.MongoWrite).RetryAfter()
+	if delay <= 0 {
+		delay = h.retryConfig.InitialDelay
+	}
+
+	time.AfterFunc(delay, func() {
+		select {
+		case h.dbWorkerPool.jobs <- job:
+		default:
+			log.Printf("[ERROR] Failed to requeue %s job for message %s after circuit breaker delay: queue full",
+				job.Type, job.Message.ID.Hex())
+		}
+	})
+	log.Printf("[AsyncHelper] ⚡ Deferred %s job for message %s by %v: %v",
+		job.Type, job.Message.ID.Hex(), delay, err)
+}
+
 func (h *AsyncHelper) handleNotificationJobFailure(job NotificationJob, errorMsg string, workerID int) {
 	log.Printf("[AsyncHelper] Worker %d failed notification for message %s: %v (attempt %d/%d)",
 		workerID, job.Message.ID.Hex(), errorMsg, job.RetryCount+1, h.retryConfig.MaxRetries)
@@ -520,10 +654,13 @@ func (h *AsyncHelper) handleNotificationJobFailure(job NotificationJob, errorMsg
 		select {
 		case h.notifyWorkerPool.retryQueue <- job:
 			// Successfully queued for retry
+			metrics.WorkerRetries.Inc("notification")
 		default:
+			metrics.WorkerJobsFailed.Inc("notification")
 			log.Printf("[ERROR] Notification retry queue full, dropping job for message %s", job.Message.ID.Hex())
 		}
 	} else {
+		metrics.WorkerJobsFailed.Inc("notification")
 		log.Printf("[ERROR] Max retries exceeded for notification job, message %s", job.Message.ID.Hex())
 		job.Service.UpdateMessageStatus(job.Message.ID, "status", "failed")
 	}
@@ -573,13 +710,42 @@ func (h *AsyncHelper) detectAndFixPhantomMessages() {
 	}
 
 	if phantomCount > 0 {
+		metrics.PhantomMessagesFound.Add(float64(phantomCount))
 		log.Printf("[PhantomDetector] Found %d phantom messages", phantomCount)
 	}
 }
 
+// fixPhantomMessage ซ่อมได้เฉพาะข้อความที่อยู่ใน DB แล้ว (status ไม่อัปเดต/cache หาย)
+// ข้อความที่ไม่อยู่ใน DB ไม่มีเนื้อหาให้กู้ จึงถูก mark เป็น failed
 func (h *AsyncHelper) fixPhantomMessage(status MessageStatus) {
 	log.Printf("[PhantomDetector] Fixing phantom message %s", status.MessageID.Hex())
-	// Implementation would depend on PhantomDetectorHandler interface
+
+	handler := h.phantomDetector.service
+	if handler == nil {
+		return
+	}
+
+	msg, err := handler.RetrieveMessage(status.MessageID)
+	if err != nil {
+		log.Printf("[PhantomDetector] Message %s not found in DB, marking as failed", status.MessageID.Hex())
+		handler.UpdateMessageStatus(status.MessageID, "status", "failed")
+		return
+	}
+
+	if !status.SavedToDB {
+		handler.UpdateMessageStatus(status.MessageID, "saved_to_db", true)
+	}
+	if !status.SavedToCache {
+		if err := handler.SaveMessageToCache(context.Background(), msg); err != nil {
+			log.Printf("[PhantomDetector] Failed to re-cache message %s: %v", status.MessageID.Hex(), err)
+			return
+		}
+		handler.UpdateMessageStatus(status.MessageID, "saved_to_cache", true)
+	}
+
+	// ไม่ส่ง notification ซ้ำเพราะข้อความเก่าเกิน maxAge แล้ว
+	handler.UpdateMessageStatus(status.MessageID, "status", "completed")
+	metrics.PhantomMessagesFixed.Inc()
 }
 
 // **Public Interface Methods**
@@ -629,21 +795,61 @@ func (h *AsyncHelper) SetPhantomDetectorHandler(handler PhantomDetectorHandler)
 
 // **Shutdown**
 func (h *AsyncHelper) Shutdown() {
-	log.Printf("[AsyncHelper] Shutting down...")
+	h.shutdownOnce.Do(func() {
+		log.Printf("[AsyncHelper] Shutting down...")
 
-	// Stop workers
-	close(h.dbWorkerPool.quit)
-	close(h.notifyWorkerPool.quit)
+		// Stop workers
+		close(h.dbWorkerPool.quit)
+		close(h.notifyWorkerPool.quit)
 
-	if h.phantomDetector != nil {
-		close(h.phantomDetector.quit)
+		if h.phantomDetector != nil {
+			close(h.phantomDetector.quit)
+		}
+
+		log.Printf("[AsyncHelper] Shutdown complete")
+	})
+}
+
+// pendingJobs จำนวน job ที่ยังค้างใน queue + retry queue + กำลังทำอยู่
+func (h *AsyncHelper) pendingJobs() int64 {
+	return int64(len(h.dbWorkerPool.jobs)+len(h.dbWorkerPool.retryQueue)+
+		len(h.notifyWorkerPool.jobs)+len(h.notifyWorkerPool.retryQueue)) + h.activeJobs.Load()
+}
+
+// Drain รอให้ worker ทำ job ที่ค้างอยู่ให้หมดภายใน timeout แล้วจึงหยุด worker
+// คืน false ถ้าหมดเวลาก่อน (job ที่เหลือจะหายไป ยกเว้นข้อความที่อยู่ใน outbox)
+func (h *AsyncHelper) Drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	flushed := true
+	for remaining := h.pendingJobs(); remaining > 0; remaining = h.pendingJobs() {
+		if time.Now().After(deadline) {
+			log.Printf("[AsyncHelper] ⚠️ Drain timeout after %s with %d jobs remaining", timeout, remaining)
+			flushed = false
+			break
+		}
+		<-ticker.C
+	}
+	if flushed {
+		log.Printf("[AsyncHelper] ✅ Worker queues flushed")
 	}
 
-	log.Printf("[AsyncHelper] Shutdown complete")
+	h.Shutdown()
+	return flushed
 }
 
 // **Metrics and Status**
 func (h *AsyncHelper) GetWorkerPoolStatus() map[string]interface{} {
+	phantom := map[string]interface{}{
+		"enabled": h.phantomDetector != nil,
+	}
+	if h.phantomDetector != nil {
+		phantom["check_interval"] = h.phantomDetector.checkInterval.String()
+		phantom["max_age"] = h.phantomDetector.maxAge.String()
+	}
+
 	return map[string]interface{}{
 		"database_workers": map[string]interface{}{
 			"worker_count": h.dbWorkerPool.workerCount,
@@ -655,16 +861,16 @@ func (h *AsyncHelper) GetWorkerPoolStatus() map[string]interface{} {
 			"jobs_queued":  len(h.notifyWorkerPool.jobs),
 			"retry_queued": len(h.notifyWorkerPool.retryQueue),
 		},
-		"phantom_detector": map[string]interface{}{
-			"enabled":        h.config.AsyncFlow.PhantomDetection.Enabled,
-			"check_interval": h.phantomDetector.checkInterval.String(),
-			"max_age":        h.phantomDetector.maxAge.String(),
-		},
+		"phantom_detector": phantom,
 	}
 }
 
 // **Public method to trigger phantom message detection**
 func (h *AsyncHelper) TriggerPhantomMessageDetection() {
+	if h.phantomDetector == nil {
+		log.Printf("[AsyncHelper] Phantom detection disabled, manual trigger ignored")
+		return
+	}
 	log.Printf("[AsyncHelper] Manual phantom message detection triggered")
 	go h.detectAndFixPhantomMessages()
 }
@@ -681,3 +887,55 @@ func (h *AsyncHelper) GetNotificationWorkerQueueMetrics() (size int, capacity in
 	defer h.mu.RUnlock()
 	return len(h.notifyWorkerPool.jobs), cap(h.notifyWorkerPool.jobs)
 }
+
+// SetOutbox ให้ saturation check และ admission control นับ backlog ของ outbox (เทียบกับ ASYNC_OUTBOX_MAX_BACKLOG)
+func (h *AsyncHelper) SetOutbox(outbox *MessageOutbox) {
+	h.outbox.Store(outbox)
+}
+
+// CheckQueueSaturation คืน error ถ้า queue ใด (รวม outbox) เต็มถึง AsyncFlow.Monitoring.QueueSaturationThreshold ของความจุ
+// (ใช้กับ readiness: instance ที่ queue ใกล้เต็มจะเริ่มทิ้ง job จึงไม่ควรรับ socket ใหม่)
+func (h *AsyncHelper) CheckQueueSaturation() error {
+	threshold := h.config.AsyncFlow.Monitoring.QueueSaturationThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = 1
+	}
+
+	for _, q := range h.queueUsage() {
+		if q.cap > 0 && float64(q.size)/float64(q.cap) >= threshold {
+			return fmt.Errorf("%s queue saturated: %d/%d (threshold %.0f%%)", q.name, q.size, q.cap, threshold*100)
+		}
+	}
+	return nil
+}
+
+// QueueFillRatio คืนสัดส่วนของ queue ที่เต็มที่สุด (0..1 รวม outbox) ใช้เป็นสัญญาณของ admission control
+func (h *AsyncHelper) QueueFillRatio() float64 {
+	var ratio float64
+	for _, q := range h.queueUsage() {
+		if q.cap > 0 {
+			ratio = math.Max(ratio, float64(q.size)/float64(q.cap))
+		}
+	}
+	return ratio
+}
+
+type queueUsage struct {
+	name      string
+	size, cap int
+}
+
+// queueUsage ขนาดเทียบกับความจุของทุก queue (outbox เทียบกับ ASYNC_OUTBOX_MAX_BACKLOG ถ้าใช้ outbox อยู่)
+func (h *AsyncHelper) queueUsage() []queueUsage {
+	usage := []queueUsage{
+		{"database", len(h.dbWorkerPool.jobs), cap(h.dbWorkerPool.jobs)},
+		{"database_retry", len(h.dbWorkerPool.retryQueue), cap(h.dbWorkerPool.retryQueue)},
+		{"notification", len(h.notifyWorkerPool.jobs), cap(h.notifyWorkerPool.jobs)},
+		{"notification_retry", len(h.notifyWorkerPool.retryQueue), cap(h.notifyWorkerPool.retryQueue)},
+	}
+	if outbox := h.outbox.Load(); outbox != nil {
+		limit := orDefaultInt(h.config.AsyncFlow.Outbox.MaxBacklog, DefaultQueueSize)
+		usage = append(usage, queueUsage{"outbox", int(outbox.SampledBacklog()), limit})
+	}
+	return usage
+}
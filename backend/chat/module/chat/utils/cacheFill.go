@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"chat/pkg/core/metrics"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// **NEW: กัน cache stampede ตอน cache ว่าง (Redis restart / TTL หมด)**
+// ภายใน instance ใช้ singleflight ให้มี loader เดียวต่อ key, ข้าม instance ใช้ lock ใน Redis:
+// instance ที่ได้ lock อ่าน DB แล้วเติม cache ส่วน instance อื่นรอ cache ถูกเติม (ไม่เกิน wait) ก่อนจะอ่าน DB เอง
+const (
+	cacheFillLockPrefix   = "chat:cache:fill:"
+	cacheFillPollInterval = 100 * time.Millisecond
+)
+
+// releaseFillLock ลบ lock เฉพาะเมื่อยังเป็นของเรา (lock อาจหมดอายุแล้วถูก instance อื่นถือต่อ)
+var releaseFillLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type CacheFill struct {
+	redis   *redis.Client
+	group   singleflight.Group
+	lockTTL time.Duration
+	wait    time.Duration
+}
+
+// NewCacheFill สร้างตัวกัน stampede (lockTTL = อายุ lock ข้าม instance, wait = เวลาที่รอ loader ของ instance อื่น)
+func NewCacheFill(redisClient *redis.Client, lockTTL, wait time.Duration) *CacheFill {
+	return &CacheFill{
+		redis:   redisClient,
+		lockTTL: lockTTL,
+		wait:    wait,
+	}
+}
+
+// FillCache โหลดค่าของ key ผ่าน loader เดียว
+// probe อ่าน cache (ok=false = ยังไม่มี) ใช้ตอนรอ instance อื่น, load อ่าน DB และเติม cache
+// load ใช้ context ที่ไม่ถูก cancel ตาม caller คนแรก เพราะผลลัพธ์ถูกแชร์ให้ caller คนอื่นด้วย
+func FillCache[T any](ctx context.Context, f *CacheFill, cacheName, key string,
+	probe func(ctx context.Context) (T, bool),
+	load func(ctx context.Context) (T, error),
+) (T, error) {
+	executed := false
+	result, err, shared := f.group.Do(key, func() (interface{}, error) {
+		executed = true
+		fillCtx := context.WithoutCancel(ctx)
+
+		lockKey := cacheFillLockPrefix + key
+		token := uuid.NewString()
+		acquired, lockErr := f.redis.SetNX(fillCtx, lockKey, token, f.lockTTL).Result()
+		if lockErr != nil {
+			// Redis มีปัญหา: อ่าน DB ตรงๆ (probe ก็คงใช้ไม่ได้เหมือนกัน)
+			return load(fillCtx)
+		}
+		if acquired {
+			defer releaseFillLock.Run(fillCtx, f.redis, []string{lockKey}, token)
+			return load(fillCtx)
+		}
+
+		if value, ok := waitForFill(fillCtx, f.wait, probe); ok {
+			metrics.CacheStampedesAvoided.Inc(cacheName, "remote")
+			return value, nil
+		}
+		log.Printf("[CacheFill] ⏱️ Timed out waiting for another instance to fill %s, loading from database", key)
+		return load(fillCtx)
+	})
+	if shared && !executed && err == nil {
+		metrics.CacheStampedesAvoided.Inc(cacheName, "local")
+	}
+
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result.(T), nil
+}
+
+// waitForFill เรียก probe ซ้ำจนกว่า cache จะมีค่า หรือครบเวลา wait
+func waitForFill[T any](ctx context.Context, wait time.Duration, probe func(ctx context.Context) (T, bool)) (T, bool) {
+	deadline := time.Now().Add(wait)
+	for {
+		if value, ok := probe(ctx); ok {
+			return value, true
+		}
+		if time.Now().Add(cacheFillPollInterval).After(deadline) {
+			var zero T
+			return zero, false
+		}
+		time.Sleep(cacheFillPollInterval)
+	}
+}
@@ -2,10 +2,12 @@ package utils
 
 import (
 	"chat/module/chat/model"
+	"chat/pkg/core/circuitbreaker"
+	"chat/pkg/core/logging"
+	"chat/pkg/core/metrics"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -14,8 +16,41 @@ import (
 const (
 	MessageTTL = 24 * time.Hour
 	MaxMessages = 1000
+
+	// **NEW: CacheSchemaVersion อยู่ในทุก key ของ message cache**
+	// เปลี่ยนรูปแบบข้อมูลใน cache เมื่อไหร่ให้เพิ่มเลขนี้ key ชุดเก่าจะไม่ถูกอ่านอีกและหมดอายุเองตาม MessageTTL
+	// v1 = sorted set ที่ member เป็น JSON ทั้งก้อน, v2 = index ของ message ID + hash ต่อข้อความ
+	CacheSchemaVersion = 2
+
+	// field ใน hash ของแต่ละข้อความ
+	cachedMessageDataField = "data"
+	cachedMessageRoomField = "room_id"
+
+	// จำนวนครั้งที่ลอง UpdateMessage ใหม่เมื่อมีคนแก้ข้อความเดียวกันพร้อมกัน
+	maxCacheUpdateRetries = 3
 )
 
+// cacheLog ถูกเรียกต่อข้อความ จึงอยู่ที่ debug ทั้งหมด (เปิดด้วย LOG_MODULE_LEVELS=cache=debug)
+var cacheLog = logging.Module("cache")
+
+// saveMessageScript เขียน hash ของข้อความ + เพิ่ม ID ลง index แล้ว trim index ให้เหลือ MaxMessages
+// คืน ID ที่ถูก trim ออก ให้ caller ลบ hash เอง (ดู deleteTrimmed) เพราะ script แตะได้เฉพาะ key ที่ส่งมาใน KEYS
+// (Redis Cluster) ถ้าลบไม่สำเร็จ hash ที่ค้างจะหมดอายุเองตาม MessageTTL และไม่ถูกอ่านเพราะไม่อยู่ใน index แล้ว
+// KEYS[1]=index KEYS[2]=message hash; ARGV: score, messageID, data, roomID, ttl (วินาที), max
+var saveMessageScript = redis.NewScript(`
+redis.call("HSET", KEYS[2], "` + cachedMessageDataField + `", ARGV[3], "` + cachedMessageRoomField + `", ARGV[4])
+redis.call("EXPIRE", KEYS[2], ARGV[5])
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+redis.call("EXPIRE", KEYS[1], ARGV[5])
+local overflow = redis.call("ZCARD", KEYS[1]) - tonumber(ARGV[6])
+if overflow <= 0 then
+	return {}
+end
+local trimmed = redis.call("ZRANGE", KEYS[1], 0, overflow - 1)
+redis.call("ZREMRANGEBYRANK", KEYS[1], 0, overflow - 1)
+return trimmed
+`)
+
 type ChatCacheService struct {
 	redis *redis.Client
 }
@@ -26,55 +61,57 @@ func NewChatCacheService(redis *redis.Client) *ChatCacheService {
 	}
 }
 
-// Key generators
-func (s *ChatCacheService) roomMessagesKey(roomID string) string {
-	return fmt.Sprintf("chat:room:%s:messages", roomID)
+// **NEW: RedisCacheBreaker คืน breaker ของ Redis cache (redis.Nil ไม่นับเป็น failure)**
+// เมื่อ breaker เปิด caller ควรข้าม cache แล้วไปอ่าน/เขียน DB แทน
+func RedisCacheBreaker() *circuitbreaker.Breaker {
+	return circuitbreaker.Get(circuitbreaker.RedisCache)
+}
+
+// RoomMessageIndexKey คือ sorted set ของ message ID ในห้อง (score = seq ดู MessageScore)
+func RoomMessageIndexKey(roomID string) string {
+	return fmt.Sprintf("chat:v%d:room:%s:index", CacheSchemaVersion, roomID)
+}
+
+// CachedMessageKey คือ hash ของข้อความหนึ่งข้อความ (field data = ChatMessageEnriched แบบ JSON)
+func CachedMessageKey(messageID string) string {
+	return fmt.Sprintf("chat:v%d:msg:%s", CacheSchemaVersion, messageID)
 }
 
 // GetRoomMessages gets messages from cache (จากใหม่สุดไปเก่าสุด)
 func (s *ChatCacheService) GetRoomMessages(ctx context.Context, roomID string, limit int) ([]model.ChatMessageEnriched, error) {
-	key := s.roomMessagesKey(roomID)
+	key := RoomMessageIndexKey(roomID)
 	
-	// Get messages using ZREVRANGE (newest first by timestamp score)
-	data, err := s.redis.ZRevRange(ctx, key, 0, int64(limit*2)).Result() // ขอมากกว่าเผื่อต้องกรอง
+	// Get message IDs using ZREVRANGE (newest first by seq score)
+	var ids []string
+	err := RedisCacheBreaker().Execute(func() error {
+		var rangeErr error
+		ids, rangeErr = s.redis.ZRevRange(ctx, key, 0, int64(limit*2)).Result() // ขอมากกว่าเผื่อต้องกรอง
+		return rangeErr
+	})
 	if err == redis.Nil {
+		metrics.CacheRequests.Inc(metrics.CacheChatMessages, metrics.CacheMiss)
 		return []model.ChatMessageEnriched{}, nil
 	}
 	if err != nil {
+		metrics.CacheRequests.Inc(metrics.CacheChatMessages, metrics.CacheError)
 		return nil, fmt.Errorf("redis get error: %w", err)
 	}
+	if len(ids) == 0 {
+		metrics.CacheRequests.Inc(metrics.CacheChatMessages, metrics.CacheMiss)
+	} else {
+		metrics.CacheRequests.Inc(metrics.CacheChatMessages, metrics.CacheHit)
+	}
 
-	messages := make([]model.ChatMessageEnriched, 0, len(data))
-	for _, item := range data {
-		var msg model.ChatMessageEnriched
-		if err := json.Unmarshal([]byte(item), &msg); err != nil {
-			log.Printf("Warning: Failed to unmarshal message: %v", err)
-			continue
-		}
-
-		// กรองข้อความที่ถูก soft delete ออก
-		if msg.ChatMessage.IsDeleted != nil && *msg.ChatMessage.IsDeleted {
-			continue // ข้าม soft deleted messages
-		}
+	loaded, err := s.loadMessages(ctx, roomID, ids)
+	if err != nil {
+		metrics.CacheRequests.Inc(metrics.CacheChatMessages, metrics.CacheError)
+		return nil, err
+	}
 
+	messages := make([]model.ChatMessageEnriched, 0, len(loaded))
+	for _, msg := range loaded {
 		// **ENHANCED: Log what we retrieved from cache**
-		log.Printf("[Cache] Retrieved message %s from cache", msg.ChatMessage.ID.Hex())
-		if msg.ChatMessage.EvoucherInfo != nil {
-			log.Printf("[Cache] Message %s contains evoucher info", msg.ChatMessage.ID.Hex())
-		}
-		if msg.ChatMessage.MentionInfo != nil {
-			log.Printf("[Cache] Message %s contains mention info with %d mentions", msg.ChatMessage.ID.Hex(), len(msg.ChatMessage.Mentions))
-		}
-		if msg.ChatMessage.ModerationInfo != nil {
-			log.Printf("[Cache] Message %s contains restriction info", msg.ChatMessage.ID.Hex())
-		}
-		if msg.ChatMessage.StickerID != nil {
-			log.Printf("[Cache] Message %s contains sticker info", msg.ChatMessage.ID.Hex())
-		}
-		if msg.ReplyTo != nil {
-			log.Printf("[Cache] Message %s contains reply-to info", msg.ChatMessage.ID.Hex())
-		}
-
+		cacheLog.DebugContext(ctx, "retrieved message from cache", append(cachedMessageAttrs(&msg), "roomId", roomID)...)
 
 		messages = append(messages, msg)
 		
@@ -85,11 +122,13 @@ func (s *ChatCacheService) GetRoomMessages(ctx context.Context, roomID string, l
 	}
 
 	// Log cache sorting info
-	log.Printf("[Cache] Retrieved %d messages from cache for room %s (newest first)", len(messages), roomID)
 	if len(messages) > 0 {
-		log.Printf("[Cache] First cached message: %v, Last cached message: %v", 
-			messages[0].ChatMessage.Timestamp, 
-			messages[len(messages)-1].ChatMessage.Timestamp)
+		cacheLog.DebugContext(ctx, "retrieved messages from cache (newest first)",
+			"roomId", roomID,
+			"count", len(messages),
+			"first", messages[0].ChatMessage.Timestamp,
+			"last", messages[len(messages)-1].ChatMessage.Timestamp,
+		)
 	}
 
 	// Refresh TTL on successful read
@@ -99,67 +138,303 @@ func (s *ChatCacheService) GetRoomMessages(ctx context.Context, roomID string, l
 }
 
 // SaveMessage saves a message to cache
+// ถ้ามีข้อความ ID เดียวกันอยู่แล้วจะถูกแทนที่ (ไม่เกิดสำเนาซ้ำใน index)
 func (s *ChatCacheService) SaveMessage(ctx context.Context, roomID string, msg *model.ChatMessageEnriched) error {
 	// **FIXED: Don't cache unsent messages**
 	if msg.ChatMessage.IsDeleted != nil && *msg.ChatMessage.IsDeleted {
-		log.Printf("[Cache] Skipping unsent message %s from cache", msg.ChatMessage.ID.Hex())
-		return nil
+		cacheLog.DebugContext(ctx, "skipping unsent message", "roomId", roomID, "messageId", msg.ChatMessage.ID.Hex())
+		return s.RemoveMessage(ctx, roomID, msg.ChatMessage.ID.Hex())
 	}
 
-	key := s.roomMessagesKey(roomID)
-	
 	// **ENHANCED: Log what we're caching for debugging**
-	log.Printf("[Cache] Saving enriched message %s to cache for room %s", msg.ChatMessage.ID.Hex(), roomID)
-	if msg.ChatMessage.EvoucherInfo != nil {
-		log.Printf("[Cache] Message contains evoucher info")
+	cacheLog.DebugContext(ctx, "saving enriched message to cache", append(cachedMessageAttrs(msg), "roomId", roomID)...)
+
+	var cmd *redis.Cmd
+	err := RedisCacheBreaker().Execute(func() error {
+		var saveErr error
+		cmd, saveErr = s.saveMessage(ctx, s.redis, roomID, msg)
+		if saveErr != nil {
+			return saveErr
+		}
+		return cmd.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("redis save error: %w", err)
+	}
+
+	s.deleteTrimmed(ctx, roomID, cmd)
+	return nil
+}
+
+// SaveMessages บันทึกหลายข้อความของห้องเดียวกันใน pipeline เดียว (ใช้กับ batch จาก async flow)
+func (s *ChatCacheService) SaveMessages(ctx context.Context, roomID string, msgs []*model.ChatMessageEnriched) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	pipe := s.redis.Pipeline()
+	cmds := make([]*redis.Cmd, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.ChatMessage.IsDeleted != nil && *msg.ChatMessage.IsDeleted {
+			continue
+		}
+		cmd, err := s.saveMessage(ctx, pipe, roomID, msg)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, cmd)
 	}
-	if msg.ChatMessage.MentionInfo != nil {
-		log.Printf("[Cache] Message contains mention info with %d mentions", len(msg.ChatMessage.Mentions))
+
+	err := RedisCacheBreaker().Execute(func() error {
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return err
 	}
-	if msg.ChatMessage.ModerationInfo != nil {
-		log.Printf("[Cache] Message contains restriction info")
+
+	s.deleteTrimmed(ctx, roomID, cmds...)
+	return nil
+}
+
+// deleteTrimmed ลบ hash ของข้อความที่ saveMessageScript trim ออกจาก index
+// ใช้ DEL ทีละ key ใน pipeline (ไม่ใช่ multi-key DEL) เพราะ hash แต่ละข้อความอาจอยู่คนละ slot ใน Redis Cluster
+func (s *ChatCacheService) deleteTrimmed(ctx context.Context, roomID string, cmds ...*redis.Cmd) {
+	var keys []string
+	for _, cmd := range cmds {
+		ids, err := cmd.StringSlice()
+		if err != nil {
+			continue
+		}
+		for _, id := range ids {
+			keys = append(keys, CachedMessageKey(id))
+		}
 	}
-	if msg.ChatMessage.StickerID != nil {
-		log.Printf("[Cache] Message contains sticker info")
+	if len(keys) == 0 {
+		return
 	}
-	if msg.ReplyTo != nil {
-		log.Printf("[Cache] Message contains reply-to info")
+
+	err := RedisCacheBreaker().Execute(func() error {
+		pipe := s.redis.Pipeline()
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		// ไม่ร้ายแรง: hash ไม่อยู่ใน index แล้วและจะหมดอายุเองตาม MessageTTL
+		cacheLog.DebugContext(ctx, "failed to delete trimmed messages", "roomId", roomID, "count", len(keys), "error", err)
 	}
+}
 
-	
+// saveMessage ส่ง saveMessageScript ผ่าน client หรือ pipeline
+func (s *ChatCacheService) saveMessage(ctx context.Context, scripter redis.Scripter, roomID string, msg *model.ChatMessageEnriched) (*redis.Cmd, error) {
 	data, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("marshal error: %w", err)
+		return nil, fmt.Errorf("marshal error: %w", err)
 	}
 
-	pipe := s.redis.Pipeline()
+	messageID := msg.ChatMessage.ID.Hex()
+	keys := []string{RoomMessageIndexKey(roomID), CachedMessageKey(messageID)}
+	args := []interface{}{
+		MessageScore(&msg.ChatMessage),
+		messageID,
+		data,
+		roomID,
+		int64(MessageTTL / time.Second),
+		MaxMessages,
+	}
 
-	// Save message with timestamp as score
-	score := float64(msg.ChatMessage.Timestamp.Unix())
-	pipe.ZAdd(ctx, key, redis.Z{
-		Score:  score,
-		Member: data,
+	// ใน pipeline ใช้ EVAL ตรงๆ เพราะ EVALSHA ที่ fallback ไม่ได้จะทำให้ทั้ง pipeline ล้มตอน script ยังไม่ถูกโหลด
+	if _, ok := scripter.(redis.Pipeliner); ok {
+		return saveMessageScript.Eval(ctx, scripter, keys, args...), nil
+	}
+	return saveMessageScript.Run(ctx, scripter, keys, args...), nil
+}
+
+// UpdateMessage แก้ข้อความที่อยู่ใน cache แบบ in-place (ใช้กับ claim evoucher, แก้ไขข้อความ, reaction)
+// ถ้าข้อความไม่อยู่ใน cache จะไม่ทำอะไร (ครั้งหน้าจะโหลดฉบับล่าสุดจาก DB เอง)
+// ใช้ WATCH เพื่อไม่ให้การแก้พร้อมกันสองที่ทับกัน
+func (s *ChatCacheService) UpdateMessage(ctx context.Context, roomID, messageID string, mutate func(msg *model.ChatMessageEnriched)) error {
+	key := CachedMessageKey(messageID)
+
+	update := func(tx *redis.Tx) error {
+		data, err := tx.HGet(ctx, key, cachedMessageDataField).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg model.ChatMessageEnriched
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			// ข้อมูลเสีย ลบทิ้งให้โหลดใหม่จาก DB
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.ZRem(ctx, RoomMessageIndexKey(roomID), messageID)
+				pipe.Del(ctx, key)
+				return nil
+			})
+			return err
+		}
+
+		mutate(&msg)
+		updated, err := json.Marshal(&msg)
+		if err != nil {
+			return fmt.Errorf("marshal error: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, cachedMessageDataField, updated)
+			return nil
+		})
+		return err
+	}
+
+	return RedisCacheBreaker().Execute(func() error {
+		var err error
+		for attempt := 0; attempt < maxCacheUpdateRetries; attempt++ {
+			err = s.redis.Watch(ctx, update, key)
+			if err != redis.TxFailedErr {
+				return err
+			}
+		}
+		return fmt.Errorf("redis update conflict on message %s: %w", messageID, err)
+	})
+}
+
+// RemoveMessage ลบข้อความเดียวออกจาก cache ของห้อง (เช่นหลัง unsend) โดยไม่กระทบข้อความอื่น
+func (s *ChatCacheService) RemoveMessage(ctx context.Context, roomID, messageID string) error {
+	return RedisCacheBreaker().Execute(func() error {
+		pipe := s.redis.TxPipeline()
+		pipe.ZRem(ctx, RoomMessageIndexKey(roomID), messageID)
+		pipe.Del(ctx, CachedMessageKey(messageID))
+		_, err := pipe.Exec(ctx)
+		return err
 	})
+}
 
-	// Set TTL
-	pipe.Expire(ctx, key, MessageTTL)
+// GetRoomMessagesBySeq อ่านข้อความที่ seq อยู่ในช่วง (afterSeq, beforeSeq) จาก cache
+// newestFirst=true คืนข้อความใหม่สุดก่อน (ใช้ตอนเลื่อนดูข้อความเก่า) beforeSeq <= 0 = ไม่จำกัด
+func (s *ChatCacheService) GetRoomMessagesBySeq(ctx context.Context, roomID string, afterSeq, beforeSeq int64, limit int, newestFirst bool) ([]model.ChatMessageEnriched, error) {
+	minScore, maxScore := SeqScoreRange(afterSeq, beforeSeq)
+	rangeBy := &redis.ZRangeBy{
+		Min:   minScore,
+		Max:   maxScore,
+		Count: int64(limit),
+	}
 
-	// Trim old messages
-	pipe.ZRemRangeByRank(ctx, key, 0, -MaxMessages-1)
+	var ids []string
+	err := RedisCacheBreaker().Execute(func() error {
+		var rangeErr error
+		if newestFirst {
+			ids, rangeErr = s.redis.ZRevRangeByScore(ctx, RoomMessageIndexKey(roomID), rangeBy).Result()
+		} else {
+			ids, rangeErr = s.redis.ZRangeByScore(ctx, RoomMessageIndexKey(roomID), rangeBy).Result()
+		}
+		return rangeErr
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("redis range error: %w", err)
+	}
 
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("redis save error: %w", err)
+	return s.loadMessages(ctx, roomID, ids)
+}
+
+// SeqScoreRange ช่วง score ของ ZRANGEBYSCORE สำหรับ seq ในช่วง (afterSeq, beforeSeq)
+// seq เป็นจำนวนเต็มที่เริ่มจาก 1 จึงใช้ min แบบรวม afterSeq+1 เพื่อตัดข้อความเก่าที่ไม่มี seq (score 0-1 ดู MessageScore) ออก
+func SeqScoreRange(afterSeq, beforeSeq int64) (minScore, maxScore string) {
+	minScore = fmt.Sprintf("%d", max(afterSeq, 0)+1)
+	maxScore = "+inf"
+	if beforeSeq > 0 {
+		maxScore = fmt.Sprintf("(%d", beforeSeq)
+	}
+	return minScore, maxScore
+}
+
+// loadMessages อ่าน hash ของข้อความตามลำดับ ids (ข้าม unsend และข้อมูลเสีย)
+// ID ที่อยู่ใน index แต่ hash หมดอายุไปแล้วจะถูกลบออกจาก index
+func (s *ChatCacheService) loadMessages(ctx context.Context, roomID string, ids []string) ([]model.ChatMessageEnriched, error) {
+	if len(ids) == 0 {
+		return []model.ChatMessageEnriched{}, nil
 	}
 
-	log.Printf("[Cache] Successfully saved enriched message %s to cache", msg.ChatMessage.ID.Hex())
-	return nil
+	cmds := make([]*redis.StringCmd, len(ids))
+	err := RedisCacheBreaker().Execute(func() error {
+		pipe := s.redis.Pipeline()
+		for i, id := range ids {
+			cmds[i] = pipe.HGet(ctx, CachedMessageKey(id), cachedMessageDataField)
+			pipe.Expire(ctx, CachedMessageKey(id), MessageTTL)
+		}
+		_, execErr := pipe.Exec(ctx)
+		return execErr
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("redis get error: %w", err)
+	}
+
+	messages := make([]model.ChatMessageEnriched, 0, len(ids))
+	var stale []interface{}
+	for i, cmd := range cmds {
+		data, err := cmd.Result()
+		if err == redis.Nil {
+			stale = append(stale, ids[i])
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var msg model.ChatMessageEnriched
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			cacheLog.WarnContext(ctx, "failed to unmarshal cached message", "roomId", roomID, "messageId", ids[i], "error", err)
+			continue
+		}
+
+		// กรองข้อความที่ถูก soft delete ออก
+		if msg.ChatMessage.IsDeleted != nil && *msg.ChatMessage.IsDeleted {
+			continue // ข้าม soft deleted messages
+		}
+		messages = append(messages, msg)
+	}
+
+	if len(stale) > 0 {
+		cacheLog.DebugContext(ctx, "pruning expired message ids from index", "roomId", roomID, "count", len(stale))
+		s.redis.ZRem(ctx, RoomMessageIndexKey(roomID), stale...)
+	}
+
+	return messages, nil
 }
 
+// cachedMessageAttrs คืน attribute สำหรับ debug log ของข้อความใน cache
+func cachedMessageAttrs(msg *model.ChatMessageEnriched) []any {
+	return []any{
+		"messageId", msg.ChatMessage.ID.Hex(),
+		"evoucher", msg.ChatMessage.EvoucherInfo != nil,
+		"mentions", len(msg.ChatMessage.Mentions),
+		"moderation", msg.ChatMessage.ModerationInfo != nil,
+		"sticker", msg.ChatMessage.StickerID != nil,
+		"replyTo", msg.ReplyTo != nil,
+	}
+}
 
 
-// DeleteRoomMessages deletes all messages for a room
+
+// DeleteRoomMessages deletes all messages for a room (index และ hash ของทุกข้อความใน index)
 func (s *ChatCacheService) DeleteRoomMessages(ctx context.Context, roomID string) error {
-	key := s.roomMessagesKey(roomID)
-	return s.redis.Del(ctx, key).Err()
-}
\ No newline at end of file
+	key := RoomMessageIndexKey(roomID)
+	return RedisCacheBreaker().Execute(func() error {
+		ids, err := s.redis.ZRange(ctx, key, 0, -1).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(ids)+1)
+		for _, id := range ids {
+			keys = append(keys, CachedMessageKey(id))
+		}
+		keys = append(keys, key)
+		return s.redis.Del(ctx, keys...).Err()
+	})
+}
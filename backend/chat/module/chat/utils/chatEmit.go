@@ -5,6 +5,7 @@ import (
 	restrictionModel "chat/module/restriction/model"
 	roomModel "chat/module/room/room/model"
 	userModel "chat/module/user/model"
+	"chat/pkg/core/admission"
 	"chat/pkg/core/kafka"
 	"chat/pkg/database/queries"
 	"context"
@@ -25,6 +26,7 @@ type ChatEventEmitter struct {
 	redis    *redis.Client
 	mongo    *mongo.Database
 	mcHelper *MCRoomHelper
+	userInfo *UserInfoResolver
 }
 
 func NewChatEventEmitter(hub *Hub, bus *kafka.Bus, redis *redis.Client, mongo *mongo.Database) *ChatEventEmitter {
@@ -34,6 +36,7 @@ func NewChatEventEmitter(hub *Hub, bus *kafka.Bus, redis *redis.Client, mongo *m
 		redis:    redis,
 		mongo:    mongo,
 		mcHelper: NewMCRoomHelper(mongo),
+		userInfo: GetUserInfoResolver(mongo, redis),
 	}
 }
 
@@ -97,6 +100,7 @@ func (e *ChatEventEmitter) EmitMessage(ctx context.Context, msg *model.ChatMessa
 		ID:        msg.ID.Hex(),
 		Type:      messageType,
 		Message:   msg.Message,
+		Seq:       msg.Seq,
 		Timestamp: msg.Timestamp,
 	}
 
@@ -131,6 +135,7 @@ func (e *ChatEventEmitter) EmitMessage(ctx context.Context, msg *model.ChatMessa
 				"type":      messageInfo.Type,
 				"message":   messageInfo.Message,
 				"timestamp": messageInfo.Timestamp,
+				"seq":       messageInfo.Seq,
 			},
 			"sticker": map[string]interface{}{
 				"_id":   stickerInfo.ID,
@@ -162,6 +167,7 @@ func (e *ChatEventEmitter) EmitMessage(ctx context.Context, msg *model.ChatMessa
 				"type":      "upload",
 				"message":   messageInfo.Message,
 				"timestamp": messageInfo.Timestamp,
+				"seq":       messageInfo.Seq,
 			},
 			"filename":  msg.Image,
 			"timestamp": msg.Timestamp,
@@ -206,6 +212,7 @@ func (e *ChatEventEmitter) EmitMessage(ctx context.Context, msg *model.ChatMessa
 				"type":      messageInfo.Type,
 				"message":   messageInfo.Message,
 				"timestamp": messageInfo.Timestamp,
+				"seq":       messageInfo.Seq,
 			},
 			"timestamp": msg.Timestamp,
 		}
@@ -247,6 +254,11 @@ func (e *ChatEventEmitter) EmitMessage(ctx context.Context, msg *model.ChatMessa
 }
 
 func (e *ChatEventEmitter) EmitTyping(ctx context.Context, roomID, userID string) error {
+	// typing เป็น event ที่ทิ้งได้ ตอน load สูงไม่ส่งเพื่อเก็บ bandwidth ไว้ให้ข้อความจริง
+	if admission.ShedNonEssential("typing") {
+		return nil
+	}
+
 	event := ChatEvent{
 		Type: "typing",
 		Payload: map[string]string{
@@ -307,98 +319,9 @@ func (e *ChatEventEmitter) EmitTyping(ctx context.Context, roomID, userID string
 // Helper methods for mobile event structure
 
 func (e *ChatEventEmitter) getUserInfo(ctx context.Context, userID primitive.ObjectID) (model.UserInfo, error) {
-	log.Printf("[DEBUG] Getting user info for ID: %s", userID.Hex())
-
-	// **USE SIMPLE QUERY WITHOUT POPULATE TO AVOID ROLE DECODING ISSUES**
-	userService := queries.NewBaseService[userModel.User](e.mongo.Collection("users"))
-	result, err := userService.FindOne(ctx, bson.M{"_id": userID})
-
-	if err != nil {
-		log.Printf("[WARN] Failed to query user %s: %v, using fallback", userID.Hex(), err)
-		// **FIXED: Never return error, always return valid user info**
-		return model.UserInfo{
-			ID:       userID.Hex(),
-			Username: "user_" + userID.Hex()[:8],
-			Name: map[string]interface{}{
-				"first":  "User",
-				"middle": "",
-				"last":   userID.Hex()[:8],
-			},
-		}, nil
-	}
-
-	if len(result.Data) == 0 {
-		log.Printf("[WARN] User %s not found in database, using fallback", userID.Hex())
-		// **FIXED: Never return error, always return valid user info**
-		return model.UserInfo{
-			ID:       userID.Hex(),
-			Username: "user_" + userID.Hex()[:8],
-			Name: map[string]interface{}{
-				"first":  "User",
-				"middle": "",
-				"last":   userID.Hex()[:8],
-			},
-		}, nil
-	}
-
-	user := result.Data[0]
-
-	// **LOG RAW DATA FOR DEBUGGING**
-	log.Printf("[DEBUG] Raw user data: ID=%s, Username='%s', Name.First='%s', Name.Middle='%s', Name.Last='%s'",
-		user.ID.Hex(), user.Username, user.Name.First, user.Name.Middle, user.Name.Last)
-
-	// **BUILD USER INFO WITH VALIDATION**
-	// **FIXED: Make validation less strict to handle missing fields gracefully**
-	if user.Username == "" {
-		log.Printf("[WARN] User %s has empty username, using fallback", userID.Hex())
-		user.Username = "user_" + userID.Hex()[:8] // Use first 8 chars of ID as fallback
-	}
-
-	if user.Name.First == "" && user.Name.Last == "" {
-		log.Printf("[WARN] User %s has empty name, using fallback", userID.Hex())
-		user.Name.First = "User"
-		user.Name.Last = userID.Hex()[:8] // Use first 8 chars of ID as fallback
-	}
-
-	userInfo := model.UserInfo{
-		ID:       user.ID.Hex(),
-		Username: user.Username,
-		Name: map[string]interface{}{
-			"first": user.Name.First,
-			"middle": func() string {
-				if user.Name.Middle == "" {
-					return ""
-				}
-				return user.Name.Middle
-			}(),
-			"last": user.Name.Last,
-		},
-	}
-
-	// **GET ROLE SEPARATELY TO AVOID POPULATION ISSUES**
-	if user.Role != primitive.NilObjectID {
-		log.Printf("[DEBUG] Getting role for user %s, role ID: %s", userID.Hex(), user.Role.Hex())
-		roleService := queries.NewBaseService[interface{}](e.mongo.Collection("roles"))
-		roleResult, err := roleService.FindOne(ctx, bson.M{"_id": user.Role})
-		if err == nil && len(roleResult.Data) > 0 {
-			if roleData, ok := roleResult.Data[0].(map[string]interface{}); ok {
-				if roleID, exists := roleData["_id"]; exists {
-					if roleName, exists := roleData["name"]; exists {
-						userInfo.Role = &model.RoleInfo{
-							ID:   roleID.(primitive.ObjectID).Hex(),
-							Name: roleName.(string),
-						}
-						log.Printf("[DEBUG] Successfully added role: %s", roleName.(string))
-					}
-				}
-			}
-		} else {
-			log.Printf("[WARNING] Failed to get role %s: %v", user.Role.Hex(), err)
-		}
-	}
-
-	log.Printf("[SUCCESS] Built userInfo: ID=%s, Username=%s, Name=%+v", userInfo.ID, userInfo.Username, userInfo.Name)
-	return userInfo, nil
+	// **NEW: ใช้ resolver ที่ batch + cache (memory/Redis) แทนการ query users/roles ทุกข้อความ**
+	// resolver ไม่คืน error เสมอ ถ้าหา user ไม่เจอจะได้ fallback user info
+	return e.userInfo.ResolveUser(ctx, userID), nil
 }
 
 func (e *ChatEventEmitter) getReplyToMessage(ctx context.Context, replyToID primitive.ObjectID) (*model.MessageInfo, error) {
@@ -435,6 +358,9 @@ func (e *ChatEventEmitter) emitEventStructured(ctx context.Context, msg *model.C
 			msg.RoomID.Hex(), msg.ID.Hex())
 	}
 
+	// **NEW: seq ของห้องอยู่ในทุก event ของข้อความ ให้ client ตรวจ gap ได้**
+	event.Seq = msg.Seq
+
 	// For WebSocket broadcasting, marshal to bytes (needed for WebSocket protocol)
 	eventBytes, err := json.Marshal(event)
 	if err != nil {
@@ -465,7 +391,7 @@ func (e *ChatEventEmitter) emitEventStructured(ctx context.Context, msg *model.C
 
 			if shouldShow {
 				log.Printf("[ChatEventEmitter] Sending message to user %s in MC room", userIDStr)
-				e.hub.BroadcastToUser(userIDStr, eventBytes)
+				e.hub.BroadcastToUserInRoom(msg.RoomID.Hex(), userIDStr, eventBytes)
 			} else {
 				log.Printf("[ChatEventEmitter] Hiding message from user %s in MC room", userIDStr)
 			}
@@ -512,6 +438,7 @@ func (e *ChatEventEmitter) EmitMentionMessage(ctx context.Context, msg *model.Ch
 		ID:        msg.ID.Hex(),
 		Type:      model.MessageTypeMention,
 		Message:   msg.Message,
+		Seq:       msg.Seq,
 		Timestamp: msg.Timestamp,
 	}
 
@@ -530,6 +457,7 @@ func (e *ChatEventEmitter) EmitMentionMessage(ctx context.Context, msg *model.Ch
 			"type":      messageInfo.Type,
 			"message":   messageInfo.Message,
 			"timestamp": messageInfo.Timestamp,
+			"seq":       messageInfo.Seq,
 		},
 		"mentions":  mentionInfo,
 		"timestamp": msg.Timestamp,
@@ -569,6 +497,7 @@ func (e *ChatEventEmitter) EmitEvoucherMessage(ctx context.Context, msg *model.C
 		ID:        msg.ID.Hex(),
 		Type:      model.MessageTypeEvoucher,
 		Message:   msg.Message,
+		Seq:       msg.Seq,
 		Timestamp: msg.Timestamp,
 	}
 
@@ -587,6 +516,7 @@ func (e *ChatEventEmitter) EmitEvoucherMessage(ctx context.Context, msg *model.C
 			"type":      messageInfo.Type,
 			"message":   messageInfo.Message,
 			"timestamp": messageInfo.Timestamp,
+			"seq":       messageInfo.Seq,
 		},
 		"evoucherInfo": map[string]interface{}{
 			"message":      msg.EvoucherInfo.Message,
@@ -637,6 +567,7 @@ func (e *ChatEventEmitter) EmitEvoucherClaimed(ctx context.Context, msg *model.C
 			"_id":       msg.ID.Hex(),
 			"type":      model.MessageTypeEvoucher,
 			"message":   msg.Message,
+			"seq":       msg.Seq,
 			"timestamp": msg.Timestamp,
 		},
 		"evoucherInfo": map[string]interface{}{
@@ -708,6 +639,7 @@ func (e *ChatEventEmitter) EmitRestrictionMessage(ctx context.Context, msg *mode
 
 	messageInfo := model.MessageInfo{
 		ID:        msg.ID.Hex(),
+		Seq:       msg.Seq,
 		Timestamp: msg.Timestamp,
 	}
 
@@ -751,6 +683,7 @@ func (e *ChatEventEmitter) EmitRestrictionMessage(ctx context.Context, msg *mode
 			"restriction": restrictionInfo,
 			"message":     messageInfo,
 		},
+		Seq:       msg.Seq,
 		Timestamp: msg.Timestamp,
 	}
 
@@ -796,6 +729,11 @@ func (e *ChatEventEmitter) EmitEvent(ctx context.Context, msg *model.ChatMessage
 	return nil
 }
 
+// Sequencer คืน sequencer ของห้อง (สำหรับ service ที่สร้างข้อความเองแล้วบันทึกก่อน emit เช่น evoucher, restriction)
+func (e *ChatEventEmitter) Sequencer() *RoomSequencer {
+	return GetRoomSequencer(e.mongo, e.redis)
+}
+
 // GetHub returns the chat hub (for use in restriction/event helpers)
 func (e *ChatEventEmitter) GetHub() *Hub {
 	return e.hub
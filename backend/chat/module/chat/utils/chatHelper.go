@@ -1,10 +1,14 @@
 package utils
 
 import (
+	"chat/module/chat/model"
+	"chat/pkg/core/admission"
+	"chat/pkg/core/logging"
 	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,6 +18,9 @@ const (
 	RoomTopicPrefix = "chat-room-"
 )
 
+// **NEW: hub log เป็น debug (ต่อ user ต่อ message) ปิด/จำกัดได้ผ่าน LOG_MODULE_LEVELS=ws=...**
+var hubLog = logging.Module("ws")
+
 type(
 	ChatEvent struct {
 		Type    string      `json:"type"`
@@ -27,12 +34,17 @@ type(
 	}
 
 	Hub struct {
-		clients sync.Map
+		clients  sync.Map
+		sessions sync.Map // *websocket.Conn -> *Session (multiplexed sockets)
+		// writeTimeout เวลาสูงสุดที่รอเขียนไปยัง connection เดียวระหว่าง broadcast (RELIABILITY_BROADCAST_TIMEOUT)
+		writeTimeout time.Duration
 	}
 ) 
 
-func NewHub() *Hub {
-	return &Hub{}
+func NewHub(writeTimeout time.Duration) *Hub {
+	h := &Hub{writeTimeout: writeTimeout}
+	metricsHub.Store(h)
+	return h
 }
 
 func getRoomTopic(roomID string) string {
@@ -63,19 +75,10 @@ func (h *Hub) Register(c Client) {
 	userKey := c.UserID.Hex()
 	connID := fmt.Sprintf("%p", c.Conn)
 
-	log.Printf("[DEBUG] Registering user with ID=%s in room=%s", userKey, roomKey)
-
 	roomMap, _ := h.clients.LoadOrStore(roomKey, &sync.Map{})
 	userConns, _ := roomMap.(*sync.Map).LoadOrStore(userKey, &sync.Map{})
 	userConns.(*sync.Map).Store(connID, c.Conn)
 
-	// Log all registered users in the room
-	log.Printf("[DEBUG] Current users in room %s:", roomKey)
-	roomMap.(*sync.Map).Range(func(uid, _ interface{}) bool {
-		log.Printf("[DEBUG] - User: %v", uid)
-		return true
-	})
-
 	users, conns := h.countRoomStats(roomKey)
 	log.Printf("[WS] User %s joined room %s (connection: %s) - Users: %d, Connections: %d", 
 		userKey, roomKey, connID, users, conns)
@@ -123,8 +126,32 @@ func (h *Hub) countRoomStats(roomID string) (users int, connections int) {
 
 
 
+// **NEW: event ที่ทิ้งได้ตอน load สูง (presence/typing) ข้อความจริงและ push ของคน offline ยังส่งตามปกติ**
+var sheddableEventTypes = map[string]bool{
+	model.EventTypePresence:   true,
+	model.EventTypeUserJoined: true,
+	model.EventTypeUserLeft:   true,
+	model.EventTypeTyping:     true,
+}
+
+// shedEvent ตรวจว่าควรข้าม event นี้หรือไม่ (ใช้ทั้ง event ของ instance นี้และที่มาจาก Kafka)
+func shedEvent(eventType string) bool {
+	if !sheddableEventTypes[eventType] {
+		return false
+	}
+	kind := eventType
+	if kind != model.EventTypeTyping {
+		kind = "presence"
+	}
+	return admission.ShedNonEssential(kind)
+}
+
 func (h *Hub) BroadcastEvent(event ChatEvent) {
-	log.Printf("[ChatMessage] Broadcasting event type=%s", event.Type)
+	hubLog.Debug("broadcasting event", "type", event.Type)
+
+	if shedEvent(event.Type) {
+		return
+	}
 
 	// Check if the event payload is empty
 	if isEmptyMessage(event.Payload) {
@@ -139,9 +166,6 @@ func (h *Hub) BroadcastEvent(event ChatEvent) {
 		return
 	}
 
-	// Log the payload structure for debugging
-	log.Printf("[DEBUG] Event payload structure: %+v", event.Payload)
-
 	// Extract roomID from payload - handle different payload types
 	var roomID string
 	
@@ -190,7 +214,6 @@ func (h *Hub) BroadcastEvent(event ChatEvent) {
 	}
 
 	if roomID != "" {
-		log.Printf("[DEBUG] Found room ID: %s, broadcasting to room", roomID)
 		h.BroadcastToRoom(roomID, payload)
 	} else {
 		log.Printf("[WARN] Cannot broadcast event - no room ID found in payload")
@@ -206,22 +229,17 @@ func (h *Hub) BroadcastToRoom(roomID string, payload []byte) {
 		return
 	}
 	if roomMap, ok := h.clients.Load(roomID); ok {
-		log.Printf("[WS] Broadcasting to room %s", roomID)
-		
 		roomMap.(*sync.Map).Range(func(userID, userConns interface{}) bool {
-			log.Printf("[WS] Broadcasting to user %s in room %s", userID, roomID)
-			
 			activeConns := 0
 			userConns.(*sync.Map).Range(func(connID, conn interface{}) bool {
 				ws := conn.(*websocket.Conn)
-				if err := ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+				if err := h.WriteToConn(roomID, ws, payload); err != nil {
 					log.Printf("[WS] Failed to send to user %s (connection: %s): %v", userID, connID, err)
 					_ = ws.Close()
 					userConns.(*sync.Map).Delete(connID)
 					failCount++
 				} else {
-					log.Printf("[WS] Successfully sent message to user %s (connection: %s)", userID, connID)
-					successCount++
+						successCount++
 					activeConns++
 				}
 				return true
@@ -235,10 +253,9 @@ func (h *Hub) BroadcastToRoom(roomID string, payload []byte) {
 			return true
 		})
 
-		log.Printf("[WS] Broadcast complete for room %s: %d successful, %d failed", 
-			roomID, successCount, failCount)
+		hubLog.Debug("broadcast complete", "roomId", roomID, "sent", successCount, "failed", failCount)
 	} else {
-		log.Printf("[WS] No clients found for room %s", roomID)
+		hubLog.Debug("no clients in room", "roomId", roomID)
 	}
 }
 
@@ -251,15 +268,6 @@ func (h *Hub) BroadcastToRoomExcept(roomID string, excludeUserID string, payload
 		return
 	}
 	if roomMap, ok := h.clients.Load(roomID); ok {
-		log.Printf("[DEBUG] Broadcasting to room %s (excluding user %s)", roomID, excludeUserID)
-		
-		// Log all users in the room before broadcasting
-		log.Printf("[DEBUG] Current users in room %s before broadcast:", roomID)
-		roomMap.(*sync.Map).Range(func(uid, _ interface{}) bool {
-			log.Printf("[DEBUG] - Found user: %v", uid)
-			return true
-		})
-		
 		roomMap.(*sync.Map).Range(func(userID, userConns interface{}) bool {
 			uidStr, ok := userID.(string)
 			if !ok {
@@ -269,23 +277,19 @@ func (h *Hub) BroadcastToRoomExcept(roomID string, excludeUserID string, payload
 
 			// Skip the excluded user - using exact string comparison
 			if uidStr == excludeUserID {
-				log.Printf("[DEBUG] Skipping excluded user: %s", uidStr)
 				return true
 			}
 
-			log.Printf("[WS] Broadcasting to user %s in room %s", uidStr, roomID)
-			
 			activeConns := 0
 			userConns.(*sync.Map).Range(func(connID, conn interface{}) bool {
 				ws := conn.(*websocket.Conn)
-				if err := ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+				if err := h.WriteToConn(roomID, ws, payload); err != nil {
 					log.Printf("[WS] Failed to send to user %s (connection: %s): %v", uidStr, connID, err)
 					_ = ws.Close()
 					userConns.(*sync.Map).Delete(connID)
 					failCount++
 				} else {
-					log.Printf("[WS] Successfully sent message to user %s (connection: %s)", uidStr, connID)
-					successCount++
+						successCount++
 					activeConns++
 				}
 				return true
@@ -299,10 +303,9 @@ func (h *Hub) BroadcastToRoomExcept(roomID string, excludeUserID string, payload
 			return true
 		})
 
-		log.Printf("[WS] Broadcast complete for room %s (except user %s): %d successful, %d failed", 
-			roomID, excludeUserID, successCount, failCount)
+		hubLog.Debug("broadcast complete", "roomId", roomID, "exceptUserId", excludeUserID, "sent", successCount, "failed", failCount)
 	} else {
-		log.Printf("[WS] No clients found for room %s", roomID)
+		hubLog.Debug("no clients in room", "roomId", roomID)
 	}
 }
 
@@ -387,7 +390,9 @@ func (h *Hub) BroadcastToUser(targetUserID string, payload []byte) {
 		log.Printf("[DEBUG] Skipping broadcast: empty chat message detected")
 		return
 	}
-	log.Printf("[WS] Broadcasting to user %s across all rooms", targetUserID)
+
+	// session เดียวอาจอยู่หลายห้อง ส่งครั้งเดียวต่อ connection
+	sent := make(map[*websocket.Conn]bool)
 	
 	// วนลูปทุก room เพื่อหา user
 	h.clients.Range(func(roomIDInterface, roomMapInterface interface{}) bool {
@@ -400,18 +405,20 @@ func (h *Hub) BroadcastToUser(targetUserID string, payload []byte) {
 		
 		// ตรวจสอบว่า user อยู่ใน room นี้หรือไม่
 		if userConns, exists := roomMap.Load(targetUserID); exists {
-			log.Printf("[WS] Found user %s in room %s, sending message", targetUserID, roomID)
 			
 			// ส่งข้อความไปยังทุก connection ของ user ใน room นี้
 			userConns.(*sync.Map).Range(func(connID, conn interface{}) bool {
 				ws := conn.(*websocket.Conn)
-				if err := ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+				if sent[ws] {
+					return true
+				}
+				sent[ws] = true
+				if err := h.WriteToConn(roomID, ws, payload); err != nil {
 					log.Printf("[WS] Failed to send to user %s (connection: %s): %v", targetUserID, connID, err)
 					_ = ws.Close()
 					userConns.(*sync.Map).Delete(connID)
 					failCount++
 				} else {
-					log.Printf("[WS] Successfully sent message to user %s (connection: %s) in room %s", targetUserID, connID, roomID)
 					successCount++
 				}
 				return true
@@ -420,7 +427,29 @@ func (h *Hub) BroadcastToUser(targetUserID string, payload []byte) {
 		return true
 	})
 	
-	log.Printf("[WS] Broadcast to user %s complete: %d successful, %d failed", targetUserID, successCount, failCount)
+	hubLog.Debug("broadcast to user complete", "userId", targetUserID, "sent", successCount, "failed", failCount)
+}
+
+// BroadcastToUserInRoom ส่งข้อความไปยัง user เฉพาะใน room ที่ระบุ (ใช้กับ MC room)
+func (h *Hub) BroadcastToUserInRoom(roomID string, targetUserID string, payload []byte) {
+	roomMap, ok := h.clients.Load(roomID)
+	if !ok {
+		return
+	}
+	userConns, ok := roomMap.(*sync.Map).Load(targetUserID)
+	if !ok {
+		return
+	}
+
+	userConns.(*sync.Map).Range(func(connID, conn interface{}) bool {
+		ws := conn.(*websocket.Conn)
+		if err := h.WriteToConn(roomID, ws, payload); err != nil {
+			log.Printf("[WS] Failed to send to user %s (connection: %s) in room %s: %v", targetUserID, connID, roomID, err)
+			_ = ws.Close()
+			userConns.(*sync.Map).Delete(connID)
+		}
+		return true
+	})
 }
 
 // ForceDisconnectAllUsersFromRoom forcefully disconnects all users from a specific room
@@ -443,6 +472,13 @@ func (h *Hub) ForceDisconnectAllUsersFromRoom(roomID string) int {
 				ws := conn.(*websocket.Conn)
 				log.Printf("[WS] Closing WebSocket connection %s for user %s in room %s", connID, userIDStr, roomID)
 				
+				// session ที่ subscribe หลายห้อง: ถอดเฉพาะห้องนี้ ไม่ปิดทั้ง socket
+				if h.detachSessionFromRoom(roomID, ws, "Room deactivated") {
+					userConns.(*sync.Map).Delete(connID)
+					disconnectedCount++
+					return true
+				}
+
 				// Send a close message before closing
 				closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Room deactivated")
 				_ = ws.WriteMessage(websocket.CloseMessage, closeMsg)
@@ -484,6 +520,13 @@ func (h *Hub) ForceDisconnectUserFromRoom(roomID string, userID string) int {
 				ws := conn.(*websocket.Conn)
 				log.Printf("[WS] Closing WebSocket connection %s for user %s in room %s", connID, userID, roomID)
 				
+				// session ที่ subscribe หลายห้อง: ถอดเฉพาะห้องนี้ ไม่ปิดทั้ง socket
+				if h.detachSessionFromRoom(roomID, ws, "You have been kicked from this room") {
+					userConns.(*sync.Map).Delete(connID)
+					disconnectedCount++
+					return true
+				}
+
 				// Send a close message before closing
 				closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "You have been kicked from this room")
 				_ = ws.WriteMessage(websocket.CloseMessage, closeMsg)
@@ -525,7 +568,11 @@ func (h *Hub) HandleKafkaMessage(topic string, payload []byte) error {
 		return fmt.Errorf("failed to unmarshal Kafka message: %w", err)
 	}
 
-	log.Printf("[ChatMessage] Received Kafka message from topic %s: type=%s", topic, event.Type)
+	hubLog.Debug("received kafka message", "topic", topic, "type", event.Type)
+
+	if shedEvent(event.Type) {
+		return nil
+	}
 
 	h.BroadcastToRoom(roomID, payload)
 	
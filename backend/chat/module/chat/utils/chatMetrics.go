@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"chat/pkg/core/metrics"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// outboxMetricsTimeout เวลาสูงสุดที่อ่านขนาด outbox จาก Redis ต่อการ scrape
+const outboxMetricsTimeout = 500 * time.Millisecond
+
+// **NEW: Gauge ที่อ่านค่าตอน scrape /metrics จาก hub, worker pools และ outbox**
+var (
+	metricsHub         atomic.Pointer[Hub]
+	metricsAsyncHelper atomic.Pointer[AsyncHelper]
+	metricsOutbox      atomic.Pointer[MessageOutbox]
+
+	_ = metrics.NewGaugeFunc("chat_ws_connections",
+		"Active websocket connections across all rooms.", "",
+		func() map[string]float64 {
+			total := 0
+			for _, conns := range hubConnectionCounts() {
+				total += conns
+			}
+			return map[string]float64{"": float64(total)}
+		})
+
+	_ = metrics.NewGaugeFunc("chat_ws_room_connections",
+		"Active websocket connections per room (only when METRICS_ROOM_LABELS is enabled).", "room",
+		func() map[string]float64 {
+			values := map[string]float64{}
+			if !metrics.RoomLabelsEnabled() {
+				return values
+			}
+			for roomID, conns := range hubConnectionCounts() {
+				values[roomID] = float64(conns)
+			}
+			return values
+		})
+
+	_ = metrics.NewGaugeFunc("chat_ws_active_rooms",
+		"Rooms with at least one active websocket connection.", "",
+		func() map[string]float64 {
+			return map[string]float64{"": float64(len(hubConnectionCounts()))}
+		})
+
+	_ = metrics.NewGaugeFunc("chat_worker_queue_depth",
+		"Jobs waiting in worker pool queues, by queue (database, notification and their retry queues; "+
+			"outbox is every unacknowledged stream entry, outbox_pending those already read by a worker).", "queue",
+		func() map[string]float64 {
+			values := map[string]float64{}
+			if h := metricsAsyncHelper.Load(); h != nil {
+				values["database"] = float64(len(h.dbWorkerPool.jobs))
+				values["database_retry"] = float64(len(h.dbWorkerPool.retryQueue))
+				values["notification"] = float64(len(h.notifyWorkerPool.jobs))
+				values["notification_retry"] = float64(len(h.notifyWorkerPool.retryQueue))
+			}
+			if o := metricsOutbox.Load(); o != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), outboxMetricsTimeout)
+				defer cancel()
+				if length, pending, err := o.Backlog(ctx); err == nil {
+					values["outbox"] = float64(length)
+					values["outbox_pending"] = float64(pending)
+				}
+			}
+			return values
+		})
+)
+
+// hubConnectionCounts นับ connection ต่อห้องจาก hub ปัจจุบัน
+func hubConnectionCounts() map[string]int {
+	counts := map[string]int{}
+	h := metricsHub.Load()
+	if h == nil {
+		return counts
+	}
+
+	h.clients.Range(func(roomID, _ interface{}) bool {
+		if _, conns := h.countRoomStats(roomID.(string)); conns > 0 {
+			counts[roomID.(string)] = conns
+		}
+		return true
+	})
+	return counts
+}
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"chat/module/chat/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: สถานะการส่งข้อความที่ผู้ส่งเห็นได้ (sending / sent / failed - retry)**
+// สถานะล่าสุดเก็บใน Redis hash ต่อข้อความ (อายุตาม ASYNC_MESSAGE_STATUS_TTL) และทุกการเปลี่ยนสถานะถูก publish
+// ไปยังทุก instance เพื่อส่ง event ให้ connection ของผู้ส่งในห้องนั้น (persist อาจเกิดบน instance อื่นผ่าน outbox)
+const (
+	MessageStatusEvent    = "message_status"
+	DeliveryStatusChannel = "chat:delivery:events"
+
+	DeliveryAccepted  = "accepted"  // server รับข้อความและให้ ID แล้ว
+	DeliveryDelivered = "delivered" // broadcast ไปยังสมาชิกที่ออนไลน์แล้ว
+	DeliveryPersisted = "persisted" // บันทึกลง Mongo แล้ว
+	DeliveryFailed    = "failed"    // ส่งหรือบันทึกไม่สำเร็จ (ดู reason)
+
+	// reason ของ DeliveryFailed
+	DeliveryReasonBroadcastFailed = "broadcast_failed"
+	DeliveryReasonPersistFailed   = "persist_failed"
+
+	deliveryKeyPrefix = "chat:delivery:"
+)
+
+type (
+	// DeliveryStatus คือสถานะล่าสุดของข้อความ (status คำนวณจาก field: failed > persisted > delivered > accepted)
+	DeliveryStatus struct {
+		MessageID   string    `json:"messageId"`
+		RoomID      string    `json:"roomId"`
+		SenderID    string    `json:"senderId"`
+		Status      string    `json:"status"`
+		Persisted   bool      `json:"persisted"`
+		DeliveredTo int       `json:"deliveredTo"` // จำนวนสมาชิกที่ออนไลน์ตอน broadcast (ไม่นับผู้ส่ง)
+		Reason      string    `json:"reason,omitempty"`
+		UpdatedAt   time.Time `json:"updatedAt"`
+	}
+
+	// DeliveryTracker บันทึกและกระจายสถานะการส่งข้อความให้ผู้ส่ง
+	DeliveryTracker struct {
+		redis   *redis.Client
+		hub     *Hub
+		ttl     time.Duration
+		enabled bool
+	}
+)
+
+// NewDeliveryTracker สร้าง tracker (enabled=false จะไม่บันทึกและไม่ส่ง event)
+func NewDeliveryTracker(redisClient *redis.Client, hub *Hub, ttl time.Duration, enabled bool) *DeliveryTracker {
+	return &DeliveryTracker{
+		redis:   redisClient,
+		hub:     hub,
+		ttl:     ttl,
+		enabled: enabled,
+	}
+}
+
+// Start เริ่มรับ event จาก instance อื่น (หยุดเมื่อ ctx ถูก cancel)
+func (t *DeliveryTracker) Start(ctx context.Context) {
+	if !t.enabled {
+		return
+	}
+	go t.listen(ctx)
+}
+
+// Accepted บันทึกว่าข้อความถูกรับแล้ว (เรียกหลังสร้าง message ID)
+func (t *DeliveryTracker) Accepted(ctx context.Context, msg *model.ChatMessage) {
+	t.update(ctx, msg.ID, DeliveryAccepted, map[string]interface{}{
+		"room_id":   msg.RoomID.Hex(),
+		"sender_id": msg.UserID.Hex(),
+	})
+}
+
+// Delivered บันทึกจำนวนสมาชิกที่ออนไลน์ตอน broadcast สำเร็จ
+func (t *DeliveryTracker) Delivered(ctx context.Context, messageID primitive.ObjectID, deliveredTo int) {
+	t.update(ctx, messageID, DeliveryDelivered, map[string]interface{}{
+		"delivered_to": deliveredTo,
+	})
+}
+
+// Persisted บันทึกว่าข้อความอยู่ใน Mongo แล้ว
+func (t *DeliveryTracker) Persisted(ctx context.Context, messageID primitive.ObjectID) {
+	t.update(ctx, messageID, DeliveryPersisted, map[string]interface{}{
+		"persisted": 1,
+		"reason":    "", // phantom fix อาจบันทึกข้อความที่เคย failed ได้ภายหลัง
+	})
+}
+
+// Failed บันทึกว่าข้อความส่งหรือบันทึกไม่สำเร็จ
+func (t *DeliveryTracker) Failed(ctx context.Context, messageID primitive.ObjectID, reason string) {
+	t.update(ctx, messageID, DeliveryFailed, map[string]interface{}{
+		"reason": reason,
+	})
+}
+
+// Get คืนสถานะล่าสุดของข้อความ (nil ถ้าไม่มี record หรือหมดอายุแล้ว)
+func (t *DeliveryTracker) Get(ctx context.Context, messageID primitive.ObjectID) (*DeliveryStatus, error) {
+	fields, err := t.redis.HGetAll(ctx, deliveryKeyPrefix+messageID.Hex()).Result()
+	if err != nil {
+		return nil, err
+	}
+	if fields["sender_id"] == "" {
+		return nil, nil
+	}
+	return deliveryStatusFromFields(messageID.Hex(), fields), nil
+}
+
+// update เขียน field ใหม่และอ่านสถานะทั้งหมดกลับใน transaction เดียว แล้ว publish ให้ทุก instance
+func (t *DeliveryTracker) update(ctx context.Context, messageID primitive.ObjectID, transition string, fields map[string]interface{}) {
+	if !t.enabled {
+		return
+	}
+	key := deliveryKeyPrefix + messageID.Hex()
+	fields["updated_at"] = time.Now().UnixMilli()
+
+	pipe := t.redis.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, t.ttl)
+	all := pipe.HGetAll(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Delivery] ⚠️ Failed to update status %s of message %s: %v", transition, messageID.Hex(), err)
+		return
+	}
+
+	// ไม่มี sender = ไม่ได้ผ่าน Accepted (เช่น record หมดอายุ หรือข้อความจาก flow อื่น) จึงไม่มีใครให้แจ้ง
+	if all.Val()["sender_id"] == "" {
+		t.redis.Del(ctx, key)
+		return
+	}
+
+	status := deliveryStatusFromFields(messageID.Hex(), all.Val())
+	payload, err := json.Marshal(deliveryEvent{Transition: transition, Status: status})
+	if err != nil {
+		return
+	}
+	if err := t.redis.Publish(ctx, DeliveryStatusChannel, payload).Err(); err != nil {
+		log.Printf("[Delivery] ⚠️ Failed to publish status of message %s: %v", messageID.Hex(), err)
+	}
+}
+
+// deliveryEvent คือข้อความบน DeliveryStatusChannel
+type deliveryEvent struct {
+	Transition string          `json:"transition"`
+	Status     *DeliveryStatus `json:"status"`
+}
+
+// listen ส่ง event ให้ connection ของผู้ส่งที่อยู่บน instance นี้
+func (t *DeliveryTracker) listen(ctx context.Context) {
+	pubsub := t.redis.Subscribe(ctx, DeliveryStatusChannel)
+	defer pubsub.Close()
+
+	log.Printf("[Delivery] 👂 Listening for status events on %s", DeliveryStatusChannel)
+
+	for msg := range pubsub.Channel() {
+		var event deliveryEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil || event.Status == nil {
+			log.Printf("[Delivery] ⚠️ Ignoring invalid status event: %v", err)
+			continue
+		}
+		status := event.Status
+		if !t.hub.IsUserOnlineInRoom(status.RoomID, status.SenderID) {
+			continue
+		}
+
+		payload, err := json.Marshal(map[string]interface{}{
+			"type": MessageStatusEvent,
+			"payload": map[string]interface{}{
+				"messageId":   status.MessageID,
+				"roomId":      status.RoomID,
+				"transition":  event.Transition,
+				"status":      status.Status,
+				"persisted":   status.Persisted,
+				"deliveredTo": status.DeliveredTo,
+				"reason":      status.Reason,
+				"timestamp":   status.UpdatedAt,
+			},
+		})
+		if err != nil {
+			continue
+		}
+		t.hub.BroadcastToUserInRoom(status.RoomID, status.SenderID, payload)
+	}
+}
+
+func deliveryStatusFromFields(messageID string, fields map[string]string) *DeliveryStatus {
+	status := &DeliveryStatus{
+		MessageID: messageID,
+		RoomID:    fields["room_id"],
+		SenderID:  fields["sender_id"],
+		Persisted: fields["persisted"] == "1",
+		Reason:    fields["reason"],
+	}
+	status.DeliveredTo, _ = strconv.Atoi(fields["delivered_to"])
+	if ms, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		status.UpdatedAt = time.UnixMilli(ms)
+	}
+
+	switch {
+	case status.Reason != "":
+		status.Status = DeliveryFailed
+	case status.Persisted:
+		status.Status = DeliveryPersisted
+	case fields["delivered_to"] != "":
+		status.Status = DeliveryDelivered
+	default:
+		status.Status = DeliveryAccepted
+	}
+	return status
+}
+
+// deliveryScanCount จำนวน key ต่อรอบของ SCAN เมื่อค้นสถานะตามผู้ส่ง
+const deliveryScanCount = 500
+
+// DeliveryStatusesBySender คืนสถานะการส่งทุกข้อความของผู้ส่งที่ยังไม่หมดอายุ
+// (รวมข้อความที่บันทึกไม่สำเร็จและไม่มีใน Mongo จึงต้องค้นจาก Redis โดยตรง)
+func DeliveryStatusesBySender(ctx context.Context, redisClient *redis.Client, senderID string) ([]DeliveryStatus, error) {
+	statuses := []DeliveryStatus{}
+	err := scanDeliveryBySender(ctx, redisClient, senderID, func(keys []string, fields []map[string]string) error {
+		for i, key := range keys {
+			statuses = append(statuses, *deliveryStatusFromFields(key[len(deliveryKeyPrefix):], fields[i]))
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// DeleteDeliveryStatusesBySender ลบสถานะการส่งทุกข้อความของผู้ส่ง คืนจำนวน key ที่ลบ
+// (update ที่มาทีหลังจะไม่สร้าง record ใหม่ เพราะไม่มี sender_id แล้ว)
+func DeleteDeliveryStatusesBySender(ctx context.Context, redisClient *redis.Client, senderID string) (int64, error) {
+	var deleted int64
+	err := scanDeliveryBySender(ctx, redisClient, senderID, func(keys []string, _ []map[string]string) error {
+		n, err := redisClient.Del(ctx, keys...).Result()
+		deleted += n
+		return err
+	})
+	return deleted, err
+}
+
+// scanDeliveryBySender ไล่ทุก key ของสถานะการส่งด้วย SCAN แล้วเรียก fn กับ key ที่เป็นของผู้ส่งทีละรอบ
+func scanDeliveryBySender(ctx context.Context, redisClient *redis.Client, senderID string, fn func(keys []string, fields []map[string]string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := redisClient.Scan(ctx, cursor, deliveryKeyPrefix+"*", deliveryScanCount).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan delivery statuses: %w", err)
+		}
+
+		if len(keys) > 0 {
+			pipe := redisClient.Pipeline()
+			cmds := make([]*redis.MapStringStringCmd, len(keys))
+			for i, key := range keys {
+				cmds[i] = pipe.HGetAll(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return fmt.Errorf("failed to read delivery statuses: %w", err)
+			}
+
+			var matched []string
+			var matchedFields []map[string]string
+			for i, cmd := range cmds {
+				if fields := cmd.Val(); fields["sender_id"] == senderID {
+					matched = append(matched, keys[i])
+					matchedFields = append(matchedFields, fields)
+				}
+			}
+			if len(matched) > 0 {
+				if err := fn(matched, matchedFields); err != nil {
+					return err
+				}
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
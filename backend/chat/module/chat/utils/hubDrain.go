@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// ServerRestartingEvent แจ้ง client ก่อนปิด socket ว่า instance นี้กำลังจะปิด
+// reconnectAfterMs ถูกสุ่มต่อ connection เพื่อไม่ให้ทุกคน reconnect ไปยัง pod ถัดไปพร้อมกัน
+const ServerRestartingEvent = "server_restarting"
+
+// drainTarget คือ socket หนึ่งตัว (per-room socket หรือ multiplexed session)
+type drainTarget struct {
+	conn    *websocket.Conn
+	session *Session
+}
+
+// Drain ส่ง server_restarting ให้ทุก socket แล้วทยอยปิดด้วย close code 1012 (Service Restart)
+// ภายใน window คืนจำนวน socket ที่ปิด
+func (h *Hub) Drain(ctx context.Context, window time.Duration) int {
+	targets := h.drainTargets()
+	if len(targets) == 0 {
+		log.Printf("[WS] Drain: no open connections")
+		return 0
+	}
+	log.Printf("[WS] 🚰 Draining %d connections over %s", len(targets), window)
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		delay := time.Duration(0)
+		if window > 0 {
+			delay = time.Duration(rand.Int63n(int64(window)))
+		}
+		target.notifyRestart(delay)
+
+		wg.Add(1)
+		go func(t drainTarget, delay time.Duration) {
+			defer wg.Done()
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			t.close()
+		}(target, delay)
+	}
+
+	wg.Wait()
+	log.Printf("[WS] ✅ Drain complete, closed %d connections", len(targets))
+	return len(targets)
+}
+
+// drainTargets รวบรวม socket ที่ไม่ซ้ำกัน (session หนึ่งตัวอาจอยู่ในหลายห้อง)
+func (h *Hub) drainTargets() []drainTarget {
+	seen := make(map[*websocket.Conn]bool)
+	var targets []drainTarget
+
+	h.sessions.Range(func(conn, session interface{}) bool {
+		ws := conn.(*websocket.Conn)
+		seen[ws] = true
+		targets = append(targets, drainTarget{conn: ws, session: session.(*Session)})
+		return true
+	})
+
+	h.clients.Range(func(_, roomMap interface{}) bool {
+		roomMap.(*sync.Map).Range(func(_, userConns interface{}) bool {
+			userConns.(*sync.Map).Range(func(_, conn interface{}) bool {
+				ws := conn.(*websocket.Conn)
+				if !seen[ws] {
+					seen[ws] = true
+					targets = append(targets, drainTarget{conn: ws})
+				}
+				return true
+			})
+			return true
+		})
+		return true
+	})
+
+	return targets
+}
+
+func (t drainTarget) notifyRestart(closeIn time.Duration) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"type": ServerRestartingEvent,
+		"payload": map[string]interface{}{
+			"reconnectAfterMs": closeIn.Milliseconds(),
+			"timestamp":        time.Now(),
+		},
+	})
+	if err != nil {
+		return
+	}
+	_ = t.write(websocket.TextMessage, payload)
+}
+
+func (t drainTarget) close() {
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	_ = t.write(websocket.CloseMessage, closeMsg)
+	_ = t.conn.Close()
+}
+
+// write ใช้ lock ของ session (ถ้ามี) และตั้ง deadline เพื่อไม่ให้ client ที่ค้างทำให้ drain เกิน window
+func (t drainTarget) write(messageType int, payload []byte) error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	if t.session != nil {
+		return t.session.WriteRaw(messageType, payload)
+	}
+	return t.conn.WriteMessage(messageType, payload)
+}
@@ -0,0 +1,596 @@
+package utils
+
+import (
+	"bufio"
+	"chat/module/chat/model"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Cold archive ของข้อความที่ retention worker ย้ายออกจาก chat-messages**
+// mode collection เก็บใน chat-messages-archive (query ได้เหมือนเดิม), mode file เก็บเป็น JSONL บีบอัดไฟล์ละห้อง
+// history API อ่านจาก archive ต่อเมื่อข้อความใน chat-messages ไม่พอ
+const (
+	ArchiveModeCollection = "collection"
+	ArchiveModeFile       = "file"
+
+	ArchiveCollectionName = "chat-messages-archive"
+
+	// ขนาดบรรทัดสูงสุดของไฟล์ archive (ข้อความยาว + mention/evoucher)
+	archiveMaxLineBytes = 1024 * 1024
+)
+
+type (
+	// MessageArchive ที่เก็บข้อความเก่า Store ต้องเรียกซ้ำได้ (worker อาจล้มหลังเขียน archive แต่ก่อนลบจาก chat-messages)
+	MessageArchive interface {
+		Store(ctx context.Context, roomID primitive.ObjectID, msgs []model.ChatMessage) error
+		Load(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery) ([]model.ChatMessage, error)
+		// Stream ส่งข้อความของห้องเรียงเก่าไปใหม่ทีละไม่เกิน batchSize (ไม่โหลดทั้ง archive) ใช้ After/Before/IncludeUnsent ของ query
+		Stream(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery, batchSize int, fn func([]model.ChatMessage) error) error
+		// Count นับข้อความตาม query เหมือน Stream หยุดนับเมื่อถึง limit (ถ้า > 0)
+		Count(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery, limit int64) (int64, error)
+		// LoadByUser / RewriteByUser ใช้กับ export/erasure ข้อมูลผู้ใช้ (ข้อความทุกห้องที่ UserRelatedFilter ครอบคลุม)
+		LoadByUser(ctx context.Context, userID primitive.ObjectID) ([]model.ChatMessage, error)
+		RewriteByUser(ctx context.Context, userID primitive.ObjectID, mutate func(*model.ChatMessage) bool) (int64, error)
+		Target() string
+	}
+
+	// ArchiveQuery ขอข้อความที่เก่ากว่า BeforeSeq (ถ้า > 0) หรือเก่ากว่า Before (ถ้าไม่ใช่ zero) เรียงใหม่ไปเก่า
+	ArchiveQuery struct {
+		BeforeSeq     int64
+		Before        time.Time
+		After         time.Time // ไม่เก่ากว่า After (รวม) ใช้กับ Stream/Count ของ transcript
+		Limit         int
+		IncludeUnsent bool // ข้อความที่ unsend (archive รุ่นก่อนอาจมี) ใช้กับ transcript ที่ขอรวมข้อความ unsend
+	}
+
+	collectionArchive struct {
+		collection *mongo.Collection
+	}
+
+	fileArchive struct {
+		dir string
+		mu  sync.Mutex
+	}
+
+	archivedMessage struct {
+		model.ChatMessage `bson:",inline"`
+		ArchivedAt        time.Time `bson:"archived_at"`
+	}
+)
+
+// NewMessageArchive สร้าง archive ตาม RETENTION_ARCHIVE_MODE
+func NewMessageArchive(db *mongo.Database, mode, dir string) MessageArchive {
+	if mode == ArchiveModeFile {
+		return &fileArchive{dir: dir}
+	}
+	return &collectionArchive{collection: db.Collection(ArchiveCollectionName)}
+}
+
+func (a *collectionArchive) Target() string {
+	return ArchiveCollectionName
+}
+
+func (a *collectionArchive) Store(ctx context.Context, roomID primitive.ObjectID, msgs []model.ChatMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	docs := make([]interface{}, len(msgs))
+	for i := range msgs {
+		docs[i] = archivedMessage{ChatMessage: msgs[i], ArchivedAt: now}
+	}
+
+	_, err := a.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicateKeyErrors(err) {
+		return fmt.Errorf("failed to archive messages of room %s: %w", roomID.Hex(), err)
+	}
+	return nil
+}
+
+func (a *collectionArchive) Load(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery) ([]model.ChatMessage, error) {
+	filter := bson.M{"room_id": roomID}
+	if !query.IncludeUnsent {
+		filter["is_deleted"] = bson.M{"$ne": true}
+	}
+	sortKey := "timestamp"
+	switch {
+	case query.BeforeSeq > 0:
+		filter["seq"] = bson.M{"$gt": 0, "$lt": query.BeforeSeq}
+		sortKey = "seq"
+	case !query.Before.IsZero():
+		filter["timestamp"] = bson.M{"$lt": query.Before}
+	}
+
+	cursor, err := a.collection.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: sortKey, Value: -1}}).SetLimit(int64(query.Limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+	var messages []model.ChatMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode archive of room %s: %w", roomID.Hex(), err)
+	}
+	return messages, nil
+}
+
+// rangeFilter เงื่อนไขของ Stream/Count: ช่วง [After, Before) และข้อความ unsend
+func (a *collectionArchive) rangeFilter(roomID primitive.ObjectID, query ArchiveQuery) bson.M {
+	filter := bson.M{"room_id": roomID}
+	if !query.IncludeUnsent {
+		filter["is_deleted"] = bson.M{"$ne": true}
+	}
+	timeRange := bson.M{}
+	if !query.After.IsZero() {
+		timeRange["$gte"] = query.After
+	}
+	if !query.Before.IsZero() {
+		timeRange["$lt"] = query.Before
+	}
+	if len(timeRange) > 0 {
+		filter["timestamp"] = timeRange
+	}
+	return filter
+}
+
+func (a *collectionArchive) Stream(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery, batchSize int, fn func([]model.ChatMessage) error) error {
+	cursor, err := a.collection.Find(ctx, a.rangeFilter(roomID, query),
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "seq", Value: 1}}).SetBatchSize(int32(batchSize)))
+	if err != nil {
+		return fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+	defer cursor.Close(ctx)
+
+	batch := make([]model.ChatMessage, 0, batchSize)
+	for cursor.Next(ctx) {
+		var msg model.ChatMessage
+		if err := cursor.Decode(&msg); err != nil {
+			return fmt.Errorf("failed to decode archive of room %s: %w", roomID.Hex(), err)
+		}
+		batch = append(batch, msg)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func (a *collectionArchive) Count(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery, limit int64) (int64, error) {
+	opts := options.Count()
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	count, err := a.collection.CountDocuments(ctx, a.rangeFilter(roomID, query), opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count archive of room %s: %w", roomID.Hex(), err)
+	}
+	return count, nil
+}
+
+func (a *collectionArchive) LoadByUser(ctx context.Context, userID primitive.ObjectID) ([]model.ChatMessage, error) {
+	cursor, err := a.collection.Find(ctx, UserRelatedFilter(userID), options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive of user %s: %w", userID.Hex(), err)
+	}
+	var messages []model.ChatMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode archive of user %s: %w", userID.Hex(), err)
+	}
+	return messages, nil
+}
+
+func (a *collectionArchive) RewriteByUser(ctx context.Context, userID primitive.ObjectID, mutate func(*model.ChatMessage) bool) (int64, error) {
+	cursor, err := a.collection.Find(ctx, UserRelatedFilter(userID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read archive of user %s: %w", userID.Hex(), err)
+	}
+	var docs []archivedMessage
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, fmt.Errorf("failed to decode archive of user %s: %w", userID.Hex(), err)
+	}
+
+	var models []mongo.WriteModel
+	for i := range docs {
+		if mutate(&docs[i].ChatMessage) {
+			models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": docs[i].ID}).SetReplacement(docs[i]))
+		}
+	}
+	if len(models) == 0 {
+		return 0, nil
+	}
+	result, err := a.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite archive of user %s: %w", userID.Hex(), err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// UserRelatedFilter ข้อความที่ user ส่ง ถูก mention claim evoucher หรือถูกลงโทษ
+func UserRelatedFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"user_id": userID},
+		{"mentions": userID.Hex()},
+		{"evoucher_info.claimed_by": userID},
+		{"moderation_info.user_id": userID},
+	}}
+}
+
+// IsUserRelated ตรวจเงื่อนไขเดียวกับ UserRelatedFilter ใน Go (สำหรับ archive แบบไฟล์)
+func IsUserRelated(msg *model.ChatMessage, userID primitive.ObjectID) bool {
+	if msg.UserID == userID {
+		return true
+	}
+	for _, mention := range msg.Mentions {
+		if mention == userID.Hex() {
+			return true
+		}
+	}
+	if msg.EvoucherInfo != nil {
+		for _, claimedBy := range msg.EvoucherInfo.ClaimedBy {
+			if claimedBy == userID {
+				return true
+			}
+		}
+	}
+	return msg.ModerationInfo != nil && msg.ModerationInfo.UserID == userID
+}
+
+// onlyDuplicateKeyErrors ข้อความที่เคย archive ไปแล้ว (รอบก่อนล้มกลางทาง) ไม่ถือเป็น error
+func onlyDuplicateKeyErrors(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *fileArchive) Target() string {
+	return a.dir
+}
+
+func (a *fileArchive) path(roomID primitive.ObjectID) string {
+	return filepath.Join(a.dir, roomID.Hex()+".jsonl.gz")
+}
+
+// Store ต่อท้ายไฟล์ของห้องด้วย gzip member ใหม่ (ไฟล์ gzip หลาย member อ่านต่อกันได้)
+func (a *fileArchive) Store(ctx context.Context, roomID primitive.ObjectID, msgs []model.ChatMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	file, err := os.OpenFile(a.path(roomID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open archive of room %s: %w", roomID.Hex(), err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	for i := range msgs {
+		// Extended JSON เก็บ ObjectID / เวลาได้ครบ อ่านกลับเป็น ChatMessage ได้ตรง
+		line, err := bson.MarshalExtJSON(&msgs[i], true, false)
+		if err != nil {
+			return fmt.Errorf("failed to encode message %s: %w", msgs[i].ID.Hex(), err)
+		}
+		if _, err := gz.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write archive of room %s: %w", roomID.Hex(), err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive of room %s: %w", roomID.Hex(), err)
+	}
+	// ต้องลงดิสก์ก่อนที่ worker จะลบข้อความออกจาก chat-messages
+	return file.Sync()
+}
+
+// Load อ่านทั้งไฟล์ของห้อง (cold path) ตัดข้อความซ้ำจากการ Store ซ้ำ แล้วกรองตาม query
+func (a *fileArchive) Load(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery) ([]model.ChatMessage, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	all, err := a.readFile(a.path(roomID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	var messages []model.ChatMessage
+	for _, msg := range all {
+		if seen[msg.ID] || (!query.IncludeUnsent && msg.IsDeleted != nil && *msg.IsDeleted) {
+			continue
+		}
+		switch {
+		case query.BeforeSeq > 0:
+			if msg.Seq <= 0 || msg.Seq >= query.BeforeSeq {
+				continue
+			}
+		case !query.Before.IsZero():
+			if !msg.Timestamp.Before(query.Before) {
+				continue
+			}
+		}
+		seen[msg.ID] = true
+		messages = append(messages, msg)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if query.BeforeSeq > 0 {
+			return messages[i].Seq > messages[j].Seq
+		}
+		return messages[i].Timestamp.After(messages[j].Timestamp)
+	})
+	if query.Limit > 0 && len(messages) > query.Limit {
+		messages = messages[:query.Limit]
+	}
+	return messages, nil
+}
+
+// Stream อ่านไฟล์ของห้องทีละบรรทัดตามลำดับที่ Store ต่อท้าย (retention ย้ายข้อความเก่าไปใหม่ ไฟล์จึงเรียงตามเวลา)
+func (a *fileArchive) Stream(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery, batchSize int, fn func([]model.ChatMessage) error) error {
+	seen := make(map[primitive.ObjectID]bool)
+	batch := make([]model.ChatMessage, 0, batchSize)
+	err := a.scan(ctx, roomID, func(msg *model.ChatMessage) error {
+		if seen[msg.ID] || !inRange(msg, query) {
+			return nil
+		}
+		seen[msg.ID] = true
+		batch = append(batch, *msg)
+		if len(batch) < batchSize {
+			return nil
+		}
+		err := fn(batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func (a *fileArchive) Count(ctx context.Context, roomID primitive.ObjectID, query ArchiveQuery, limit int64) (int64, error) {
+	seen := make(map[primitive.ObjectID]bool)
+	err := a.scan(ctx, roomID, func(msg *model.ChatMessage) error {
+		if seen[msg.ID] || !inRange(msg, query) {
+			return nil
+		}
+		seen[msg.ID] = true
+		if limit > 0 && int64(len(seen)) >= limit {
+			return errStopScan
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopScan) {
+		return 0, err
+	}
+	return int64(len(seen)), nil
+}
+
+// errStopScan ให้ callback ของ scan หยุดอ่านก่อนจบไฟล์
+var errStopScan = errors.New("stop archive scan")
+
+// scan อ่านไฟล์ของห้องโดยถือ lock แค่ตอนเปิดไฟล์และอ่านขนาด
+// Store ต่อท้ายภายใต้ lock และ RewriteByUser เขียนไฟล์ใหม่แล้ว rename จึงอ่านถึงขนาดที่เห็นตอนเปิดได้ครบเสมอ
+func (a *fileArchive) scan(ctx context.Context, roomID primitive.ObjectID, fn func(*model.ChatMessage) error) error {
+	a.mu.Lock()
+	file, err := os.Open(a.path(roomID))
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = file.Stat(); err != nil {
+			file.Close()
+		} else {
+			size = info.Size()
+		}
+	}
+	a.mu.Unlock()
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(io.LimitReader(file, size))
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), archiveMaxLineBytes)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var msg model.ChatMessage
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &msg); err != nil {
+			continue
+		}
+		if err := fn(&msg); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read archive of room %s: %w", roomID.Hex(), err)
+	}
+	return nil
+}
+
+// inRange เงื่อนไขเดียวกับ collectionArchive.rangeFilter
+func inRange(msg *model.ChatMessage, query ArchiveQuery) bool {
+	if !query.IncludeUnsent && msg.IsDeleted != nil && *msg.IsDeleted {
+		return false
+	}
+	if !query.After.IsZero() && msg.Timestamp.Before(query.After) {
+		return false
+	}
+	return query.Before.IsZero() || msg.Timestamp.Before(query.Before)
+}
+
+// LoadByUser อ่านทุกไฟล์ใน archive (cold path ใช้เฉพาะงาน export ข้อมูลผู้ใช้)
+func (a *fileArchive) LoadByUser(ctx context.Context, userID primitive.ObjectID) ([]model.ChatMessage, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(a.dir, "*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[primitive.ObjectID]bool)
+	var messages []model.ChatMessage
+	for _, path := range paths {
+		all, err := a.readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %w", filepath.Base(path), err)
+		}
+		for _, msg := range all {
+			if !seen[msg.ID] && IsUserRelated(&msg, userID) {
+				seen[msg.ID] = true
+				messages = append(messages, msg)
+			}
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Timestamp.Before(messages[j].Timestamp) })
+	return messages, nil
+}
+
+// RewriteByUser เขียนไฟล์ของห้องที่มีข้อความถูกแก้ใหม่ทั้งไฟล์ (เขียน .tmp แล้ว rename)
+func (a *fileArchive) RewriteByUser(ctx context.Context, userID primitive.ObjectID, mutate func(*model.ChatMessage) bool) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(a.dir, "*.jsonl.gz"))
+	if err != nil {
+		return 0, err
+	}
+	var rewritten int64
+	for _, path := range paths {
+		all, err := a.readFile(path)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to read archive %s: %w", filepath.Base(path), err)
+		}
+		var changed int64
+		for i := range all {
+			if IsUserRelated(&all[i], userID) && mutate(&all[i]) {
+				changed++
+			}
+		}
+		if changed == 0 {
+			continue
+		}
+		if err := a.writeFile(path, all); err != nil {
+			return rewritten, fmt.Errorf("failed to rewrite archive %s: %w", filepath.Base(path), err)
+		}
+		rewritten += changed
+	}
+	return rewritten, nil
+}
+
+// readFile อ่านทุกบรรทัดของไฟล์ archive (รวมข้อความซ้ำ) ไฟล์ที่ไม่มีคือ archive ว่าง
+func (a *fileArchive) readFile(path string) ([]model.ChatMessage, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var messages []model.ChatMessage
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), archiveMaxLineBytes)
+	for scanner.Scan() {
+		var msg model.ChatMessage
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &msg); err != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, scanner.Err()
+}
+
+func (a *fileArchive) writeFile(path string, msgs []model.ChatMessage) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(file)
+	for i := range msgs {
+		line, err := bson.MarshalExtJSON(&msgs[i], true, false)
+		if err == nil {
+			_, err = gz.Write(append(line, '\n'))
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
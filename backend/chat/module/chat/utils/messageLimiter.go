@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AllowRoomMessage นับข้อความของ user ในห้องแบบ fixed window 1 นาทีใน Redis (ใช้ร่วมกันทุก instance)
+// perMinute <= 0 = ไม่จำกัด ถ้า Redis มีปัญหาจะปล่อยผ่านเพื่อไม่ให้แชทหยุด
+func AllowRoomMessage(ctx context.Context, redisClient *redis.Client, roomID, userID string, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+
+	window := time.Now().Unix() / 60
+	key := fmt.Sprintf("chat:ratelimit:%s:%s:%d", roomID, userID, window)
+
+	pipe := redisClient.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[RateLimit] ⚠️ Failed to check message rate for user %s in room %s: %v", userID, roomID, err)
+		return true
+	}
+
+	return incr.Val() <= int64(perMinute)
+}
+
+// TakeSlowModeSlot จองสิทธิ์ส่งข้อความถัดไปของ user ในห้องที่เปิด slow mode (key หมดอายุเมื่อครบ interval)
+// คืน false พร้อมเวลาที่เหลือถ้ายังอยู่ใน cooldown ถ้า Redis มีปัญหาจะปล่อยผ่านเหมือน AllowRoomMessage
+func TakeSlowModeSlot(ctx context.Context, redisClient *redis.Client, roomID, userID string, interval time.Duration) (time.Duration, bool) {
+	if interval <= 0 {
+		return 0, true
+	}
+
+	key := fmt.Sprintf("chat:slowmode:%s:%s", roomID, userID)
+	acquired, err := redisClient.SetNX(ctx, key, "1", interval).Result()
+	if err != nil {
+		log.Printf("[SlowMode] ⚠️ Failed to check slow mode for user %s in room %s: %v", userID, roomID, err)
+		return 0, true
+	}
+	if acquired {
+		return 0, true
+	}
+
+	remaining, err := redisClient.PTTL(ctx, key).Result()
+	if err != nil || remaining <= 0 {
+		// key เพิ่งหมดอายุระหว่างสองคำสั่ง: ให้ client ลองใหม่ทันที
+		return time.Millisecond, false
+	}
+	return remaining, false
+}
@@ -0,0 +1,524 @@
+package utils
+
+import (
+	"chat/module/chat/model"
+	"chat/pkg/core/metrics"
+	"chat/pkg/core/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// **NEW: Durable outbox สำหรับบันทึกข้อความลง Mongo**
+// ทุกข้อความที่รับแล้วจะถูก XADD ลง Redis Stream ก่อน broadcast แล้ว worker จะ XACK หลัง insert สำเร็จ
+// ถ้า instance ตายระหว่างทาง entry จะค้างอยู่ใน pending list และถูก reclaim โดย instance ไหนก็ได้
+const (
+	OutboxStream        = "chat:outbox:messages"
+	OutboxDeadStream    = "chat:outbox:messages:dead"
+	OutboxIndexKey      = "chat:outbox:index" // messageID -> stream entry ID
+	OutboxConsumerGroup = "chat-persist"
+
+	OutboxWorkerCount    = 4
+	OutboxReadCount      = 100
+	OutboxBlockTimeout   = 2 * time.Second
+	OutboxReclaimEvery   = 15 * time.Second
+	OutboxReclaimMinIdle = 30 * time.Second
+	OutboxMaxDeliveries  = 10
+
+	// OutboxBacklogSampleEvery ความถี่ที่อ่านขนาด stream ไว้ให้ saturation check ใช้
+	OutboxBacklogSampleEvery = time.Second
+)
+
+// สถานะของข้อความใน outbox
+const (
+	OutboxStatusQueued    = "queued"    // อยู่ใน stream แต่ยังไม่มี worker หยิบไป
+	OutboxStatusPending   = "pending"   // worker หยิบไปแล้วแต่ยังไม่ ack
+	OutboxStatusPersisted = "persisted" // ไม่อยู่ใน outbox แล้ว (ack หลังบันทึกลง Mongo)
+)
+
+type (
+	// OutboxHandler คือ service ที่ใช้บันทึกข้อความจริง (ChatService)
+	OutboxHandler interface {
+		SaveMessageToDB(ctx context.Context, msg *model.ChatMessage) error
+		SaveMessageBatch(ctx context.Context, msgs []*model.ChatMessage) error
+		MarkMessagePersisted(messageID primitive.ObjectID)
+		MarkMessageFailed(messageID primitive.ObjectID, reason string)
+	}
+
+	// MessageOutbox คือ consumer ของ Redis Stream สำหรับ persistence
+	MessageOutbox struct {
+		redis   *redis.Client
+		handler OutboxHandler
+		// dbTimeout เวลาสูงสุดของการเขียน Mongo ต่อครั้ง (RELIABILITY_DATABASE_TIMEOUT)
+		dbTimeout time.Duration
+		consumer  string
+		backlog   atomic.Int64 // ขนาด stream ล่าสุดจาก runBacklogSampler
+		quit      chan struct{}
+		wg        sync.WaitGroup
+		once      sync.Once
+	}
+
+	// OutboxMessageStatus คือสถานะของข้อความที่อ่านจาก stream pending list
+	OutboxMessageStatus struct {
+		MessageID  string `json:"message_id"`
+		Status     string `json:"status"`
+		EntryID    string `json:"entry_id,omitempty"`
+		Consumer   string `json:"consumer,omitempty"`
+		Deliveries int64  `json:"deliveries,omitempty"`
+		IdleMs     int64  `json:"idle_ms,omitempty"`
+	}
+
+	outboxEntry struct {
+		id  string
+		msg *model.ChatMessage
+		ctx context.Context // trace context ของผู้ส่ง (จาก field ที่ Append เก็บไว้)
+	}
+)
+
+// NewMessageOutbox สร้าง outbox (ยังไม่เริ่ม worker จนกว่าจะเรียก Start)
+func NewMessageOutbox(redis *redis.Client, handler OutboxHandler, dbTimeout time.Duration) *MessageOutbox {
+	hostname, _ := os.Hostname()
+	return &MessageOutbox{
+		redis:     redis,
+		handler:   handler,
+		dbTimeout: dbTimeout,
+		consumer:  fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start สร้าง consumer group (ถ้ายังไม่มี) แล้วเริ่ม worker กับ reclaimer
+// reclaimer รันทันทีตอน start เพื่อเก็บ entry ที่ค้างจาก instance ก่อน restart
+func (o *MessageOutbox) Start(ctx context.Context) error {
+	err := o.redis.XGroupCreateMkStream(ctx, OutboxStream, OutboxConsumerGroup, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create outbox consumer group: %w", err)
+	}
+
+	for i := 0; i < OutboxWorkerCount; i++ {
+		o.wg.Add(1)
+		go o.runWorker(i)
+	}
+
+	o.wg.Add(1)
+	go o.runReclaimer()
+
+	o.wg.Add(1)
+	go o.runBacklogSampler()
+
+	metricsOutbox.Store(o)
+	log.Printf("[Outbox] Started %d workers as consumer %s", OutboxWorkerCount, o.consumer)
+	return nil
+}
+
+// Append บันทึกข้อความลง stream ก่อน broadcast คืน entry ID
+func (o *MessageOutbox) Append(ctx context.Context, msg *model.ChatMessage) (string, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal outbox message: %w", err)
+	}
+
+	values := map[string]interface{}{
+		"message_id": msg.ID.Hex(),
+		"payload":    payload,
+	}
+	// เก็บ traceparent ไว้ใน entry เพื่อให้ worker (อาจเป็น instance อื่น) link กลับมาที่ trace ของผู้ส่ง
+	carrier := propagation.MapCarrier{}
+	tracing.Inject(ctx, carrier)
+	for k, v := range carrier {
+		values[k] = v
+	}
+
+	entryID, err := o.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: OutboxStream,
+		Values: values,
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to append message to outbox: %w", err)
+	}
+
+	if err := o.redis.HSet(ctx, OutboxIndexKey, msg.ID.Hex(), entryID).Err(); err != nil {
+		log.Printf("[Outbox] ⚠️ Failed to index message %s: %v", msg.ID.Hex(), err)
+	}
+	return entryID, nil
+}
+
+// Discard ลบข้อความออกจาก outbox (ใช้เมื่อ broadcast ล้มเหลวและผู้ส่งได้รับ error แล้ว)
+func (o *MessageOutbox) Discard(ctx context.Context, messageID primitive.ObjectID, entryID string) {
+	pipe := o.redis.TxPipeline()
+	pipe.XAck(ctx, OutboxStream, OutboxConsumerGroup, entryID)
+	pipe.XDel(ctx, OutboxStream, entryID)
+	pipe.HDel(ctx, OutboxIndexKey, messageID.Hex())
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Outbox] ⚠️ Failed to discard message %s: %v", messageID.Hex(), err)
+	}
+}
+
+// Status อ่านสถานะของข้อความจาก index และ pending list ของ stream
+func (o *MessageOutbox) Status(ctx context.Context, messageID primitive.ObjectID) (*OutboxMessageStatus, error) {
+	status := &OutboxMessageStatus{MessageID: messageID.Hex()}
+
+	entryID, err := o.redis.HGet(ctx, OutboxIndexKey, messageID.Hex()).Result()
+	if err == redis.Nil {
+		status.Status = OutboxStatusPersisted
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.EntryID = entryID
+
+	pending, err := o.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: OutboxStream,
+		Group:  OutboxConsumerGroup,
+		Start:  entryID,
+		End:    entryID,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pending) == 0 {
+		status.Status = OutboxStatusQueued
+		return status, nil
+	}
+
+	status.Status = OutboxStatusPending
+	status.Consumer = pending[0].Consumer
+	status.Deliveries = pending[0].RetryCount
+	status.IdleMs = pending[0].Idle.Milliseconds()
+	return status, nil
+}
+
+// SampledBacklog คืนขนาด stream ล่าสุดที่ sampler อ่านไว้ (ไม่เรียก Redis จึงใช้บน hot path ได้)
+func (o *MessageOutbox) SampledBacklog() int64 {
+	return o.backlog.Load()
+}
+
+// Backlog คืนจำนวน entry ทั้งหมดใน stream (ยังไม่ถูก ack) และจำนวนที่ worker หยิบไปแล้วแต่ยังไม่ ack
+func (o *MessageOutbox) Backlog(ctx context.Context) (length, pending int64, err error) {
+	pipe := o.redis.Pipeline()
+	lengthCmd := pipe.XLen(ctx, OutboxStream)
+	pendingCmd := pipe.XPending(ctx, OutboxStream, OutboxConsumerGroup)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return lengthCmd.Val(), pendingCmd.Val().Count, nil
+}
+
+// Stats คืนขนาด stream และจำนวน pending สำหรับ health endpoint
+func (o *MessageOutbox) Stats(ctx context.Context) map[string]interface{} {
+	stats := map[string]interface{}{
+		"stream":   OutboxStream,
+		"consumer": o.consumer,
+	}
+
+	if length, err := o.redis.XLen(ctx, OutboxStream).Result(); err == nil {
+		stats["stream_length"] = length
+	}
+	if pending, err := o.redis.XPending(ctx, OutboxStream, OutboxConsumerGroup).Result(); err == nil {
+		stats["pending"] = pending.Count
+		stats["pending_consumers"] = pending.Consumers
+	}
+	if dead, err := o.redis.XLen(ctx, OutboxDeadStream).Result(); err == nil {
+		stats["dead_letters"] = dead
+	}
+	return stats
+}
+
+// Shutdown หยุด worker (entry ที่ยังไม่ ack จะค้างใน pending list ให้ reclaim ภายหลัง)
+func (o *MessageOutbox) Shutdown() {
+	o.once.Do(func() {
+		close(o.quit)
+	})
+	o.wg.Wait()
+	log.Printf("[Outbox] Shutdown complete")
+}
+
+func (o *MessageOutbox) runWorker(workerID int) {
+	defer o.wg.Done()
+
+	for {
+		select {
+		case <-o.quit:
+			return
+		default:
+		}
+
+		streams, err := o.redis.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+			Group:    OutboxConsumerGroup,
+			Consumer: o.consumer,
+			Streams:  []string{OutboxStream, ">"},
+			Count:    OutboxReadCount,
+			Block:    OutboxBlockTimeout,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			log.Printf("[Outbox] Worker %d read error: %v", workerID, err)
+			o.sleep(OutboxBlockTimeout)
+			continue
+		}
+
+		for _, stream := range streams {
+			o.persist(o.decode(stream.Messages))
+		}
+	}
+}
+
+// runReclaimer ดึง entry ที่ค้างเกิน OutboxReclaimMinIdle (consumer ตาย/restart) มาทำต่อ
+func (o *MessageOutbox) runReclaimer() {
+	defer o.wg.Done()
+
+	o.reclaim()
+
+	ticker := time.NewTicker(OutboxReclaimEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.reclaim()
+		case <-o.quit:
+			return
+		}
+	}
+}
+
+// runBacklogSampler อ่านขนาด stream ทุก OutboxBacklogSampleEvery (stream รวมทุก instance จึงเป็น backlog ของทั้ง cluster)
+func (o *MessageOutbox) runBacklogSampler() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(OutboxBacklogSampleEvery)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), OutboxBacklogSampleEvery)
+		if length, err := o.redis.XLen(ctx, OutboxStream).Result(); err == nil {
+			o.backlog.Store(length)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-o.quit:
+			return
+		}
+	}
+}
+
+func (o *MessageOutbox) reclaim() {
+	ctx := context.Background()
+
+	pending, err := o.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: OutboxStream,
+		Group:  OutboxConsumerGroup,
+		Idle:   OutboxReclaimMinIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  OutboxReadCount,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		if p.RetryCount >= OutboxMaxDeliveries {
+			o.deadLetter(ctx, p.ID, p.RetryCount)
+			continue
+		}
+		ids = append(ids, p.ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	claimed, err := o.redis.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   OutboxStream,
+		Group:    OutboxConsumerGroup,
+		Consumer: o.consumer,
+		MinIdle:  OutboxReclaimMinIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		log.Printf("[Outbox] Failed to reclaim pending entries: %v", err)
+		return
+	}
+
+	if len(claimed) > 0 {
+		metrics.WorkerRetries.Add(float64(len(claimed)), "outbox")
+		log.Printf("[Outbox] ♻️ Reclaimed %d pending entries", len(claimed))
+		o.persist(o.decode(claimed))
+	}
+}
+
+// persist บันทึกแบบ batch ก่อน ถ้าไม่สำเร็จจะลองทีละข้อความ (duplicate key = บันทึกไปแล้ว)
+// entry ที่บันทึกไม่ได้จะไม่ถูก ack และจะถูก reclaim ในรอบถัดไป
+func (o *MessageOutbox) persist(entries []outboxEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	// batch เดียวรวมข้อความจากหลาย trace จึงใช้ link แทน parent
+	links := make([]trace.Link, 0, len(entries))
+	for _, entry := range entries {
+		if link, ok := tracing.LinkFrom(entry.ctx); ok {
+			links = append(links, link)
+		}
+	}
+	ctx, span := tracing.Tracer().Start(context.Background(), "outbox.persist",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("chat.batch_size", len(entries))),
+	)
+	defer span.End()
+
+	msgs := make([]*model.ChatMessage, len(entries))
+	for i, entry := range entries {
+		msgs[i] = entry.msg
+	}
+
+	batchCtx, cancel := context.WithTimeout(ctx, o.dbTimeout)
+	err := o.handler.SaveMessageBatch(batchCtx, msgs)
+	cancel()
+	if err == nil {
+		o.ack(ctx, entries)
+		return
+	} else if isCircuitOpenError(err) {
+		log.Printf("[Outbox] ⚡ Mongo breaker open, leaving %d entries pending: %v", len(entries), err)
+		return
+	}
+
+	saved := make([]outboxEntry, 0, len(entries))
+	for _, entry := range entries {
+		saveCtx, cancel := context.WithTimeout(ctx, o.dbTimeout)
+		err := o.handler.SaveMessageToDB(saveCtx, entry.msg)
+		cancel()
+		if err == nil || mongo.IsDuplicateKeyError(err) {
+			saved = append(saved, entry)
+			continue
+		}
+		log.Printf("[Outbox] ❌ Failed to persist message %s (entry %s): %v", entry.msg.ID.Hex(), entry.id, err)
+	}
+	o.ack(ctx, saved)
+}
+
+func (o *MessageOutbox) ack(ctx context.Context, entries []outboxEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	ids := make([]string, len(entries))
+	messageIDs := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.id
+		messageIDs[i] = entry.msg.ID.Hex()
+	}
+
+	pipe := o.redis.TxPipeline()
+	pipe.XAck(ctx, OutboxStream, OutboxConsumerGroup, ids...)
+	pipe.XDel(ctx, OutboxStream, ids...)
+	pipe.HDel(ctx, OutboxIndexKey, messageIDs...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Outbox] ⚠️ Failed to ack %d entries: %v", len(entries), err)
+	}
+
+	for _, entry := range entries {
+		o.handler.MarkMessagePersisted(entry.msg.ID)
+	}
+}
+
+// decode แปลง stream entries เป็นข้อความ entry ที่เสียจะถูกย้ายไป dead-letter
+func (o *MessageOutbox) decode(messages []redis.XMessage) []outboxEntry {
+	entries := make([]outboxEntry, 0, len(messages))
+	for _, m := range messages {
+		msg, err := decodeOutboxPayload(m.Values)
+		if err != nil {
+			log.Printf("[Outbox] ❌ Invalid entry %s: %v", m.ID, err)
+			o.deadLetter(context.Background(), m.ID, 0)
+			continue
+		}
+		entries = append(entries, outboxEntry{id: m.ID, msg: msg, ctx: tracing.Extract(context.Background(), outboxCarrier(m.Values))})
+	}
+	return entries
+}
+
+// deadLetter ย้าย entry ที่บันทึกไม่สำเร็จเกิน OutboxMaxDeliveries ไปไว้ใน dead stream เพื่อตรวจสอบ
+func (o *MessageOutbox) deadLetter(ctx context.Context, entryID string, deliveries int64) {
+	values := map[string]interface{}{"entry_id": entryID, "deliveries": deliveries}
+	if entries, err := o.redis.XRange(ctx, OutboxStream, entryID, entryID).Result(); err == nil && len(entries) > 0 {
+		for k, v := range entries[0].Values {
+			values[k] = v
+		}
+	}
+
+	pipe := o.redis.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: OutboxDeadStream, Values: values})
+	pipe.XAck(ctx, OutboxStream, OutboxConsumerGroup, entryID)
+	pipe.XDel(ctx, OutboxStream, entryID)
+	if messageID, ok := values["message_id"].(string); ok {
+		pipe.HDel(ctx, OutboxIndexKey, messageID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Outbox] ⚠️ Failed to dead-letter entry %s: %v", entryID, err)
+		return
+	}
+	metrics.WorkerJobsFailed.Inc("outbox")
+	log.Printf("[Outbox] ☠️ Entry %s moved to %s after %d deliveries", entryID, OutboxDeadStream, deliveries)
+
+	if messageID, ok := values["message_id"].(string); ok {
+		if id, err := primitive.ObjectIDFromHex(messageID); err == nil {
+			o.handler.MarkMessageFailed(id, DeliveryReasonPersistFailed)
+		}
+	}
+}
+
+func (o *MessageOutbox) sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-o.quit:
+	}
+}
+
+// outboxCarrier อ่าน trace field จาก stream entry
+func outboxCarrier(values map[string]interface{}) propagation.MapCarrier {
+	carrier := propagation.MapCarrier{}
+	for k, v := range values {
+		if str, ok := v.(string); ok && k != "payload" {
+			carrier[k] = str
+		}
+	}
+	return carrier
+}
+
+func decodeOutboxPayload(values map[string]interface{}) (*model.ChatMessage, error) {
+	raw, ok := values["payload"].(string)
+	if !ok {
+		return nil, errors.New("missing payload")
+	}
+
+	var msg model.ChatMessage
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return nil, err
+	}
+	if msg.ID.IsZero() {
+		return nil, errors.New("missing message ID")
+	}
+	return &msg, nil
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"chat/module/chat/model"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Per-room sequence number**
+// ทุกข้อความได้ seq ที่เพิ่มทีละ 1 ต่อห้อง (Redis INCR ตอนรับข้อความ) ใช้เป็น score ของ cache, cursor ของ history
+// และส่งไปกับทุก event เพื่อให้ client ตรวจ gap แล้วขอ resync ได้
+// ถ้า key หาย (Redis ถูก flush) จะ seed ใหม่จาก seq สูงสุดใน Mongo ก่อน INCR เพื่อไม่ให้ seq ย้อนกลับ
+const roomSeqKeyPrefix = "chat:room:seq:"
+
+// incrIfExists คืน nil ถ้ายังไม่มี key (ต้อง seed ก่อน) เพื่อไม่ให้ INCR เริ่มจาก 1 ทับ seq เดิม
+var incrIfExists = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("INCR", KEYS[1])
+end
+return false
+`)
+
+type RoomSequencer struct {
+	redis      *redis.Client
+	collection *mongo.Collection
+}
+
+var (
+	sharedRoomSequencer     *RoomSequencer
+	sharedRoomSequencerOnce sync.Once
+)
+
+// GetRoomSequencer คืน sequencer ตัวเดียวของ process
+func GetRoomSequencer(db *mongo.Database, redisClient *redis.Client) *RoomSequencer {
+	sharedRoomSequencerOnce.Do(func() {
+		sharedRoomSequencer = &RoomSequencer{
+			redis:      redisClient,
+			collection: db.Collection("chat-messages"),
+		}
+	})
+	return sharedRoomSequencer
+}
+
+// Next คืน seq ถัดไปของห้อง
+func (s *RoomSequencer) Next(ctx context.Context, roomID primitive.ObjectID) (int64, error) {
+	key := roomSeqKeyPrefix + roomID.Hex()
+
+	seq, err := incrIfExists.Run(ctx, s.redis, []string{key}).Int64()
+	if err == nil {
+		return seq, nil
+	}
+	if err != redis.Nil {
+		return 0, fmt.Errorf("failed to increment room sequence: %w", err)
+	}
+
+	// key ยังไม่มี: seed จาก Mongo (SETNX กัน instance อื่น seed ซ้อน) แล้ว INCR
+	floor, err := s.maxPersistedSeq(ctx, roomID)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.redis.SetNX(ctx, key, floor, 0).Err(); err != nil {
+		return 0, fmt.Errorf("failed to seed room sequence: %w", err)
+	}
+	return s.redis.Incr(ctx, key).Result()
+}
+
+// Assign ใส่ seq ให้ข้อความ ถ้า Redis ใช้ไม่ได้จะปล่อย seq เป็น 0 (เรียงด้วย timestamp แทน) เพื่อไม่ให้การส่งข้อความล้ม
+func (s *RoomSequencer) Assign(ctx context.Context, msg *model.ChatMessage) {
+	seq, err := s.Next(ctx, msg.RoomID)
+	if err != nil {
+		log.Printf("[Sequence] ⚠️ Failed to assign sequence for room %s: %v", msg.RoomID.Hex(), err)
+		return
+	}
+	msg.Seq = seq
+}
+
+// Current คืน seq ล่าสุดของห้อง (0 ถ้ายังไม่มีข้อความ)
+func (s *RoomSequencer) Current(ctx context.Context, roomID primitive.ObjectID) (int64, error) {
+	seq, err := s.redis.Get(ctx, roomSeqKeyPrefix+roomID.Hex()).Int64()
+	if err == redis.Nil {
+		return s.maxPersistedSeq(ctx, roomID)
+	}
+	return seq, err
+}
+
+func (s *RoomSequencer) maxPersistedSeq(ctx context.Context, roomID primitive.ObjectID) (int64, error) {
+	var latest struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := s.collection.FindOne(ctx,
+		bson.M{"room_id": roomID, "seq": bson.M{"$gt": 0}},
+		options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}}).SetProjection(bson.M{"seq": 1}),
+	).Decode(&latest)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read latest room sequence: %w", err)
+	}
+	return latest.Seq, nil
+}
+
+// MessageScore คือ score ของข้อความใน sorted set ของ cache
+// ข้อความเก่าที่ไม่มี seq ได้ score ระหว่าง 0-1 ตามเวลา จึงอยู่ก่อนข้อความที่มี seq เสมอ
+func MessageScore(msg *model.ChatMessage) float64 {
+	if msg.Seq > 0 {
+		return float64(msg.Seq)
+	}
+	return float64(msg.Timestamp.UnixMilli()) / 1e14
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"chat/module/chat/model"
+)
+
+func TestSeqScoreRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		afterSeq  int64
+		beforeSeq int64
+		wantMin   string
+		wantMax   string
+	}{
+		{name: "no cursor", afterSeq: 0, beforeSeq: 0, wantMin: "1", wantMax: "+inf"},
+		{name: "negative after", afterSeq: -5, beforeSeq: 0, wantMin: "1", wantMax: "+inf"},
+		{name: "after only", afterSeq: 41, beforeSeq: 0, wantMin: "42", wantMax: "+inf"},
+		{name: "before only", afterSeq: 0, beforeSeq: 100, wantMin: "1", wantMax: "(100"},
+		{name: "both", afterSeq: 10, beforeSeq: 20, wantMin: "11", wantMax: "(20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMin, gotMax := SeqScoreRange(tt.afterSeq, tt.beforeSeq)
+			if gotMin != tt.wantMin || gotMax != tt.wantMax {
+				t.Errorf("SeqScoreRange(%d, %d) = (%q, %q), want (%q, %q)",
+					tt.afterSeq, tt.beforeSeq, gotMin, gotMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestMessageScoreLegacyBelowFirstSeq(t *testing.T) {
+	legacy := &model.ChatMessage{Timestamp: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	score := MessageScore(legacy)
+	if score <= 0 || score >= 1 {
+		t.Fatalf("legacy score = %v, want in (0, 1)", score)
+	}
+
+	// ข้อความที่ไม่มี seq ต้องไม่อยู่ในช่วงของ cursor ใดๆ (min ต่ำสุดคือ 1)
+	minScore, _ := SeqScoreRange(0, 0)
+	if minScore != "1" {
+		t.Fatalf("min score = %q, legacy messages would leak into seq ranges", minScore)
+	}
+
+	if got := MessageScore(&model.ChatMessage{Seq: 7}); got != 7 {
+		t.Errorf("MessageScore(seq=7) = %v, want 7", got)
+	}
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type (
+	// Session คือ websocket connection เดียวที่ subscribe ได้หลายห้อง (multiplexed socket)
+	// ทุก event ที่ส่งผ่าน session จะถูก tag ด้วย roomId เพื่อให้ client แยกห้องได้
+	Session struct {
+		Conn   *websocket.Conn
+		UserID primitive.ObjectID
+
+		rooms   sync.Map // roomID -> bool (false = ถูก hub ถอดออกแล้ว รอ cleanup)
+		writeMu sync.Mutex
+	}
+
+	// sessionNotice ใช้ห่อข้อความ plain text ให้เป็น JSON สำหรับ session
+	sessionNotice struct {
+		RoomID  string `json:"roomId"`
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+)
+
+// AddRoom บันทึกว่า session นี้ subscribe ห้องนี้แล้ว
+func (s *Session) AddRoom(roomID string) {
+	s.rooms.Store(roomID, true)
+}
+
+// RemoveRoom ลบห้องออกจาก session คืนค่า true ถ้าเคยมีห้องนี้อยู่
+func (s *Session) RemoveRoom(roomID string) bool {
+	_, existed := s.rooms.LoadAndDelete(roomID)
+	return existed
+}
+
+// HasRoom ตรวจสอบว่า session subscribe ห้องนี้อยู่ (และยังไม่ถูกถอดออก) หรือไม่
+func (s *Session) HasRoom(roomID string) bool {
+	active, ok := s.rooms.Load(roomID)
+	return ok && active.(bool)
+}
+
+// IsDetached ตรวจสอบว่าห้องนี้ถูก hub ถอดออก (kick/room ปิด) แต่ยังไม่ได้ cleanup
+func (s *Session) IsDetached(roomID string) bool {
+	active, ok := s.rooms.Load(roomID)
+	return ok && !active.(bool)
+}
+
+// markDetached ใช้โดย hub เมื่อถอด session ออกจากห้อง
+func (s *Session) markDetached(roomID string) {
+	if _, ok := s.rooms.Load(roomID); ok {
+		s.rooms.Store(roomID, false)
+	}
+}
+
+// Rooms คืนรายการห้องทั้งหมดของ session (รวมห้องที่ถูกถอดแต่ยังไม่ได้ cleanup)
+func (s *Session) Rooms() []string {
+	var rooms []string
+	s.rooms.Range(func(roomID, _ interface{}) bool {
+		rooms = append(rooms, roomID.(string))
+		return true
+	})
+	return rooms
+}
+
+// RoomCount คืนจำนวนห้องที่ session subscribe อยู่จริง
+func (s *Session) RoomCount() int {
+	count := 0
+	s.rooms.Range(func(_, active interface{}) bool {
+		if active.(bool) {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// WriteRaw เขียน frame ตรงไปยัง connection (ไม่ tag room) โดยกัน concurrent write
+func (s *Session) WriteRaw(messageType int, payload []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.Conn.WriteMessage(messageType, payload)
+}
+
+// writeRawWithin เหมือน WriteRaw แต่จำกัดเวลาเขียน
+func (s *Session) writeRawWithin(timeout time.Duration, messageType int, payload []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return writeWithin(s.Conn, timeout, messageType, payload)
+}
+
+// writeWithin ตั้ง write deadline ก่อนเขียน (client ที่อ่านช้าไม่บล็อก broadcast ทั้งห้อง) แล้วล้างออกให้ writer อื่น
+func writeWithin(conn *websocket.Conn, timeout time.Duration, messageType int, payload []byte) error {
+	if timeout <= 0 {
+		return conn.WriteMessage(messageType, payload)
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+	err := conn.WriteMessage(messageType, payload)
+	_ = conn.SetWriteDeadline(time.Time{})
+	return err
+}
+
+// RegisterSession ลงทะเบียน connection เป็น multiplexed session
+func (h *Hub) RegisterSession(conn *websocket.Conn, userID primitive.ObjectID) *Session {
+	session := &Session{
+		Conn:   conn,
+		UserID: userID,
+	}
+	h.sessions.Store(conn, session)
+	log.Printf("[WS] Session registered for user %s (connection: %p)", userID.Hex(), conn)
+	return session
+}
+
+// UnregisterSession ลบ session ออกจาก hub (ต้อง unsubscribe ห้องต่างๆ ก่อนเรียก)
+func (h *Hub) UnregisterSession(conn *websocket.Conn) {
+	if s, ok := h.sessions.LoadAndDelete(conn); ok {
+		log.Printf("[WS] Session unregistered for user %s (connection: %p)", s.(*Session).UserID.Hex(), conn)
+	}
+}
+
+// GetSession คืน session ของ connection (ถ้า connection นี้เป็น session)
+func (h *Hub) GetSession(conn *websocket.Conn) (*Session, bool) {
+	if s, ok := h.sessions.Load(conn); ok {
+		return s.(*Session), true
+	}
+	return nil, false
+}
+
+// WriteToConn ส่ง payload ไปยัง connection ในบริบทของห้อง
+// ถ้าเป็น session จะ tag roomId ให้ และล็อคการเขียนกันชนกัน, ถ้าเป็น socket แบบเดิมจะส่งตรง
+func (h *Hub) WriteToConn(roomID string, conn *websocket.Conn, payload []byte) error {
+	session, ok := h.GetSession(conn)
+	if !ok {
+		return writeWithin(conn, h.writeTimeout, websocket.TextMessage, payload)
+	}
+	return session.writeRawWithin(h.writeTimeout, websocket.TextMessage, tagPayloadWithRoom(roomID, payload))
+}
+
+// detachSessionFromRoom ใช้แทนการปิด connection เมื่อเป็น session:
+// ถอดเฉพาะห้องนั้นออกและแจ้ง client ด้วย event unsubscribed
+func (h *Hub) detachSessionFromRoom(roomID string, conn *websocket.Conn, reason string) bool {
+	session, ok := h.GetSession(conn)
+	if !ok {
+		return false
+	}
+	session.markDetached(roomID)
+
+	if payload, err := json.Marshal(map[string]interface{}{
+		"roomId": roomID,
+		"type":   "unsubscribed",
+		"reason": reason,
+	}); err == nil {
+		_ = session.WriteRaw(websocket.TextMessage, payload)
+	}
+	log.Printf("[WS] Detached session of user %s from room %s: %s", session.UserID.Hex(), roomID, reason)
+	return true
+}
+
+// tagPayloadWithRoom เพิ่ม field roomId ไว้ที่ระดับบนสุดของ JSON object
+// ข้อความที่ไม่ใช่ JSON object (เช่น error แบบ plain text) จะถูกห่อเป็น notice
+func tagPayloadWithRoom(roomID string, payload []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || fields == nil {
+		wrapped, err := json.Marshal(sessionNotice{
+			RoomID:  roomID,
+			Type:    "notice",
+			Message: string(payload),
+		})
+		if err != nil {
+			return payload
+		}
+		return wrapped
+	}
+
+	// แทนที่ roomId เดิม (ถ้ามี) เพื่อไม่ให้ได้ key ซ้ำ
+	roomField, err := json.Marshal(roomID)
+	if err != nil {
+		return payload
+	}
+	fields["roomId"] = roomField
+	tagged, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return tagged
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// **NEW: รูปแบบไฟล์ของ transcript ห้อง**
+// ทุก writer เขียนทีละข้อความ (stream) ไม่ต้องโหลดประวัติทั้งห้องไว้ใน memory
+const (
+	TranscriptFormatJSON = "json"
+	TranscriptFormatCSV  = "csv"
+	TranscriptFormatHTML = "html"
+)
+
+type (
+	// TranscriptHeader ข้อมูลของห้องและเงื่อนไขการ export (อยู่ต้นไฟล์)
+	TranscriptHeader struct {
+		RoomID        string     `json:"roomId"`
+		RoomName      string     `json:"roomName"`
+		RoomType      string     `json:"roomType"`
+		From          *time.Time `json:"from,omitempty"`
+		To            *time.Time `json:"to,omitempty"`
+		IncludeUnsent bool       `json:"includeUnsent"`
+		ExportedAt    time.Time  `json:"exportedAt"`
+		ExportedBy    string     `json:"exportedBy"`
+	}
+
+	// TranscriptEntry ข้อความหนึ่งรายการในรูปที่คนอ่านได้ (ชื่อผู้ส่ง/role/sticker/evoucher ถูก resolve แล้ว)
+	TranscriptEntry struct {
+		MessageID  string     `json:"messageId"`
+		Seq        int64      `json:"seq,omitempty"`
+		Timestamp  time.Time  `json:"timestamp"`
+		Type       string     `json:"type"`
+		UserID     string     `json:"userId"`
+		Username   string     `json:"username"`
+		Name       string     `json:"name"`
+		Role       string     `json:"role,omitempty"`
+		Text       string     `json:"text"`
+		Attachment string     `json:"attachment,omitempty"` // รูป sticker หรือไฟล์ที่ upload
+		Mentions   []string   `json:"mentions,omitempty"`
+		ReplyToID  string     `json:"replyToId,omitempty"`
+		Unsent     bool       `json:"unsent,omitempty"`
+		UnsentAt   *time.Time `json:"unsentAt,omitempty"`
+		Archived   bool       `json:"archived,omitempty"`
+	}
+
+	// TranscriptWriter เขียน transcript ตามลำดับ Begin → Write (หลายครั้ง) → End
+	TranscriptWriter interface {
+		Begin(header TranscriptHeader) error
+		Write(entry TranscriptEntry) error
+		End(count int) error
+	}
+
+	jsonTranscriptWriter struct {
+		w     io.Writer
+		count int
+	}
+
+	csvTranscriptWriter struct {
+		w *csv.Writer
+	}
+
+	htmlTranscriptWriter struct {
+		w io.Writer
+	}
+)
+
+// IsTranscriptFormat ตรวจว่าเป็นรูปแบบที่รองรับ
+func IsTranscriptFormat(format string) bool {
+	return format == TranscriptFormatJSON || format == TranscriptFormatCSV || format == TranscriptFormatHTML
+}
+
+// TranscriptContentType คืน Content-Type ของแต่ละรูปแบบ
+func TranscriptContentType(format string) string {
+	switch format {
+	case TranscriptFormatCSV:
+		return "text/csv; charset=utf-8"
+	case TranscriptFormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// NewTranscriptWriter สร้าง writer ตามรูปแบบ (format ต้องผ่าน IsTranscriptFormat แล้ว)
+func NewTranscriptWriter(format string, w io.Writer) TranscriptWriter {
+	switch format {
+	case TranscriptFormatCSV:
+		return &csvTranscriptWriter{w: csv.NewWriter(w)}
+	case TranscriptFormatHTML:
+		return &htmlTranscriptWriter{w: w}
+	default:
+		return &jsonTranscriptWriter{w: w}
+	}
+}
+
+// JSON: {"room": {...}, "messages": [...], "count": n}
+func (t *jsonTranscriptWriter) Begin(header TranscriptHeader) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(t.w, "{\"room\":%s,\"messages\":[", data)
+	return err
+}
+
+func (t *jsonTranscriptWriter) Write(entry TranscriptEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if t.count > 0 {
+		if _, err := io.WriteString(t.w, ","); err != nil {
+			return err
+		}
+	}
+	t.count++
+	_, err = t.w.Write(data)
+	return err
+}
+
+func (t *jsonTranscriptWriter) End(count int) error {
+	_, err := fmt.Fprintf(t.w, "],\"count\":%d}", count)
+	return err
+}
+
+// CSV: header row แล้วหนึ่งแถวต่อข้อความ (ข้อมูลห้องอยู่ในชื่อไฟล์/สถานะ job)
+func (t *csvTranscriptWriter) Begin(header TranscriptHeader) error {
+	return t.w.Write([]string{
+		"timestamp", "seq", "message_id", "type", "user_id", "username", "name", "role",
+		"text", "attachment", "mentions", "reply_to_id", "unsent", "unsent_at", "archived",
+	})
+}
+
+func (t *csvTranscriptWriter) Write(entry TranscriptEntry) error {
+	unsentAt := ""
+	if entry.UnsentAt != nil {
+		unsentAt = entry.UnsentAt.Format(time.RFC3339)
+	}
+	return t.w.Write([]string{
+		entry.Timestamp.Format(time.RFC3339),
+		strconv.FormatInt(entry.Seq, 10),
+		entry.MessageID,
+		entry.Type,
+		entry.UserID,
+		entry.Username,
+		entry.Name,
+		entry.Role,
+		entry.Text,
+		entry.Attachment,
+		strings.Join(entry.Mentions, " "),
+		entry.ReplyToID,
+		strconv.FormatBool(entry.Unsent),
+		unsentAt,
+		strconv.FormatBool(entry.Archived),
+	})
+}
+
+func (t *csvTranscriptWriter) End(count int) error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// HTML: หน้าเดียวอ่านได้ทันทีในเบราว์เซอร์ (escape ทุกค่าที่มาจากผู้ใช้)
+func (t *htmlTranscriptWriter) Begin(header TranscriptHeader) error {
+	rangeText := "all messages"
+	if header.From != nil || header.To != nil {
+		rangeText = fmt.Sprintf("%s – %s", formatTranscriptTime(header.From), formatTranscriptTime(header.To))
+	}
+	_, err := fmt.Fprintf(t.w, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>%s</title>
+<style>
+body{font-family:sans-serif;margin:2em;color:#222}
+table{border-collapse:collapse;width:100%%}
+td,th{border-bottom:1px solid #ddd;padding:6px;text-align:left;vertical-align:top}
+.meta{color:#666;font-size:.9em}
+.unsent{color:#999;text-decoration:line-through}
+.notice{background:#fff6e0}
+</style></head><body>
+<h1>%s</h1>
+<p class="meta">Room %s (%s) · %s · exported %s by %s</p>
+<table><thead><tr><th>Time</th><th>User</th><th>Role</th><th>Message</th></tr></thead><tbody>
+`,
+		html.EscapeString(header.RoomName),
+		html.EscapeString(header.RoomName),
+		html.EscapeString(header.RoomID),
+		html.EscapeString(header.RoomType),
+		html.EscapeString(rangeText),
+		header.ExportedAt.Format(time.RFC3339),
+		html.EscapeString(header.ExportedBy),
+	)
+	return err
+}
+
+func (t *htmlTranscriptWriter) Write(entry TranscriptEntry) error {
+	class := ""
+	switch {
+	case entry.Unsent:
+		class = ` class="unsent"`
+	case entry.Type == "restriction":
+		class = ` class="notice"`
+	}
+
+	text := html.EscapeString(entry.Text)
+	if entry.Attachment != "" {
+		text += fmt.Sprintf(` <span class="meta">(%s)</span>`, html.EscapeString(entry.Attachment))
+	}
+	if entry.ReplyToID != "" {
+		text = fmt.Sprintf(`<span class="meta">↪ reply to %s</span><br>%s`, html.EscapeString(entry.ReplyToID), text)
+	}
+
+	_, err := fmt.Fprintf(t.w, "<tr%s><td>%s</td><td>%s<br><span class=\"meta\">@%s</span></td><td>%s</td><td>%s</td></tr>\n",
+		class,
+		entry.Timestamp.Format("2006-01-02 15:04:05"),
+		html.EscapeString(entry.Name),
+		html.EscapeString(entry.Username),
+		html.EscapeString(entry.Role),
+		text,
+	)
+	return err
+}
+
+func (t *htmlTranscriptWriter) End(count int) error {
+	_, err := fmt.Fprintf(t.w, "</tbody></table>\n<p class=\"meta\">%d messages</p></body></html>\n", count)
+	return err
+}
+
+func formatTranscriptTime(t *time.Time) string {
+	if t == nil {
+		return "…"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,515 @@
+package utils
+
+import (
+	"chat/module/chat/model"
+	userModel "chat/module/user/model"
+	"chat/pkg/core/kafka"
+	"container/list"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	UserInfoLocalTTL        = 2 * time.Minute  // อายุ cache ใน memory
+	UserInfoRedisTTL        = 30 * time.Minute // อายุ cache ใน Redis
+	UserInfoLocalMaxEntries = 20000            // จำนวน user สูงสุดที่เก็บใน memory
+	RoleInfoLocalMaxEntries = 500              // จำนวน role สูงสุดที่เก็บใน memory
+
+	// UserInfoInvalidateChannel ใช้ประกาศให้ทุก instance ล้าง cache
+	// payload: "user:<id>" หรือ "role:<id>" (backend อื่นที่แก้ user/role publish มาที่ channel นี้ได้)
+	UserInfoInvalidateChannel = "chat:userinfo:invalidate"
+
+	userInfoKeyPrefix = "chat:userinfo:user:"
+	roleInfoKeyPrefix = "chat:userinfo:role:"
+)
+
+// event บน topic KAFKA_TOPICS_USER_EVENTS ที่ทำให้ cache ของ user/role ต้องล้าง
+const (
+	UserEventUpdated = "user.updated"
+	UserEventDeleted = "user.deleted"
+	RoleEventUpdated = "role.updated"
+	RoleEventDeleted = "role.deleted"
+)
+
+type (
+	// UserUpdateEvent payload ของ event user/role update (id = user ID หรือ role ID ตาม type)
+	UserUpdateEvent struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+
+	// cachedUser เก็บข้อมูล user แยกจาก role เพื่อให้ role update ไม่ต้องล้าง cache ของ user ทุกคน
+	cachedUser struct {
+		Info   model.UserInfo `json:"info"`
+		RoleID string         `json:"roleId,omitempty"`
+	}
+
+	// UserInfoResolver ดึง UserInfo/RoleInfo แบบ batch ($in) พร้อม cache 2 ชั้น (memory + Redis)
+	UserInfoResolver struct {
+		mongo *mongo.Database
+		redis *redis.Client
+		users *localTTLCache[cachedUser]
+		roles *localTTLCache[model.RoleInfo]
+	}
+
+	// localTTLCache คือ LRU cache ขนาดจำกัดที่ entry หมดอายุตาม TTL
+	localTTLCache[V any] struct {
+		mu         sync.Mutex
+		ttl        time.Duration
+		maxEntries int
+		order      *list.List
+		items      map[string]*list.Element
+	}
+
+	localTTLEntry[V any] struct {
+		key       string
+		value     V
+		expiresAt time.Time
+	}
+)
+
+var (
+	sharedUserInfoResolver     *UserInfoResolver
+	sharedUserInfoResolverOnce sync.Once
+)
+
+// GetUserInfoResolver คืน resolver ตัวเดียวของ process เพื่อให้ทุก emitter/handler ใช้ cache ร่วมกัน
+func GetUserInfoResolver(db *mongo.Database, redisClient *redis.Client) *UserInfoResolver {
+	sharedUserInfoResolverOnce.Do(func() {
+		sharedUserInfoResolver = NewUserInfoResolver(db, redisClient)
+		go sharedUserInfoResolver.listenInvalidations(context.Background())
+	})
+	return sharedUserInfoResolver
+}
+
+// NewUserInfoResolver creates a new resolver (ใช้ GetUserInfoResolver แทนถ้าต้องการ cache ร่วม)
+func NewUserInfoResolver(db *mongo.Database, redisClient *redis.Client) *UserInfoResolver {
+	return &UserInfoResolver{
+		mongo: db,
+		redis: redisClient,
+		users: newLocalTTLCache[cachedUser](UserInfoLocalTTL, UserInfoLocalMaxEntries),
+		roles: newLocalTTLCache[model.RoleInfo](UserInfoLocalTTL, RoleInfoLocalMaxEntries),
+	}
+}
+
+// ResolveUser ดึง UserInfo ของ user คนเดียว (ไม่คืน error ใช้ fallback เสมอ)
+func (r *UserInfoResolver) ResolveUser(ctx context.Context, userID primitive.ObjectID) model.UserInfo {
+	return r.ResolveUsers(ctx, []primitive.ObjectID{userID})[userID.Hex()]
+}
+
+// ResolveUsers ดึง UserInfo ของหลาย user ในครั้งเดียว คืน map[userID hex]UserInfo ครบทุก ID ที่ขอ
+func (r *UserInfoResolver) ResolveUsers(ctx context.Context, userIDs []primitive.ObjectID) map[string]model.UserInfo {
+	result := make(map[string]model.UserInfo, len(userIDs))
+	entries := make(map[string]cachedUser, len(userIDs))
+
+	// 1. memory cache
+	var missing []string
+	seen := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		key := id.Hex()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if entry, ok := r.users.Get(key); ok {
+			entries[key] = entry
+		} else {
+			missing = append(missing, key)
+		}
+	}
+
+	// 2. Redis
+	if len(missing) > 0 {
+		missing = r.loadUsersFromRedis(ctx, missing, entries)
+	}
+
+	// 3. MongoDB ($in)
+	if len(missing) > 0 {
+		r.loadUsersFromDB(ctx, missing, entries)
+	}
+
+	// 4. role ของ user ทั้งหมด (batch เช่นกัน)
+	roleIDs := make([]string, 0)
+	for _, entry := range entries {
+		if entry.RoleID != "" {
+			roleIDs = append(roleIDs, entry.RoleID)
+		}
+	}
+	roles := r.ResolveRoles(ctx, roleIDs)
+
+	for _, id := range userIDs {
+		key := id.Hex()
+		entry, ok := entries[key]
+		if !ok {
+			result[key] = fallbackUserInfo(id)
+			continue
+		}
+		info := entry.Info
+		if entry.RoleID != "" {
+			if role, ok := roles[entry.RoleID]; ok {
+				roleCopy := role
+				info.Role = &roleCopy
+			} else {
+				info.Role = &model.RoleInfo{ID: entry.RoleID}
+			}
+		}
+		result[key] = info
+	}
+
+	return result
+}
+
+// ResolveRoles ดึง RoleInfo หลายตัวในครั้งเดียว คืนเฉพาะ role ที่พบ
+func (r *UserInfoResolver) ResolveRoles(ctx context.Context, roleIDs []string) map[string]model.RoleInfo {
+	result := make(map[string]model.RoleInfo, len(roleIDs))
+
+	var missing []string
+	seen := make(map[string]bool, len(roleIDs))
+	for _, id := range roleIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if role, ok := r.roles.Get(id); ok {
+			result[id] = role
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) > 0 {
+		missing = r.loadRolesFromRedis(ctx, missing, result)
+	}
+	if len(missing) > 0 {
+		r.loadRolesFromDB(ctx, missing, result)
+	}
+
+	return result
+}
+
+// InvalidateUser ล้าง cache ของ user ทั้ง memory, Redis และแจ้ง instance อื่น
+func (r *UserInfoResolver) InvalidateUser(ctx context.Context, userID string) {
+	r.users.Delete(userID)
+	r.redis.Del(ctx, userInfoKeyPrefix+userID)
+	r.redis.Publish(ctx, UserInfoInvalidateChannel, "user:"+userID)
+}
+
+// InvalidateRole ล้าง cache ของ role ทั้ง memory, Redis และแจ้ง instance อื่น
+func (r *UserInfoResolver) InvalidateRole(ctx context.Context, roleID string) {
+	r.roles.Delete(roleID)
+	r.redis.Del(ctx, roleInfoKeyPrefix+roleID)
+	r.redis.Publish(ctx, UserInfoInvalidateChannel, "role:"+roleID)
+}
+
+// ConsumeUpdateEvents ล้าง cache เมื่อมี event user/role update (ต้องเรียกก่อน bus.Start)
+// consumer group ส่ง event ให้ instance เดียว InvalidateUser/InvalidateRole จึงประกาศต่อให้ instance อื่นผ่าน Redis
+func (r *UserInfoResolver) ConsumeUpdateEvents(bus *kafka.Bus, topic string) {
+	if topic == "" {
+		return
+	}
+	bus.On(topic, func(ctx context.Context, msg *kafka.Message) error {
+		var event UserUpdateEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil || event.ID == "" {
+			log.Printf("[UserInfoResolver] ⚠️ Ignoring invalid user event on %s: %s", topic, string(msg.Value))
+			return nil
+		}
+		switch event.Type {
+		case UserEventUpdated, UserEventDeleted:
+			r.InvalidateUser(ctx, event.ID)
+		case RoleEventUpdated, RoleEventDeleted:
+			r.InvalidateRole(ctx, event.ID)
+		default:
+			return nil
+		}
+		log.Printf("[UserInfoResolver] 🧹 Invalidated cache for %s %s", event.Type, event.ID)
+		return nil
+	})
+	log.Printf("[UserInfoResolver] 👂 Consuming user/role update events on %s", topic)
+}
+
+// listenInvalidations รับประกาศล้าง cache แล้วลบทั้ง memory ของ instance นี้และ Redis
+// (publisher ภายนอกอาจ publish มาอย่างเดียวโดยไม่ได้ลบ key ใน Redis)
+func (r *UserInfoResolver) listenInvalidations(ctx context.Context) {
+	pubsub := r.redis.Subscribe(ctx, UserInfoInvalidateChannel)
+	defer pubsub.Close()
+
+	log.Printf("[UserInfoResolver] 👂 Listening for invalidations on %s", UserInfoInvalidateChannel)
+
+	for msg := range pubsub.Channel() {
+		kind, id, found := strings.Cut(msg.Payload, ":")
+		if !found || id == "" {
+			log.Printf("[UserInfoResolver] ⚠️ Ignoring invalid invalidation payload: %q", msg.Payload)
+			continue
+		}
+		switch kind {
+		case "user":
+			r.users.Delete(id)
+			r.redis.Del(ctx, userInfoKeyPrefix+id)
+		case "role":
+			r.roles.Delete(id)
+			r.redis.Del(ctx, roleInfoKeyPrefix+id)
+		default:
+			log.Printf("[UserInfoResolver] ⚠️ Unknown invalidation kind: %s", kind)
+		}
+	}
+}
+
+func (r *UserInfoResolver) loadUsersFromRedis(ctx context.Context, ids []string, entries map[string]cachedUser) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userInfoKeyPrefix + id
+	}
+
+	values, err := r.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("[UserInfoResolver] ⚠️ Redis MGET users failed: %v", err)
+		return ids
+	}
+
+	var missing []string
+	for i, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			missing = append(missing, ids[i])
+			continue
+		}
+		var entry cachedUser
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			missing = append(missing, ids[i])
+			continue
+		}
+		entries[ids[i]] = entry
+		r.users.Set(ids[i], entry)
+	}
+	return missing
+}
+
+func (r *UserInfoResolver) loadUsersFromDB(ctx context.Context, ids []string, entries map[string]cachedUser) {
+	objIDs := toObjectIDs(ids)
+	if len(objIDs) == 0 {
+		return
+	}
+
+	opts := options.Find().SetProjection(bson.M{"username": 1, "name": 1, "role": 1})
+	cursor, err := r.mongo.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}}, opts)
+	if err != nil {
+		log.Printf("[UserInfoResolver] ❌ Failed to query %d users: %v", len(objIDs), err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var users []userModel.User
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Printf("[UserInfoResolver] ❌ Failed to decode users: %v", err)
+		return
+	}
+
+	pipe := r.redis.Pipeline()
+	for _, user := range users {
+		entry := cachedUser{Info: buildUserInfo(&user)}
+		if user.Role != primitive.NilObjectID {
+			entry.RoleID = user.Role.Hex()
+		}
+
+		key := user.ID.Hex()
+		entries[key] = entry
+		r.users.Set(key, entry)
+		if data, err := json.Marshal(entry); err == nil {
+			pipe.Set(ctx, userInfoKeyPrefix+key, data, UserInfoRedisTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[UserInfoResolver] ⚠️ Failed to cache users in Redis: %v", err)
+	}
+}
+
+func (r *UserInfoResolver) loadRolesFromRedis(ctx context.Context, ids []string, result map[string]model.RoleInfo) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = roleInfoKeyPrefix + id
+	}
+
+	values, err := r.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("[UserInfoResolver] ⚠️ Redis MGET roles failed: %v", err)
+		return ids
+	}
+
+	var missing []string
+	for i, v := range values {
+		raw, ok := v.(string)
+		if !ok {
+			missing = append(missing, ids[i])
+			continue
+		}
+		var role model.RoleInfo
+		if err := json.Unmarshal([]byte(raw), &role); err != nil {
+			missing = append(missing, ids[i])
+			continue
+		}
+		result[ids[i]] = role
+		r.roles.Set(ids[i], role)
+	}
+	return missing
+}
+
+func (r *UserInfoResolver) loadRolesFromDB(ctx context.Context, ids []string, result map[string]model.RoleInfo) {
+	objIDs := toObjectIDs(ids)
+	if len(objIDs) == 0 {
+		return
+	}
+
+	// ไม่ดึง permissions มาด้วย (ไม่ต้องส่งให้ client)
+	opts := options.Find().SetProjection(bson.M{"name": 1})
+	cursor, err := r.mongo.Collection("roles").Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}}, opts)
+	if err != nil {
+		log.Printf("[UserInfoResolver] ❌ Failed to query %d roles: %v", len(objIDs), err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var roles []userModel.Role
+	if err := cursor.All(ctx, &roles); err != nil {
+		log.Printf("[UserInfoResolver] ❌ Failed to decode roles: %v", err)
+		return
+	}
+
+	pipe := r.redis.Pipeline()
+	for _, role := range roles {
+		info := model.RoleInfo{ID: role.ID.Hex(), Name: role.Name}
+		result[info.ID] = info
+		r.roles.Set(info.ID, info)
+		if data, err := json.Marshal(info); err == nil {
+			pipe.Set(ctx, roleInfoKeyPrefix+info.ID, data, UserInfoRedisTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[UserInfoResolver] ⚠️ Failed to cache roles in Redis: %v", err)
+	}
+}
+
+// buildUserInfo สร้าง UserInfo พร้อม fallback เมื่อ username/name ว่าง (ไม่รวม role)
+func buildUserInfo(user *userModel.User) model.UserInfo {
+	username := user.Username
+	if username == "" {
+		username = "user_" + user.ID.Hex()[:8]
+	}
+
+	firstName, lastName := user.Name.First, user.Name.Last
+	if firstName == "" && lastName == "" {
+		firstName = "User"
+		lastName = user.ID.Hex()[:8]
+	}
+
+	return model.UserInfo{
+		ID:       user.ID.Hex(),
+		Username: username,
+		Name: map[string]interface{}{
+			"first":  firstName,
+			"middle": user.Name.Middle,
+			"last":   lastName,
+		},
+	}
+}
+
+// fallbackUserInfo ใช้เมื่อหา user ไม่เจอ
+func fallbackUserInfo(userID primitive.ObjectID) model.UserInfo {
+	return model.UserInfo{
+		ID:       userID.Hex(),
+		Username: "user_" + userID.Hex()[:8],
+		Name: map[string]interface{}{
+			"first":  "User",
+			"middle": "",
+			"last":   userID.Hex()[:8],
+		},
+	}
+}
+
+func toObjectIDs(ids []string) []primitive.ObjectID {
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	return objIDs
+}
+
+func newLocalTTLCache[V any](ttl time.Duration, maxEntries int) *localTTLCache[V] {
+	return &localTTLCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get คืนค่าถ้ายังไม่หมดอายุ และขยับ entry ไปด้านหน้า (LRU)
+func (c *localTTLCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*localTTLEntry[V])
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set เพิ่ม/แทนที่ค่า และตัด entry ที่เก่าที่สุดออกเมื่อเกินขนาด
+func (c *localTTLCache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*localTTLEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&localTTLEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*localTTLEntry[V]).key)
+	}
+}
+
+// Delete ลบ entry ออกจาก cache
+func (c *localTTLCache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// Len คืนจำนวน entry ปัจจุบัน (รวม entry ที่หมดอายุแต่ยังไม่ถูกลบ)
+func (c *localTTLCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
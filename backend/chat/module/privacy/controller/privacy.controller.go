@@ -0,0 +1,116 @@
+package controller
+
+import (
+	privacyService "chat/module/privacy/service"
+	"chat/pkg/decorators"
+	"chat/pkg/middleware"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	PrivacyController struct {
+		*decorators.BaseController
+		privacyService *privacyService.PrivacyService
+		rbac           middleware.IRBACMiddleware
+	}
+
+	// EraseUserRequest ต้องส่ง confirm เป็น user ID เดียวกับใน path เพื่อกันลบผิดคน
+	EraseUserRequest struct {
+		Confirm string `json:"confirm"`
+		Reason  string `json:"reason"`
+	}
+)
+
+func NewPrivacyController(
+	app fiber.Router,
+	privacyService *privacyService.PrivacyService,
+	rbac middleware.IRBACMiddleware,
+) *PrivacyController {
+	controller := &PrivacyController{
+		BaseController: decorators.NewBaseController(app, ""),
+		privacyService: privacyService,
+		rbac:           rbac,
+	}
+
+	controller.setupRoutes()
+	return controller
+}
+
+func (c *PrivacyController) setupRoutes() {
+	c.Get("/admin/users/:userId/data", c.handleExportUserData, c.rbac.RequireAdministrator())
+	c.Post("/admin/users/:userId/erase", c.handleEraseUserData, c.rbac.RequireAdministrator())
+
+	c.SetupRoutes()
+}
+
+// handleExportUserData คืน bundle ข้อมูลทั้งหมดของผู้ใช้ (?download=true ส่งเป็นไฟล์แนบ)
+func (c *PrivacyController) handleExportUserData(ctx *fiber.Ctx) error {
+	userID := ctx.Params("userId")
+	bundle, err := c.privacyService.ExportUserData(ctx.UserContext(), userID, c.actor(ctx))
+	if err != nil {
+		return c.respondError(ctx, "Failed to export user data", err)
+	}
+
+	if ctx.QueryBool("download", false) {
+		ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="user_%s_data.json"`, userID))
+	}
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    bundle,
+	})
+}
+
+// handleEraseUserData anonymise ข้อมูลของผู้ใช้ (ย้อนกลับไม่ได้)
+func (c *PrivacyController) handleEraseUserData(ctx *fiber.Ctx) error {
+	userID := ctx.Params("userId")
+
+	var body EraseUserRequest
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if body.Confirm != userID {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "confirm must equal the user ID being erased",
+		})
+	}
+
+	result, err := c.privacyService.EraseUserData(ctx.UserContext(), userID, c.actor(ctx), body.Reason)
+	if err != nil {
+		return c.respondError(ctx, "Failed to erase user data", err)
+	}
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "User data erased",
+		"data":    result,
+	})
+}
+
+func (c *PrivacyController) respondError(ctx *fiber.Ctx, message string, err error) error {
+	if errors.Is(err, privacyService.ErrInvalidUserID) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+	})
+}
+
+func (c *PrivacyController) actor(ctx *fiber.Ctx) string {
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return "unknown"
+	}
+	return userID
+}
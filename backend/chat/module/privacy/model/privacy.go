@@ -0,0 +1,96 @@
+package model
+
+import (
+	chatModel "chat/module/chat/model"
+	chatUtils "chat/module/chat/utils"
+	restrictionModel "chat/module/restriction/model"
+	"chat/pkg/common"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: ข้อมูลผู้ใช้สำหรับคำขอดูข้อมูล/ลบข้อมูล (data subject request)**
+const (
+	ActionExport = "export"
+	ActionErase  = "erase"
+
+	// ErasedMessageText ข้อความที่แทนเนื้อหาของผู้ใช้ที่ถูกลบข้อมูล
+	ErasedMessageText = "[removed]"
+	// ErasedUsername ใช้แทน @username ในข้อความของคนอื่นที่ mention ผู้ใช้ที่ถูกลบข้อมูล
+	ErasedUsername = "deleted-user"
+)
+
+// ErasedUserID ผู้ส่ง/เป้าหมายของข้อความหลังลบข้อมูล (resolver จะแสดงเป็น user ที่ไม่มีตัวตน)
+var ErasedUserID = primitive.NilObjectID
+
+type (
+	// UserDataBundle ข้อมูลทั้งหมดของผู้ใช้ที่ chat service เก็บไว้
+	UserDataBundle struct {
+		UserID         string                             `json:"userId"`
+		GeneratedAt    time.Time                          `json:"generatedAt"`
+		Profile        *UserProfile                       `json:"profile,omitempty"`
+		Messages       []chatModel.ChatMessage            `json:"messages"`
+		Mentions       []MentionRecord                    `json:"mentions"`
+		Reactions      []interface{}                      `json:"reactions"`
+		EvoucherClaims []bson.M                           `json:"evoucherClaims"`
+		ClaimedInChat  []MessageRef                       `json:"claimedInChat"`
+		Restrictions   []restrictionModel.UserRestriction `json:"restrictions"`
+		Rooms          []RoomMembership                   `json:"rooms"`
+		Deliveries     []chatUtils.DeliveryStatus         `json:"deliveries"`
+		Notes          []string                           `json:"notes,omitempty"`
+	}
+
+	UserProfile struct {
+		Username  string      `json:"username"`
+		Name      common.Name `json:"name"`
+		Role      string      `json:"role,omitempty"`
+		CreatedAt time.Time   `json:"createdAt"`
+	}
+
+	// MentionRecord ข้อความของคนอื่นที่ mention ผู้ใช้
+	MentionRecord struct {
+		MessageID string    `json:"messageId"`
+		RoomID    string    `json:"roomId"`
+		FromID    string    `json:"fromUserId"`
+		Message   string    `json:"message"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	MessageRef struct {
+		MessageID string    `json:"messageId"`
+		RoomID    string    `json:"roomId"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	RoomMembership struct {
+		RoomID string               `bson:"_id" json:"roomId"`
+		Name   common.LocalizedName `bson:"name" json:"name"`
+		Type   string               `bson:"type" json:"type"`
+		Status string               `bson:"status" json:"status"`
+	}
+
+	// ErasureResult จำนวนรายการที่ถูกแก้ในแต่ละส่วน
+	ErasureResult struct {
+		MessagesAnonymised      int64 `bson:"messages_anonymised" json:"messagesAnonymised"`
+		ArchivedAnonymised      int64 `bson:"archived_anonymised" json:"archivedAnonymised"`
+		UploadsDeleted          int64 `bson:"uploads_deleted" json:"uploadsDeleted"`
+		RoomsLeft               int64 `bson:"rooms_left" json:"roomsLeft"`
+		EvoucherClaims          int64 `bson:"evoucher_claims" json:"evoucherClaims"`
+		RestrictionsAnonymised  int64 `bson:"restrictions_anonymised" json:"restrictionsAnonymised"`
+		DeliveryStatusesCleared int64 `bson:"delivery_statuses_cleared" json:"deliveryStatusesCleared"`
+	}
+
+	// PrivacyAudit บันทึกทุกการ export/erase (ไม่เก็บข้อมูลส่วนตัวนอกจาก user ID)
+	PrivacyAudit struct {
+		ID        primitive.ObjectID `bson:"_id" json:"id"`
+		UserID    primitive.ObjectID `bson:"user_id" json:"userId"`
+		Action    string             `bson:"action" json:"action"`
+		Actor     string             `bson:"actor" json:"actor"`
+		Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+		Result    *ErasureResult     `bson:"result,omitempty" json:"result,omitempty"`
+		Error     string             `bson:"error,omitempty" json:"error,omitempty"`
+		CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+	}
+)
@@ -0,0 +1,420 @@
+package service
+
+import (
+	chatModel "chat/module/chat/model"
+	chatUtils "chat/module/chat/utils"
+	"chat/module/privacy/model"
+	restrictionModel "chat/module/restriction/model"
+	roomCache "chat/module/room/shared/cache"
+	userModel "chat/module/user/model"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Export / erasure ข้อมูลผู้ใช้**
+// รวบรวมทุกอย่างที่ chat service เก็บเกี่ยวกับผู้ใช้ (รวมข้อความใน archive) และลบข้อมูลโดย anonymise ข้อความในที่เดิม
+// เพื่อไม่ให้ลำดับบทสนทนาของคนอื่นเสีย ตัว document ใน users ไม่ถูกลบ (backend หลักเป็นเจ้าของ)
+// งานนี้นาน ๆ ครั้ง query แบบ $or บน chat-messages จึงไม่ได้เพิ่ม index ให้ทุก field
+const (
+	PrivacyAuditCollection = "privacy-audit"
+	uploadsDir             = "uploads"
+	erasureBatchSize       = 500
+)
+
+var ErrInvalidUserID = errors.New("invalid user ID")
+
+type PrivacyService struct {
+	messages     *mongo.Collection
+	users        *mongo.Collection
+	roles        *mongo.Collection
+	rooms        *mongo.Collection
+	claims       *mongo.Collection
+	restrictions *mongo.Collection
+	audits       *mongo.Collection
+	archive      chatUtils.MessageArchive
+	chatCache    *chatUtils.ChatCacheService
+	roomCache    *roomCache.RoomCacheService
+	resolver     *chatUtils.UserInfoResolver
+	redis        *redis.Client
+}
+
+func NewPrivacyService(db *mongo.Database, redisClient *redis.Client, archive chatUtils.MessageArchive) *PrivacyService {
+	return &PrivacyService{
+		messages:     db.Collection("chat-messages"),
+		users:        db.Collection("users"),
+		roles:        db.Collection("roles"),
+		rooms:        db.Collection("rooms"),
+		claims:       db.Collection("evoucher-claims"),
+		restrictions: db.Collection("user-restrictions"),
+		audits:       db.Collection(PrivacyAuditCollection),
+		archive:      archive,
+		chatCache:    chatUtils.NewChatCacheService(redisClient),
+		roomCache:    roomCache.NewRoomCacheService(redisClient),
+		resolver:     chatUtils.GetUserInfoResolver(db, redisClient),
+		redis:        redisClient,
+	}
+}
+
+// ExportUserData รวบรวมข้อมูลของผู้ใช้เป็น bundle เดียว
+func (s *PrivacyService) ExportUserData(ctx context.Context, userIDHex, actor string) (*model.UserDataBundle, error) {
+	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	bundle := &model.UserDataBundle{
+		UserID:         userIDHex,
+		GeneratedAt:    time.Now(),
+		Messages:       []chatModel.ChatMessage{},
+		Mentions:       []model.MentionRecord{},
+		Reactions:      []interface{}{},
+		EvoucherClaims: []bson.M{},
+		ClaimedInChat:  []model.MessageRef{},
+		Restrictions:   []restrictionModel.UserRestriction{},
+		Rooms:          []model.RoomMembership{},
+		Notes: []string{
+			"Reactions are not persisted by the chat service.",
+			"Read receipts are not tracked by the chat service; delivery statuses expire after ASYNC_MESSAGE_STATUS_TTL.",
+		},
+	}
+
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		bundle.Notes = append(bundle.Notes, "User profile not found (account may already be removed).")
+	} else {
+		bundle.Profile = &model.UserProfile{Username: user.Username, Name: user.Name, CreatedAt: user.CreatedAt}
+		var role userModel.Role
+		if err := s.roles.FindOne(ctx, bson.M{"_id": user.Role}).Decode(&role); err == nil {
+			bundle.Profile.Role = role.Name
+		}
+	}
+
+	related, err := s.relatedMessages(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range related {
+		msg := &related[i]
+		if msg.UserID == userID {
+			bundle.Messages = append(bundle.Messages, *msg)
+		}
+		if msg.UserID != userID && slices.Contains(msg.Mentions, userIDHex) {
+			bundle.Mentions = append(bundle.Mentions, model.MentionRecord{
+				MessageID: msg.ID.Hex(),
+				RoomID:    msg.RoomID.Hex(),
+				FromID:    msg.UserID.Hex(),
+				Message:   msg.Message,
+				Timestamp: msg.Timestamp,
+			})
+		}
+		if msg.EvoucherInfo != nil && slices.Contains(msg.EvoucherInfo.ClaimedBy, userID) {
+			bundle.ClaimedInChat = append(bundle.ClaimedInChat, model.MessageRef{
+				MessageID: msg.ID.Hex(),
+				RoomID:    msg.RoomID.Hex(),
+				Timestamp: msg.Timestamp,
+			})
+		}
+	}
+
+	if err := findAll(ctx, s.claims, bson.M{"user_id": userID}, &bundle.EvoucherClaims); err != nil {
+		return nil, fmt.Errorf("failed to load evoucher claims: %w", err)
+	}
+	if err := findAll(ctx, s.restrictions, bson.M{"user_id": userID}, &bundle.Restrictions); err != nil {
+		return nil, fmt.Errorf("failed to load restrictions: %w", err)
+	}
+	if err := findAll(ctx, s.rooms, bson.M{"members": userID}, &bundle.Rooms,
+		options.Find().SetProjection(bson.M{"name": 1, "type": 1, "status": 1})); err != nil {
+		return nil, fmt.Errorf("failed to load room memberships: %w", err)
+	}
+	if bundle.Deliveries, err = chatUtils.DeliveryStatusesBySender(ctx, s.redis, userIDHex); err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, model.PrivacyAudit{UserID: userID, Action: model.ActionExport, Actor: actor}, nil)
+	log.Printf("[Privacy] 📦 Exported data of user %s for %s (%d messages)", userIDHex, actor, len(bundle.Messages))
+	return bundle, nil
+}
+
+// EraseUserData anonymise ข้อความ ถอดออกจากทุกห้อง ล้าง cache และบันทึก audit
+// ทำซ้ำได้ (ถ้าล้มกลางทาง สั่งใหม่จะทำต่อจากส่วนที่ยังไม่เสร็จ)
+func (s *PrivacyService) EraseUserData(ctx context.Context, userIDHex, actor, reason string) (*model.ErasureResult, error) {
+	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	if err != nil || userID == model.ErasedUserID {
+		return nil, ErrInvalidUserID
+	}
+
+	result := &model.ErasureResult{}
+	err = s.erase(ctx, userID, result)
+	s.recordAudit(ctx, model.PrivacyAudit{UserID: userID, Action: model.ActionErase, Actor: actor, Reason: reason, Result: result}, err)
+	if err != nil {
+		log.Printf("[Privacy] ❌ Erasure of user %s failed: %v", userIDHex, err)
+		return result, err
+	}
+	log.Printf("[Privacy] 🧽 Erased data of user %s for %s: %+v", userIDHex, actor, *result)
+	return result, nil
+}
+
+func (s *PrivacyService) erase(ctx context.Context, userID primitive.ObjectID, result *model.ErasureResult) error {
+	username := ""
+	if user, err := s.findUser(ctx, userID); err != nil {
+		return err
+	} else if user != nil {
+		username = user.Username
+	}
+
+	affectedRooms := make(map[primitive.ObjectID]bool)
+	var uploads []string
+	mutate := func(msg *chatModel.ChatMessage) bool {
+		if msg.UserID == userID && msg.StickerID == nil && msg.Image != "" {
+			uploads = append(uploads, msg.Image)
+		}
+		if !anonymiseMessage(msg, userID, username) {
+			return false
+		}
+		affectedRooms[msg.RoomID] = true
+		return true
+	}
+
+	// 1. ข้อความใน chat-messages (แก้ทีละ batch)
+	anonymised, err := s.rewriteLiveMessages(ctx, userID, mutate)
+	result.MessagesAnonymised = anonymised
+	if err != nil {
+		return err
+	}
+
+	// 2. ข้อความใน archive
+	result.ArchivedAnonymised, err = s.archive.RewriteByUser(ctx, userID, mutate)
+	if err != nil {
+		return err
+	}
+
+	// 3. ไฟล์ที่ upload
+	for _, name := range uploads {
+		err := os.Remove(filepath.Join(uploadsDir, filepath.Base(name)))
+		if err == nil {
+			result.UploadsDeleted++
+		} else if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[Privacy] ⚠️ Failed to delete upload %s: %v", name, err)
+		}
+	}
+
+	// 4. สมาชิกและ role ในห้อง (member_roles อาจค้างอยู่ในห้องที่ไม่ได้เป็นสมาชิกแล้ว เช่น ผู้สร้างห้องที่ออกไป)
+	roleKey := "member_roles." + userID.Hex()
+	roomFilter := bson.M{"$or": bson.A{bson.M{"members": userID}, bson.M{roleKey: bson.M{"$exists": true}}}}
+	var rooms []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := findAll(ctx, s.rooms, roomFilter, &rooms, options.Find().SetProjection(bson.M{"_id": 1})); err != nil {
+		return fmt.Errorf("failed to load room memberships: %w", err)
+	}
+	if len(rooms) > 0 {
+		update, err := s.rooms.UpdateMany(ctx, bson.M{"members": userID}, bson.M{"$pull": bson.M{"members": userID}})
+		if err != nil {
+			return fmt.Errorf("failed to remove user from rooms: %w", err)
+		}
+		result.RoomsLeft = update.ModifiedCount
+		if _, err := s.rooms.UpdateMany(ctx, bson.M{roleKey: bson.M{"$exists": true}}, bson.M{"$unset": bson.M{roleKey: ""}}); err != nil {
+			return fmt.Errorf("failed to remove user room roles: %w", err)
+		}
+		for _, room := range rooms {
+			if err := s.roomCache.DeleteRoom(ctx, room.ID.Hex()); err != nil {
+				log.Printf("[Privacy] ⚠️ Failed to clear room cache %s: %v", room.ID.Hex(), err)
+			}
+		}
+	}
+
+	// 5. claim evoucher และประวัติการลงโทษ (เก็บ record ไว้เพื่อนับ/ตรวจสอบ แต่ไม่ผูกกับตัวผู้ใช้)
+	update, err := s.claims.UpdateMany(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"user_id": model.ErasedUserID}})
+	if err != nil {
+		return fmt.Errorf("failed to anonymise evoucher claims: %w", err)
+	}
+	result.EvoucherClaims = update.ModifiedCount
+
+	for _, field := range []string{"user_id", "restrictor_id", "revoked_by"} {
+		update, err := s.restrictions.UpdateMany(ctx, bson.M{field: userID}, bson.M{"$set": bson.M{field: model.ErasedUserID}})
+		if err != nil {
+			return fmt.Errorf("failed to anonymise restrictions: %w", err)
+		}
+		result.RestrictionsAnonymised += update.ModifiedCount
+	}
+
+	// 6. สถานะการส่งข้อความใน Redis (chat:delivery:*) ผูกกับ sender_id จึงลบทิ้งทั้ง record
+	result.DeliveryStatusesCleared, err = chatUtils.DeleteDeliveryStatusesBySender(ctx, s.redis, userID.Hex())
+	if err != nil {
+		return err
+	}
+
+	// 7. cache ที่ยังมีชื่อ/ข้อความของผู้ใช้
+	for roomID := range affectedRooms {
+		if err := s.chatCache.DeleteRoomMessages(ctx, roomID.Hex()); err != nil {
+			log.Printf("[Privacy] ⚠️ Failed to clear message cache of room %s: %v", roomID.Hex(), err)
+		}
+	}
+	s.resolver.InvalidateUser(ctx, userID.Hex())
+	return nil
+}
+
+func (s *PrivacyService) rewriteLiveMessages(ctx context.Context, userID primitive.ObjectID, mutate func(*chatModel.ChatMessage) bool) (int64, error) {
+	cursor, err := s.messages.Find(ctx, chatUtils.UserRelatedFilter(userID), options.Find().SetBatchSize(erasureBatchSize))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var total int64
+	var models []mongo.WriteModel
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		res, err := s.messages.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("failed to anonymise messages: %w", err)
+		}
+		total += res.ModifiedCount
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var msg chatModel.ChatMessage
+		if err := cursor.Decode(&msg); err != nil {
+			return total, fmt.Errorf("failed to decode message: %w", err)
+		}
+		if !mutate(&msg) {
+			continue
+		}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": msg.ID}).SetReplacement(msg))
+		if len(models) == erasureBatchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return total, fmt.Errorf("failed to read messages: %w", err)
+	}
+	return total, flush()
+}
+
+// relatedMessages ข้อความทั้งใน chat-messages และ archive ที่เกี่ยวกับผู้ใช้ เรียงตามเวลา
+func (s *PrivacyService) relatedMessages(ctx context.Context, userID primitive.ObjectID) ([]chatModel.ChatMessage, error) {
+	archived, err := s.archive.LoadByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var live []chatModel.ChatMessage
+	if err := findAll(ctx, s.messages, chatUtils.UserRelatedFilter(userID), &live,
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})); err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(archived))
+	for _, msg := range archived {
+		seen[msg.ID] = true
+	}
+	messages := archived
+	for _, msg := range live {
+		if !seen[msg.ID] {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (s *PrivacyService) findUser(ctx context.Context, userID primitive.ObjectID) (*userModel.User, error) {
+	var user userModel.User
+	err := s.users.FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"password": 0, "refreshToken": 0})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	return &user, nil
+}
+
+func (s *PrivacyService) recordAudit(ctx context.Context, audit model.PrivacyAudit, err error) {
+	audit.ID = primitive.NewObjectID()
+	audit.CreatedAt = time.Now()
+	if err != nil {
+		audit.Error = err.Error()
+	}
+	if _, insertErr := s.audits.InsertOne(ctx, audit); insertErr != nil {
+		log.Printf("[Privacy] ⚠️ Failed to record %s audit for user %s: %v", audit.Action, audit.UserID.Hex(), insertErr)
+	}
+}
+
+// anonymiseMessage ตัดความเชื่อมโยงของข้อความกับผู้ใช้ คืน true ถ้ามีการแก้
+func anonymiseMessage(msg *chatModel.ChatMessage, userID primitive.ObjectID, username string) bool {
+	changed := false
+	userHex := userID.Hex()
+
+	// ข้อความของผู้ใช้เอง: เหลือแค่ตำแหน่งในบทสนทนา
+	if msg.UserID == userID {
+		msg.UserID = model.ErasedUserID
+		msg.Message = model.ErasedMessageText
+		msg.Mentions = nil
+		msg.MentionInfo = nil
+		msg.FileName = ""
+		if msg.StickerID == nil {
+			msg.Image = ""
+		}
+		changed = true
+	}
+
+	// ข้อความของคนอื่นที่ mention ผู้ใช้
+	if slices.Contains(msg.Mentions, userHex) {
+		msg.Mentions = slices.DeleteFunc(msg.Mentions, func(id string) bool { return id == userHex })
+		msg.MentionInfo = slices.DeleteFunc(msg.MentionInfo, func(m chatModel.MentionInfo) bool { return m.UserID == userHex })
+		if username != "" {
+			msg.Message = strings.ReplaceAll(msg.Message, "@"+username, "@"+model.ErasedUsername)
+		}
+		changed = true
+	}
+
+	if msg.EvoucherInfo != nil && slices.Contains(msg.EvoucherInfo.ClaimedBy, userID) {
+		msg.EvoucherInfo.ClaimedBy = slices.DeleteFunc(msg.EvoucherInfo.ClaimedBy, func(id primitive.ObjectID) bool { return id == userID })
+		changed = true
+	}
+	if msg.ModerationInfo != nil && msg.ModerationInfo.UserID == userID {
+		msg.ModerationInfo.UserID = model.ErasedUserID
+		changed = true
+	}
+	if msg.DeletedBy != nil && *msg.DeletedBy == userID {
+		erased := model.ErasedUserID
+		msg.DeletedBy = &erased
+		changed = true
+	}
+
+	if changed {
+		msg.UpdatedAt = time.Now()
+	}
+	return changed
+}
+
+func findAll(ctx context.Context, collection *mongo.Collection, filter interface{}, out interface{}, opts ...*options.FindOptions) error {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, out)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	restrictionDto "chat/module/restriction/dto"
 	restrictionService "chat/module/restriction/service"
+	roomModel "chat/module/room/room/model"
 	"chat/pkg/database/queries"
 	"chat/pkg/decorators"
 	"chat/pkg/middleware"
@@ -17,6 +18,7 @@ type (
 		*decorators.BaseController
 		moderationService *restrictionService.RestrictionService
 		rbac middleware.IRBACMiddleware
+		roomRoles         middleware.RoomRoleResolver
 	}
 )
 
@@ -24,11 +26,13 @@ func NewModerationController(
 	app fiber.Router,
 	moderationService *restrictionService.RestrictionService,
 	rbac middleware.IRBACMiddleware,
+	roomRoles middleware.RoomRoleResolver,
 ) *RestrictionController {
 	controller := &RestrictionController{
 		BaseController:    decorators.NewBaseController(app, ""),
 		moderationService: moderationService,
 		rbac: rbac,
+		roomRoles:         roomRoles,
 	}
 
 	controller.setupRoutes()
@@ -36,21 +40,26 @@ func NewModerationController(
 }
 
 func (c *RestrictionController) setupRoutes() {
+	// **NEW: owner/moderator ของห้องใช้ได้เฉพาะห้องตัวเอง (Administrator ได้ทุกห้อง)**
+	bodyRoom := c.rbac.RequireRoomRole(c.roomRoles, middleware.RoomIDFromBody("roomId"), roomModel.ModeratorRoomRoles...)
+	paramRoom := c.rbac.RequireRoomRole(c.roomRoles, middleware.RoomIDFromParam("roomId"), roomModel.ModeratorRoomRoles...)
+	queryRoom := c.rbac.RequireRoomRole(c.roomRoles, middleware.RoomIDFromQuery("roomId"), roomModel.ModeratorRoomRoles...)
+
 	// Ban operations
-	c.Post("/ban", c.handleBanUser, c.rbac.RequireAdministrator())
-	c.Post("/unban", c.handleUnbanUser, c.rbac.RequireAdministrator())
+	c.Post("/ban", c.handleBanUser, bodyRoom)
+	c.Post("/unban", c.handleUnbanUser, bodyRoom)
 	
 	// Mute operations
-	c.Post("/mute", c.handleMuteUser, c.rbac.RequireAdministrator())
-	c.Post("/unmute", c.handleUnmuteUser, c.rbac.RequireAdministrator())
+	c.Post("/mute", c.handleMuteUser, bodyRoom)
+	c.Post("/unmute", c.handleUnmuteUser, bodyRoom)
 	
 	// Kick operations
-	c.Post("/kick", c.handleKickUser, c.rbac.RequireAdministrator())
+	c.Post("/kick", c.handleKickUser, bodyRoom)
 	
-	// Status and history
-	c.Get("/status/:roomId/:userId", c.handleGetModerationStatus, c.rbac.RequireAdministrator())
-	c.Get("/room/:roomId/restrictions", c.handleGetRoomRestrictions, c.rbac.RequireAdministrator())
-	c.Get("/history", c.handleGetModerationHistory, c.rbac.RequireAdministrator())
+	// Status and history (history ไม่ระบุ roomId ได้เฉพาะ Administrator)
+	c.Get("/status/:roomId/:userId", c.handleGetModerationStatus, paramRoom)
+	c.Get("/room/:roomId/restrictions", c.handleGetRoomRestrictions, paramRoom)
+	c.Get("/history", c.handleGetModerationHistory, queryRoom)
 	
 	c.SetupRoutes()
 }
@@ -80,6 +89,9 @@ func (c *RestrictionController) handleBanUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.checkAuthorizedRoom(ctx, roomObjID); !ok {
+		return err
+	}
 	restrictorId, err := c.rbac.ExtractUserIDFromContext(ctx)
 	if err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -104,6 +116,9 @@ func (c *RestrictionController) handleBanUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.canRestrictTarget(ctx, roomObjID, userObjID); !ok {
+		return err
+	}
 	banRecord, err := c.moderationService.BanUser(
 		ctx.Context(),
 		userObjID,
@@ -157,6 +172,9 @@ func (c *RestrictionController) handleUnbanUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.checkAuthorizedRoom(ctx, roomObjID); !ok {
+		return err
+	}
 	restrictorId, err := c.rbac.ExtractUserIDFromContext(ctx)
 	if err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -216,6 +234,9 @@ func (c *RestrictionController) handleMuteUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.checkAuthorizedRoom(ctx, roomObjID); !ok {
+		return err
+	}
 	restrictorId, err := c.rbac.ExtractUserIDFromContext(ctx)
 	if err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -240,6 +261,9 @@ func (c *RestrictionController) handleMuteUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.canRestrictTarget(ctx, roomObjID, userObjID); !ok {
+		return err
+	}
 	muteRecord, err := c.moderationService.MuteUser(
 		ctx.Context(),
 		userObjID,
@@ -295,6 +319,9 @@ func (c *RestrictionController) handleUnmuteUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.checkAuthorizedRoom(ctx, roomObjID); !ok {
+		return err
+	}
 	restrictorId, err := c.rbac.ExtractUserIDFromContext(ctx)
 	if err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -354,6 +381,9 @@ func (c *RestrictionController) handleKickUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.checkAuthorizedRoom(ctx, roomObjID); !ok {
+		return err
+	}
 	restrictorId, err := c.rbac.ExtractUserIDFromContext(ctx)
 	if err != nil {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -370,6 +400,9 @@ func (c *RestrictionController) handleKickUser(ctx *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if ok, err := c.canRestrictTarget(ctx, roomObjID, userObjID); !ok {
+		return err
+	}
 	kickRecord, err := c.moderationService.KickUser(
 		ctx.Context(),
 		userObjID,
@@ -531,4 +564,50 @@ func (c *RestrictionController) handleGetModerationHistory(ctx *fiber.Ctx) error
 		"data":    result.Data,
 		"meta":    result.Meta,
 	})
-} 
\ No newline at end of file
+} 
+
+// checkAuthorizedRoom ห้องใน body ต้องเป็นห้องเดียวกับที่ RequireRoomRole ตรวจสิทธิ์ให้
+func (c *RestrictionController) checkAuthorizedRoom(ctx *fiber.Ctx, roomID primitive.ObjectID) (bool, error) {
+	if middleware.IsAuthorizedRoom(ctx, roomID) {
+		return true, nil
+	}
+	return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"success": false,
+		"message": "You don't have the required role in this room",
+		"error":   "INSUFFICIENT_PERMISSIONS",
+	})
+}
+
+// canRestrictTarget moderator ของห้องลงโทษได้เฉพาะสมาชิกทั่วไป (ไม่ใช่ owner/moderator ของห้อง หรือ Administrator)
+func (c *RestrictionController) canRestrictTarget(ctx *fiber.Ctx, roomID, userID primitive.ObjectID) (bool, error) {
+	if middleware.IsGlobalAdministrator(ctx) {
+		return true, nil
+	}
+
+	targetRoomRole, err := c.roomRoles.GetMemberRole(ctx.UserContext(), roomID, userID)
+	if err != nil {
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to check target room role",
+			"error":   err.Error(),
+		})
+	}
+	// ถ้าหา global role ของเป้าหมายไม่ได้ ให้ปฏิเสธไว้ก่อน (อาจเป็น Administrator)
+	targetRole, err := c.rbac.GetUserRole(userID.Hex())
+	if err != nil {
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to check target role",
+			"error":   err.Error(),
+		})
+	}
+
+	if targetRoomRole == roomModel.RoomRoleOwner || targetRoomRole == roomModel.RoomRoleModerator || targetRole == middleware.RoleAdministrator {
+		return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Room moderators cannot restrict room owners, moderators or administrators",
+			"error":   "INSUFFICIENT_PERMISSIONS",
+		})
+	}
+	return true, nil
+}
@@ -12,7 +12,9 @@ import (
 
 	notificationservice "chat/module/notification/service"
 
+	"chat/pkg/core/audit"
 	"chat/pkg/core/kafka"
+	"chat/pkg/core/metrics"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -84,6 +86,14 @@ func (s *RestrictionService) BanUser(ctx context.Context, userID, roomID, restri
 	}
 	banRecord.ID = result.Data[0].ID
 	log.Printf("[ModerationService] Successfully banned user %s for %s", userID.Hex(), duration)
+	metrics.RestrictionActions.Inc("ban")
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRestrictionBan,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+		After:      banRecord,
+		Details:    map[string]interface{}{"roomId": roomID.Hex()},
+	})
 
 	// Emit and notify using helper
 	err = restrictionUtils.EmitAndNotifyRestriction(ctx, s.emitter, s.notificationService, s.mongo, userID, roomID, restrictorID, banRecord, "ban", reason, duration, "", endTime)
@@ -126,6 +136,14 @@ func (s *RestrictionService) MuteUser(ctx context.Context, userID, roomID, restr
 	}
 	muteRecord.ID = result.Data[0].ID
 	log.Printf("[ModerationService] Successfully muted user %s for %s", userID.Hex(), duration)
+	metrics.RestrictionActions.Inc("mute")
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRestrictionMute,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+		After:      muteRecord,
+		Details:    map[string]interface{}{"roomId": roomID.Hex()},
+	})
 
 	// Emit and notify using helper
 	err = restrictionUtils.EmitAndNotifyRestriction(ctx, s.emitter, s.notificationService, s.mongo, userID, roomID, restrictorID, muteRecord, "mute", reason, duration, restriction, endTime)
@@ -173,7 +191,10 @@ func (s *RestrictionService) UnbanUser(ctx context.Context, userID, roomID, rest
 	}
 
 	log.Printf("[ModerationService] Successfully updated ban record: ModifiedCount=%d", result.ModifiedCount)
+	before := *activeBan
 	activeBan.Status = "revoked"
+	activeBan.RevokedAt = &now
+	activeBan.RevokedBy = &restrictorID
 
 	// **NEW: Re-add user to room if not already a member**
 	roomCollection := s.mongo.Collection("rooms")
@@ -216,6 +237,15 @@ func (s *RestrictionService) UnbanUser(ctx context.Context, userID, roomID, rest
 	}
 
 	log.Printf("[ModerationService] ✅ Successfully unbanned user %s in room %s", userID.Hex(), roomID.Hex())
+	metrics.RestrictionActions.Inc("unban")
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRestrictionUnban,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+		Before:     before,
+		After:      activeBan,
+		Details:    map[string]interface{}{"roomId": roomID.Hex()},
+	})
 	return nil
 }
 
@@ -256,7 +286,10 @@ func (s *RestrictionService) UnmuteUser(ctx context.Context, userID, roomID, res
 	}
 
 	log.Printf("[ModerationService] Successfully updated mute record: ModifiedCount=%d", result.ModifiedCount)
+	before := *activeMute
 	activeMute.Status = "revoked"
+	activeMute.RevokedAt = &now
+	activeMute.RevokedBy = &restrictorID
 
 	// **NEW: Re-add user to room if not already a member**
 	roomCollection := s.mongo.Collection("rooms")
@@ -299,6 +332,15 @@ func (s *RestrictionService) UnmuteUser(ctx context.Context, userID, roomID, res
 	}
 
 	log.Printf("[ModerationService] ✅ Successfully unmuted user %s in room %s", userID.Hex(), roomID.Hex())
+	metrics.RestrictionActions.Inc("unmute")
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRestrictionUnmute,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+		Before:     before,
+		After:      activeMute,
+		Details:    map[string]interface{}{"roomId": roomID.Hex()},
+	})
 	return nil
 }
 
@@ -333,6 +375,14 @@ func (s *RestrictionService) KickUser(ctx context.Context, userID, roomID, restr
 	}
 	kickRecord.ID = result.Data[0].ID
 	log.Printf("[ModerationService] Successfully kicked user %s from room", userID.Hex())
+	metrics.RestrictionActions.Inc("kick")
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRestrictionKick,
+		TargetType: audit.TargetUser,
+		TargetID:   userID.Hex(),
+		After:      kickRecord,
+		Details:    map[string]interface{}{"roomId": roomID.Hex()},
+	})
 
 	// Cache already cleared in removeUserFromRoom function
 
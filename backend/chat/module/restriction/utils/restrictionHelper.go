@@ -87,7 +87,8 @@ func EmitAndNotifyRestriction(
 	// Update message with target username
 	msg.Message = generateRestrictionMessage(action, targetUser.Username, reason)
 
-	// 3. Save moderation message to chat-messages collection
+	// 3. Save moderation message to chat-messages collection (พร้อม seq ของห้อง)
+	emitter.Sequencer().Assign(ctx, msg)
 	chatMsgCollection := db.Collection("chat-messages")
 	msg.CreatedAt = time.Now()
 	msg.UpdatedAt = time.Now()
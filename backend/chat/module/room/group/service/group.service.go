@@ -9,6 +9,7 @@ import (
 	sharedEvents "chat/module/room/shared/events"
 	userService "chat/module/user/service"
 	"chat/pkg/config"
+	"chat/pkg/core/audit"
 	"chat/pkg/core/kafka"
 	"chat/pkg/database/queries"
 	serviceHelper "chat/pkg/helpers/service"
@@ -167,6 +168,12 @@ func (gs *GroupRoomService) CreateRoomByGroup(ctx context.Context, createDto *dt
 	}
 
 	gs.eventEmitter.EmitRoomCreated(ctx, finalRoom.ID, finalRoom)
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomCreate,
+		TargetType: audit.TargetRoom,
+		TargetID:   finalRoom.ID.Hex(),
+		After:      finalRoom.AuditSnapshot(),
+	})
 
 	return finalRoom, nil
 }
@@ -240,7 +247,18 @@ func (gs *GroupRoomService) JoinRoomByGroup(ctx context.Context, roomID primitiv
 		return 0, fmt.Errorf("room is not a group room")
 	}
 
-	return gs.addGroupMembersAndWait(ctx, roomID, groupType, groupValue)
+	added, err := gs.addGroupMembersAndWait(ctx, roomID, groupType, groupValue)
+	entry := audit.Entry{
+		Action:     audit.ActionRoomGroupJoin,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Details:    map[string]interface{}{"groupType": groupType, "groupValue": groupValue, "added": added},
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	audit.Record(ctx, entry)
+	return added, err
 }
 
 // BulkAddUsersToRoom เพิ่มผู้ใช้หลายคนเข้าห้อง
@@ -265,7 +283,18 @@ func (gs *GroupRoomService) BulkAddUsersToRoom(ctx context.Context, roomID primi
 		return 0, nil
 	}
 
-	return gs.helper.BulkAddMembers(ctx, roomID, validUserIDs)
+	added, err := gs.helper.BulkAddMembers(ctx, roomID, validUserIDs)
+	entry := audit.Entry{
+		Action:     audit.ActionRoomBulkAdd,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Details:    map[string]interface{}{"requested": len(userIDs), "added": added, "userIds": validUserIDs},
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	audit.Record(ctx, entry)
+	return added, err
 }
 
 // AutoAddUserToGroupRooms เพิ่ม user เข้าห้องกลุ่มที่เหมาะสมอัตโนมัติ
@@ -0,0 +1,292 @@
+package controller
+
+import (
+	inviteService "chat/module/room/invite/service"
+	roomModel "chat/module/room/room/model"
+	sharedUtils "chat/module/room/shared/utils"
+	"chat/pkg/database/queries"
+	"chat/pkg/decorators"
+	"chat/pkg/middleware"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type (
+	InviteController struct {
+		*decorators.BaseController
+		inviteService    *inviteService.InviteService
+		rbac             middleware.IRBACMiddleware
+		validationHelper *sharedUtils.RoomValidationHelper
+		controllerHelper *sharedUtils.RoomControllerHelper
+	}
+
+	// RedeemInviteRequest ส่ง token (จากลิงก์/QR) หรือ code (พิมพ์เอง) อย่างใดอย่างหนึ่ง
+	RedeemInviteRequest struct {
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}
+)
+
+func NewInviteController(
+	app fiber.Router,
+	inviteService *inviteService.InviteService,
+	rbac middleware.IRBACMiddleware,
+) *InviteController {
+	controller := &InviteController{
+		BaseController:   decorators.NewBaseController(app, ""),
+		inviteService:    inviteService,
+		rbac:             rbac,
+		validationHelper: sharedUtils.NewRoomValidationHelper(0, nil),
+		controllerHelper: sharedUtils.NewRoomControllerHelper(),
+	}
+
+	controller.setupRoutes()
+	return controller
+}
+
+func (c *InviteController) setupRoutes() {
+	c.Post("/invites/redeem", c.handleRedeemInvite, c.rbac.RequireReadOnlyAccess())
+	c.Get("/invites/:code/preview", c.handlePreviewInvite, c.rbac.RequireReadOnlyAccess())
+	c.Post("/:id/invites", c.handleCreateInvite, c.rbac.RequireRoles(middleware.RoleAdministrator, middleware.RoleStaff))
+	c.Get("/:id/invites", c.handleListInvites, c.rbac.RequireRoles(middleware.RoleAdministrator, middleware.RoleStaff))
+	c.Delete("/:id/invites/:inviteId", c.handleRevokeInvite, c.rbac.RequireRoles(middleware.RoleAdministrator, middleware.RoleStaff))
+	c.Get("/:id/invites/:inviteId/redemptions", c.handleListRedemptions, c.rbac.RequireRoles(middleware.RoleAdministrator, middleware.RoleStaff))
+	c.Get("/:id/invite-redemptions", c.handleListRedemptions, c.rbac.RequireRoles(middleware.RoleAdministrator, middleware.RoleStaff))
+	c.SetupRoutes()
+}
+
+// handleCreateInvite สร้างลิงก์เชิญ + join code ของห้อง
+func (c *InviteController) handleCreateInvite(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	var input inviteService.CreateInviteInput
+	if len(ctx.Body()) > 0 {
+		if err := json.Unmarshal(ctx.Body(), &input); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid invite body",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	invite, err := c.inviteService.CreateInvite(ctx.UserContext(), roomObjID, input, c.actor(ctx))
+	if err != nil {
+		return c.respondError(ctx, "Failed to create invite", err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Invite created successfully",
+		"data":    invite,
+	})
+}
+
+// handleListInvites invite ทั้งหมดของห้อง (รวมที่หมดอายุ/ยกเลิกแล้ว)
+func (c *InviteController) handleListInvites(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	invites, err := c.inviteService.ListInvites(ctx.UserContext(), roomObjID)
+	if err != nil {
+		return c.respondError(ctx, "Failed to list invites", err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Invites retrieved successfully",
+		"data":    invites,
+	})
+}
+
+// handleRevokeInvite ยกเลิก invite
+func (c *InviteController) handleRevokeInvite(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+	inviteObjID, err := primitive.ObjectIDFromHex(ctx.Params("inviteId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid invite ID",
+		})
+	}
+
+	invite, err := c.inviteService.RevokeInvite(ctx.UserContext(), roomObjID, inviteObjID, c.actor(ctx))
+	if err != nil {
+		return c.respondError(ctx, "Failed to revoke invite", err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Invite revoked successfully",
+		"data":    invite,
+	})
+}
+
+// handleListRedemptions ใครเข้าห้องผ่าน invite ไหน (ไม่มี :inviteId = ทุก invite ของห้อง)
+func (c *InviteController) handleListRedemptions(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	var inviteObjID primitive.ObjectID
+	if raw := ctx.Params("inviteId"); raw != "" {
+		if inviteObjID, err = primitive.ObjectIDFromHex(raw); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid invite ID",
+			})
+		}
+	}
+
+	redemptions, meta, err := c.inviteService.Redemptions(ctx.UserContext(), roomObjID, inviteObjID,
+		ctx.QueryInt("page", 1), ctx.QueryInt("limit", 50))
+	if err != nil {
+		return c.respondError(ctx, "Failed to list invite redemptions", err)
+	}
+
+	return ctx.JSON(queries.Response[inviteService.RedemptionView]{
+		Success: true,
+		Message: "Invite redemptions retrieved successfully",
+		Data:    redemptions,
+		Meta:    meta,
+	})
+}
+
+// handlePreviewInvite ข้อมูลห้องก่อนเข้าร่วม (รับได้ทั้ง token และ code)
+func (c *InviteController) handlePreviewInvite(ctx *fiber.Ctx) error {
+	userObjID, err := sharedUtils.ExtractUserIDFromJWT(ctx)
+	if err != nil {
+		return c.validationHelper.BuildValidationErrorResponse(ctx, err)
+	}
+
+	preview, err := c.inviteService.Preview(ctx.UserContext(), userObjID, ctx.Params("code"))
+	if err != nil {
+		return c.respondError(ctx, "Failed to load invite", err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Invite is valid",
+		"data":    preview,
+	})
+}
+
+// handleRedeemInvite เข้าห้องด้วย invite (ผ่าน JoinRoom ปกติ: capacity, สถานะห้อง, event สมาชิก)
+func (c *InviteController) handleRedeemInvite(ctx *fiber.Ctx) error {
+	var body RedeemInviteRequest
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	value := strings.TrimSpace(body.Token)
+	if value == "" {
+		value = strings.TrimSpace(body.Code)
+	}
+	if value == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "token or code is required",
+		})
+	}
+
+	userObjID, err := sharedUtils.ExtractUserIDFromJWT(ctx)
+	if err != nil {
+		return c.validationHelper.BuildValidationErrorResponse(ctx, err)
+	}
+
+	room, err := c.inviteService.Redeem(ctx.UserContext(), userObjID, value)
+	if err != nil {
+		return c.respondError(ctx, "Failed to join room", err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Successfully joined room",
+		"data": fiber.Map{
+			"roomId":   room.ID.Hex(),
+			"userId":   userObjID.Hex(),
+			"roomName": room.Name,
+		},
+	})
+}
+
+func (c *InviteController) respondError(ctx *fiber.Ctx, message string, err error) error {
+	status := 0
+	code := ""
+	switch {
+	case errors.Is(err, inviteService.ErrInviteNotFound):
+		status, code = fiber.StatusNotFound, "INVITE_NOT_FOUND"
+	case errors.Is(err, inviteService.ErrInviteExpired):
+		status, code = fiber.StatusGone, "INVITE_EXPIRED"
+	case errors.Is(err, inviteService.ErrInviteRevoked):
+		status, code = fiber.StatusGone, "INVITE_REVOKED"
+	case errors.Is(err, inviteService.ErrInviteExhausted):
+		status, code = fiber.StatusGone, "INVITE_EXHAUSTED"
+	case errors.Is(err, inviteService.ErrInviteRoleMismatch):
+		status, code = fiber.StatusForbidden, "INVITE_ROLE_RESTRICTION"
+	case errors.Is(err, inviteService.ErrAlreadyMember):
+		status, code = fiber.StatusConflict, "ALREADY_MEMBER"
+	case errors.Is(err, inviteService.ErrTooManyAttempts):
+		status, code = fiber.StatusTooManyRequests, "TOO_MANY_ATTEMPTS"
+	case errors.Is(err, inviteService.ErrInvalidInvite):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, queries.ErrNotFound):
+		return c.validationHelper.BuildNotFoundErrorResponse(ctx, "Room")
+	case errors.Is(err, roomModel.ErrRoomFull),
+		errors.Is(err, roomModel.ErrGroupRoomRestricted),
+		errors.Is(err, roomModel.ErrRoomInactive),
+		errors.Is(err, roomModel.ErrRoomNotFound):
+		return c.controllerHelper.HandleJoinRoomError(ctx, err, c.validationHelper)
+	}
+
+	if status != 0 {
+		resp := fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		}
+		if code != "" {
+			resp["error"] = code
+		}
+		return ctx.Status(status).JSON(resp)
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+	})
+}
+
+func (c *InviteController) actor(ctx *fiber.Ctx) string {
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return "unknown"
+	}
+	return userID
+}
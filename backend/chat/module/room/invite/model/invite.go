@@ -0,0 +1,94 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	InviteCollection     = "room-invites"
+	RedemptionCollection = "room-invite-redemptions"
+)
+
+// CodeAlphabet ตัวอักษรของ join code แบบ Crockford base32 (ไม่มี I, L, O, U ที่อ่านสับสนบนป้าย)
+const CodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// สถานะที่คำนวณจาก field ของ invite (ไม่ได้เก็บใน DB)
+const (
+	InviteStatusActive    = "active"
+	InviteStatusExpired   = "expired"
+	InviteStatusExhausted = "exhausted"
+	InviteStatusRevoked   = "revoked"
+)
+
+type (
+	// RoomInvite ลิงก์เชิญ (Token) และ join code (Code) ของห้อง ใช้ได้ทั้งสองแบบ
+	RoomInvite struct {
+		ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+		RoomID     primitive.ObjectID `bson:"room_id" json:"roomId"`
+		Token      string             `bson:"token" json:"token"`
+		Code       string             `bson:"code" json:"code"`                     // เก็บแบบไม่มีขีด แสดงผลด้วย FormatCode
+		Role       string             `bson:"role,omitempty" json:"role,omitempty"` // ถ้าระบุ ใช้ได้เฉพาะ user ที่มี role นี้
+		MaxUses    int                `bson:"max_uses" json:"maxUses"`              // 0 = ไม่จำกัด
+		Uses       int                `bson:"uses" json:"uses"`
+		ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expiresAt,omitempty"`
+		CreatedBy  string             `bson:"created_by" json:"createdBy"`
+		CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+		RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+		RevokedBy  string             `bson:"revoked_by,omitempty" json:"revokedBy,omitempty"`
+		Note       string             `bson:"note,omitempty" json:"note,omitempty"` // เช่น "ป้ายหน้าห้อง 301"
+		LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"lastUsedAt,omitempty"`
+	}
+
+	// InviteRedemption บันทึกว่า user เข้าห้องผ่าน invite ใด
+	InviteRedemption struct {
+		ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+		InviteID   primitive.ObjectID `bson:"invite_id" json:"inviteId"`
+		RoomID     primitive.ObjectID `bson:"room_id" json:"roomId"`
+		UserID     primitive.ObjectID `bson:"user_id" json:"userId"`
+		Method     string             `bson:"method" json:"method"` // token หรือ code
+		RedeemedAt time.Time          `bson:"redeemed_at" json:"redeemedAt"`
+	}
+)
+
+// Status สถานะของ invite ณ เวลา now
+func (i *RoomInvite) Status(now time.Time) string {
+	switch {
+	case i.RevokedAt != nil:
+		return InviteStatusRevoked
+	case i.ExpiresAt != nil && !now.Before(*i.ExpiresAt):
+		return InviteStatusExpired
+	case i.MaxUses > 0 && i.Uses >= i.MaxUses:
+		return InviteStatusExhausted
+	default:
+		return InviteStatusActive
+	}
+}
+
+// FormatCode แบ่ง code เป็นสองช่วงให้อ่าน/พิมพ์ง่าย เช่น ABCD-EFGH
+func FormatCode(code string) string {
+	if len(code) < 6 {
+		return code
+	}
+	half := (len(code) + 1) / 2
+	return code[:half] + "-" + code[half:]
+}
+
+// NormalizeCode แปลง code ที่ผู้ใช้พิมพ์ (ตัวเล็ก, ขีด, ช่องว่าง, O/I/L ที่หมายถึง 0/1) ให้ตรงกับที่เก็บไว้
+func NormalizeCode(input string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		switch r {
+		case '-', ' ', '\t':
+			continue
+		case 'O':
+			r = '0'
+		case 'I', 'L':
+			r = '1'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "ABCD2345", want: "ABCD2345"},
+		{input: "abcd-2345", want: "ABCD2345"},
+		{input: "  ab cd\t23 45 ", want: "ABCD2345"},
+		{input: "ABCD-2345-", want: "ABCD2345"},
+		{input: "oOiIlL", want: "001111"}, // ตัวที่อ่านสับสนบนป้าย
+		{input: "", want: ""},
+		{input: "---", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeCode(tt.input); got != tt.want {
+				t.Errorf("NormalizeCode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{code: "ABCD2345", want: "ABCD-2345"},
+		{code: "ABC234", want: "ABC-234"},
+		{code: "ABCDE2345", want: "ABCDE-2345"}, // ความยาวคี่: ช่วงแรกยาวกว่า
+		{code: "ABCDEFGH23456789", want: "ABCDEFGH-23456789"},
+		{code: "ABCDE", want: "ABCDE"}, // สั้นเกินไปไม่แบ่ง
+		{code: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := FormatCode(tt.code); got != tt.want {
+				t.Errorf("FormatCode(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormattedCodeNormalizesBack(t *testing.T) {
+	// code ที่สร้างจาก CodeAlphabet ต้องพิมพ์ตามป้าย (มีขีด/ตัวเล็ก) แล้วได้ค่าเดิม
+	for _, code := range []string{CodeAlphabet[:8], CodeAlphabet[8:16], CodeAlphabet[16:24], CodeAlphabet[24:], CodeAlphabet} {
+		typed := FormatCode(code)
+		if got := NormalizeCode(typed); got != code {
+			t.Errorf("NormalizeCode(FormatCode(%q)) = %q, want %q", code, got, code)
+		}
+		if got := NormalizeCode(" " + typed + " "); got != code {
+			t.Errorf("NormalizeCode(padded %q) = %q, want %q", typed, got, code)
+		}
+	}
+}
+
+func TestRoomInviteStatus(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		invite RoomInvite
+		want   string
+	}{
+		{name: "unlimited", invite: RoomInvite{Uses: 500}, want: InviteStatusActive},
+		{name: "uses left", invite: RoomInvite{MaxUses: 3, Uses: 2}, want: InviteStatusActive},
+		{name: "used up", invite: RoomInvite{MaxUses: 3, Uses: 3}, want: InviteStatusExhausted},
+		{name: "not yet expired", invite: RoomInvite{ExpiresAt: &future}, want: InviteStatusActive},
+		{name: "expires exactly now", invite: RoomInvite{ExpiresAt: &now}, want: InviteStatusExpired},
+		{name: "expired beats exhausted", invite: RoomInvite{ExpiresAt: &past, MaxUses: 1, Uses: 1}, want: InviteStatusExpired},
+		{name: "revoked beats everything", invite: RoomInvite{RevokedAt: &past, ExpiresAt: &past, MaxUses: 1, Uses: 1}, want: InviteStatusRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.invite.Status(now); got != tt.want {
+				t.Errorf("Status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
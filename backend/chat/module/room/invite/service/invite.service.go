@@ -0,0 +1,523 @@
+package service
+
+import (
+	"chat/module/room/invite/model"
+	roomModel "chat/module/room/room/model"
+	roomService "chat/module/room/room/service"
+	"chat/pkg/common"
+	"chat/pkg/config"
+	"chat/pkg/core/audit"
+	"chat/pkg/core/metrics"
+	"chat/pkg/database/queries"
+	"chat/pkg/middleware"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Room invites**
+// ลิงก์เชิญ (token ยาว สำหรับ URL/QR) และ join code (สั้น พิมพ์เองได้) ของห้อง
+// การใช้ invite ผ่าน RoomService.JoinRoom เหมือนการ join ปกติ (สถานะห้อง, capacity, group room)
+
+const maxCodeAttempts = 5
+
+var (
+	ErrInviteNotFound     = errors.New("invite not found")
+	ErrInviteExpired      = errors.New("invite has expired")
+	ErrInviteExhausted    = errors.New("invite has reached its maximum number of uses")
+	ErrInviteRevoked      = errors.New("invite has been revoked")
+	ErrInviteRoleMismatch = errors.New("invite is restricted to another role")
+	ErrAlreadyMember      = errors.New("user is already a member of this room")
+	ErrTooManyAttempts    = errors.New("too many invite attempts, please wait a minute")
+	ErrInvalidInvite      = errors.New("invalid invite")
+)
+
+var inviteRedemptions = metrics.NewCounterVec(
+	"chat_room_invite_redemptions_total",
+	"Room invite redemption attempts by outcome.",
+	"method", "result",
+)
+
+type (
+	InviteService struct {
+		invites     *mongo.Collection
+		redemptions *queries.BaseService[model.InviteRedemption]
+		users       *mongo.Collection
+		roles       *mongo.Collection
+		roomService roomService.RoomService
+		rbac        *middleware.RBACMiddleware
+		redis       *redis.Client
+		cfg         config.RoomInviteConfig
+	}
+
+	// CreateInviteInput ค่าที่ admin กำหนดตอนสร้าง invite
+	CreateInviteInput struct {
+		ExpiresAt *time.Time `json:"expiresAt"` // nil = ROOM_INVITE_DEFAULT_TTL
+		MaxUses   int        `json:"maxUses"`   // 0 = ไม่จำกัด
+		Role      string     `json:"role"`      // ว่าง = ทุก role
+		Note      string     `json:"note"`
+	}
+
+	// InviteView invite พร้อมสถานะและ code/URL สำหรับแสดงหรือพิมพ์เป็น QR
+	InviteView struct {
+		*model.RoomInvite
+		Status      string `json:"status"`
+		DisplayCode string `json:"displayCode"`
+		JoinURL     string `json:"joinUrl,omitempty"`
+	}
+
+	// InvitePreview ข้อมูลห้องที่แสดงก่อนผู้ใช้กดเข้าร่วม
+	InvitePreview struct {
+		RoomID      primitive.ObjectID   `json:"roomId"`
+		RoomName    common.LocalizedName `json:"roomName"`
+		RoomImage   string               `json:"roomImage,omitempty"`
+		MemberCount int                  `json:"memberCount"`
+		Capacity    int                  `json:"capacity"`
+		ExpiresAt   *time.Time           `json:"expiresAt,omitempty"`
+		Role        string               `json:"role,omitempty"`
+	}
+
+	// RedemptionView การใช้ invite พร้อมข้อมูล user ที่เข้าห้อง
+	RedemptionView struct {
+		model.InviteRedemption `bson:",inline"`
+		Username               string      `json:"username,omitempty"`
+		Name                   common.Name `json:"name"`
+	}
+)
+
+func NewInviteService(db *mongo.Database, redisClient *redis.Client, roomSvc roomService.RoomService, cfg config.RoomInviteConfig) *InviteService {
+	return &InviteService{
+		invites:     db.Collection(model.InviteCollection),
+		redemptions: queries.NewBaseService[model.InviteRedemption](db.Collection(model.RedemptionCollection)),
+		users:       db.Collection("users"),
+		roles:       db.Collection("roles"),
+		roomService: roomSvc,
+		rbac:        middleware.NewRBACMiddleware(db),
+		redis:       redisClient,
+		cfg:         cfg,
+	}
+}
+
+// CreateInvite สร้าง invite ใหม่ของห้อง
+func (s *InviteService) CreateInvite(ctx context.Context, roomID primitive.ObjectID, input CreateInviteInput, createdBy string) (*InviteView, error) {
+	if _, err := s.roomService.GetRoomById(ctx, roomID); err != nil {
+		return nil, err
+	}
+	if input.MaxUses < 0 {
+		return nil, fmt.Errorf("%w: maxUses must be >= 0", ErrInvalidInvite)
+	}
+
+	now := time.Now()
+	expiresAt := input.ExpiresAt
+	if expiresAt == nil && s.cfg.DefaultTTL > 0 {
+		t := now.Add(s.cfg.DefaultTTL)
+		expiresAt = &t
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidInvite)
+	}
+
+	role := strings.TrimSpace(input.Role)
+	if role != "" {
+		count, err := s.roles.CountDocuments(ctx, bson.M{"name": role})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidInvite, role)
+		}
+	}
+
+	invite := &model.RoomInvite{
+		RoomID:    roomID,
+		Role:      role,
+		MaxUses:   input.MaxUses,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		Note:      strings.TrimSpace(input.Note),
+	}
+
+	// token/code ชนกับของเดิมได้ (unique index) จึงสุ่มใหม่
+	for attempt := 1; ; attempt++ {
+		var err error
+		if invite.Token, err = newToken(); err != nil {
+			return nil, err
+		}
+		if invite.Code, err = newCode(s.cfg.CodeLength); err != nil {
+			return nil, err
+		}
+		invite.ID = primitive.NewObjectID()
+
+		_, err = s.invites.InsertOne(ctx, invite)
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) || attempt == maxCodeAttempts {
+			return nil, fmt.Errorf("failed to create invite: %w", err)
+		}
+	}
+
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomInviteCreate,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		After:      inviteSnapshot(invite),
+	})
+	log.Printf("[Invite] 🎟️ Created invite %s for room %s", invite.ID.Hex(), roomID.Hex())
+	return s.view(invite, now), nil
+}
+
+// ListInvites invite ทั้งหมดของห้อง ใหม่สุดก่อน
+func (s *InviteService) ListInvites(ctx context.Context, roomID primitive.ObjectID) ([]InviteView, error) {
+	cursor, err := s.invites.Find(ctx, bson.M{"room_id": roomID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var invites []model.RoomInvite
+	if err := cursor.All(ctx, &invites); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	views := make([]InviteView, 0, len(invites))
+	for i := range invites {
+		views = append(views, *s.view(&invites[i], now))
+	}
+	return views, nil
+}
+
+// RevokeInvite ยกเลิก invite (ผู้ที่เข้าห้องไปแล้วยังเป็นสมาชิกอยู่)
+func (s *InviteService) RevokeInvite(ctx context.Context, roomID, inviteID primitive.ObjectID, revokedBy string) (*InviteView, error) {
+	now := time.Now()
+	var invite model.RoomInvite
+	err := s.invites.FindOneAndUpdate(ctx,
+		bson.M{"_id": inviteID, "room_id": roomID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now, "revoked_by": revokedBy}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invite)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, countErr := s.invites.CountDocuments(ctx, bson.M{"_id": inviteID, "room_id": roomID})
+		if countErr == nil && count > 0 {
+			return nil, ErrInviteRevoked
+		}
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	before := inviteSnapshot(&invite)
+	delete(before, "revokedAt")
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomInviteRevoke,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Before:     before,
+		After:      inviteSnapshot(&invite),
+	})
+	log.Printf("[Invite] 🚫 Revoked invite %s of room %s", inviteID.Hex(), roomID.Hex())
+	return s.view(&invite, now), nil
+}
+
+// Preview ข้อมูลห้องของ invite ที่ยังใช้ได้ (สำหรับหน้า landing ของลิงก์/QR)
+// นับรวมกับ attempt limit ของ Redeem เพราะ preview ก็ใช้เดา code ได้เช่นกัน
+func (s *InviteService) Preview(ctx context.Context, userID primitive.ObjectID, tokenOrCode string) (*InvitePreview, error) {
+	if !s.allowAttempt(ctx, userID.Hex()) {
+		return nil, ErrTooManyAttempts
+	}
+
+	invite, _, err := s.lookup(ctx, tokenOrCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(invite.Status(time.Now())); err != nil {
+		return nil, err
+	}
+
+	room, err := s.roomService.GetRoomById(ctx, invite.RoomID)
+	if err != nil {
+		return nil, ErrInviteNotFound
+	}
+	return &InvitePreview{
+		RoomID:      room.ID,
+		RoomName:    room.Name,
+		RoomImage:   room.Image,
+		MemberCount: len(room.Members),
+		Capacity:    room.Capacity,
+		ExpiresAt:   invite.ExpiresAt,
+		Role:        invite.Role,
+	}, nil
+}
+
+// Redeem ให้ user เข้าห้องด้วย token หรือ join code
+// นับการใช้ก่อน join (กันเกิน maxUses เมื่อใช้พร้อมกัน) และคืนสิทธิ์ถ้า join ไม่สำเร็จ
+func (s *InviteService) Redeem(ctx context.Context, userID primitive.ObjectID, tokenOrCode string) (*roomModel.Room, error) {
+	if !s.allowAttempt(ctx, userID.Hex()) {
+		inviteRedemptions.Inc("unknown", "rate_limited")
+		return nil, ErrTooManyAttempts
+	}
+
+	invite, method, err := s.lookup(ctx, tokenOrCode)
+	if err != nil {
+		inviteRedemptions.Inc("unknown", "not_found")
+		return nil, err
+	}
+
+	room, err := s.redeem(ctx, invite, userID, method)
+	if err != nil {
+		inviteRedemptions.Inc(method, redeemResult(err))
+		return nil, err
+	}
+	inviteRedemptions.Inc(method, "joined")
+	return room, nil
+}
+
+func (s *InviteService) redeem(ctx context.Context, invite *model.RoomInvite, userID primitive.ObjectID, method string) (*roomModel.Room, error) {
+	now := time.Now()
+	if err := statusError(invite.Status(now)); err != nil {
+		return nil, err
+	}
+
+	if invite.Role != "" {
+		role, err := s.rbac.GetUserRole(userID.Hex())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user role: %w", err)
+		}
+		if role != invite.Role {
+			return nil, ErrInviteRoleMismatch
+		}
+	}
+
+	isMember, err := s.roomService.IsUserInRoom(ctx, invite.RoomID, userID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if isMember {
+		return nil, ErrAlreadyMember
+	}
+
+	// จองการใช้หนึ่งครั้งแบบ atomic (เงื่อนไขเดียวกับ Status)
+	res, err := s.invites.UpdateOne(ctx, claimFilter(invite.ID, now), bson.M{"$inc": bson.M{"uses": 1}, "$set": bson.M{"last_used_at": now}})
+	if err != nil {
+		return nil, err
+	}
+	if res.ModifiedCount == 0 {
+		// invite เปลี่ยนระหว่างตรวจ (ใช้ครบ / ถูก revoke / หมดอายุ)
+		return nil, ErrInviteExhausted
+	}
+
+	if err := s.roomService.JoinRoom(ctx, invite.RoomID, userID.Hex()); err != nil {
+		if _, releaseErr := s.invites.UpdateOne(context.WithoutCancel(ctx), bson.M{"_id": invite.ID}, bson.M{"$inc": bson.M{"uses": -1}}); releaseErr != nil {
+			log.Printf("[Invite] ⚠️ Failed to release use of invite %s: %v", invite.ID.Hex(), releaseErr)
+		}
+		return nil, err
+	}
+
+	redemption := model.InviteRedemption{
+		ID:         primitive.NewObjectID(),
+		InviteID:   invite.ID,
+		RoomID:     invite.RoomID,
+		UserID:     userID,
+		Method:     method,
+		RedeemedAt: now,
+	}
+	if _, err := s.redemptions.GetMongoCollection().InsertOne(ctx, redemption); err != nil {
+		log.Printf("[Invite] ⚠️ User %s joined room %s but redemption of invite %s was not recorded: %v",
+			userID.Hex(), invite.RoomID.Hex(), invite.ID.Hex(), err)
+	}
+
+	log.Printf("[Invite] ✅ User %s joined room %s via invite %s (%s)", userID.Hex(), invite.RoomID.Hex(), invite.ID.Hex(), method)
+	return s.roomService.GetRoomById(ctx, invite.RoomID)
+}
+
+// Redemptions ผู้ที่เข้าห้องผ่าน invite ใหม่สุดก่อน (inviteID = NilObjectID คือทุก invite ของห้อง)
+func (s *InviteService) Redemptions(ctx context.Context, roomID, inviteID primitive.ObjectID, page, limit int) ([]RedemptionView, *queries.Meta, error) {
+	filter := map[string]interface{}{"room_id": roomID}
+	if !inviteID.IsZero() {
+		filter["invite_id"] = inviteID
+	}
+	resp, err := s.redemptions.FindAll(ctx, queries.QueryOptions{
+		Page:   page,
+		Limit:  limit,
+		Sort:   "-redeemed_at",
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userIDs := make([]primitive.ObjectID, 0, len(resp.Data))
+	for _, r := range resp.Data {
+		userIDs = append(userIDs, r.UserID)
+	}
+	type redeemer struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Username string             `bson:"username"`
+		Name     common.Name        `bson:"name"`
+	}
+	users := make(map[primitive.ObjectID]redeemer, len(userIDs))
+	if len(userIDs) > 0 {
+		cursor, err := s.users.Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}},
+			options.Find().SetProjection(bson.M{"username": 1, "name": 1}))
+		if err != nil {
+			return nil, nil, err
+		}
+		var docs []redeemer
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, nil, err
+		}
+		for _, d := range docs {
+			users[d.ID] = d
+		}
+	}
+
+	views := make([]RedemptionView, 0, len(resp.Data))
+	for _, r := range resp.Data {
+		u := users[r.UserID]
+		views = append(views, RedemptionView{InviteRedemption: r, Username: u.Username, Name: u.Name})
+	}
+	return views, resp.Meta, nil
+}
+
+// lookup หา invite จาก token (ลิงก์) ก่อน แล้วค่อยลองเป็น join code
+func (s *InviteService) lookup(ctx context.Context, tokenOrCode string) (*model.RoomInvite, string, error) {
+	value := strings.TrimSpace(tokenOrCode)
+	if value == "" {
+		return nil, "", ErrInviteNotFound
+	}
+
+	var invite model.RoomInvite
+	err := s.invites.FindOne(ctx, bson.M{"token": value}).Decode(&invite)
+	if err == nil {
+		return &invite, "token", nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, "", err
+	}
+
+	err = s.invites.FindOne(ctx, bson.M{"code": model.NormalizeCode(value)}).Decode(&invite)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, "", ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &invite, "code", nil
+}
+
+// allowAttempt จำกัดจำนวนครั้งที่ user ลองใช้ invite ต่อนาที (fixed window ใน Redis, Redis ล่ม = ปล่อยผ่าน)
+func (s *InviteService) allowAttempt(ctx context.Context, userID string) bool {
+	if s.cfg.AttemptsPerMinute <= 0 || s.redis == nil {
+		return true
+	}
+	key := fmt.Sprintf("chat:invite:attempts:%s:%d", userID, time.Now().Unix()/60)
+	pipe := s.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Invite] ⚠️ Failed to check invite attempts for user %s: %v", userID, err)
+		return true
+	}
+	return incr.Val() <= int64(s.cfg.AttemptsPerMinute)
+}
+
+func (s *InviteService) view(invite *model.RoomInvite, now time.Time) *InviteView {
+	view := &InviteView{
+		RoomInvite:  invite,
+		Status:      invite.Status(now),
+		DisplayCode: model.FormatCode(invite.Code),
+	}
+	if s.cfg.JoinURL != "" {
+		view.JoinURL = strings.NewReplacer("{code}", invite.Code, "{token}", invite.Token).Replace(s.cfg.JoinURL)
+	}
+	return view
+}
+
+// claimFilter filter ที่ match เฉพาะ invite ที่ยัง active ณ now (ตรงกับ RoomInvite.Status)
+// ใช้กับ $inc uses เพื่อไม่ให้สอง request แย่งใช้ที่ว่างสุดท้ายจนเกิน MaxUses
+func claimFilter(inviteID primitive.ObjectID, now time.Time) bson.M {
+	return bson.M{
+		"_id":        inviteID,
+		"revoked_at": bson.M{"$exists": false},
+		"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"expires_at": bson.M{"$exists": false}}, bson.M{"expires_at": bson.M{"$gt": now}}}},
+			bson.M{"$or": bson.A{bson.M{"max_uses": 0}, bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}}}},
+		},
+	}
+}
+
+func statusError(status string) error {
+	switch status {
+	case model.InviteStatusRevoked:
+		return ErrInviteRevoked
+	case model.InviteStatusExpired:
+		return ErrInviteExpired
+	case model.InviteStatusExhausted:
+		return ErrInviteExhausted
+	default:
+		return nil
+	}
+}
+
+func redeemResult(err error) string {
+	switch {
+	case errors.Is(err, ErrInviteRevoked):
+		return "revoked"
+	case errors.Is(err, ErrInviteExpired):
+		return "expired"
+	case errors.Is(err, ErrInviteExhausted):
+		return "exhausted"
+	case errors.Is(err, ErrInviteRoleMismatch):
+		return "role_mismatch"
+	case errors.Is(err, ErrAlreadyMember):
+		return "already_member"
+	default:
+		return "join_failed"
+	}
+}
+
+// inviteSnapshot ข้อมูล invite สำหรับ audit (ไม่เก็บ code/token เพราะใช้ join ได้ อ้างถึงด้วย invite ID เท่านั้น)
+func inviteSnapshot(invite *model.RoomInvite) map[string]interface{} {
+	return map[string]interface{}{
+		"inviteId":  invite.ID.Hex(),
+		"role":      invite.Role,
+		"maxUses":   invite.MaxUses,
+		"expiresAt": invite.ExpiresAt,
+		"note":      invite.Note,
+		"revokedAt": invite.RevokedAt,
+	}
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func newCode(length int) (string, error) {
+	alphabet := big.NewInt(int64(len(model.CodeAlphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabet)
+		if err != nil {
+			return "", err
+		}
+		code[i] = model.CodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
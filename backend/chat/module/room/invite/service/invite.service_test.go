@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"chat/module/room/invite/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchClaim ประเมิน filter จาก claimFilter กับเอกสารใน DB (รองรับเฉพาะ operator ที่ claimFilter ใช้)
+func matchClaim(t *testing.T, filter bson.M, doc bson.M) bool {
+	t.Helper()
+	for key, cond := range filter {
+		switch key {
+		case "$and":
+			for _, sub := range cond.(bson.A) {
+				if !matchClaim(t, sub.(bson.M), doc) {
+					return false
+				}
+			}
+		case "$or":
+			matched := false
+			for _, sub := range cond.(bson.A) {
+				if matchClaim(t, sub.(bson.M), doc) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "$expr":
+			// {"$lt": ["$uses", "$max_uses"]}
+			args := cond.(bson.M)["$lt"].(bson.A)
+			left := toInt(t, doc[strings.TrimPrefix(args[0].(string), "$")])
+			right := toInt(t, doc[strings.TrimPrefix(args[1].(string), "$")])
+			if left >= right {
+				return false
+			}
+		default:
+			value, exists := doc[key]
+			if ops, ok := cond.(bson.M); ok {
+				if want, ok := ops["$exists"]; ok && exists != want.(bool) {
+					return false
+				}
+				if bound, ok := ops["$gt"]; ok {
+					if !exists || !value.(primitive.DateTime).Time().After(bound.(time.Time)) {
+						return false
+					}
+				}
+				continue
+			}
+			if !exists || fmt.Sprint(value) != fmt.Sprint(cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func toInt(t *testing.T, v interface{}) int64 {
+	t.Helper()
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	default:
+		t.Fatalf("unexpected numeric type %T", v)
+		return 0
+	}
+}
+
+// storedDoc invite ในรูปที่ Mongo เก็บจริง (omitempty, DateTime, int32)
+func storedDoc(t *testing.T, invite model.RoomInvite) bson.M {
+	t.Helper()
+	raw, err := bson.Marshal(invite)
+	if err != nil {
+		t.Fatalf("marshal invite: %v", err)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("unmarshal invite: %v", err)
+	}
+	return doc
+}
+
+func TestClaimFilterMatchesStatus(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	id := primitive.NewObjectID()
+
+	tests := []struct {
+		name      string
+		invite    model.RoomInvite
+		wantClaim bool
+	}{
+		{name: "unlimited", invite: model.RoomInvite{Uses: 500}, wantClaim: true},
+		{name: "last free use", invite: model.RoomInvite{MaxUses: 3, Uses: 2}, wantClaim: true},
+		{name: "used up", invite: model.RoomInvite{MaxUses: 3, Uses: 3}, wantClaim: false},
+		{name: "over-used", invite: model.RoomInvite{MaxUses: 3, Uses: 4}, wantClaim: false},
+		{name: "not yet expired", invite: model.RoomInvite{ExpiresAt: &future}, wantClaim: true},
+		{name: "expires exactly now", invite: model.RoomInvite{ExpiresAt: &now}, wantClaim: false},
+		{name: "expired", invite: model.RoomInvite{ExpiresAt: &past}, wantClaim: false},
+		{name: "revoked", invite: model.RoomInvite{RevokedAt: &past}, wantClaim: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.invite.ID = id
+			got := matchClaim(t, claimFilter(id, now), storedDoc(t, tt.invite))
+			if got != tt.wantClaim {
+				t.Errorf("claim matched = %v, want %v", got, tt.wantClaim)
+			}
+			if active := tt.invite.Status(now) == model.InviteStatusActive; active != got {
+				t.Errorf("claim matched = %v but Status() = %q", got, tt.invite.Status(now))
+			}
+		})
+	}
+}
+
+func TestClaimFilterOnlyMatchesOwnInvite(t *testing.T) {
+	now := time.Now()
+	other := model.RoomInvite{ID: primitive.NewObjectID()}
+	if matchClaim(t, claimFilter(primitive.NewObjectID(), now), storedDoc(t, other)) {
+		t.Error("claim matched a different invite")
+	}
+}
+
+func TestNewCode(t *testing.T) {
+	for _, length := range []int{6, 8, 16} {
+		t.Run(fmt.Sprint(length), func(t *testing.T) {
+			code, err := newCode(length)
+			if err != nil {
+				t.Fatalf("newCode(%d) error = %v", length, err)
+			}
+			if len(code) != length {
+				t.Errorf("len(code) = %d, want %d", len(code), length)
+			}
+			if strings.Trim(code, model.CodeAlphabet) != "" {
+				t.Errorf("code %q has characters outside CodeAlphabet", code)
+			}
+			if got := model.NormalizeCode(model.FormatCode(code)); got != code {
+				t.Errorf("NormalizeCode(FormatCode(%q)) = %q", code, got)
+			}
+		})
+	}
+}
+
+func TestRedeemResult(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: statusError(model.InviteStatusRevoked), want: "revoked"},
+		{err: statusError(model.InviteStatusExpired), want: "expired"},
+		{err: statusError(model.InviteStatusExhausted), want: "exhausted"},
+		{err: fmt.Errorf("redeem: %w", ErrInviteRoleMismatch), want: "role_mismatch"},
+		{err: ErrAlreadyMember, want: "already_member"},
+		{err: fmt.Errorf("room is full"), want: "join_failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := redeemResult(tt.err); got != tt.want {
+				t.Errorf("redeemResult(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+	if err := statusError(model.InviteStatusActive); err != nil {
+		t.Errorf("statusError(active) = %v, want nil", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	roomHelper "chat/module/room/shared/utils"
 	"chat/pkg/middleware"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -90,8 +91,9 @@ func (h *RoomMemberHelper) RemoveUserFromRoom(ctx context.Context, roomID primit
 	collection := h.db.Collection("rooms")
 	filter := bson.M{"_id": roomID}
 	update := bson.M{
-		"$pull": bson.M{"members": userObjID},
-		"$set":  bson.M{"updatedAt": time.Now()},
+		"$pull":  bson.M{"members": userObjID},
+		"$set":   bson.M{"updatedAt": time.Now()},
+		"$unset": bson.M{"member_roles." + userID: ""}, // **NEW: role ในห้องหมดไปพร้อมการเป็นสมาชิก**
 	}
 
 	var room model.Room
@@ -102,6 +104,18 @@ func (h *RoomMemberHelper) RemoveUserFromRoom(ctx context.Context, roomID primit
 
 	// Update cache with the new room state
 	room.Members = roomHelper.RemoveMemberObjectID(room.Members, userObjID)
+	delete(room.MemberRoles, userID)
+
+	// **NEW: ผู้สร้างห้องที่ออกไปแล้วไม่กลับมาเป็น owner โดยปริยายเมื่อ join ใหม่ (รวมถึงกรณีถูกลดเป็น member ก่อนออก)**
+	if room.CreatedBy == userObjID {
+		if _, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"member_roles." + userID: model.RoomRoleMember}}); err != nil {
+			log.Printf("[MemberHelper] Warning: Failed to revoke creator ownership: %v", err)
+		}
+		if room.MemberRoles == nil {
+			room.MemberRoles = map[string]string{}
+		}
+		room.MemberRoles[userID] = model.RoomRoleMember
+	}
 	room.UpdatedAt = time.Now()
 	if err := h.cache.SaveRoom(ctx, &room); err != nil {
 		log.Printf("[MemberHelper] Warning: Failed to update cache: %v", err)
@@ -150,18 +164,21 @@ func (h *RoomMemberHelper) JoinRoom(ctx context.Context, roomID primitive.Object
 	// ดึงข้อมูลห้อง
 	var room model.Room
 	err = collection.FindOne(ctx, bson.M{"_id": roomID}).Decode(&room)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.ErrRoomNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("room not found: %w", err)
+		return fmt.Errorf("failed to load room: %w", err)
 	}
 
 	// Check if room is active
 	if room.IsInactive() {
-		return fmt.Errorf("room is inactive and not accepting new members")
+		return model.ErrRoomInactive
 	}
 
 	// ตรวจสอบ capacity
 	if !room.IsUnlimitedCapacity() && len(room.Members) >= room.Capacity {
-		return fmt.Errorf("room is at full capacity")
+		return model.ErrRoomFull
 	}
 
 	// **NEW: ตรวจสอบ role และ group room restrictions**
@@ -183,7 +200,7 @@ func (h *RoomMemberHelper) validateUserRoleForRoom(ctx context.Context, userID s
 		}
 
 		if roleName != "Administrator" && roleName != "Staff" {
-			return fmt.Errorf("only Administrator and Staff can join group rooms")
+			return model.ErrGroupRoomRestricted
 		}
 	}
 	return nil
@@ -9,7 +9,9 @@ import (
 	"chat/pkg/decorators"
 	"chat/pkg/middleware"
 	"chat/pkg/utils"
+	"errors"
 	"mime/multipart"
+	"time"
 
 	userService "chat/module/user/service"
 
@@ -71,6 +73,9 @@ func (c *RoomController) setupRoutes() {
 	c.Post("/:id/join", c.JoinRoom)
 	c.Post("/:id/leave", c.LeaveRoom)
 	c.Put("/:id/readonly", c.handleSetRoomReadOnly, c.rbac.RequireAdministrator())
+	c.Put("/:id/schedule", c.handleSetRoomSchedule, c.rbac.RequireAdministrator())
+	c.Delete("/:id/schedule", c.handleClearRoomSchedule, c.rbac.RequireAdministrator())
+	c.setupModerationRoutes()
 	c.SetupRoutes()
 }
 
@@ -167,6 +172,7 @@ func (c *RoomController) GetRoomMembers(ctx *fiber.Ctx) error {
 		Name    interface{}        `json:"name"`
 		Type    string             `json:"type"`
 		Members interface{}        `json:"members"`
+		Roles   map[string]string  `json:"roles"` // **NEW: owner/moderator ของห้อง (คนอื่นเป็น member)**
 		Meta    interface{}        `json:"meta"`
 	}
 
@@ -175,6 +181,7 @@ func (c *RoomController) GetRoomMembers(ctx *fiber.Ctx) error {
 		Name:    roomMember.Name,
 		Type:    roomMember.Type,
 		Members: roomMember.Members,
+		Roles:   room.ModeratorRoles(),
 		Meta: fiber.Map{
 			"total":      total,
 			"page":       page,
@@ -340,11 +347,17 @@ func (c *RoomController) UpdateRoom(ctx *fiber.Ctx) error {
 
 	// Parse multipart form and update room
 	updateDto, err := c.controllerHelper.ParseAndUpdateRoom(ctx, room, c.validationHelper, c.uploadHandler)
+	if errors.Is(err, model.ErrInvalidSlowMode) {
+		return c.validationHelper.BuildValidationErrorResponse(ctx, err)
+	}
 	if err != nil {
 		return c.validationHelper.BuildInternalErrorResponse(ctx, err)
 	}
 
 	updatedRoom, err := c.roomService.UpdateRoom(ctx.Context(), roomObjID.Hex(), updateDto)
+	if errors.Is(err, model.ErrInvalidSlowMode) {
+		return c.validationHelper.BuildValidationErrorResponse(ctx, err)
+	}
 	if err != nil {
 		return c.validationHelper.BuildInternalErrorResponse(ctx, err)
 	}
@@ -486,6 +499,94 @@ func (c *RoomController) handleSetRoomReadOnly(ctx *fiber.Ctx) error {
 	return ctx.JSON(c.controllerHelper.BuildReadOnlyResponse(roomID, isReadOnly, updatedRoom))
 }
 
+// **NEW: handleSetRoomSchedule ตั้งตารางเปิด/ปิดห้อง (body เป็น RoomSchedule)**
+func (c *RoomController) handleSetRoomSchedule(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	var schedule model.RoomSchedule
+	if err := ctx.BodyParser(&schedule); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid schedule body",
+			"error":   err.Error(),
+		})
+	}
+	if err := schedule.Validate(); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	room, err := c.roomService.SetRoomSchedule(ctx.Context(), roomObjID, &schedule)
+	if err != nil {
+		return c.scheduleError(ctx, err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Room schedule updated successfully",
+		"data":    c.scheduleResponse(room),
+	})
+}
+
+// handleClearRoomSchedule ลบตาราง (สถานะห้องคงเดิม)
+func (c *RoomController) handleClearRoomSchedule(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	room, err := c.roomService.SetRoomSchedule(ctx.Context(), roomObjID, nil)
+	if err != nil {
+		return c.scheduleError(ctx, err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Room schedule removed successfully",
+		"data":    c.scheduleResponse(room),
+	})
+}
+
+func (c *RoomController) scheduleError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, queries.ErrNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Room not found",
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": "Failed to update room schedule",
+		"error":   err.Error(),
+	})
+}
+
+func (c *RoomController) scheduleResponse(room *model.Room) fiber.Map {
+	data := fiber.Map{
+		"roomId":   room.ID.Hex(),
+		"status":   room.Status,
+		"schedule": room.Schedule,
+	}
+	if room.Schedule != nil {
+		loc := room.Schedule.Location(c.roomService.ScheduleLocation())
+		now := time.Now()
+		data["nextOpenAt"] = room.Schedule.NextOpen(now, loc)
+		data["closesAt"] = room.Schedule.CurrentClose(now, loc)
+	}
+	return data
+}
+
 // GetAllRoomForUser - ดึงห้องทั้งหมดที่ user มองเห็น (รวม group room)
 func (c *RoomController) GetAllRoomForUser(ctx *fiber.Ctx) error {
 	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"chat/module/room/room/model"
+	"chat/module/room/room/service"
+	"chat/pkg/database/queries"
+	"chat/pkg/middleware"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: Room-scoped moderation**
+// route ที่ moderator ของห้องใช้ได้เฉพาะห้องตัวเอง (Administrator ใช้ได้ทุกห้อง)
+
+type (
+	SetMemberRoleRequest struct {
+		Role string `json:"role"` // owner, moderator หรือ member
+	}
+
+	SetSlowModeRequest struct {
+		Seconds     int      `json:"seconds"` // 0 = ปิด
+		ExemptRoles []string `json:"exemptRoles"`
+	}
+)
+
+func (c *RoomController) setupModerationRoutes() {
+	moderators := c.rbac.RequireRoomRole(c.roomService, middleware.RoomIDFromParam("id"), model.ModeratorRoomRoles...)
+	owners := c.rbac.RequireRoomRole(c.roomService, middleware.RoomIDFromParam("id"), model.RoomRoleOwner)
+
+	c.Put("/:id/members/:userId/role", c.handleSetMemberRole, owners)
+	c.Put("/:id/slow-mode", c.handleSetSlowMode, moderators)
+	c.Delete("/:id/slow-mode", c.handleClearSlowMode, moderators)
+}
+
+// handleSetMemberRole ตั้ง role ในห้อง (owner ตั้ง moderator ได้ ส่วน owner ตั้ง/ถอดได้เฉพาะ Administrator)
+func (c *RoomController) handleSetMemberRole(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+	userObjID, err := primitive.ObjectIDFromHex(ctx.Params("userId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	var body SetMemberRoleRequest
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil || !model.ValidateRoomRole(body.Role) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "role must be one of owner, moderator, member",
+		})
+	}
+
+	if !middleware.IsGlobalAdministrator(ctx) {
+		current, err := c.roomService.GetMemberRole(ctx.UserContext(), roomObjID, userObjID)
+		if err != nil {
+			return c.moderationError(ctx, "Failed to update member role", err)
+		}
+		if body.Role == model.RoomRoleOwner || current == model.RoomRoleOwner {
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Only administrators can assign or remove room owners",
+				"error":   "INSUFFICIENT_PERMISSIONS",
+			})
+		}
+	}
+
+	room, err := c.roomService.SetMemberRole(ctx.UserContext(), roomObjID, userObjID, body.Role)
+	if err != nil {
+		return c.moderationError(ctx, "Failed to update member role", err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Member role updated successfully",
+		"data": fiber.Map{
+			"roomId": room.ID.Hex(),
+			"userId": userObjID.Hex(),
+			"role":   room.MemberRole(userObjID),
+			"roles":  room.ModeratorRoles(),
+		},
+	})
+}
+
+// handleSetSlowMode ตั้ง slow mode ของห้อง
+func (c *RoomController) handleSetSlowMode(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	var body SetSlowModeRequest
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid slow mode body",
+			"error":   err.Error(),
+		})
+	}
+	if body.Seconds < 0 || body.Seconds > model.MaxSlowModeSeconds {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("seconds must be between 0 and %d", model.MaxSlowModeSeconds),
+		})
+	}
+
+	return c.applySlowMode(ctx, roomObjID, body.Seconds, body.ExemptRoles)
+}
+
+// handleClearSlowMode ปิด slow mode ของห้อง
+func (c *RoomController) handleClearSlowMode(ctx *fiber.Ctx) error {
+	roomObjID, err := c.controllerHelper.ValidateRoomIDParam(ctx, c.validationHelper)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid room ID",
+		})
+	}
+
+	return c.applySlowMode(ctx, roomObjID, 0, nil)
+}
+
+func (c *RoomController) applySlowMode(ctx *fiber.Ctx, roomID primitive.ObjectID, seconds int, exemptRoles []string) error {
+	room, err := c.roomService.SetRoomSlowMode(ctx.UserContext(), roomID, seconds, exemptRoles)
+	if err != nil {
+		return c.moderationError(ctx, "Failed to update slow mode", err)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Slow mode updated successfully",
+		"data": fiber.Map{
+			"roomId":   room.ID.Hex(),
+			"slowMode": room.SlowMode,
+		},
+	})
+}
+
+func (c *RoomController) moderationError(ctx *fiber.Ctx, message string, err error) error {
+	switch {
+	case errors.Is(err, queries.ErrNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Room not found",
+		})
+	case errors.Is(err, service.ErrNotRoomMember):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	case errors.Is(err, model.ErrInvalidSlowMode):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+	})
+}
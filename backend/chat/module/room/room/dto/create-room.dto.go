@@ -31,6 +31,9 @@ type (
 		UpdatedAt      time.Time            `form:"updatedAt" validate:"optional"`
 		CreatedBy      string               `form:"createdBy" validate:"mongoId,optional"`
 		SelectAllUsers bool                 `form:"selectAllUsers" json:"selectAllUsers"`
+		// **NEW: slow mode** nil = คงค่าเดิม, 0 = ปิด
+		SlowModeSeconds     *int     `form:"slowModeSeconds" json:"slowModeSeconds,omitempty"`
+		SlowModeExemptRoles []string `form:"slowModeExemptRoles" json:"slowModeExemptRoles,omitempty"` // nil = คงค่าเดิม
 	}
 
 	AddRoomMembersDto struct {
@@ -21,6 +21,9 @@ type (
 		Metadata    map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
 		MemberCount int                    `bson:"memberCount" json:"memberCount"`
 		CanJoin     bool                   `json:"canJoin,omitempty"`
+		NextOpenAt  *time.Time             `bson:"-" json:"nextOpenAt,omitempty"` // เวลาเปิดครั้งถัดไปตามตารางของห้อง
+		ClosesAt    *time.Time             `bson:"-" json:"closesAt,omitempty"`   // เวลาปิดของช่วงที่เปิดอยู่
+		MyRole      string                 `bson:"-" json:"myRole,omitempty"`     // role ของ user ในห้อง (owner / moderator / member)
 	}
 
 	ResponseAllRoomForUserDto struct {
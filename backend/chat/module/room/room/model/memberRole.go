@@ -0,0 +1,61 @@
+package model
+
+import (
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: Room-scoped member roles**
+// role ในห้อง (แยกจาก global role ของ user) ใช้ให้ moderator ดูแลเฉพาะห้องของตัวเองได้
+const (
+	RoomRoleOwner     = "owner"
+	RoomRoleModerator = "moderator"
+	RoomRoleMember    = "member"
+)
+
+// ModeratorRoomRoles role ในห้องที่ใช้คำสั่ง moderation (restriction, slow mode, ลบข้อความ) ได้
+var ModeratorRoomRoles = []string{RoomRoleOwner, RoomRoleModerator}
+
+// ValidateRoomRole ตรวจสอบความถูกต้องของ role ในห้อง
+func ValidateRoomRole(role string) bool {
+	return role == RoomRoleOwner || role == RoomRoleModerator || role == RoomRoleMember
+}
+
+// MemberRole role ของ user ในห้อง ("" = ไม่ใช่สมาชิก รวมถึงผู้สร้างห้องที่ออกไปแล้ว)
+// ผู้สร้างห้องเป็น owner เว้นแต่ถูกตั้ง role อื่นไว้ใน MemberRoles (ถูกลดเป็น member หรือเคยออกจากห้อง)
+func (r *Room) MemberRole(userID primitive.ObjectID) string {
+	if userID.IsZero() || !slices.Contains(r.Members, userID) {
+		return ""
+	}
+	if role, ok := r.MemberRoles[userID.Hex()]; ok {
+		return role
+	}
+	if r.CreatedBy == userID {
+		return RoomRoleOwner
+	}
+	return RoomRoleMember
+}
+
+// CanModerate ตรวจสอบว่า user เป็น owner หรือ moderator ของห้อง
+func (r *Room) CanModerate(userID primitive.ObjectID) bool {
+	role := r.MemberRole(userID)
+	return role == RoomRoleOwner || role == RoomRoleModerator
+}
+
+// ModeratorRoles owner/moderator ของห้อง (userID -> role) รวม owner โดยปริยายของผู้สร้างห้อง
+func (r *Room) ModeratorRoles() map[string]string {
+	roles := make(map[string]string)
+	candidates := []primitive.ObjectID{r.CreatedBy}
+	for userID := range r.MemberRoles {
+		if id, err := primitive.ObjectIDFromHex(userID); err == nil {
+			candidates = append(candidates, id)
+		}
+	}
+	for _, id := range candidates {
+		if r.CanModerate(id) {
+			roles[id.Hex()] = r.MemberRole(id)
+		}
+	}
+	return roles
+}
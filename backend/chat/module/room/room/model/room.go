@@ -3,6 +3,7 @@ package model
 import (
 	"chat/pkg/common"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -21,6 +22,14 @@ const (
 	RoomStatusInactive = "inactive" // ห้องปิดใช้งาน - user ไม่สามารถเชื่อมต่อได้
 )
 
+// **NEW: error ของการ join ห้อง (ใช้ errors.Is แทนการเทียบข้อความ)**
+var (
+	ErrRoomNotFound        = errors.New("room not found")
+	ErrRoomInactive        = errors.New("room is inactive and not accepting new members")
+	ErrRoomFull            = errors.New("room is at full capacity")
+	ErrGroupRoomRestricted = errors.New("only Administrator and Staff can join group rooms")
+)
+
 type (
 	Room struct {
 		ID        primitive.ObjectID   `bson:"_id,omitempty" json:"_id,omitempty"`
@@ -34,6 +43,9 @@ type (
 		CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
 		UpdatedAt time.Time           `bson:"updatedAt" json:"updatedAt"`
 		Metadata  map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
+		Schedule  *RoomSchedule          `bson:"schedule,omitempty" json:"schedule,omitempty"`
+		SlowMode  *SlowMode              `bson:"slow_mode,omitempty" json:"slowMode,omitempty"`
+		MemberRoles map[string]string    `bson:"member_roles,omitempty" json:"memberRoles,omitempty"` // userID -> owner/moderator (คนอื่นเป็น member)
 	}
 
 	RoomEvent struct {
@@ -43,6 +55,22 @@ type (
 	}
 )
 
+// AuditSnapshot สถานะห้องสำหรับ audit log (เก็บจำนวนสมาชิกแทนรายชื่อ เพราะห้องกลุ่มมีสมาชิกหลักพัน)
+func (r *Room) AuditSnapshot() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        r.Name,
+		"type":        r.Type,
+		"status":      r.Status,
+		"capacity":    r.Capacity,
+		"image":       r.Image,
+		"createdBy":   r.CreatedBy,
+		"memberCount": len(r.Members),
+		"metadata":    r.Metadata,
+		"slowMode":    r.SlowMode,
+		"memberRoles": r.MemberRoles,
+	}
+}
+
 // IsReadOnly ตรวจสอบว่าห้องเป็นแบบ read-only หรือไม่
 func (r *Room) IsReadOnly() bool {
 	return r.Type == RoomTypeReadOnly
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+	_ "time/tzdata" // image ที่ deploy อาจไม่มี zoneinfo
+)
+
+// **NEW: ตารางเปิด/ปิดห้องอัตโนมัติ (RoomScheduler เปลี่ยน Status ตาม boundary ของตาราง)**
+
+// scheduleHorizon ระยะที่คำนวณช่วงเปิดย้อนหลัง/ล่วงหน้า (recurring วนรอบละสัปดาห์)
+const scheduleHorizon = 8 * 24 * time.Hour
+
+const clockLayout = "15:04"
+
+type (
+	// RoomSchedule ช่วงเวลาที่ห้องเปิด นอกช่วงห้องจะถูกปิด (inactive)
+	RoomSchedule struct {
+		Timezone string           `bson:"timezone,omitempty" json:"timezone,omitempty"` // IANA เช่น Asia/Bangkok (ว่าง = ROOM_SCHEDULE_TIMEZONE)
+		Windows  []ScheduleWindow `bson:"windows" json:"windows"`
+		// AppliedAt boundary ล่าสุดที่ scheduler ใช้แล้ว: เปลี่ยนสถานะด้วยมือหลังจากนั้นจะคงอยู่จนถึง boundary ถัดไป
+		AppliedAt *time.Time `bson:"applied_at,omitempty" json:"appliedAt,omitempty"`
+	}
+
+	// ScheduleWindow one-off ใช้ OpensAt/ClosesAt, recurring ใช้ Days + OpenTime/CloseTime (เวลาท้องถิ่น)
+	ScheduleWindow struct {
+		OpensAt   *time.Time `bson:"opens_at,omitempty" json:"opensAt,omitempty"`
+		ClosesAt  *time.Time `bson:"closes_at,omitempty" json:"closesAt,omitempty"`
+		Days      []int      `bson:"days,omitempty" json:"days,omitempty"`            // 0 = อาทิตย์ ... 6 = เสาร์
+		OpenTime  string     `bson:"open_time,omitempty" json:"openTime,omitempty"`   // "HH:MM"
+		CloseTime string     `bson:"close_time,omitempty" json:"closeTime,omitempty"` // "HH:MM" (ไม่เกิน OpenTime = ปิดวันถัดไป)
+	}
+
+	// ScheduleInterval ช่วงเปิดที่คำนวณแล้ว (ช่วงที่ซ้อน/ต่อกันถูกรวมเป็นช่วงเดียว)
+	ScheduleInterval struct {
+		Start time.Time
+		End   time.Time
+	}
+
+	// ScheduleBoundary จุดที่ห้องเปิด (Open) หรือปิด
+	ScheduleBoundary struct {
+		At   time.Time
+		Open bool
+	}
+)
+
+// IsRecurring window แบบวนรายสัปดาห์
+func (w ScheduleWindow) IsRecurring() bool {
+	return len(w.Days) > 0
+}
+
+// Validate ตรวจตารางก่อนบันทึก
+func (s *RoomSchedule) Validate() error {
+	if len(s.Windows) == 0 {
+		return errors.New("schedule must have at least one window")
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", s.Timezone)
+		}
+	}
+
+	for i, w := range s.Windows {
+		oneOff := w.OpensAt != nil || w.ClosesAt != nil
+		switch {
+		case oneOff && w.IsRecurring():
+			return fmt.Errorf("window %d: use either opensAt/closesAt or days/openTime/closeTime", i)
+		case oneOff:
+			if w.OpensAt == nil || w.ClosesAt == nil {
+				return fmt.Errorf("window %d: opensAt and closesAt are both required", i)
+			}
+			if !w.ClosesAt.After(*w.OpensAt) {
+				return fmt.Errorf("window %d: closesAt must be after opensAt", i)
+			}
+		case w.IsRecurring():
+			for _, day := range w.Days {
+				if day < 0 || day > 6 {
+					return fmt.Errorf("window %d: days must be 0 (Sunday) to 6 (Saturday)", i)
+				}
+			}
+			open, err := time.Parse(clockLayout, w.OpenTime)
+			if err != nil {
+				return fmt.Errorf("window %d: openTime must be HH:MM", i)
+			}
+			closing, err := time.Parse(clockLayout, w.CloseTime)
+			if err != nil {
+				return fmt.Errorf("window %d: closeTime must be HH:MM", i)
+			}
+			if open.Equal(closing) {
+				return fmt.Errorf("window %d: openTime and closeTime must differ", i)
+			}
+		default:
+			return fmt.Errorf("window %d: set opensAt/closesAt or days/openTime/closeTime", i)
+		}
+	}
+	return nil
+}
+
+// Location timezone ของตาราง (fallback ถ้าไม่ได้ระบุหรือโหลดไม่ได้)
+func (s *RoomSchedule) Location(fallback *time.Location) *time.Location {
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+	return fallback
+}
+
+// Intervals ช่วงเปิดที่คาบเกี่ยวกับ [from, to) เรียงตามเวลาและรวมช่วงที่ซ้อนกันแล้ว
+func (s *RoomSchedule) Intervals(from, to time.Time, loc *time.Location) []ScheduleInterval {
+	var intervals []ScheduleInterval
+	add := func(start, end time.Time) {
+		if end.After(from) && start.Before(to) {
+			intervals = append(intervals, ScheduleInterval{Start: start, End: end})
+		}
+	}
+
+	for _, w := range s.Windows {
+		if !w.IsRecurring() {
+			if w.OpensAt != nil && w.ClosesAt != nil {
+				add(*w.OpensAt, *w.ClosesAt)
+			}
+			continue
+		}
+
+		open, err1 := time.Parse(clockLayout, w.OpenTime)
+		closing, err2 := time.Parse(clockLayout, w.CloseTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		// เริ่มก่อน from หนึ่งวันเพื่อรวมช่วงข้ามเที่ยงคืนที่เปิดค้างอยู่
+		day := from.In(loc).AddDate(0, 0, -1)
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if !slices.Contains(w.Days, int(day.Weekday())) {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), open.Hour(), open.Minute(), 0, 0, loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), closing.Hour(), closing.Minute(), 0, 0, loc)
+			if !end.After(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			add(start, end)
+		}
+	}
+
+	return mergeIntervals(intervals)
+}
+
+// IsOpenAt ห้องควรเปิดอยู่ที่เวลา t หรือไม่
+func (s *RoomSchedule) IsOpenAt(t time.Time, loc *time.Location) bool {
+	for _, iv := range s.Intervals(t, t.Add(time.Nanosecond), loc) {
+		if !t.Before(iv.Start) && t.Before(iv.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// LastBoundary boundary ล่าสุดที่ไม่เกิน t (false ถ้ายังไม่เคยมี)
+func (s *RoomSchedule) LastBoundary(t time.Time, loc *time.Location) (ScheduleBoundary, bool) {
+	from := t.Add(-scheduleHorizon)
+	for _, w := range s.Windows {
+		if w.OpensAt != nil && w.OpensAt.Before(from) {
+			from = *w.OpensAt
+		}
+	}
+
+	var last ScheduleBoundary
+	found := false
+	for _, b := range boundaries(s.Intervals(from, t.Add(time.Nanosecond), loc)) {
+		if b.At.After(t) {
+			break
+		}
+		last, found = b, true
+	}
+	return last, found
+}
+
+// NextBoundary boundary ถัดไปหลัง t ภายใน scheduleHorizon
+func (s *RoomSchedule) NextBoundary(t time.Time, loc *time.Location) (ScheduleBoundary, bool) {
+	for _, b := range boundaries(s.Intervals(t, t.Add(scheduleHorizon), loc)) {
+		if b.At.After(t) {
+			return b, true
+		}
+	}
+	return ScheduleBoundary{}, false
+}
+
+// NextOpen เวลาเปิดครั้งถัดไปหลัง t (nil ถ้าไม่มีภายใน scheduleHorizon)
+func (s *RoomSchedule) NextOpen(t time.Time, loc *time.Location) *time.Time {
+	for _, iv := range s.Intervals(t, t.Add(scheduleHorizon), loc) {
+		if iv.Start.After(t) {
+			start := iv.Start
+			return &start
+		}
+	}
+	return nil
+}
+
+// CurrentClose เวลาปิดของช่วงที่เปิดอยู่ตอน t (nil ถ้าตอนนี้ไม่อยู่ในช่วงเปิด)
+func (s *RoomSchedule) CurrentClose(t time.Time, loc *time.Location) *time.Time {
+	// ใช้ช่วงยาวเพื่อให้ window ที่ต่อกันถูกรวมก่อน (ไม่งั้นจะได้เวลาปิดของ window แรก)
+	for _, iv := range s.Intervals(t, t.Add(scheduleHorizon), loc) {
+		if !t.Before(iv.Start) && t.Before(iv.End) {
+			end := iv.End
+			return &end
+		}
+	}
+	return nil
+}
+
+func mergeIntervals(intervals []ScheduleInterval) []ScheduleInterval {
+	if len(intervals) < 2 {
+		return intervals
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+
+	merged := intervals[:1]
+	for _, iv := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if !iv.Start.After(last.End) {
+			if iv.End.After(last.End) {
+				last.End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+func boundaries(intervals []ScheduleInterval) []ScheduleBoundary {
+	result := make([]ScheduleBoundary, 0, len(intervals)*2)
+	for _, iv := range intervals {
+		result = append(result, ScheduleBoundary{At: iv.Start, Open: true}, ScheduleBoundary{At: iv.End})
+	}
+	return result
+}
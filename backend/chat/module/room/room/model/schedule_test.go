@@ -0,0 +1,210 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestRoomScheduleIntervals(t *testing.T) {
+	bangkok := mustLoad(t, "Asia/Bangkok")
+	newYork := mustLoad(t, "America/New_York")
+	opens, closes := utc(2026, 10, 1, 0, 0), utc(2026, 10, 2, 0, 0)
+
+	tests := []struct {
+		name     string
+		schedule RoomSchedule
+		from, to time.Time
+		loc      *time.Location
+		want     []ScheduleInterval
+	}{
+		{
+			// ศุกร์ 22:00 ถึงเสาร์ 02:00 เวลาไทย: query เริ่มหลังเที่ยงคืนต้องยังเห็นช่วงที่เปิดค้างจากวันศุกร์
+			name: "overnight window still open after midnight",
+			schedule: RoomSchedule{Windows: []ScheduleWindow{
+				{Days: []int{5}, OpenTime: "22:00", CloseTime: "02:00"},
+			}},
+			from: utc(2026, 10, 23, 18, 0), // เสาร์ 01:00 ICT
+			to:   utc(2026, 10, 24, 5, 0),  // เสาร์ 12:00 ICT
+			loc:  bangkok,
+			want: []ScheduleInterval{{Start: utc(2026, 10, 23, 15, 0), End: utc(2026, 10, 23, 19, 0)}},
+		},
+		{
+			name: "touching windows are merged",
+			schedule: RoomSchedule{Windows: []ScheduleWindow{
+				{Days: []int{1}, OpenTime: "12:00", CloseTime: "15:00"},
+				{Days: []int{1}, OpenTime: "09:00", CloseTime: "12:00"},
+			}},
+			from: utc(2026, 10, 19, 0, 0),
+			to:   utc(2026, 10, 20, 0, 0),
+			loc:  time.UTC,
+			want: []ScheduleInterval{{Start: utc(2026, 10, 19, 9, 0), End: utc(2026, 10, 19, 15, 0)}},
+		},
+		{
+			// 2026-03-08 นาฬิกาเดินข้าม 02:00 EST ไป 03:00 EDT: ช่วง 01:00-03:00 ยาวแค่ชั่วโมงเดียว
+			name: "spring forward shortens the window",
+			schedule: RoomSchedule{Windows: []ScheduleWindow{
+				{Days: []int{0}, OpenTime: "01:00", CloseTime: "03:00"},
+			}},
+			from: utc(2026, 3, 8, 0, 0),
+			to:   utc(2026, 3, 9, 0, 0),
+			loc:  newYork,
+			want: []ScheduleInterval{{Start: utc(2026, 3, 8, 6, 0), End: utc(2026, 3, 8, 7, 0)}},
+		},
+		{
+			// 2026-11-01 ชั่วโมง 01:00 เกิดซ้ำสองครั้ง: ช่วง 00:30-02:30 ยาวสามชั่วโมง
+			name: "fall back lengthens the window",
+			schedule: RoomSchedule{Windows: []ScheduleWindow{
+				{Days: []int{0}, OpenTime: "00:30", CloseTime: "02:30"},
+			}},
+			from: utc(2026, 11, 1, 0, 0),
+			to:   utc(2026, 11, 2, 0, 0),
+			loc:  newYork,
+			want: []ScheduleInterval{{Start: utc(2026, 11, 1, 4, 30), End: utc(2026, 11, 1, 7, 30)}},
+		},
+		{
+			name: "overnight window across spring forward",
+			schedule: RoomSchedule{Windows: []ScheduleWindow{
+				{Days: []int{6}, OpenTime: "22:00", CloseTime: "06:00"},
+			}},
+			from: utc(2026, 3, 7, 0, 0),
+			to:   utc(2026, 3, 9, 0, 0),
+			loc:  newYork,
+			// เสาร์ 22:00 EST = 03:00Z, อาทิตย์ 06:00 EDT = 10:00Z (7 ชั่วโมง)
+			want: []ScheduleInterval{{Start: utc(2026, 3, 8, 3, 0), End: utc(2026, 3, 8, 10, 0)}},
+		},
+		{
+			name: "one-off window outside range is dropped",
+			schedule: RoomSchedule{Windows: []ScheduleWindow{
+				{OpensAt: &opens, ClosesAt: &closes},
+			}},
+			from: utc(2026, 10, 2, 0, 0), // ปิดพอดีตอน from: ไม่นับ
+			to:   utc(2026, 10, 3, 0, 0),
+			loc:  time.UTC,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schedule.Intervals(tt.from, tt.to, tt.loc)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Intervals() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) {
+					t.Errorf("interval %d = [%s, %s), want [%s, %s)", i,
+						got[i].Start.UTC(), got[i].End.UTC(), tt.want[i].Start, tt.want[i].End)
+				}
+			}
+		})
+	}
+}
+
+func TestRoomScheduleLastBoundary(t *testing.T) {
+	bangkok := mustLoad(t, "Asia/Bangkok")
+	newYork := mustLoad(t, "America/New_York")
+
+	overnight := RoomSchedule{Windows: []ScheduleWindow{
+		{Days: []int{5}, OpenTime: "22:00", CloseTime: "02:00"},
+	}}
+	springForward := RoomSchedule{Windows: []ScheduleWindow{
+		{Days: []int{0}, OpenTime: "01:00", CloseTime: "03:00"},
+	}}
+	longOpens, longCloses := utc(2026, 9, 1, 0, 0), utc(2026, 12, 1, 0, 0)
+	longRunning := RoomSchedule{Windows: []ScheduleWindow{{OpensAt: &longOpens, ClosesAt: &longCloses}}}
+	futureOpens, futureCloses := utc(2027, 1, 1, 0, 0), utc(2027, 1, 2, 0, 0)
+	notStarted := RoomSchedule{Windows: []ScheduleWindow{{OpensAt: &futureOpens, ClosesAt: &futureCloses}}}
+
+	tests := []struct {
+		name      string
+		schedule  RoomSchedule
+		at        time.Time
+		loc       *time.Location
+		want      ScheduleBoundary
+		wantFound bool
+	}{
+		{
+			name:      "after midnight inside overnight window",
+			schedule:  overnight,
+			at:        utc(2026, 10, 23, 18, 0), // เสาร์ 01:00 ICT
+			loc:       bangkok,
+			want:      ScheduleBoundary{At: utc(2026, 10, 23, 15, 0), Open: true},
+			wantFound: true,
+		},
+		{
+			name:      "exactly at close",
+			schedule:  overnight,
+			at:        utc(2026, 10, 23, 19, 0), // เสาร์ 02:00 ICT
+			loc:       bangkok,
+			want:      ScheduleBoundary{At: utc(2026, 10, 23, 19, 0)},
+			wantFound: true,
+		},
+		{
+			name:      "days after close",
+			schedule:  overnight,
+			at:        utc(2026, 10, 26, 5, 0), // จันทร์ 12:00 ICT
+			loc:       bangkok,
+			want:      ScheduleBoundary{At: utc(2026, 10, 23, 19, 0)},
+			wantFound: true,
+		},
+		{
+			name:      "inside window shortened by spring forward",
+			schedule:  springForward,
+			at:        utc(2026, 3, 8, 6, 30), // 01:30 EST
+			loc:       newYork,
+			want:      ScheduleBoundary{At: utc(2026, 3, 8, 6, 0), Open: true},
+			wantFound: true,
+		},
+		{
+			name:      "after spring forward close",
+			schedule:  springForward,
+			at:        utc(2026, 3, 8, 7, 30), // 03:30 EDT
+			loc:       newYork,
+			want:      ScheduleBoundary{At: utc(2026, 3, 8, 7, 0)},
+			wantFound: true,
+		},
+		{
+			name:      "one-off opened before the horizon",
+			schedule:  longRunning,
+			at:        utc(2026, 10, 19, 0, 0),
+			loc:       time.UTC,
+			want:      ScheduleBoundary{At: longOpens, Open: true},
+			wantFound: true,
+		},
+		{
+			name:      "no boundary yet",
+			schedule:  notStarted,
+			at:        utc(2026, 10, 19, 0, 0),
+			loc:       time.UTC,
+			wantFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := tt.schedule.LastBoundary(tt.at, tt.loc)
+			if found != tt.wantFound {
+				t.Fatalf("LastBoundary() found = %v, want %v (got %+v)", found, tt.wantFound, got)
+			}
+			if !found {
+				return
+			}
+			if !got.At.Equal(tt.want.At) || got.Open != tt.want.Open {
+				t.Errorf("LastBoundary() = {%s open=%v}, want {%s open=%v}", got.At.UTC(), got.Open, tt.want.At, tt.want.Open)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaxSlowModeSeconds ระยะห่างระหว่างข้อความสูงสุดที่ตั้งได้
+const MaxSlowModeSeconds = 3600
+
+// ErrInvalidSlowMode ค่า slow mode ไม่ถูกต้อง (controller ตอบ 400)
+var ErrInvalidSlowMode = errors.New("invalid slow mode")
+
+// SlowMode จำกัดให้ user แต่ละคนส่งข้อความได้หนึ่งครั้งต่อ Seconds วินาที (role ใน ExemptRoles ไม่ถูกจำกัด)
+type SlowMode struct {
+	Seconds     int      `bson:"seconds" json:"seconds"`
+	ExemptRoles []string `bson:"exempt_roles,omitempty" json:"exemptRoles,omitempty"` // ชื่อ role เช่น Administrator
+}
+
+// Validate ตรวจค่าก่อนบันทึก
+func (m *SlowMode) Validate() error {
+	if m.Seconds < 1 || m.Seconds > MaxSlowModeSeconds {
+		return fmt.Errorf("%w: seconds must be between 1 and %d", ErrInvalidSlowMode, MaxSlowModeSeconds)
+	}
+	return nil
+}
+
+// Interval ระยะห่างขั้นต่ำระหว่างข้อความของ user หนึ่งคน (0 = ไม่ได้เปิด)
+func (m *SlowMode) Interval() time.Duration {
+	if m == nil {
+		return 0
+	}
+	return time.Duration(m.Seconds) * time.Second
+}
+
+// IsExempt role นี้ไม่ถูกจำกัดหรือไม่
+func (m *SlowMode) IsExempt(role string) bool {
+	return m != nil && role != "" && slices.Contains(m.ExemptRoles, role)
+}
@@ -10,6 +10,7 @@ import (
 	sharedUtils "chat/module/room/shared/utils"
 	userService "chat/module/user/service"
 	"chat/pkg/config"
+	"chat/pkg/core/audit"
 	"chat/pkg/core/kafka"
 	"chat/pkg/database/queries"
 	serviceHelper "chat/pkg/helpers/service"
@@ -19,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -37,6 +39,7 @@ type RoomServiceImpl struct {
 	db                   *mongo.Database
 	memberHelper         *memberUtils.RoomMemberHelper
 	statusChangeCallback func(ctx context.Context, roomID string, newStatus string)
+	scheduleLoc          *time.Location // timezone ของตารางที่ไม่ได้ระบุเอง
 }
 
 type RoomService interface {
@@ -63,6 +66,12 @@ type RoomService interface {
 	GetRoomsForMe(ctx context.Context, userID string) ([]dto.ResponseRoomDto, error)
 	DisconnectAllUsersFromRoom(ctx context.Context, roomID primitive.ObjectID) error
 	SetStatusChangeCallback(callback func(ctx context.Context, roomID string, newStatus string))
+	SetRoomStatus(ctx context.Context, roomID primitive.ObjectID, status string) (*model.Room, error)
+	SetRoomSchedule(ctx context.Context, roomID primitive.ObjectID, schedule *model.RoomSchedule) (*model.Room, error)
+	ScheduleLocation() *time.Location
+	SetRoomSlowMode(ctx context.Context, roomID primitive.ObjectID, seconds int, exemptRoles []string) (*model.Room, error)
+	GetMemberRole(ctx context.Context, roomID, userID primitive.ObjectID) (string, error)
+	SetMemberRole(ctx context.Context, roomID, userID primitive.ObjectID, role string) (*model.Room, error)
 }
 
 func NewRoomService(db *mongo.Database, redis *redis.Client, cfg *config.Config, hub *chatUtils.Hub) RoomService {
@@ -75,6 +84,11 @@ func NewRoomService(db *mongo.Database, redis *redis.Client, cfg *config.Config,
 	cache := sharedCache.NewRoomCacheService(redis)
 	eventEmitter := sharedEvents.NewRoomEventEmitter(bus, cfg)
 
+	scheduleLoc, err := time.LoadLocation(cfg.RoomSchedule.Timezone)
+	if err != nil {
+		scheduleLoc = time.UTC
+	}
+
 	service := &RoomServiceImpl{
 		BaseService:  queries.NewBaseService[model.Room](db.Collection("rooms")),
 		userService:  userSvc,
@@ -84,6 +98,7 @@ func NewRoomService(db *mongo.Database, redis *redis.Client, cfg *config.Config,
 		hub:          hub,
 		db:           db,
 		memberHelper: memberUtils.NewRoomMemberHelper(db, cache, eventEmitter, hub),
+		scheduleLoc:  scheduleLoc,
 	}
 
 	return service
@@ -412,6 +427,12 @@ func (s *RoomServiceImpl) CreateRoom(ctx context.Context, createDto *dto.CreateR
 
 	created := &resp.Data[0]
 	s.handleRoomCreated(ctx, created)
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomCreate,
+		TargetType: audit.TargetRoom,
+		TargetID:   created.ID.Hex(),
+		After:      created.AuditSnapshot(),
+	})
 	return created, nil
 }
 
@@ -439,6 +460,9 @@ func (s *RoomServiceImpl) UpdateRoom(ctx context.Context, id string, updateDto *
 		CreatedAt: oldRoom.CreatedAt,
 		UpdatedAt: oldRoom.UpdatedAt,
 		Metadata:  oldRoom.Metadata,
+		Schedule:  oldRoom.Schedule,
+		SlowMode:  oldRoom.SlowMode,
+		MemberRoles: oldRoom.MemberRoles,
 	}
 	updatedRoom.Name = updateDto.Name
 	updatedRoom.Type = updateDto.Type
@@ -450,6 +474,11 @@ func (s *RoomServiceImpl) UpdateRoom(ctx context.Context, id string, updateDto *
 	if updateDto.Image != "" {
 		updatedRoom.Image = updateDto.Image
 	}
+	// **NEW: slow mode (nil ใน dto = คงค่าเดิม)**
+	updatedRoom.SlowMode, err = mergeSlowMode(oldRoom.SlowMode, updateDto.SlowModeSeconds, updateDto.SlowModeExemptRoles)
+	if err != nil {
+		return nil, err
+	}
 	updatedRoom.UpdatedAt = time.Now()
 	// createdBy: use from updateDto if present, else preserve
 	if updateDto.CreatedBy != "" {
@@ -483,6 +512,11 @@ func (s *RoomServiceImpl) UpdateRoom(ctx context.Context, id string, updateDto *
 
 	filter := bson.M{"_id": roomObjID}
 	update := bson.M{"$set": setFields}
+	if updatedRoom.SlowMode != nil {
+		setFields["slow_mode"] = updatedRoom.SlowMode
+	} else if oldRoom.SlowMode != nil {
+		update["$unset"] = bson.M{"slow_mode": ""}
+	}
 
 	// Try to get the collection via public getter
 	var updateErr error
@@ -509,28 +543,196 @@ func (s *RoomServiceImpl) UpdateRoom(ctx context.Context, id string, updateDto *
 
 	// Check if room status changed to inactive and disconnect all users
 	if oldRoom.IsActive() && updatedRoom.IsInactive() {
-		log.Printf("[RoomService] Room %s status changed from active to inactive, triggering WebSocket disconnect", roomObjID.Hex())
+		s.onRoomDeactivated(ctx, roomObjID, updatedRoom.Status)
+	}
 
-		// Call status change callback if set
-		if s.statusChangeCallback != nil {
-			log.Printf("[RoomService] 🔔 Calling status change callback for room %s", roomObjID.Hex())
-			s.statusChangeCallback(ctx, roomObjID.Hex(), updatedRoom.Status)
-		} else {
-			log.Printf("[RoomService] ⚠️ No status change callback set for room %s", roomObjID.Hex())
-		}
+	// **NEW: แจ้ง client ในห้องทันทีเมื่อ slow mode เปลี่ยน**
+	if !reflect.DeepEqual(oldRoom.SlowMode, updatedRoom.SlowMode) {
+		s.broadcastSlowMode(ctx, roomObjID, updatedRoom.SlowMode)
+	}
 
-		// Emit room status change event
-		if s.eventEmitter != nil {
-			s.eventEmitter.EmitRoomStatusChanged(ctx, roomObjID, updatedRoom.Status)
-		}
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomUpdate,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomObjID.Hex(),
+		Before:     oldRoom.AuditSnapshot(),
+		After:      updatedRoom.AuditSnapshot(),
+	})
+	return updatedRoom, nil
+}
+
+// onRoomDeactivated แจ้ง callback/event และตัด connection เมื่อห้องเปลี่ยนจาก active เป็น inactive
+func (s *RoomServiceImpl) onRoomDeactivated(ctx context.Context, roomObjID primitive.ObjectID, status string) {
+	log.Printf("[RoomService] Room %s status changed from active to inactive, triggering WebSocket disconnect", roomObjID.Hex())
+
+	// Call status change callback if set
+	if s.statusChangeCallback != nil {
+		log.Printf("[RoomService] 🔔 Calling status change callback for room %s", roomObjID.Hex())
+		s.statusChangeCallback(ctx, roomObjID.Hex(), status)
+	} else {
+		log.Printf("[RoomService] ⚠️ No status change callback set for room %s", roomObjID.Hex())
+	}
 
-		// Also disconnect users immediately for immediate response
-		if err := sharedUtils.DisconnectAllUsersFromRoom(ctx, roomObjID, s.hub, s.cache); err != nil {
-			log.Printf("[RoomService] Warning: Failed to disconnect users from room %s: %v", roomObjID.Hex(), err)
+	// Emit room status change event
+	if s.eventEmitter != nil {
+		s.eventEmitter.EmitRoomStatusChanged(ctx, roomObjID, status)
+	}
+
+	// Also disconnect users immediately for immediate response
+	if err := sharedUtils.DisconnectAllUsersFromRoom(ctx, roomObjID, s.hub, s.cache); err != nil {
+		log.Printf("[RoomService] Warning: Failed to disconnect users from room %s: %v", roomObjID.Hex(), err)
+	}
+}
+
+// mergeSlowMode รวมค่า slow mode จาก dto กับค่าเดิม (Seconds = 0 คือปิด)
+func mergeSlowMode(current *model.SlowMode, seconds *int, exemptRoles []string) (*model.SlowMode, error) {
+	if seconds == nil && exemptRoles == nil {
+		return current, nil
+	}
+
+	next := model.SlowMode{}
+	if current != nil {
+		next = model.SlowMode{Seconds: current.Seconds, ExemptRoles: current.ExemptRoles}
+	}
+	if seconds != nil {
+		next.Seconds = *seconds
+	}
+	if exemptRoles != nil {
+		next.ExemptRoles = exemptRoles
+	}
+	if next.Seconds == 0 {
+		return nil, nil
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// broadcastSlowMode แจ้ง client ทุก instance ด้วย room_slow_mode_changed
+func (s *RoomServiceImpl) broadcastSlowMode(ctx context.Context, roomID primitive.ObjectID, slowMode *model.SlowMode) {
+	if slowMode != nil {
+		log.Printf("[RoomService] 🐢 Slow mode for room %s set to %ds", roomID.Hex(), slowMode.Seconds)
+	} else {
+		log.Printf("[RoomService] 🐢 Slow mode for room %s turned off", roomID.Hex())
+	}
+
+	// ส่งผ่าน room topic อย่างเดียวเหมือน room_status_changed (ทุก instance รวมถึง instance นี้ได้ event เดียวกัน)
+	if s.eventEmitter != nil {
+		s.eventEmitter.EmitRoomSlowModeChanged(ctx, roomID, slowMode)
+	}
+}
+
+// **NEW: SetRoomStatus เปลี่ยนแค่ field status (ไม่เขียน members ทับ สมาชิกที่ join ระหว่างนั้นจึงไม่หาย)**
+// ใช้ flow disconnect/event/audit เดียวกับ UpdateRoom
+func (s *RoomServiceImpl) SetRoomStatus(ctx context.Context, roomID primitive.ObjectID, status string) (*model.Room, error) {
+	if !model.ValidateRoomStatus(status) {
+		return nil, fmt.Errorf("invalid room status %q", status)
+	}
+	oldRoom, err := s.GetRoomById(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"status": status, "updatedAt": now}}
+	if _, err := s.db.Collection("rooms").UpdateOne(ctx, bson.M{"_id": roomID}, update); err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		_ = s.cache.DeleteRoom(ctx, roomID.Hex())
+	}
+
+	updated := *oldRoom
+	updated.Status = status
+	updated.UpdatedAt = now
+
+	if oldRoom.IsActive() && updated.IsInactive() {
+		s.onRoomDeactivated(ctx, roomID, status)
+	}
+
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomUpdate,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Before:     oldRoom.AuditSnapshot(),
+		After:      updated.AuditSnapshot(),
+	})
+	return &updated, nil
+}
+
+// **NEW: SetRoomSlowMode ตั้ง slow mode อย่างเดียว (seconds = 0 คือปิด) ให้ moderator ของห้องใช้โดยไม่ต้องแก้ข้อมูลห้อง**
+func (s *RoomServiceImpl) SetRoomSlowMode(ctx context.Context, roomID primitive.ObjectID, seconds int, exemptRoles []string) (*model.Room, error) {
+	room, err := s.GetRoomById(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.UpdateRoom(ctx, roomID.Hex(), &dto.UpdateRoomDto{
+		Name:                room.Name,
+		Type:                room.Type,
+		Status:              room.Status,
+		Capacity:            room.Capacity,
+		Image:               room.Image,
+		SlowModeSeconds:     &seconds,
+		SlowModeExemptRoles: exemptRoles,
+	})
+}
+
+// **NEW: SetRoomSchedule ตั้งหรือลบ (schedule = nil) ตารางเปิด/ปิดห้อง**
+func (s *RoomServiceImpl) SetRoomSchedule(ctx context.Context, roomID primitive.ObjectID, schedule *model.RoomSchedule) (*model.Room, error) {
+	if schedule != nil {
+		if err := schedule.Validate(); err != nil {
+			return nil, err
 		}
+		// ตารางใหม่: ให้ scheduler ใช้ boundary ล่าสุดทันทีในรอบถัดไป
+		schedule.AppliedAt = nil
 	}
 
-	return updatedRoom, nil
+	oldRoom, err := s.GetRoomById(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	update := bson.M{"$unset": bson.M{"schedule": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	if schedule != nil {
+		update = bson.M{"$set": bson.M{"schedule": schedule, "updatedAt": time.Now()}}
+	}
+	if _, err := s.db.Collection("rooms").UpdateOne(ctx, bson.M{"_id": roomID}, update); err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		_ = s.cache.DeleteRoom(ctx, roomID.Hex())
+	}
+
+	updated := *oldRoom
+	updated.Schedule = schedule
+
+	var before, after interface{}
+	if oldRoom.Schedule != nil {
+		before = oldRoom.Schedule
+	}
+	if schedule != nil {
+		after = schedule
+	}
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomSchedule,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Before:     before,
+		After:      after,
+	})
+	if schedule != nil {
+		log.Printf("[RoomService] 🗓️ Schedule set for room %s (%d windows)", roomID.Hex(), len(schedule.Windows))
+	} else {
+		log.Printf("[RoomService] 🗓️ Schedule cleared for room %s", roomID.Hex())
+	}
+	return &updated, nil
+}
+
+// ScheduleLocation timezone ที่ใช้กับตารางที่ไม่ได้ระบุ timezone
+func (s *RoomServiceImpl) ScheduleLocation() *time.Location {
+	return s.scheduleLoc
 }
 
 // ลบ room
@@ -542,6 +744,12 @@ func (s *RoomServiceImpl) DeleteRoom(ctx context.Context, id string) (*model.Roo
 
 	deleted := &room.Data[0]
 	s.handleRoomDeleted(ctx, deleted)
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomDelete,
+		TargetType: audit.TargetRoom,
+		TargetID:   deleted.ID.Hex(),
+		Before:     deleted.AuditSnapshot(),
+	})
 	return deleted, nil
 }
 
@@ -682,7 +890,16 @@ func (s *RoomServiceImpl) GetRoomsForMe(ctx context.Context, userID string) ([]d
 			Metadata:    room.Metadata,
 			MemberCount: len(room.Members),
 			Status:      room.Status,
+			MyRole:      room.MemberRole(userObjID),
 		})
+		if room.Schedule != nil {
+			loc := room.Schedule.Location(s.scheduleLoc)
+			now := time.Now()
+			result[len(result)-1].NextOpenAt = room.Schedule.NextOpen(now, loc)
+			if room.IsActive() {
+				result[len(result)-1].ClosesAt = room.Schedule.CurrentClose(now, loc)
+			}
+		}
 	}
 	return result, nil
 }
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"chat/module/room/room/model"
+	"chat/pkg/core/audit"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: Room-scoped member roles**
+// role ในห้องเก็บที่ rooms.member_roles (เฉพาะ owner/moderator หรือ creator ที่ถูกลดเป็น member)
+// จึงมากับ room cache โดยไม่ต้อง query เพิ่มตอนตรวจสิทธิ์
+
+var ErrNotRoomMember = errors.New("user is not a member of this room")
+
+// GetMemberRole role ของ user ในห้อง ("" = ไม่ใช่สมาชิก) ใช้เป็น middleware.RoomRoleResolver
+func (s *RoomServiceImpl) GetMemberRole(ctx context.Context, roomID, userID primitive.ObjectID) (string, error) {
+	room, err := s.GetRoomById(ctx, roomID)
+	if err != nil {
+		return "", err
+	}
+	return room.MemberRole(userID), nil
+}
+
+// SetMemberRole ตั้ง role ในห้องของสมาชิก (owner / moderator / member)
+func (s *RoomServiceImpl) SetMemberRole(ctx context.Context, roomID, userID primitive.ObjectID, role string) (*model.Room, error) {
+	if !model.ValidateRoomRole(role) {
+		return nil, fmt.Errorf("invalid room role %q", role)
+	}
+
+	room, err := s.GetRoomById(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	current := room.MemberRole(userID)
+	if current == "" {
+		return nil, ErrNotRoomMember
+	}
+	if current == role {
+		return room, nil
+	}
+
+	// member เป็นค่า default จึงไม่ต้องเก็บ ยกเว้นผู้สร้างห้องที่ default เป็น owner
+	key := "member_roles." + userID.Hex()
+	now := time.Now()
+	update := bson.M{"$set": bson.M{key: role, "updatedAt": now}}
+	storeRole := role != model.RoomRoleMember || room.CreatedBy == userID
+	if !storeRole {
+		update = bson.M{"$unset": bson.M{key: ""}, "$set": bson.M{"updatedAt": now}}
+	}
+	if _, err := s.db.Collection("rooms").UpdateOne(ctx, bson.M{"_id": roomID}, update); err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		_ = s.cache.DeleteRoom(ctx, roomID.Hex())
+	}
+
+	updated := *room
+	updated.MemberRoles = maps.Clone(room.MemberRoles)
+	if updated.MemberRoles == nil {
+		updated.MemberRoles = map[string]string{}
+	}
+	if storeRole {
+		updated.MemberRoles[userID.Hex()] = role
+	} else {
+		delete(updated.MemberRoles, userID.Hex())
+	}
+	updated.UpdatedAt = now
+
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionRoomMemberRole,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID.Hex(),
+		Before:     map[string]interface{}{"userId": userID.Hex(), "role": current},
+		After:      map[string]interface{}{"userId": userID.Hex(), "role": role},
+	})
+	s.broadcastMemberRole(ctx, roomID, userID, role)
+	log.Printf("[RoomService] 🛡️ User %s in room %s: %s -> %s", userID.Hex(), roomID.Hex(), current, role)
+	return &updated, nil
+}
+
+// broadcastMemberRole แจ้ง client ทุก instance ด้วย room_member_role_changed (ผ่าน room topic อย่างเดียวเหมือน slow mode)
+func (s *RoomServiceImpl) broadcastMemberRole(ctx context.Context, roomID, userID primitive.ObjectID, role string) {
+	if s.eventEmitter != nil {
+		s.eventEmitter.EmitRoomMemberRoleChanged(ctx, roomID, userID, role)
+	}
+}
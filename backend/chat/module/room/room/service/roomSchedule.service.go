@@ -0,0 +1,253 @@
+package service
+
+import (
+	chatUtils "chat/module/chat/utils"
+	"chat/module/room/room/model"
+	"chat/pkg/config"
+	"chat/pkg/core/metrics"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// **NEW: Room scheduler**
+// เปลี่ยนสถานะห้องตาม boundary ของตาราง (เปิด = active, ปิด = inactive) ผ่าน SetRoomStatus
+// ซึ่งใช้ flow เดิมของ UpdateRoom: statusChangeCallback, disconnect ผู้ใช้ และ audit
+//
+// ทุก instance รัน scheduler แต่ boundary หนึ่งถูก claim ด้วย conditional update บน schedule.applied_at
+// จึงมีแค่ instance เดียวที่เปลี่ยนสถานะ ส่วนคำเตือนก่อนปิดส่งจากทุก instance ถึง client ที่ต่อกับ instance นั้น
+const roomClosingEventType = "room_closing"
+
+var roomScheduleTransitions = metrics.NewCounterVec(
+	"chat_room_schedule_transitions_total",
+	"Room status transitions applied by the room scheduler.",
+	"status", "result",
+)
+
+type RoomScheduler struct {
+	rooms       *mongo.Collection
+	roomService RoomService
+	hub         *chatUtils.Hub
+	cfg         config.RoomScheduleConfig
+
+	mu     sync.Mutex
+	warned map[string]time.Time // roomID|closeAt|threshold -> closeAt (กันเตือนซ้ำ)
+}
+
+func NewRoomScheduler(db *mongo.Database, roomService RoomService, hub *chatUtils.Hub, cfg config.RoomScheduleConfig) *RoomScheduler {
+	return &RoomScheduler{
+		rooms:       db.Collection("rooms"),
+		roomService: roomService,
+		hub:         hub,
+		cfg:         cfg,
+		warned:      make(map[string]time.Time),
+	}
+}
+
+// Start รันหนึ่งรอบทันทีแล้ววนทุก ROOM_SCHEDULE_TICK_INTERVAL จน ctx ถูก cancel (ไม่ทำอะไรถ้าปิดอยู่)
+func (s *RoomScheduler) Start(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.TickInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunOnce(ctx); err != nil {
+				log.Printf("[RoomScheduler] ⚠️ Schedule run failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce ใช้ boundary ที่ถึงแล้วและส่งคำเตือนก่อนปิดของทุกห้องที่มีตาราง
+func (s *RoomScheduler) RunOnce(ctx context.Context) error {
+	rooms, err := s.scheduledRooms(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range rooms {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		room := &rooms[i]
+		loc := room.Schedule.Location(s.roomService.ScheduleLocation())
+
+		if err := s.applyBoundary(ctx, room, now, loc); err != nil {
+			log.Printf("[RoomScheduler] ❌ Failed to apply schedule for room %s: %v", room.ID.Hex(), err)
+			continue
+		}
+		if room.IsActive() {
+			s.warnClosing(room, now, loc)
+		}
+	}
+	s.pruneWarnings(now)
+	return nil
+}
+
+// UpcomingRooms ห้องที่จะเปิดภายใน within (ใช้เป็น UpcomingRoomsSource ของ cache warmer)
+func (s *RoomScheduler) UpcomingRooms(ctx context.Context, within time.Duration) ([]primitive.ObjectID, error) {
+	rooms, err := s.scheduledRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var ids []primitive.ObjectID
+	for _, room := range rooms {
+		loc := room.Schedule.Location(s.roomService.ScheduleLocation())
+		if next := room.Schedule.NextOpen(now, loc); next != nil && !next.After(now.Add(within)) {
+			ids = append(ids, room.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *RoomScheduler) scheduledRooms(ctx context.Context) ([]model.Room, error) {
+	// ใช้แค่ field ที่ scheduler ต้องใช้ (ไม่โหลด members ของทุกห้อง)
+	cursor, err := s.rooms.Find(ctx, bson.M{"schedule.windows.0": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"_id": 1, "status": 1, "schedule": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled rooms: %w", err)
+	}
+	var rooms []model.Room
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduled rooms: %w", err)
+	}
+	return rooms, nil
+}
+
+// applyBoundary ใช้ boundary ล่าสุดที่ยังไม่เคยใช้ (เปลี่ยนสถานะด้วยมือหลัง boundary จึงคงอยู่จนถึง boundary ถัดไป)
+func (s *RoomScheduler) applyBoundary(ctx context.Context, room *model.Room, now time.Time, loc *time.Location) error {
+	boundary, ok := room.Schedule.LastBoundary(now, loc)
+	if !ok {
+		return nil
+	}
+	applied := room.Schedule.AppliedAt
+	if applied != nil && !boundary.At.After(*applied) {
+		return nil
+	}
+
+	// claim boundary: instance อื่นที่ claim ไปก่อนจะทำให้ filter ไม่ match
+	filter := bson.M{"_id": room.ID, "schedule.applied_at": bson.M{"$exists": false}}
+	if applied != nil {
+		filter = bson.M{"_id": room.ID, "schedule.applied_at": *applied}
+	}
+	res, err := s.rooms.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"schedule.applied_at": boundary.At}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return nil
+	}
+	room.Schedule.AppliedAt = &boundary.At
+
+	status := model.RoomStatusInactive
+	if boundary.Open {
+		status = model.RoomStatusActive
+	}
+	if room.Status == status {
+		roomScheduleTransitions.Inc(status, "unchanged")
+		return nil
+	}
+
+	updated, err := s.roomService.SetRoomStatus(ctx, room.ID, status)
+	if err != nil {
+		roomScheduleTransitions.Inc(status, "error")
+		return err
+	}
+	roomScheduleTransitions.Inc(status, "applied")
+	log.Printf("[RoomScheduler] 🗓️ Room %s is now %s (boundary %s)", room.ID.Hex(), status, boundary.At.Format(time.RFC3339))
+	room.Status = updated.Status
+	return nil
+}
+
+// warnClosing แจ้ง client ในห้อง (ของ instance นี้) เมื่อเหลือเวลาถึงแต่ละ threshold ของ ROOM_SCHEDULE_WARNINGS
+func (s *RoomScheduler) warnClosing(room *model.Room, now time.Time, loc *time.Location) {
+	next, ok := room.Schedule.NextBoundary(now, loc)
+	if !ok || next.Open {
+		return
+	}
+	remaining := next.At.Sub(now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// ส่งครั้งเดียวต่อรอบ แม้ tick จะข้ามหลาย threshold (เช่น scheduler เพิ่ง start)
+	send := false
+	for _, threshold := range s.cfg.Warnings {
+		if remaining > threshold {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d|%s", room.ID.Hex(), next.At.Unix(), threshold)
+		if _, sent := s.warned[key]; !sent {
+			s.warned[key] = next.At
+			send = true
+		}
+	}
+	if !send {
+		return
+	}
+
+	secondsLeft := int(remaining.Round(time.Second).Seconds())
+	payload, err := json.Marshal(map[string]interface{}{
+		"type": roomClosingEventType,
+		"data": map[string]interface{}{
+			"roomId":      room.ID.Hex(),
+			"closesAt":    next.At,
+			"secondsLeft": secondsLeft,
+			"message":     fmt.Sprintf("This room will close in %s", formatCountdown(remaining)),
+		},
+	})
+	if err != nil {
+		return
+	}
+	s.hub.BroadcastToRoom(room.ID.Hex(), payload)
+	log.Printf("[RoomScheduler] ⏳ Room %s closes in %ds", room.ID.Hex(), secondsLeft)
+}
+
+func (s *RoomScheduler) pruneWarnings(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, closeAt := range s.warned {
+		if closeAt.Before(now) {
+			delete(s.warned, key)
+		}
+	}
+}
+
+// formatCountdown เวลาที่เหลือแบบอ่านง่าย: ต่ำกว่านาทีแสดงเป็นวินาที (45s) นอกนั้นปัดเป็นนาที (5m, 1h30m)
+func formatCountdown(d time.Duration) string {
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", max(seconds, 0))
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+}
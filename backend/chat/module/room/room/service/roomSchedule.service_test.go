@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatCountdown(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 0, want: "0s"},
+		{in: -time.Second, want: "0s"},
+		{in: 10 * time.Second, want: "10s"},
+		{in: 30 * time.Second, want: "30s"},
+		{in: 50 * time.Second, want: "50s"},
+		{in: 59*time.Second + 400*time.Millisecond, want: "59s"},
+		{in: 59*time.Second + 600*time.Millisecond, want: "1m"},
+		{in: time.Minute, want: "1m"},
+		{in: 4*time.Minute + 50*time.Second, want: "5m"},
+		{in: 10 * time.Minute, want: "10m"},
+		{in: 60 * time.Minute, want: "1h"},
+		{in: 90 * time.Minute, want: "1h30m"},
+		{in: 2*time.Hour + 10*time.Minute, want: "2h10m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in.String(), func(t *testing.T) {
+			if got := formatCountdown(tt.in); got != tt.want {
+				t.Errorf("formatCountdown(%s) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
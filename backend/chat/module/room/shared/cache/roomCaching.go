@@ -2,6 +2,7 @@ package cache
 
 import (
 	"chat/module/room/room/model"
+	"chat/pkg/core/metrics"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -54,13 +55,16 @@ func (s *RoomCacheService) GetRoom(ctx context.Context, roomID string) (*model.R
 	// ดึง data จาก cache
 	data, err := s.redis.Get(ctx, key).Result()
 	if err == redis.Nil {
+		metrics.CacheRequests.Inc(metrics.CacheRoom, metrics.CacheMiss)
 		return nil, nil
 	}
 
 	// ตรวจสอบ error
 	if err != nil {
+		metrics.CacheRequests.Inc(metrics.CacheRoom, metrics.CacheError)
 		return nil, err
 	}
+	metrics.CacheRequests.Inc(metrics.CacheRoom, metrics.CacheHit)
 
 	// แปลง data เป็น model.Room
 	var room model.Room
@@ -107,13 +111,16 @@ func (s *RoomCacheService) GetMembers(ctx context.Context, roomID string) ([]pri
 	key := s.membersKey(roomID)
 	data, err := s.redis.Get(ctx, key).Result()
 	if err == redis.Nil {
+		metrics.CacheRequests.Inc(metrics.CacheRoomMembers, metrics.CacheMiss)
 		return nil, nil
 	}
 
 	// ตรวจสอบ error
 	if err != nil {
+		metrics.CacheRequests.Inc(metrics.CacheRoomMembers, metrics.CacheError)
 		return nil, err
 	}
+	metrics.CacheRequests.Inc(metrics.CacheRoomMembers, metrics.CacheHit)
 
 	// แปลง data เป็น model.Room
 	var members []primitive.ObjectID
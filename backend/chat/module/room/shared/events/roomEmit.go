@@ -322,3 +322,89 @@ func (e *RoomEventEmitter) EmitRoomStatusChanged(ctx context.Context, roomID pri
 		log.Printf("[RoomEvent] Successfully emitted room_status_changed event for room %s, status: %s", roomID.Hex(), newStatus)
 	}
 }
+
+// **NEW: ส่ง event ไปยัง topic สำหรับ room_slow_mode_changed (slowMode = nil คือปิด)**
+func (e *RoomEventEmitter) EmitRoomSlowModeChanged(ctx context.Context, roomID primitive.ObjectID, slowMode *model.SlowMode) {
+	if !roomHelper.ValidateRoomID(roomID, "room_slow_mode_changed") {
+		return
+	}
+
+	payloadBytes, ok := roomHelper.MustMarshal(SlowModePayload(roomID, slowMode), "room_slow_mode_changed payload")
+	if !ok {
+		return
+	}
+
+	event := model.RoomEvent{
+		Type:    "room_slow_mode_changed",
+		RoomID:  roomID.Hex(),
+		Payload: payloadBytes,
+	}
+
+	if err := kafka.EnsureTopic(e.brokers, GetRoomTopic(roomID.Hex()), 1); err != nil {
+		log.Printf("[ERROR] Failed to ensure room topic: %v", err)
+		return
+	}
+
+	if err := e.emitEvent(ctx, event); err != nil {
+		roomHelper.EmitErrorLog(ctx, fmt.Sprintf("room_slow_mode_changed room=%s", roomID.Hex()), err)
+	} else {
+		log.Printf("[RoomEvent] Successfully emitted room_slow_mode_changed event for room %s", roomID.Hex())
+	}
+}
+
+// SlowModePayload payload ของ room_slow_mode_changed
+func SlowModePayload(roomID primitive.ObjectID, slowMode *model.SlowMode) map[string]interface{} {
+	payload := map[string]interface{}{
+		"roomId":      roomID.Hex(),
+		"enabled":     slowMode != nil,
+		"seconds":     0,
+		"exemptRoles": []string{},
+		"timestamp":   time.Now(),
+	}
+	if slowMode != nil {
+		payload["seconds"] = slowMode.Seconds
+		if slowMode.ExemptRoles != nil {
+			payload["exemptRoles"] = slowMode.ExemptRoles
+		}
+	}
+	return payload
+}
+
+// **NEW: EmitRoomMemberRoleChanged แจ้งทุก instance เมื่อ role ในห้องของสมาชิกเปลี่ยน**
+func (e *RoomEventEmitter) EmitRoomMemberRoleChanged(ctx context.Context, roomID, userID primitive.ObjectID, role string) {
+	if !roomHelper.ValidateRoomID(roomID, "room_member_role_changed") {
+		return
+	}
+
+	payloadBytes, ok := roomHelper.MustMarshal(MemberRolePayload(roomID, userID, role), "room_member_role_changed payload")
+	if !ok {
+		return
+	}
+
+	event := model.RoomEvent{
+		Type:    "room_member_role_changed",
+		RoomID:  roomID.Hex(),
+		Payload: payloadBytes,
+	}
+
+	if err := kafka.EnsureTopic(e.brokers, GetRoomTopic(roomID.Hex()), 1); err != nil {
+		log.Printf("[ERROR] Failed to ensure room topic: %v", err)
+		return
+	}
+
+	if err := e.emitEvent(ctx, event); err != nil {
+		roomHelper.EmitErrorLog(ctx, fmt.Sprintf("room_member_role_changed room=%s user=%s", roomID.Hex(), userID.Hex()), err)
+	} else {
+		log.Printf("[RoomEvent] Successfully emitted room_member_role_changed event for room %s", roomID.Hex())
+	}
+}
+
+// MemberRolePayload payload ของ room_member_role_changed
+func MemberRolePayload(roomID, userID primitive.ObjectID, role string) map[string]interface{} {
+	return map[string]interface{}{
+		"roomId":    roomID.Hex(),
+		"userId":    userID.Hex(),
+		"role":      role,
+		"timestamp": time.Now(),
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"chat/pkg/common"
 	"chat/pkg/middleware"
 	"chat/pkg/utils"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -193,6 +195,25 @@ func (h *RoomControllerHelper) ParseAndUpdateRoom(
 		members = objIDs
 	}
 
+	// **NEW: slow mode (ส่งเฉพาะ field ที่จะเปลี่ยน) ตรวจก่อน upload รูปเพื่อไม่ให้เหลือไฟล์ค้าง**
+	var slowModeSeconds *int
+	if v := ctx.FormValue("slowModeSeconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 || seconds > model.MaxSlowModeSeconds {
+			return nil, fmt.Errorf("%w: slowModeSeconds must be between 0 and %d", model.ErrInvalidSlowMode, model.MaxSlowModeSeconds)
+		}
+		slowModeSeconds = &seconds
+	}
+	var slowModeExemptRoles []string
+	if form != nil && form.Value["slowModeExemptRoles"] != nil {
+		slowModeExemptRoles = []string{}
+		for _, role := range form.Value["slowModeExemptRoles"] {
+			if role = strings.TrimSpace(role); role != "" {
+				slowModeExemptRoles = append(slowModeExemptRoles, role)
+			}
+		}
+	}
+
 	// Handle image
 	imagePath := room.Image
 	file, err := ctx.FormFile("image")
@@ -220,6 +241,9 @@ func (h *RoomControllerHelper) ParseAndUpdateRoom(
 		Members:   stringMembers,
 		Image:     imagePath,
 		CreatedBy: createdBy,
+
+		SlowModeSeconds:     slowModeSeconds,
+		SlowModeExemptRoles: slowModeExemptRoles,
 	}
 
 	return updateDto, nil
@@ -231,21 +255,28 @@ func (h *RoomControllerHelper) HandleJoinRoomError(
 	err error,
 	validationHelper *RoomValidationHelper,
 ) error {
-	if strings.Contains(err.Error(), "not allowed to join group rooms") {
+	if errors.Is(err, model.ErrGroupRoomRestricted) {
 		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
 			"message": "Access denied: Users with role 'user' cannot join group rooms",
 			"error":   "ROLE_RESTRICTION",
 		})
 	}
-	if strings.Contains(err.Error(), "full capacity") {
+	if errors.Is(err, model.ErrRoomFull) {
 		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"success": false,
 			"message": "Room is at full capacity",
 			"error":   "ROOM_FULL",
 		})
 	}
-	if strings.Contains(err.Error(), "room not found") {
+	if errors.Is(err, model.ErrRoomInactive) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+			"error":   "ROOM_INACTIVE",
+		})
+	}
+	if errors.Is(err, model.ErrRoomNotFound) {
 		return validationHelper.BuildNotFoundErrorResponse(ctx, "Room")
 	}
 	return validationHelper.BuildInternalErrorResponse(ctx, err)
@@ -122,6 +122,7 @@ func GetRoomStatus(ctx context.Context, room *model.Room, cache *sharedCache.Roo
 		"activeCount": activeCount,
 		"activeUsers": activeUsers,
 		"lastActive":  room.UpdatedAt,
+		"slowMode":    room.SlowMode, // nil = ปิด
 	}, nil
 }
 
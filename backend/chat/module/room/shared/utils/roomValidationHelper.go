@@ -2,6 +2,7 @@ package utils
 
 import (
 	"chat/module/room/room/dto"
+	uploadUtils "chat/pkg/utils"
 	"chat/pkg/validator"
 	"fmt"
 	"mime/multipart"
@@ -73,7 +74,9 @@ func (h *RoomValidationHelper) ValidateImageUpload(file *multipart.FileHeader) e
 		return nil // image is optional
 	}
 
-	return h.imageValidator.ValidateFile(file)
+	// ใช้ limit ล่าสุดของ module room (ปรับได้ผ่าน runtime settings)
+	config := uploadUtils.GetModuleConfig("room")
+	return validator.ValidateImageUpload(file, config.MaxSize, config.AllowedTypes)
 }
 
 // ValidateRoomType ตรวจสอบ room type
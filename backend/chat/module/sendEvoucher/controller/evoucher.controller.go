@@ -4,9 +4,11 @@ import (
 	"chat/module/chat/model"
 	"chat/module/sendEvoucher/dto"
 	"chat/module/sendEvoucher/service"
+	"chat/pkg/core/circuitbreaker"
 	"chat/pkg/decorators"
 	"chat/pkg/middleware"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -204,6 +206,12 @@ func (c *EvoucherController) handleClaimEvoucherInChat(ctx *fiber.Ctx) error {
 
 	// Claim evoucher through NestJS API
 	claimResult, err := c.evoucherService.ClaimEvoucherThroughNestJS(ctx.Context(), userID, evoucherId, claimDto.ClaimURL, jwtToken)
+	if errors.Is(err, circuitbreaker.ErrOpen) || errors.Is(err, circuitbreaker.ErrTooManyCalls) {
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"message": "Evoucher service is temporarily unavailable, please try again later",
+		})
+	}
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
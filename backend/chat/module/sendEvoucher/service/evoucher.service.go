@@ -7,6 +7,7 @@ import (
 	"chat/module/notification/service"
 	restrctionService "chat/module/restriction/service"
 	userModel "chat/module/user/model"
+	"chat/pkg/core/circuitbreaker"
 	"chat/pkg/core/kafka"
 	"chat/pkg/database/queries"
 	serviceHelper "chat/pkg/helpers/service"
@@ -16,6 +17,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -88,6 +90,11 @@ func (s *EvoucherService) SendEvoucherMessage(ctx context.Context, userID, roomI
 		Timestamp:    time.Now(),
 	}
 
+	// **NEW: ลำดับต่อห้องก่อนบันทึก**
+	if s.emitter != nil {
+		s.emitter.Sequencer().Assign(ctx, msg)
+	}
+
 	// Create message in database
 	result, err := s.Create(ctx, *msg)
 	if err != nil {
@@ -195,8 +202,8 @@ func (s *EvoucherService) ClaimEvoucher(ctx context.Context, userID, messageID p
 	updateResult, err := s.db.Collection("chat-messages").UpdateOne(ctx, 
 		bson.M{"_id": messageID}, 
 		bson.M{
-			"$set": bson.M{
-				"evoucher_info.claimed_by": msg.EvoucherInfo.ClaimedBy,
+			"$addToSet": bson.M{
+				"evoucher_info.claimed_by": userID,
 			},
 		})
 	if err != nil {
@@ -210,12 +217,16 @@ func (s *EvoucherService) ClaimEvoucher(ctx context.Context, userID, messageID p
 	log.Printf("[EvoucherService] Successfully claimed evoucher for user %s in message %s", 
 		userID.Hex(), messageID.Hex())
 
-	// Update cache
+	// Update cache (แก้ claimed_by ของข้อความเดิมใน cache แทนการเพิ่มสำเนาใหม่)
+	// เพิ่มเฉพาะ user นี้เข้าไปในค่าที่อ่านภายใต้ WATCH เพื่อไม่ทับ claim ของคนอื่นที่เกิดพร้อมกัน
 	if s.cache != nil {
-		enriched := model.ChatMessageEnriched{
-			ChatMessage: msg,
-		}
-		if err := s.cache.SaveMessage(ctx, msg.RoomID.Hex(), &enriched); err != nil {
+		err := s.cache.UpdateMessage(ctx, msg.RoomID.Hex(), messageID.Hex(), func(cached *model.ChatMessageEnriched) {
+			info := cached.ChatMessage.EvoucherInfo
+			if info != nil && !slices.Contains(info.ClaimedBy, userID) {
+				info.ClaimedBy = append(info.ClaimedBy, userID)
+			}
+		})
+		if err != nil {
 			log.Printf("[EvoucherService] Failed to update cache after claim: %v", err)
 		}
 	}
@@ -292,12 +303,26 @@ func (s *EvoucherService) ClaimEvoucherThroughNestJS(ctx context.Context, userID
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
 
+	// **NEW: ผ่าน circuit breaker นับเฉพาะ network error และ 5xx (4xx คือผลปกติ เช่น claim ซ้ำ/หมด)**
+	breaker := circuitbreaker.Get(circuitbreaker.EvoucherClaimNestJS)
+	if err := breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("%w: evoucher service is temporarily unavailable, retry in %v",
+			err, breaker.RetryAfter().Round(time.Second))
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
+		breaker.Done(err)
 		return nil, fmt.Errorf("failed to make request to NestJS: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 500 {
+		breaker.Done(fmt.Errorf("NestJS API returned %s", resp.Status))
+	} else {
+		breaker.Done(nil)
+	}
+
 	// Read response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
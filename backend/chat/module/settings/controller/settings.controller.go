@@ -0,0 +1,119 @@
+package controller
+
+import (
+	settingsModel "chat/module/settings/model"
+	settingsService "chat/module/settings/service"
+	"chat/pkg/decorators"
+	"chat/pkg/middleware"
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	SettingsController struct {
+		*decorators.BaseController
+		settingsService *settingsService.SettingsService
+		rbac            middleware.IRBACMiddleware
+	}
+)
+
+func NewSettingsController(
+	app fiber.Router,
+	settingsService *settingsService.SettingsService,
+	rbac middleware.IRBACMiddleware,
+) *SettingsController {
+	controller := &SettingsController{
+		BaseController:  decorators.NewBaseController(app, ""),
+		settingsService: settingsService,
+		rbac:            rbac,
+	}
+
+	controller.setupRoutes()
+	return controller
+}
+
+func (c *SettingsController) setupRoutes() {
+	c.Get("/admin/settings", c.handleGetSettings, c.rbac.RequireAdministrator())
+	c.Get("/admin/settings/defaults", c.handleGetDefaultSettings, c.rbac.RequireAdministrator())
+	c.Put("/admin/settings", c.handleReplaceSettings, c.rbac.RequireAdministrator())
+	c.Patch("/admin/settings", c.handlePatchSettings, c.rbac.RequireAdministrator())
+
+	c.SetupRoutes()
+}
+
+// handleGetSettings คืน settings ที่มีผลอยู่
+func (c *SettingsController) handleGetSettings(ctx *fiber.Ctx) error {
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    c.settingsService.Get(),
+	})
+}
+
+// handleGetDefaultSettings คืนค่า default (ใช้เป็นแม่แบบตอน reset)
+func (c *SettingsController) handleGetDefaultSettings(ctx *fiber.Ctx) error {
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    settingsModel.DefaultRuntimeSettings(),
+	})
+}
+
+// handleReplaceSettings แทนที่ settings ทั้งชุด body ต้องมี version ปัจจุบัน
+func (c *SettingsController) handleReplaceSettings(ctx *fiber.Ctx) error {
+	var body settingsModel.RuntimeSettings
+	if err := json.Unmarshal(ctx.Body(), &body); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	updated, err := c.settingsService.Update(ctx.Context(), &body, body.Version, c.actor(ctx))
+	return c.respondUpdate(ctx, updated, err)
+}
+
+// handlePatchSettings แก้เฉพาะ field ที่ส่งมา
+func (c *SettingsController) handlePatchSettings(ctx *fiber.Ctx) error {
+	if !json.Valid(ctx.Body()) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	updated, err := c.settingsService.Patch(ctx.Context(), ctx.Body(), c.actor(ctx))
+	return c.respondUpdate(ctx, updated, err)
+}
+
+func (c *SettingsController) respondUpdate(ctx *fiber.Ctx, updated *settingsModel.RuntimeSettings, err error) error {
+	if errors.Is(err, settingsService.ErrVersionConflict) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+			"data":    c.settingsService.Get(),
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update settings",
+			"error":   err.Error(),
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "Settings updated",
+		"data":    updated,
+	})
+}
+
+func (c *SettingsController) actor(ctx *fiber.Ctx) string {
+	userID, err := c.rbac.ExtractUserIDFromContext(ctx)
+	if err != nil {
+		return "unknown"
+	}
+	return userID
+}
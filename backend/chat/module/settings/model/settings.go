@@ -0,0 +1,303 @@
+package model
+
+import (
+	roomModel "chat/module/room/room/model"
+	"chat/pkg/core/connection"
+	"chat/pkg/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RuntimeSettingsID คือ _id ของเอกสาร settings (มีเอกสารเดียวต่อ cluster)
+const RuntimeSettingsID = "runtime"
+
+// การจัดการข้อความที่มีคำต้องห้าม
+const (
+	ModerationActionMask   = "mask"   // แทนคำต้องห้ามด้วย *
+	ModerationActionReject = "reject" // ไม่รับข้อความนั้นเลย
+)
+
+// DefaultUploadKey ใช้กับ upload handler ที่สร้างจาก DefaultImageConfig (chat upload, sticker)
+const DefaultUploadKey = "default"
+
+const (
+	MaxHistoryLength    = 500
+	MaxMessageLength    = 10000
+	MaxUploadSizeBytes  = 50 * 1024 * 1024
+	MaxBlockedWordCount = 5000
+)
+
+type (
+	// RuntimeSettings ค่าที่ปรับได้ระหว่างงานโดยไม่ต้อง restart (เก็บใน Mongo, cache ใน Redis)
+	RuntimeSettings struct {
+		ID        string    `bson:"_id" json:"-"`
+		Version   int64     `bson:"version" json:"version"`
+		UpdatedAt time.Time `bson:"updated_at" json:"updatedAt"`
+		UpdatedBy string    `bson:"updated_by,omitempty" json:"updatedBy,omitempty"`
+
+		Messages    MessageSettings            `bson:"messages" json:"messages"`
+		Connections ConnectionSettings         `bson:"connections" json:"connections"`
+		Uploads     map[string]UploadSettings  `bson:"uploads" json:"uploads"`
+		Moderation  ModerationSettings         `bson:"moderation" json:"moderation"`
+		Features    FeatureFlags               `bson:"features" json:"features"`
+		Retention   map[string]RetentionPolicy `bson:"retention" json:"retention"` // key = ประเภทห้อง
+
+		filterOnce sync.Once
+		filter     *regexp.Regexp
+	}
+
+	// MessageSettings จำกัดข้อความแยกตามประเภทห้อง
+	MessageSettings struct {
+		HistoryLength int                          `bson:"history_length" json:"historyLength"`
+		RoomTypes     map[string]RoomMessageLimits `bson:"room_types" json:"roomTypes"`
+	}
+
+	// RoomMessageLimits ค่า 0 = ไม่จำกัด
+	RoomMessageLimits struct {
+		MaxLength        int `bson:"max_length" json:"maxLength"`                 // จำนวนตัวอักษรสูงสุดต่อข้อความ
+		PerUserPerMinute int `bson:"per_user_per_minute" json:"perUserPerMinute"` // จำนวนข้อความต่อ user ต่อห้องต่อนาที
+	}
+
+	// ConnectionSettings ค่าที่ใช้กับ connection.ConnectionManager
+	ConnectionSettings struct {
+		MaxConnections       int32   `bson:"max_connections" json:"maxConnections"`
+		MaxConnectionsPerIP  int32   `bson:"max_connections_per_ip" json:"maxConnectionsPerIp"`
+		ConnectRatePerSecond float64 `bson:"connect_rate_per_second" json:"connectRatePerSecond"`
+		ConnectBurst         int     `bson:"connect_burst" json:"connectBurst"`
+		MaxRoomsPerSession   int     `bson:"max_rooms_per_session" json:"maxRoomsPerSession"`
+	}
+
+	// UploadSettings ค่าที่ใช้แทน utils.ModuleConfig ของแต่ละ module
+	UploadSettings struct {
+		MaxSizeBytes int64    `bson:"max_size_bytes" json:"maxSizeBytes"`
+		AllowedTypes []string `bson:"allowed_types" json:"allowedTypes"`
+	}
+
+	// ModerationSettings รายการคำต้องห้าม
+	ModerationSettings struct {
+		BlockedWords []string `bson:"blocked_words" json:"blockedWords"`
+		Action       string   `bson:"action" json:"action"`
+	}
+
+	// RetentionPolicy กฎการเก็บข้อความของประเภทห้อง ค่า 0 = ไม่ทำ (ทำงานเมื่อเปิด RETENTION_ENABLED)
+	RetentionPolicy struct {
+		PurgeUnsentAfterDays     int `bson:"purge_unsent_after_days" json:"purgeUnsentAfterDays"`         // ลบถาวรข้อความที่ unsend ไปแล้วเกินกี่วัน
+		ArchiveAfterDays         int `bson:"archive_after_days" json:"archiveAfterDays"`                  // ย้ายข้อความที่เก่ากว่ากี่วันไป archive
+		ArchiveInactiveAfterDays int `bson:"archive_inactive_after_days" json:"archiveInactiveAfterDays"` // ย้ายทั้งห้องไป archive เมื่อไม่มีข้อความใหม่กี่วัน
+	}
+
+	// FeatureFlags เปิด/ปิดความสามารถของห้องแชท
+	FeatureFlags struct {
+		Mentions bool `bson:"mentions" json:"mentions"`
+		Replies  bool `bson:"replies" json:"replies"`
+		Unsend   bool `bson:"unsend" json:"unsend"`
+		Stickers bool `bson:"stickers" json:"stickers"`
+	}
+)
+
+// DefaultRuntimeSettings ค่าเริ่มต้นเท่ากับค่าที่ hard-code ไว้เดิม
+func DefaultRuntimeSettings() *RuntimeSettings {
+	conn := connection.DefaultConfig()
+
+	uploads := make(map[string]UploadSettings, len(utils.ModuleConfig)+1)
+	for module, cfg := range utils.ModuleConfig {
+		uploads[module] = UploadSettings{MaxSizeBytes: cfg.MaxSize, AllowedTypes: append([]string(nil), cfg.AllowedTypes...)}
+	}
+	defaultImage := utils.DefaultImageConfig()
+	uploads[DefaultUploadKey] = UploadSettings{MaxSizeBytes: defaultImage.MaxSize, AllowedTypes: defaultImage.AllowedTypes}
+
+	return &RuntimeSettings{
+		ID: RuntimeSettingsID,
+		Messages: MessageSettings{
+			HistoryLength: 50,
+			RoomTypes: map[string]RoomMessageLimits{
+				roomModel.RoomTypeNormal:   {},
+				roomModel.RoomTypeReadOnly: {},
+				roomModel.RoomTypeMC:       {},
+			},
+		},
+		Connections: ConnectionSettings{
+			MaxConnections:       conn.MaxConnections,
+			MaxConnectionsPerIP:  conn.MaxConnectionsPerIP,
+			ConnectRatePerSecond: float64(conn.RateLimit),
+			ConnectBurst:         conn.RateBurst,
+			MaxRoomsPerSession:   conn.MaxRoomsPerSession,
+		},
+		Uploads: uploads,
+		Moderation: ModerationSettings{
+			BlockedWords: []string{},
+			Action:       ModerationActionMask,
+		},
+		Features: FeatureFlags{
+			Mentions: true,
+			Replies:  true,
+			Unsend:   true,
+			Stickers: true,
+		},
+		Retention: map[string]RetentionPolicy{
+			roomModel.RoomTypeNormal:   {PurgeUnsentAfterDays: 30, ArchiveInactiveAfterDays: 90},
+			roomModel.RoomTypeReadOnly: {PurgeUnsentAfterDays: 30, ArchiveInactiveAfterDays: 90},
+			roomModel.RoomTypeMC:       {PurgeUnsentAfterDays: 30, ArchiveInactiveAfterDays: 90},
+		},
+	}
+}
+
+// Clone คัดลอกแบบ deep copy (settings ที่ใช้งานอยู่ต้องไม่ถูกแก้ในที่)
+func (s *RuntimeSettings) Clone() *RuntimeSettings {
+	c := &RuntimeSettings{
+		ID:          s.ID,
+		Version:     s.Version,
+		UpdatedAt:   s.UpdatedAt,
+		UpdatedBy:   s.UpdatedBy,
+		Messages:    MessageSettings{HistoryLength: s.Messages.HistoryLength},
+		Connections: s.Connections,
+		Moderation: ModerationSettings{
+			BlockedWords: append([]string{}, s.Moderation.BlockedWords...),
+			Action:       s.Moderation.Action,
+		},
+		Features: s.Features,
+	}
+
+	c.Messages.RoomTypes = make(map[string]RoomMessageLimits, len(s.Messages.RoomTypes))
+	for k, v := range s.Messages.RoomTypes {
+		c.Messages.RoomTypes[k] = v
+	}
+	c.Retention = make(map[string]RetentionPolicy, len(s.Retention))
+	for k, v := range s.Retention {
+		c.Retention[k] = v
+	}
+	c.Uploads = make(map[string]UploadSettings, len(s.Uploads))
+	for k, v := range s.Uploads {
+		c.Uploads[k] = UploadSettings{MaxSizeBytes: v.MaxSizeBytes, AllowedTypes: append([]string(nil), v.AllowedTypes...)}
+	}
+	return c
+}
+
+// Normalize ตัดช่องว่าง/คำซ้ำใน blocked words และเติมค่าที่ขาด
+func (s *RuntimeSettings) Normalize() {
+	s.ID = RuntimeSettingsID
+
+	seen := make(map[string]bool, len(s.Moderation.BlockedWords))
+	words := make([]string, 0, len(s.Moderation.BlockedWords))
+	for _, w := range s.Moderation.BlockedWords {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	s.Moderation.BlockedWords = words
+
+	if s.Moderation.Action == "" {
+		s.Moderation.Action = ModerationActionMask
+	}
+	if s.Messages.RoomTypes == nil {
+		s.Messages.RoomTypes = map[string]RoomMessageLimits{}
+	}
+	if s.Uploads == nil {
+		s.Uploads = map[string]UploadSettings{}
+	}
+	if s.Retention == nil {
+		s.Retention = map[string]RetentionPolicy{}
+	}
+}
+
+// Validate ตรวจค่าทั้งหมดและคืน error ที่รวมทุกปัญหา
+func (s *RuntimeSettings) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(s.Messages.HistoryLength >= 1 && s.Messages.HistoryLength <= MaxHistoryLength,
+		"messages.historyLength must be between 1 and %d", MaxHistoryLength)
+	for roomType, limits := range s.Messages.RoomTypes {
+		check(roomModel.ValidateRoomType(roomType), "messages.roomTypes.%s: unknown room type", roomType)
+		check(limits.MaxLength >= 0 && limits.MaxLength <= MaxMessageLength,
+			"messages.roomTypes.%s.maxLength must be between 0 and %d", roomType, MaxMessageLength)
+		check(limits.PerUserPerMinute >= 0, "messages.roomTypes.%s.perUserPerMinute must be >= 0", roomType)
+	}
+
+	c := s.Connections
+	check(c.MaxConnections > 0, "connections.maxConnections must be > 0")
+	check(c.MaxConnectionsPerIP >= 0, "connections.maxConnectionsPerIp must be >= 0")
+	check(c.ConnectRatePerSecond >= 0, "connections.connectRatePerSecond must be >= 0")
+	check(c.ConnectRatePerSecond == 0 || c.ConnectBurst > 0, "connections.connectBurst must be > 0 when connectRatePerSecond is set")
+	check(c.MaxRoomsPerSession >= 0, "connections.maxRoomsPerSession must be >= 0")
+
+	for module, upload := range s.Uploads {
+		_, known := utils.ModuleConfig[module]
+		check(known || module == DefaultUploadKey, "uploads.%s: unknown upload module", module)
+		check(upload.MaxSizeBytes > 0 && upload.MaxSizeBytes <= MaxUploadSizeBytes,
+			"uploads.%s.maxSizeBytes must be between 1 and %d", module, MaxUploadSizeBytes)
+		check(len(upload.AllowedTypes) > 0, "uploads.%s.allowedTypes must not be empty", module)
+	}
+
+	for roomType, policy := range s.Retention {
+		check(roomModel.ValidateRoomType(roomType), "retention.%s: unknown room type", roomType)
+		check(policy.PurgeUnsentAfterDays >= 0, "retention.%s.purgeUnsentAfterDays must be >= 0", roomType)
+		check(policy.ArchiveAfterDays >= 0, "retention.%s.archiveAfterDays must be >= 0", roomType)
+		check(policy.ArchiveInactiveAfterDays >= 0, "retention.%s.archiveInactiveAfterDays must be >= 0", roomType)
+	}
+
+	check(len(s.Moderation.BlockedWords) <= MaxBlockedWordCount, "moderation.blockedWords must have at most %d entries", MaxBlockedWordCount)
+	check(s.Moderation.Action == ModerationActionMask || s.Moderation.Action == ModerationActionReject,
+		"moderation.action must be %q or %q", ModerationActionMask, ModerationActionReject)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid settings: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// LimitsFor คืน limit ของประเภทห้อง (ไม่มี = ไม่จำกัด)
+func (s *RuntimeSettings) LimitsFor(roomType string) RoomMessageLimits {
+	return s.Messages.RoomTypes[roomType]
+}
+
+// ExceedsMaxLength ตรวจความยาวข้อความตามประเภทห้อง (นับเป็นตัวอักษร ไม่ใช่ byte)
+func (s *RuntimeSettings) ExceedsMaxLength(roomType, text string) bool {
+	limit := s.LimitsFor(roomType).MaxLength
+	return limit > 0 && utf8.RuneCountInString(text) > limit
+}
+
+// FilterMessage ตรวจคำต้องห้าม คืนข้อความที่ mask แล้ว และ blocked = true ถ้า action เป็น reject
+func (s *RuntimeSettings) FilterMessage(text string) (filtered string, matched bool, blocked bool) {
+	re := s.blockedWordsPattern()
+	if re == nil || !re.MatchString(text) {
+		return text, false, false
+	}
+	if s.Moderation.Action == ModerationActionReject {
+		return text, true, true
+	}
+	return re.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	}), true, false
+}
+
+// blockedWordsPattern compile regex ครั้งเดียวต่อ settings version
+// ภาษาไทยไม่มีเว้นวรรคระหว่างคำ จึงจับเป็น substring แบบไม่สนตัวพิมพ์
+func (s *RuntimeSettings) blockedWordsPattern() *regexp.Regexp {
+	s.filterOnce.Do(func() {
+		if len(s.Moderation.BlockedWords) == 0 {
+			return
+		}
+		words := append([]string(nil), s.Moderation.BlockedWords...)
+		// คำยาวก่อน เพื่อให้ mask คำที่ยาวที่สุดที่ match
+		sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		s.filter = regexp.MustCompile("(?i)" + strings.Join(words, "|"))
+	})
+	return s.filter
+}
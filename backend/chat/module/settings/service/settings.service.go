@@ -0,0 +1,270 @@
+package service
+
+import (
+	settingsModel "chat/module/settings/model"
+	"chat/pkg/core/audit"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// SettingsCacheKey เก็บ settings ล่าสุดเป็น JSON ให้ instance อื่นโหลดได้โดยไม่ต้องถาม Mongo
+	SettingsCacheKey = "chat:settings:runtime"
+	// SettingsChangedChannel ประกาศ version ใหม่ให้ทุก instance
+	// ใช้ Redis pub/sub แทน Kafka เพราะ Kafka consumer group "chat-service" ส่งให้ instance เดียว
+	SettingsChangedChannel = "chat:settings:changed"
+
+	settingsCollection = "chat-settings"
+	// settingsResyncInterval กันกรณีพลาด pub/sub message (เช่น Redis reconnect)
+	settingsResyncInterval = time.Minute
+)
+
+var (
+	// ErrVersionConflict มีคนแก้ settings ไปก่อนแล้ว ต้องโหลดใหม่แล้วลองอีกครั้ง
+	ErrVersionConflict = errors.New("settings were modified by someone else, reload and retry")
+
+	current atomic.Pointer[settingsModel.RuntimeSettings]
+)
+
+type (
+	// SettingsService จัดการ runtime settings: Mongo เป็นต้นฉบับ, Redis เป็น cache + ช่องแจ้งเปลี่ยน
+	SettingsService struct {
+		collection *mongo.Collection
+		redis      *redis.Client
+
+		mu        sync.Mutex
+		listeners []func(*settingsModel.RuntimeSettings)
+
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+)
+
+// Current คืน settings ที่มีผลอยู่ของ process นี้ (ค่า default ถ้ายังไม่ได้โหลด)
+// ค่าที่คืนห้ามแก้ ถ้าจะแก้ให้ Clone ก่อน
+func Current() *settingsModel.RuntimeSettings {
+	if s := current.Load(); s != nil {
+		return s
+	}
+	defaults := settingsModel.DefaultRuntimeSettings()
+	current.CompareAndSwap(nil, defaults)
+	return current.Load()
+}
+
+func NewSettingsService(db *mongo.Database, redisClient *redis.Client) *SettingsService {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &SettingsService{
+		collection: db.Collection(settingsCollection),
+		redis:      redisClient,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	loadCtx, loadCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer loadCancel()
+	if loaded, err := s.load(loadCtx); err != nil {
+		log.Printf("[Settings] ⚠️ Failed to load runtime settings, using defaults: %v", err)
+	} else {
+		s.apply(loaded)
+	}
+
+	go s.listenChanges()
+	go s.resyncLoop()
+
+	log.Printf("[Settings] ✅ Runtime settings v%d active", Current().Version)
+	return s
+}
+
+// Get คืน settings ปัจจุบัน
+func (s *SettingsService) Get() *settingsModel.RuntimeSettings {
+	return Current()
+}
+
+// OnChange ลงทะเบียน callback ที่ถูกเรียกทันทีด้วยค่าปัจจุบัน และทุกครั้งที่ settings เปลี่ยน
+func (s *SettingsService) OnChange(fn func(*settingsModel.RuntimeSettings)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+	fn(Current())
+}
+
+// Update แทนที่ settings ทั้งชุด expectedVersion ต้องตรงกับ version ล่าสุด (optimistic locking)
+func (s *SettingsService) Update(ctx context.Context, next *settingsModel.RuntimeSettings, expectedVersion int64, updatedBy string) (*settingsModel.RuntimeSettings, error) {
+	previous := Current()
+	next = next.Clone()
+	next.Normalize()
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	next.Version = expectedVersion + 1
+	next.UpdatedAt = time.Now()
+	next.UpdatedBy = updatedBy
+
+	// upsert เฉพาะเมื่อ version ตรง ถ้าไม่ตรงจะชน _id แล้วได้ duplicate key
+	filter := bson.M{"_id": settingsModel.RuntimeSettingsID, "version": expectedVersion}
+	_, err := s.collection.ReplaceOne(ctx, filter, next, options.Replace().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrVersionConflict
+		}
+		return nil, fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	s.cache(ctx, next)
+	if err := s.redis.Publish(ctx, SettingsChangedChannel, strconv.FormatInt(next.Version, 10)).Err(); err != nil {
+		log.Printf("[Settings] ⚠️ Failed to publish settings change (other instances resync within %s): %v", settingsResyncInterval, err)
+	}
+	s.apply(next)
+
+	log.Printf("[Settings] 🔧 Runtime settings updated to v%d by %s", next.Version, updatedBy)
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionSettingsUpdate,
+		TargetType: audit.TargetSettings,
+		TargetID:   settingsModel.RuntimeSettingsID,
+		Before:     previous,
+		After:      next,
+	})
+	return next, nil
+}
+
+// Patch merge JSON บางส่วนเข้ากับค่าปัจจุบัน (field ที่ไม่ส่งมาคงเดิม)
+func (s *SettingsService) Patch(ctx context.Context, patch []byte, updatedBy string) (*settingsModel.RuntimeSettings, error) {
+	base := Current()
+	next := base.Clone()
+	if err := json.Unmarshal(patch, next); err != nil {
+		return nil, fmt.Errorf("invalid settings patch: %w", err)
+	}
+	// version ใน body (ถ้ามี) ใช้เป็น expected version
+	return s.Update(ctx, next, next.Version, updatedBy)
+}
+
+// Shutdown หยุด listener
+func (s *SettingsService) Shutdown() {
+	s.cancel()
+}
+
+// load อ่านจาก Redis ก่อน ถ้าไม่มีจึงอ่านจาก Mongo (ไม่มีทั้งคู่ = default)
+func (s *SettingsService) load(ctx context.Context) (*settingsModel.RuntimeSettings, error) {
+	if raw, err := s.redis.Get(ctx, SettingsCacheKey).Bytes(); err == nil {
+		var cached settingsModel.RuntimeSettings
+		if err := json.Unmarshal(raw, &cached); err == nil {
+			return &cached, nil
+		}
+		log.Printf("[Settings] ⚠️ Ignoring corrupt settings cache")
+	} else if err != redis.Nil {
+		log.Printf("[Settings] ⚠️ Redis read failed, falling back to MongoDB: %v", err)
+	}
+
+	var stored settingsModel.RuntimeSettings
+	err := s.collection.FindOne(ctx, bson.M{"_id": settingsModel.RuntimeSettingsID}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return settingsModel.DefaultRuntimeSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.cache(ctx, &stored)
+	return &stored, nil
+}
+
+func (s *SettingsService) cache(ctx context.Context, settings *settingsModel.RuntimeSettings) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return
+	}
+	if err := s.redis.Set(ctx, SettingsCacheKey, data, 0).Err(); err != nil {
+		log.Printf("[Settings] ⚠️ Failed to cache settings: %v", err)
+	}
+}
+
+// apply ตั้งค่าใหม่ให้ process นี้ (ข้ามถ้า version ไม่ใหม่กว่า) แล้วแจ้ง listener
+func (s *SettingsService) apply(next *settingsModel.RuntimeSettings) {
+	next.Normalize()
+	if err := next.Validate(); err != nil {
+		log.Printf("[Settings] ❌ Refusing to apply settings v%d: %v", next.Version, err)
+		return
+	}
+
+	for {
+		prev := current.Load()
+		if prev != nil && prev.Version >= next.Version && prev.Version != 0 {
+			return
+		}
+		if current.CompareAndSwap(prev, next) {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	listeners := append([]func(*settingsModel.RuntimeSettings){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn(next)
+	}
+}
+
+// reload โหลดใหม่ถ้า version ใน cache ใหม่กว่าที่ใช้อยู่
+func (s *SettingsService) reload() {
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	loaded, err := s.load(ctx)
+	if err != nil {
+		log.Printf("[Settings] ⚠️ Failed to reload settings: %v", err)
+		return
+	}
+	if loaded.Version > Current().Version {
+		log.Printf("[Settings] 🔄 Applying runtime settings v%d", loaded.Version)
+		s.apply(loaded)
+	}
+}
+
+func (s *SettingsService) listenChanges() {
+	pubsub := s.redis.Subscribe(s.ctx, SettingsChangedChannel)
+	defer pubsub.Close()
+
+	log.Printf("[Settings] 👂 Listening for settings changes on %s", SettingsChangedChannel)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case msg, ok := <-pubsub.Channel():
+			if !ok {
+				return
+			}
+			version, err := strconv.ParseInt(msg.Payload, 10, 64)
+			if err != nil || version <= Current().Version {
+				continue
+			}
+			s.reload()
+		}
+	}
+}
+
+func (s *SettingsService) resyncLoop() {
+	ticker := time.NewTicker(settingsResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.reload()
+		}
+	}
+}
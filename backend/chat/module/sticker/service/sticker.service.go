@@ -2,6 +2,7 @@ package service
 
 import (
 	"chat/module/sticker/model"
+	"chat/pkg/core/audit"
 	"chat/pkg/database/queries"
 	"context"
 
@@ -41,15 +42,33 @@ func (s *StickerService) CreateSticker(ctx context.Context, sticker *model.Stick
 	if err != nil {
 		return nil, err
 	}
-	return &result.Data[0], nil
+	created := &result.Data[0]
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionStickerCreate,
+		TargetType: audit.TargetSticker,
+		TargetID:   created.ID.Hex(),
+		After:      created,
+	})
+	return created, nil
 }
 
 func (s *StickerService) UpdateSticker(ctx context.Context, stickerID string, sticker *model.Sticker) (*model.Sticker, error) {
+	// ค่าเดิมสำหรับ audit (ถ้าหาไม่เจอ UpdateById จะคืน error เอง)
+	before, _ := s.GetStickerById(ctx, stickerID)
+
 	result, err := s.UpdateById(ctx, stickerID, *sticker)
 	if err != nil {
 		return nil, err
 	}
-	return &result.Data[0], nil
+	updated := &result.Data[0]
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionStickerUpdate,
+		TargetType: audit.TargetSticker,
+		TargetID:   stickerID,
+		Before:     before,
+		After:      updated,
+	})
+	return updated, nil
 }
 
 func (s *StickerService) DeleteSticker(ctx context.Context, stickerID string) (*model.Sticker, error) {
@@ -57,5 +76,12 @@ func (s *StickerService) DeleteSticker(ctx context.Context, stickerID string) (*
 	if err != nil {
 		return nil, err
 	}
-	return &result.Data[0], nil
+	deleted := &result.Data[0]
+	audit.Record(ctx, audit.Entry{
+		Action:     audit.ActionStickerDelete,
+		TargetType: audit.TargetSticker,
+		TargetID:   stickerID,
+		Before:     deleted,
+	})
+	return deleted, nil
 }
@@ -18,6 +18,16 @@ type Config struct {
 	Upload UploadConfig
 	AsyncFlow            AsyncFlowConfig       `env:",prefix=ASYNC_"`
 	ReliabilityThresholds ReliabilityThresholds `env:",prefix=RELIABILITY_"`
+	Shutdown             ShutdownConfig        `env:",prefix=SHUTDOWN_"`
+	Admission            AdmissionConfig       `env:",prefix=ADMISSION_"`
+	Tracing              TracingConfig         `env:",prefix=TRACING_"`
+	Logging              LoggingConfig         `env:",prefix=LOG_"`
+	Cache                CacheConfig           `env:",prefix=CACHE_"`
+	Migration            MigrationConfig       `env:",prefix=MIGRATE_"`
+	Retention            RetentionConfig       `env:",prefix=RETENTION_"`
+	Transcript           TranscriptConfig      `env:",prefix=TRANSCRIPT_"`
+	RoomSchedule         RoomScheduleConfig    `env:",prefix=ROOM_SCHEDULE_"`
+	RoomInvite           RoomInviteConfig      `env:",prefix=ROOM_INVITE_"`
 }
 
 type AppConfig struct {
@@ -29,7 +39,7 @@ type AppConfig struct {
 }
 
 type MongoConfig struct {
-	URI      string
+	URI      string `secret:"true"`
 	Database string
 }
 
@@ -37,7 +47,7 @@ type RedisConfig struct {
 	Host     string
 	Port     string
 	Addr     string
-	Password string
+	Password string `secret:"true"`
 	DB       int
 }
 
@@ -46,6 +56,7 @@ type KafkaConfig struct {
 	Topics  struct {
 		RoomEvents string
 		ChatEvents string
+		UserEvents string // **NEW: event user/role update จาก backend อื่น (ใช้ล้าง cache ของ UserInfoResolver)**
 	}
 }
 
@@ -105,33 +116,128 @@ type AsyncFlowConfig struct {
 		CleanupInterval time.Duration `env:"MESSAGE_STATUS_CLEANUP_INTERVAL" envDefault:"1h"`
 	}
 
+	// Durable outbox (Redis Stream) ไม่มีความจุตายตัว จึงกำหนดขนาดที่ถือว่าเต็มไว้คำนวณ saturation
+	Outbox struct {
+		MaxBacklog int `env:"OUTBOX_MAX_BACKLOG" envDefault:"20000"`
+	}
+
 	// Performance monitoring
 	Monitoring struct {
 		MetricsEnabled    bool          `env:"METRICS_ENABLED" envDefault:"true"`
 		MetricsInterval   time.Duration `env:"METRICS_INTERVAL" envDefault:"10s"`
 		SlowQueryThreshold time.Duration `env:"SLOW_QUERY_THRESHOLD" envDefault:"1s"`
 		AlertThreshold    float64       `env:"ALERT_THRESHOLD" envDefault:"0.95"` // 95% success rate
+		QueueSaturationThreshold float64 `env:"QUEUE_SATURATION_THRESHOLD" envDefault:"0.9"` // /readyz ล้มเมื่อ queue ใดเต็มถึงสัดส่วนนี้
+		RoomLabels        bool          `env:"METRICS_ROOM_LABELS" envDefault:"false"` // room ID เป็น label (cardinality สูง)
 	}
 }
 
 // **NEW: Message reliability thresholds**
 type ReliabilityThresholds struct {
-	BroadcastTimeout    time.Duration `env:"BROADCAST_TIMEOUT" envDefault:"500ms"`
-	DatabaseTimeout     time.Duration `env:"DATABASE_TIMEOUT" envDefault:"5s"`
-	NotificationTimeout time.Duration `env:"NOTIFICATION_TIMEOUT" envDefault:"3s"`
-	CacheTimeout        time.Duration `env:"CACHE_TIMEOUT" envDefault:"1s"`
+	BroadcastTimeout    time.Duration `env:"BROADCAST_TIMEOUT" envDefault:"500ms"` // เขียนไปยัง websocket หนึ่ง connection ระหว่าง broadcast
+	DatabaseTimeout     time.Duration `env:"DATABASE_TIMEOUT" envDefault:"5s"`     // บันทึกข้อความลง Mongo หนึ่งครั้ง (outbox และ DB worker)
+	NotificationTimeout time.Duration `env:"NOTIFICATION_TIMEOUT" envDefault:"3s"` // ส่ง notification ของหนึ่งข้อความ
+	CacheTimeout        time.Duration `env:"CACHE_TIMEOUT" envDefault:"1s"`        // เขียนข้อความลง Redis cache หนึ่งครั้ง
+}
+
+// **NEW: Graceful drain ตอน SIGTERM**
+type ShutdownConfig struct {
+	ReadinessDelay    time.Duration `env:"READINESS_DELAY" envDefault:"2s"`      // รอให้ load balancer เห็นว่า not ready ก่อนเริ่มปิด socket
+	DrainWindow       time.Duration `env:"DRAIN_WINDOW" envDefault:"10s"`        // ช่วงเวลาที่ทยอยปิด WebSocket
+	QueueFlushTimeout time.Duration `env:"QUEUE_FLUSH_TIMEOUT" envDefault:"15s"` // เวลาสูงสุดที่รอ worker queue ว่าง
+}
+
+// AdmissionConfig เกณฑ์ load shedding (elevated = ตัด event ที่ไม่จำเป็น, overload = ปฏิเสธ connection/ข้อความใหม่)
+type AdmissionConfig struct {
+	Enabled            bool          `env:"ENABLED" envDefault:"true"`
+	ElevatedQueueRatio float64       `env:"ELEVATED_QUEUE_RATIO" envDefault:"0.7"`
+	OverloadQueueRatio float64       `env:"OVERLOAD_QUEUE_RATIO" envDefault:"0.9"`
+	ElevatedLag        time.Duration `env:"ELEVATED_LAG" envDefault:"100ms"`
+	OverloadLag        time.Duration `env:"OVERLOAD_LAG" envDefault:"500ms"`
+	RetryAfter         time.Duration `env:"RETRY_AFTER" envDefault:"5s"`
+}
+
+// LoggingConfig structured logging (LOG_MODULE_LEVELS เช่น "ws=debug,cache=warn" ชื่อ module = tag ของ log ตัวพิมพ์เล็ก)
+type LoggingConfig struct {
+	Level          string `env:"LEVEL" envDefault:"info"`
+	Format         string `env:"FORMAT" envDefault:"json"` // json | text
+	ModuleLevels   string `env:"MODULE_LEVELS"`
+	DebugPerSecond int    `env:"DEBUG_PER_SECOND" envDefault:"100"` // จำกัด debug log ต่อ module (0 = ไม่จำกัด)
+}
+
+// CacheConfig การกัน cache stampede และ warm-up cache ของห้องที่ active หรือกำลังจะเปิด
+type CacheConfig struct {
+	FillLockTTL time.Duration `env:"FILL_LOCK_TTL" envDefault:"5s"` // อายุ lock ของ loader ข้าม instance
+	FillWait    time.Duration `env:"FILL_WAIT" envDefault:"3s"`     // เวลาที่รอ loader ของ instance อื่นก่อนอ่าน DB เอง
+
+	WarmupEnabled      bool          `env:"WARMUP_ENABLED" envDefault:"true"`
+	WarmupInterval     time.Duration `env:"WARMUP_INTERVAL" envDefault:"5m"`
+	WarmupActiveWindow time.Duration `env:"WARMUP_ACTIVE_WINDOW" envDefault:"30m"` // ห้องที่มีข้อความในช่วงนี้ถือว่า active
+	WarmupLookahead    time.Duration `env:"WARMUP_LOOKAHEAD" envDefault:"15m"`     // ห้องที่จะเปิดภายในช่วงนี้
+	WarmupHistoryLimit int           `env:"WARMUP_HISTORY_LIMIT" envDefault:"50"`
+	WarmupMaxRooms     int           `env:"WARMUP_MAX_ROOMS" envDefault:"200"`
+}
+
+// MigrationConfig การรัน schema migration ตอน startup (สั่งเองได้ด้วย `chat migrate status|up|down`)
+type MigrationConfig struct {
+	OnStartup bool          `env:"ON_STARTUP" envDefault:"true"`
+	LockTTL   time.Duration `env:"LOCK_TTL" envDefault:"5m"`  // อายุ lock เผื่อ instance ตายระหว่างรัน
+	LockWait  time.Duration `env:"LOCK_WAIT" envDefault:"2m"` // เวลาที่รอ instance อื่นที่กำลังรัน
+}
+
+// RetentionConfig worker ลบ/ย้ายข้อความเก่าตามกฎใน runtime settings (retention ของแต่ละประเภทห้อง)
+type RetentionConfig struct {
+	Enabled     bool          `env:"ENABLED" envDefault:"false"`
+	Interval    time.Duration `env:"INTERVAL" envDefault:"1h"`
+	BatchSize   int           `env:"BATCH_SIZE" envDefault:"500"`
+	ArchiveMode string        `env:"ARCHIVE_MODE" envDefault:"collection"` // collection | file
+	ArchivePath string        `env:"ARCHIVE_PATH" envDefault:"./archive"`  // โฟลเดอร์ของไฟล์ .jsonl.gz (mode file)
+}
+
+// TranscriptConfig การ export transcript ของห้อง (ห้องใหญ่ export แบบ async แล้วให้ดาวน์โหลดไฟล์ภายหลัง)
+type TranscriptConfig struct {
+	AsyncThreshold int           `env:"ASYNC_THRESHOLD" envDefault:"5000"` // จำนวนข้อความที่เกินแล้วต้อง export แบบ async
+	ExportDir      string        `env:"EXPORT_DIR" envDefault:"./exports"` // ต้องเป็น volume ที่ทุก instance เห็นร่วมกัน (เหมือน uploads)
+	ExportTTL      time.Duration `env:"EXPORT_TTL" envDefault:"24h"`       // อายุไฟล์ก่อนถูกลบ
+	MaxConcurrent  int           `env:"MAX_CONCURRENT" envDefault:"2"`     // job async ที่รันพร้อมกันได้ต่อ instance
+}
+
+// RoomScheduleConfig scheduler ที่เปิด/ปิดห้องตามตารางเวลาของห้อง
+type RoomScheduleConfig struct {
+	Enabled      bool            `env:"ENABLED" envDefault:"true"`
+	TickInterval time.Duration   `env:"TICK_INTERVAL" envDefault:"15s"`
+	Warnings     []time.Duration `env:"WARNINGS" envDefault:"5m,1m"`          // แจ้ง countdown ก่อนปิดห้อง
+	Timezone     string          `env:"TIMEZONE" envDefault:"Asia/Bangkok"` // ของตาราง recurring ที่ไม่ได้ระบุ timezone
+}
+
+// RoomInviteConfig ลิงก์เชิญและ join code ของห้อง
+type RoomInviteConfig struct {
+	JoinURL           string        `env:"JOIN_URL"`                            // เช่น https://app.example.com/join/{code} (ว่าง = ไม่คืน joinUrl)
+	CodeLength        int           `env:"CODE_LENGTH" envDefault:"8"`          // จำนวนตัวอักษรของ join code
+	DefaultTTL        time.Duration `env:"DEFAULT_TTL" envDefault:"168h"`       // อายุของ invite ที่ไม่ได้ระบุ expiresAt (0 = ไม่หมดอายุ)
+	AttemptsPerMinute int           `env:"ATTEMPTS_PER_MINUTE" envDefault:"10"` // จำนวนครั้งที่ user ลองใช้ code ได้ต่อนาที (กันเดา code)
+}
+
+// TracingConfig การ export trace (exporter: none | stdout | otlp)
+type TracingConfig struct {
+	Exporter    string  `env:"EXPORTER" envDefault:"none"`
+	Endpoint    string  `env:"OTLP_ENDPOINT"` // host:port หรือ URL เต็ม ว่าง = ใช้ OTEL_EXPORTER_OTLP_ENDPOINT
+	Insecure    bool    `env:"OTLP_INSECURE" envDefault:"true"`
+	ServiceName string  `env:"SERVICE_NAME" envDefault:"chat-service"`
+	SampleRatio float64 `env:"SAMPLE_RATIO" envDefault:"1"`
 }
 
 // Default เผื่ออ่าน env ไม่ได้จะกลับมาอ่าน default ที่ set ไว้
 var defaults = map[string]string{
-	"APP_BASE_URL":           "http://localhost:1334",
-	"REDIS_HOST":             "localhost",
-	"REDIS_PORT":             "6379",
-	"REDIS_DB":               "0",
-	"MONGO_URI":              "mongodb://localhost:27017",
-	"MONGO_DATABASE":         "hllc-2025",
-	"KAFKA_BROKERS":          "localhost:9092",
-	"UPLOAD_PATH":            "/uploads",
+	"APP_BASE_URL":             "http://localhost:1334",
+	"REDIS_HOST":               "localhost",
+	"REDIS_PORT":               "6379",
+	"REDIS_DB":                 "0",
+	"MONGO_URI":                "mongodb://localhost:27017",
+	"MONGO_DATABASE":           "hllc-2025",
+	"KAFKA_BROKERS":            "localhost:9092",
+	"UPLOAD_PATH":              "/uploads",
+	"KAFKA_TOPICS_USER_EVENTS": "user-events",
 }
 
 func getEnv(key string) string {
@@ -213,9 +319,11 @@ func LoadConfig() (*Config, error) {
 			Topics: struct {
 				RoomEvents string
 				ChatEvents string
+				UserEvents string
 			}{
 				RoomEvents: getEnv("KAFKA_TOPICS_ROOM_EVENTS"),
 				ChatEvents: getEnv("KAFKA_TOPICS_CHAT_EVENTS"),
+				UserEvents: getEnv("KAFKA_TOPICS_USER_EVENTS"),
 			},
 		},
 		Upload: UploadConfig{
@@ -223,6 +331,14 @@ func LoadConfig() (*Config, error) {
 		},
 	}
 
+	// **NEW: AsyncFlow และ ReliabilityThresholds อ่านจาก env ตาม struct tags**
+	if err := loadEnvStruct(cfg, ""); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
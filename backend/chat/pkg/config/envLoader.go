@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// **NEW: Tag-driven env loader**
+// รองรับ tag แบบเดียวกับ caarlos0/env ที่ใช้ใน struct อยู่แล้ว:
+//
+//	env:"NAME"              อ่านค่าจาก env NAME (ต่อท้าย prefix ของ struct แม่)
+//	env:",prefix=ASYNC_"    nested struct ที่ต้องเติม prefix ให้ field ลูก
+//	envDefault:"10s"        ค่า default ถ้าไม่ได้ตั้ง env
+//	envSeparator:";"        ตัวคั่นของ slice (default ",")
+//
+// nested struct ที่ไม่มี tag จะใช้ prefix เดียวกับ struct แม่
+// error ของทุก field จะถูกรวบรวมแล้วคืนครั้งเดียวเพื่อให้แก้ .env ได้ในรอบเดียว
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// loadEnvStruct เติมค่าให้ target (pointer ไปยัง struct) จาก environment
+func loadEnvStruct(target interface{}, prefix string) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: target must be a pointer to struct, got %T", target)
+	}
+
+	var errs []string
+	loadEnvFields(v.Elem(), prefix, &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+func loadEnvFields(v reflect.Value, prefix string, errs *[]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		name, opts := parseEnvTag(field.Tag.Get("env"))
+
+		// nested struct (ยกเว้น time.Duration ซึ่งเป็น int64)
+		if fv.Kind() == reflect.Struct {
+			loadEnvFields(fv, prefix+opts["prefix"], errs)
+			continue
+		}
+		if name == "" {
+			continue
+		}
+
+		key := prefix + name
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			raw, ok = field.Tag.Lookup("envDefault")
+			if !ok {
+				continue
+			}
+		}
+
+		separator := field.Tag.Get("envSeparator")
+		if separator == "" {
+			separator = ","
+		}
+		if err := setEnvValue(fv, raw, separator); err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s=%q: %v", key, raw, err))
+		}
+	}
+}
+
+// parseEnvTag แยก `env:"NAME,prefix=X_"` เป็นชื่อและ options
+func parseEnvTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, ",")
+	opts := make(map[string]string)
+	for _, part := range parts[1:] {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			opts[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		} else if part != "" {
+			opts[strings.TrimSpace(part)] = "true"
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts
+}
+
+func setEnvValue(fv reflect.Value, raw, separator string) error {
+	raw = strings.TrimSpace(raw)
+
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration like 500ms or 2s")
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a non-negative integer")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		items := strings.Split(raw, separator)
+		slice := reflect.MakeSlice(fv.Type(), 0, len(items))
+		for _, item := range items {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			if err := setEnvValue(elem, item, separator); err != nil {
+				return fmt.Errorf("item %q: %v", item, err)
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// Redacted คืน config ที่มีผลจริงในรูป map โดยซ่อน field ที่มี tag `secret:"true"`
+// ค่าที่เป็น URL จะซ่อนเฉพาะ password เพื่อให้ยังเห็น host
+func (c *Config) Redacted() map[string]interface{} {
+	return redactStruct(reflect.ValueOf(c).Elem())
+}
+
+func redactStruct(v reflect.Value) map[string]interface{} {
+	out := make(map[string]interface{})
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+
+		switch {
+		case field.Tag.Get("secret") == "true":
+			out[field.Name] = redactSecret(fmt.Sprint(fv.Interface()))
+		case fv.Type() == durationType:
+			out[field.Name] = time.Duration(fv.Int()).String()
+		case fv.Kind() == reflect.Struct:
+			out[field.Name] = redactStruct(fv)
+		default:
+			out[field.Name] = fv.Interface()
+		}
+	}
+	return out
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		if u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), "********")
+			}
+		}
+		return u.String()
+	}
+	return "********"
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Validate ตรวจค่าของ AsyncFlow และ ReliabilityThresholds รวมถึงความสัมพันธ์ระหว่าง field
+// คืน error ที่รวมทุกปัญหาไว้ เพื่อให้ service ไม่ start ด้วยค่าที่ใช้งานไม่ได้
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	af := c.AsyncFlow
+
+	// Worker pools
+	check(af.DatabaseWorkers.WorkerCount > 0, "ASYNC_DB_WORKER_COUNT must be > 0 (got %d)", af.DatabaseWorkers.WorkerCount)
+	check(af.DatabaseWorkers.QueueSize > 0, "ASYNC_DB_QUEUE_SIZE must be > 0 (got %d)", af.DatabaseWorkers.QueueSize)
+	check(af.DatabaseWorkers.BatchSize > 0, "ASYNC_DB_BATCH_SIZE must be > 0 (got %d)", af.DatabaseWorkers.BatchSize)
+	check(af.DatabaseWorkers.BatchSize <= af.DatabaseWorkers.QueueSize,
+		"ASYNC_DB_BATCH_SIZE (%d) must be <= ASYNC_DB_QUEUE_SIZE (%d)", af.DatabaseWorkers.BatchSize, af.DatabaseWorkers.QueueSize)
+	check(af.DatabaseWorkers.FlushTimeout > 0, "ASYNC_DB_FLUSH_TIMEOUT must be > 0")
+
+	check(af.NotificationWorkers.WorkerCount > 0, "ASYNC_NOTIFICATION_WORKER_COUNT must be > 0 (got %d)", af.NotificationWorkers.WorkerCount)
+	check(af.NotificationWorkers.QueueSize > 0, "ASYNC_NOTIFICATION_QUEUE_SIZE must be > 0 (got %d)", af.NotificationWorkers.QueueSize)
+	check(af.NotificationWorkers.BatchSize > 0, "ASYNC_NOTIFICATION_BATCH_SIZE must be > 0 (got %d)", af.NotificationWorkers.BatchSize)
+	check(af.NotificationWorkers.BatchSize <= af.NotificationWorkers.QueueSize,
+		"ASYNC_NOTIFICATION_BATCH_SIZE (%d) must be <= ASYNC_NOTIFICATION_QUEUE_SIZE (%d)", af.NotificationWorkers.BatchSize, af.NotificationWorkers.QueueSize)
+	check(af.NotificationWorkers.FlushTimeout > 0, "ASYNC_NOTIFICATION_FLUSH_TIMEOUT must be > 0")
+
+	// Retry
+	check(af.Retry.MaxRetries >= 0, "ASYNC_RETRY_MAX_RETRIES must be >= 0 (got %d)", af.Retry.MaxRetries)
+	check(af.Retry.InitialDelay > 0, "ASYNC_RETRY_INITIAL_DELAY must be > 0")
+	check(af.Retry.MaxDelay >= af.Retry.InitialDelay,
+		"ASYNC_RETRY_MAX_DELAY (%s) must be >= ASYNC_RETRY_INITIAL_DELAY (%s)", af.Retry.MaxDelay, af.Retry.InitialDelay)
+	check(af.Retry.BackoffFactor >= 1, "ASYNC_RETRY_BACKOFF_FACTOR must be >= 1 (got %g)", af.Retry.BackoffFactor)
+	check(af.Retry.RetryQueueSize > 0, "ASYNC_RETRY_QUEUE_SIZE must be > 0 (got %d)", af.Retry.RetryQueueSize)
+
+	// Phantom detection
+	if af.PhantomDetection.Enabled {
+		check(af.PhantomDetection.CheckInterval > 0, "ASYNC_PHANTOM_CHECK_INTERVAL must be > 0")
+		check(af.PhantomDetection.MaxAge >= af.PhantomDetection.CheckInterval,
+			"ASYNC_PHANTOM_MAX_AGE (%s) must be >= ASYNC_PHANTOM_CHECK_INTERVAL (%s)", af.PhantomDetection.MaxAge, af.PhantomDetection.CheckInterval)
+		check(af.PhantomDetection.BatchSize > 0, "ASYNC_PHANTOM_BATCH_SIZE must be > 0 (got %d)", af.PhantomDetection.BatchSize)
+	}
+
+	// Circuit breaker
+	if af.CircuitBreaker.Enabled {
+		check(af.CircuitBreaker.FailureThreshold > 0, "ASYNC_CIRCUIT_FAILURE_THRESHOLD must be > 0 (got %d)", af.CircuitBreaker.FailureThreshold)
+		check(af.CircuitBreaker.SuccessThreshold > 0, "ASYNC_CIRCUIT_SUCCESS_THRESHOLD must be > 0 (got %d)", af.CircuitBreaker.SuccessThreshold)
+		check(af.CircuitBreaker.Timeout > 0, "ASYNC_CIRCUIT_TIMEOUT must be > 0")
+		check(af.CircuitBreaker.HalfOpenMaxCalls > 0, "ASYNC_CIRCUIT_HALF_OPEN_MAX_CALLS must be > 0 (got %d)", af.CircuitBreaker.HalfOpenMaxCalls)
+	}
+
+	// Message status
+	if af.MessageStatus.Enabled {
+		check(af.MessageStatus.TTL > 0, "ASYNC_MESSAGE_STATUS_TTL must be > 0")
+		check(af.MessageStatus.CleanupInterval > 0, "ASYNC_MESSAGE_STATUS_CLEANUP_INTERVAL must be > 0")
+	}
+
+	// Outbox
+	check(af.Outbox.MaxBacklog > 0, "ASYNC_OUTBOX_MAX_BACKLOG must be > 0 (got %d)", af.Outbox.MaxBacklog)
+
+	// Monitoring
+	if af.Monitoring.MetricsEnabled {
+		check(af.Monitoring.MetricsInterval > 0, "ASYNC_METRICS_INTERVAL must be > 0")
+	}
+	check(af.Monitoring.AlertThreshold > 0 && af.Monitoring.AlertThreshold <= 1,
+		"ASYNC_ALERT_THRESHOLD must be in (0, 1] (got %g)", af.Monitoring.AlertThreshold)
+	check(af.Monitoring.QueueSaturationThreshold > 0 && af.Monitoring.QueueSaturationThreshold <= 1,
+		"ASYNC_QUEUE_SATURATION_THRESHOLD must be in (0, 1] (got %g)", af.Monitoring.QueueSaturationThreshold)
+
+	// Reliability thresholds
+	rt := c.ReliabilityThresholds
+	check(rt.BroadcastTimeout > 0, "RELIABILITY_BROADCAST_TIMEOUT must be > 0")
+	check(rt.DatabaseTimeout > 0, "RELIABILITY_DATABASE_TIMEOUT must be > 0")
+	check(rt.NotificationTimeout > 0, "RELIABILITY_NOTIFICATION_TIMEOUT must be > 0")
+	check(rt.CacheTimeout > 0, "RELIABILITY_CACHE_TIMEOUT must be > 0")
+
+	// Shutdown
+	check(c.Shutdown.ReadinessDelay >= 0, "SHUTDOWN_READINESS_DELAY must be >= 0")
+	check(c.Shutdown.DrainWindow >= 0, "SHUTDOWN_DRAIN_WINDOW must be >= 0")
+	check(c.Shutdown.QueueFlushTimeout > 0, "SHUTDOWN_QUEUE_FLUSH_TIMEOUT must be > 0")
+
+	// Admission
+	ad := c.Admission
+	check(ad.ElevatedQueueRatio > 0 && ad.ElevatedQueueRatio <= 1, "ADMISSION_ELEVATED_QUEUE_RATIO must be in (0, 1]")
+	check(ad.OverloadQueueRatio > 0 && ad.OverloadQueueRatio <= 1, "ADMISSION_OVERLOAD_QUEUE_RATIO must be in (0, 1]")
+	check(ad.OverloadQueueRatio >= ad.ElevatedQueueRatio, "ADMISSION_OVERLOAD_QUEUE_RATIO must be >= ADMISSION_ELEVATED_QUEUE_RATIO")
+	check(ad.ElevatedLag > 0, "ADMISSION_ELEVATED_LAG must be > 0")
+	check(ad.OverloadLag >= ad.ElevatedLag, "ADMISSION_OVERLOAD_LAG must be >= ADMISSION_ELEVATED_LAG")
+	check(ad.RetryAfter > 0, "ADMISSION_RETRY_AFTER must be > 0")
+
+	// Tracing
+	tr := c.Tracing
+	check(tr.Exporter == "none" || tr.Exporter == "stdout" || tr.Exporter == "otlp", "TRACING_EXPORTER must be one of none, stdout, otlp")
+	check(tr.SampleRatio >= 0 && tr.SampleRatio <= 1, "TRACING_SAMPLE_RATIO must be in [0, 1]")
+	check(tr.ServiceName != "", "TRACING_SERVICE_NAME must not be empty")
+
+	// Logging
+	lg := c.Logging
+	check(validLogLevel(lg.Level), "LOG_LEVEL %q is not a valid level (debug, info, warn, error)", lg.Level)
+	check(lg.Format == "json" || lg.Format == "text", "LOG_FORMAT must be json or text")
+	check(lg.DebugPerSecond >= 0, "LOG_DEBUG_PER_SECOND must be >= 0")
+	for _, pair := range strings.Split(lg.ModuleLevels, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		_, level, ok := strings.Cut(pair, "=")
+		check(ok && validLogLevel(level), "LOG_MODULE_LEVELS entry %q must be module=level", pair)
+	}
+
+	// Cache
+	cc := c.Cache
+	check(cc.FillLockTTL > 0, "CACHE_FILL_LOCK_TTL must be > 0")
+	check(cc.FillWait >= 0, "CACHE_FILL_WAIT must be >= 0")
+	if cc.WarmupEnabled {
+		check(cc.WarmupInterval > 0, "CACHE_WARMUP_INTERVAL must be > 0")
+		check(cc.WarmupActiveWindow > 0, "CACHE_WARMUP_ACTIVE_WINDOW must be > 0")
+		check(cc.WarmupLookahead >= 0, "CACHE_WARMUP_LOOKAHEAD must be >= 0")
+		check(cc.WarmupHistoryLimit > 0, "CACHE_WARMUP_HISTORY_LIMIT must be > 0 (got %d)", cc.WarmupHistoryLimit)
+		check(cc.WarmupMaxRooms > 0, "CACHE_WARMUP_MAX_ROOMS must be > 0 (got %d)", cc.WarmupMaxRooms)
+	}
+
+	// Migration
+	check(c.Migration.LockTTL > 0, "MIGRATE_LOCK_TTL must be > 0")
+	check(c.Migration.LockWait >= 0, "MIGRATE_LOCK_WAIT must be >= 0")
+
+	// Retention
+	rc := c.Retention
+	check(rc.ArchiveMode == "collection" || rc.ArchiveMode == "file", "RETENTION_ARCHIVE_MODE must be collection or file")
+	check(rc.ArchiveMode != "file" || rc.ArchivePath != "", "RETENTION_ARCHIVE_PATH must be set when RETENTION_ARCHIVE_MODE=file")
+	if rc.Enabled {
+		check(rc.Interval > 0, "RETENTION_INTERVAL must be > 0")
+		check(rc.BatchSize > 0, "RETENTION_BATCH_SIZE must be > 0 (got %d)", rc.BatchSize)
+	}
+
+	// Transcript
+	tc := c.Transcript
+	check(tc.AsyncThreshold > 0, "TRANSCRIPT_ASYNC_THRESHOLD must be > 0 (got %d)", tc.AsyncThreshold)
+	check(tc.ExportDir != "", "TRANSCRIPT_EXPORT_DIR must be set")
+	check(tc.ExportTTL > 0, "TRANSCRIPT_EXPORT_TTL must be > 0")
+	check(tc.MaxConcurrent > 0, "TRANSCRIPT_MAX_CONCURRENT must be > 0 (got %d)", tc.MaxConcurrent)
+
+	// Room schedule
+	sc := c.RoomSchedule
+	if sc.Enabled {
+		check(sc.TickInterval > 0, "ROOM_SCHEDULE_TICK_INTERVAL must be > 0")
+	}
+	for _, w := range sc.Warnings {
+		check(w > 0, "ROOM_SCHEDULE_WARNINGS must be positive durations (got %s)", w)
+	}
+	_, tzErr := time.LoadLocation(sc.Timezone)
+	check(tzErr == nil, "ROOM_SCHEDULE_TIMEZONE %q is not a known IANA timezone", sc.Timezone)
+
+	// Room invites
+	ri := c.RoomInvite
+	check(ri.CodeLength >= 6 && ri.CodeLength <= 16, "ROOM_INVITE_CODE_LENGTH must be between 6 and 16 (got %d)", ri.CodeLength)
+	check(ri.DefaultTTL >= 0, "ROOM_INVITE_DEFAULT_TTL must be >= 0")
+	check(ri.AttemptsPerMinute >= 0, "ROOM_INVITE_ATTEMPTS_PER_MINUTE must be >= 0 (got %d)", ri.AttemptsPerMinute)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func validLogLevel(s string) bool {
+	var level slog.Level
+	return level.UnmarshalText([]byte(strings.TrimSpace(s))) == nil
+}
@@ -0,0 +1,221 @@
+package admission
+
+import (
+	"chat/pkg/core/circuitbreaker"
+	"chat/pkg/core/metrics"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Admission control: ประเมินภาระจาก queue fill ratio, scheduler lag และ circuit breaker
+//
+//	normal     รับทุกอย่าง
+//	elevated   ตัด event ที่ไม่จำเป็น (typing, notification ของคนที่ออนไลน์อยู่แล้ว)
+//	overloaded ปฏิเสธ WebSocket ใหม่ (Retry-After) และตอบ "server busy" แทนการรับข้อความแล้วทิ้ง
+
+// Level ระดับภาระของ instance
+type Level int32
+
+const (
+	LevelNormal Level = iota
+	LevelElevated
+	LevelOverloaded
+)
+
+// ErrServerBusy ข้อความถูกปฏิเสธเพราะ instance รับไม่ไหว (client ควรส่งใหม่หลัง RetryAfter)
+var ErrServerBusy = errors.New("server busy, please retry shortly")
+
+const lagSampleInterval = 100 * time.Millisecond
+
+type (
+	// Settings เกณฑ์ของแต่ละระดับ
+	Settings struct {
+		Enabled            bool
+		ElevatedQueueRatio float64
+		OverloadQueueRatio float64
+		ElevatedLag        time.Duration
+		OverloadLag        time.Duration
+		RetryAfter         time.Duration
+	}
+
+	// Snapshot สถานะปัจจุบันสำหรับ health/admin
+	Snapshot struct {
+		Level         string  `json:"level"`
+		QueueFill     float64 `json:"queueFill"`
+		SchedulerLag  string  `json:"schedulerLag"`
+		BreakerOpen   bool    `json:"breakerOpen"`
+		RetryAfterSec int     `json:"retryAfterSec"`
+	}
+)
+
+var (
+	settingsMu sync.RWMutex
+	settings   = Settings{
+		Enabled:            true,
+		ElevatedQueueRatio: 0.7,
+		OverloadQueueRatio: 0.9,
+		ElevatedLag:        100 * time.Millisecond,
+		OverloadLag:        500 * time.Millisecond,
+		RetryAfter:         5 * time.Second,
+	}
+
+	queueFill atomic.Pointer[func() float64]
+	lagNanos  atomic.Int64
+	lagOnce   sync.Once
+	lastLevel atomic.Int32
+	rejected  = metrics.NewCounterVec("chat_admission_rejected_total", "Requests rejected or shed by admission control, by kind.", "kind")
+	_         = metrics.NewGaugeFunc("chat_admission_level", "Admission level (0 normal, 1 elevated, 2 overloaded).", "", func() map[string]float64 {
+		return map[string]float64{"": float64(CurrentLevel())}
+	})
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelElevated:
+		return "elevated"
+	case LevelOverloaded:
+		return "overloaded"
+	default:
+		return "normal"
+	}
+}
+
+// Configure ตั้งเกณฑ์ และเริ่มวัด scheduler lag (เรียกตอน startup)
+func Configure(s Settings) {
+	settingsMu.Lock()
+	settings = s
+	settingsMu.Unlock()
+
+	lagOnce.Do(func() { go monitorSchedulerLag() })
+}
+
+// SetQueueFill กำหนดฟังก์ชันที่คืนสัดส่วน queue ที่เต็มที่สุด (0..1)
+func SetQueueFill(fn func() float64) {
+	queueFill.Store(&fn)
+}
+
+func currentSettings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}
+
+// CurrentLevel ประเมินระดับภาระตอนนี้
+func CurrentLevel() Level {
+	s := currentSettings()
+	if !s.Enabled {
+		return LevelNormal
+	}
+
+	fill := currentQueueFill()
+	lag := time.Duration(lagNanos.Load())
+	breakerOpen := circuitbreaker.Get(circuitbreaker.MongoWrite).IsOpen()
+
+	level := LevelNormal
+	switch {
+	case fill >= s.OverloadQueueRatio, lag >= s.OverloadLag:
+		level = LevelOverloaded
+	case fill >= s.ElevatedQueueRatio, lag >= s.ElevatedLag, breakerOpen:
+		// breaker เปิด = DB ช้า/ล่ม ข้อความยังรับได้ (outbox) แต่ควรลดงานอื่น
+		level = LevelElevated
+	}
+
+	if prev := Level(lastLevel.Swap(int32(level))); prev != level {
+		log.Printf("[Admission] Level changed %s -> %s (queueFill=%.2f, lag=%s, breakerOpen=%v)", prev, level, fill, lag, breakerOpen)
+	}
+	return level
+}
+
+// AllowConnection ตรวจว่ารับ WebSocket ใหม่ได้หรือไม่ คืน retry-after เมื่อปฏิเสธ
+func AllowConnection() (bool, time.Duration) {
+	if CurrentLevel() >= LevelOverloaded {
+		rejected.Inc("connection")
+		return false, currentSettings().RetryAfter
+	}
+	return true, 0
+}
+
+// AllowMessage คืน ErrServerBusy ถ้าไม่ควรรับข้อความใหม่
+func AllowMessage() error {
+	if CurrentLevel() >= LevelOverloaded {
+		rejected.Inc("message")
+		return ErrServerBusy
+	}
+	return nil
+}
+
+// ShedNonEssential ตรวจว่าควรข้าม event ที่ไม่จำเป็นหรือไม่ (kind ใช้เป็น label ของ metric)
+func ShedNonEssential(kind string) bool {
+	if CurrentLevel() >= LevelElevated {
+		rejected.Inc(kind)
+		return true
+	}
+	return false
+}
+
+// RetryAfter เวลาที่แนะนำให้ client รอก่อนลองใหม่
+func RetryAfter() time.Duration {
+	return currentSettings().RetryAfter
+}
+
+// RetryAfterSeconds ค่า header Retry-After (ปัดขึ้น อย่างน้อย 1 วินาที)
+func RetryAfterSeconds() string {
+	return fmt.Sprintf("%d", int(math.Max(1, math.Ceil(RetryAfter().Seconds()))))
+}
+
+// RespondBusy ตอบ 503 พร้อม Retry-After ให้ REST/upgrade request ที่ถูกปฏิเสธ
+func RespondBusy(c *fiber.Ctx, message string) error {
+	c.Set(fiber.HeaderRetryAfter, RetryAfterSeconds())
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+		"data": fiber.Map{
+			"reason":       "server_busy",
+			"retryAfterMs": RetryAfter().Milliseconds(),
+		},
+	})
+}
+
+// CurrentSnapshot คืนสถานะปัจจุบัน
+func CurrentSnapshot() Snapshot {
+	return Snapshot{
+		Level:         CurrentLevel().String(),
+		QueueFill:     currentQueueFill(),
+		SchedulerLag:  time.Duration(lagNanos.Load()).String(),
+		BreakerOpen:   circuitbreaker.Get(circuitbreaker.MongoWrite).IsOpen(),
+		RetryAfterSec: int(math.Ceil(RetryAfter().Seconds())),
+	}
+}
+
+func currentQueueFill() float64 {
+	if fn := queueFill.Load(); fn != nil {
+		return (*fn)()
+	}
+	return 0
+}
+
+// monitorSchedulerLag วัดว่า ticker ตื่นช้ากว่าที่ควรเท่าไร (Go ไม่มี event loop แต่ lag นี้สะท้อน
+// CPU saturation/GC pause แบบเดียวกัน) เก็บเป็นค่าเฉลี่ยถ่วงน้ำหนักเพื่อไม่ให้ spike เดียวทำให้ level แกว่ง
+func monitorSchedulerLag() {
+	ticker := time.NewTicker(lagSampleInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for now := range ticker.C {
+		lag := now.Sub(last) - lagSampleInterval
+		if lag < 0 {
+			lag = 0
+		}
+		last = now
+
+		prev := lagNanos.Load()
+		lagNanos.Store(int64(0.7*float64(prev) + 0.3*float64(lag)))
+	}
+}
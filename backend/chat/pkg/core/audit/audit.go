@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"chat/pkg/core/metrics"
+	"context"
+	"log"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Admin audit log: บันทึกการกระทำของ admin แบบ append-only (insert อย่างเดียว ไม่มี API แก้/ลบ)
+//
+// service เรียก Record พร้อม before/after ของสิ่งที่แก้ ส่วน Middleware เติม actor/request ให้
+// และบันทึก entry แบบ generic ให้ request ที่ผ่าน RequireRoles แต่ไม่มี service ไหนบันทึก
+
+// CollectionName collection ของ audit log
+const CollectionName = "admin-audit"
+
+// recordTimeout เวลาสูงสุดของการเขียน audit หนึ่งรายการ
+const recordTimeout = 5 * time.Second
+
+const (
+	// ActorSystem ใช้เมื่อไม่มี request (worker, Kafka consumer)
+	ActorSystem = "system"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Target types
+const (
+	TargetRoom     = "room"
+	TargetSticker  = "sticker"
+	TargetUser     = "user"
+	TargetSettings = "settings"
+	TargetRoute    = "route"
+)
+
+// Actions ที่ service บันทึก (generic entry จาก middleware ใช้ "http.<method>")
+const (
+	ActionRoomCreate             = "room.create"
+	ActionRoomUpdate             = "room.update"
+	ActionRoomDelete             = "room.delete"
+	ActionRoomBulkAdd            = "room.bulk_add"
+	ActionRoomGroupJoin          = "room.group_join"
+	ActionRoomCacheClear         = "room.cache_clear"
+	ActionRoomSchedule           = "room.schedule"
+	ActionRoomInviteCreate       = "room.invite_create"
+	ActionRoomInviteRevoke       = "room.invite_revoke"
+	ActionRoomMemberRole         = "room.member_role"
+	ActionPhantomFix             = "message.phantom_fix"
+	ActionMessageModeratorDelete = "message.moderator_delete"
+	ActionStickerCreate          = "sticker.create"
+	ActionStickerUpdate          = "sticker.update"
+	ActionStickerDelete          = "sticker.delete"
+	ActionRestrictionBan         = "restriction.ban"
+	ActionRestrictionUnban       = "restriction.unban"
+	ActionRestrictionMute        = "restriction.mute"
+	ActionRestrictionUnmute      = "restriction.unmute"
+	ActionRestrictionKick        = "restriction.kick"
+	ActionSettingsUpdate         = "settings.update"
+)
+
+// field ที่เปลี่ยนทุกครั้งจึงไม่นับเป็น diff
+var ignoredDiffFields = map[string]bool{
+	"_id":        true,
+	"updated_at": true,
+	"updatedAt":  true,
+}
+
+type (
+	// Entry หนึ่งรายการใน audit log
+	Entry struct {
+		ID         primitive.ObjectID     `bson:"_id" json:"id"`
+		Timestamp  time.Time              `bson:"timestamp" json:"timestamp"`
+		ActorID    string                 `bson:"actor_id" json:"actorId"`
+		ActorRole  string                 `bson:"actor_role,omitempty" json:"actorRole,omitempty"`
+		Action     string                 `bson:"action" json:"action"`
+		TargetType string                 `bson:"target_type" json:"targetType"`
+		TargetID   string                 `bson:"target_id,omitempty" json:"targetId,omitempty"`
+		Before     interface{}            `bson:"before,omitempty" json:"before,omitempty"`
+		After      interface{}            `bson:"after,omitempty" json:"after,omitempty"`
+		Changes    []Change               `bson:"changes,omitempty" json:"changes,omitempty"`
+		Details    map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+		Outcome    string                 `bson:"outcome" json:"outcome"`
+		Error      string                 `bson:"error,omitempty" json:"error,omitempty"`
+		Request    *RequestInfo           `bson:"request,omitempty" json:"request,omitempty"`
+	}
+
+	// Change field ระดับบนสุดที่ค่าเปลี่ยนระหว่าง before กับ after
+	Change struct {
+		Field string      `bson:"field" json:"field"`
+		From  interface{} `bson:"from" json:"from"`
+		To    interface{} `bson:"to" json:"to"`
+	}
+
+	// RequestInfo metadata ของ HTTP request ที่ทำให้เกิด action
+	RequestInfo struct {
+		RequestID string `bson:"request_id,omitempty" json:"requestId,omitempty"`
+		Method    string `bson:"method" json:"method"`
+		Path      string `bson:"path" json:"path"`
+		Route     string `bson:"route,omitempty" json:"route,omitempty"`
+		IP        string `bson:"ip,omitempty" json:"ip,omitempty"`
+		UserAgent string `bson:"user_agent,omitempty" json:"userAgent,omitempty"`
+		Status    int    `bson:"status,omitempty" json:"status,omitempty"`
+	}
+)
+
+var (
+	collection atomic.Pointer[mongo.Collection]
+
+	records = metrics.NewCounterVec("chat_admin_audit_records_total", "Admin audit entries by write result.", "result")
+)
+
+// Configure ตั้ง collection ที่ใช้เขียน (เรียกตอน startup ก่อนนั้น Record เป็น no-op)
+func Configure(db *mongo.Database) {
+	collection.Store(db.Collection(CollectionName))
+}
+
+// Record เขียน entry ทันที actor/request มาจาก context ของ Middleware (ถ้ามี)
+// ไม่คืน error: audit ล้มต้องไม่ทำให้ action ที่สำเร็จไปแล้วล้มตาม
+func Record(ctx context.Context, entry Entry) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if state := stateFrom(ctx); state != nil {
+		state.recorded.Store(true)
+		if entry.ActorID == "" {
+			entry.ActorID, entry.ActorRole = state.actorID, state.actorRole
+		}
+		if entry.Request == nil {
+			info := state.info
+			entry.Request = &info
+		}
+	}
+	if entry.ActorID == "" {
+		entry.ActorID = ActorSystem
+	}
+
+	coll := collection.Load()
+	if coll == nil {
+		return
+	}
+
+	entry.ID = primitive.NewObjectID()
+	entry.Timestamp = time.Now()
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeSuccess
+		if entry.Error != "" {
+			entry.Outcome = OutcomeFailure
+		}
+	}
+	entry.Before = snapshot(entry.Before)
+	entry.After = snapshot(entry.After)
+	if entry.Changes == nil {
+		entry.Changes = Diff(entry.Before, entry.After)
+	}
+
+	// ไม่ผูกกับ cancel ของ request: client ตัดการเชื่อมต่อแล้ว audit ก็ยังต้องถูกเขียน
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if _, err := coll.InsertOne(writeCtx, entry); err != nil {
+		records.Inc("failed")
+		log.Printf("[Audit] ❌ Failed to record %s on %s/%s by %s: %v", entry.Action, entry.TargetType, entry.TargetID, entry.ActorID, err)
+		return
+	}
+	records.Inc("written")
+}
+
+// Diff เทียบ field ระดับบนสุดของ before/after (nil ฝั่งใดฝั่งหนึ่งคือสร้าง/ลบ จึงไม่มี diff)
+func Diff(before, after interface{}) []Change {
+	b, a := toDocument(before), toDocument(after)
+	if b == nil || a == nil {
+		return nil
+	}
+
+	fields := make(map[string]bool, len(b)+len(a))
+	for k := range b {
+		fields[k] = true
+	}
+	for k := range a {
+		fields[k] = true
+	}
+
+	var changes []Change
+	for field := range fields {
+		if ignoredDiffFields[field] {
+			continue
+		}
+		if !reflect.DeepEqual(b[field], a[field]) {
+			changes = append(changes, Change{Field: field, From: b[field], To: a[field]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// snapshot คืน nil interface (ไม่ใช่ bson.M ที่เป็น nil) เพื่อให้ omitempty ทำงาน
+func snapshot(v interface{}) interface{} {
+	if doc := toDocument(v); doc != nil {
+		return doc
+	}
+	return nil
+}
+
+// toDocument แปลง struct/map เป็น bson.M เพื่อให้ diff และสิ่งที่เก็บใช้ชื่อ field เดียวกับใน Mongo
+func toDocument(v interface{}) bson.M {
+	switch doc := v.(type) {
+	case nil:
+		return nil
+	case bson.M:
+		return doc
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		log.Printf("[Audit] ⚠️ Cannot snapshot %T: %v", v, err)
+		return nil
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		log.Printf("[Audit] ⚠️ Cannot snapshot %T: %v", v, err)
+		return nil
+	}
+	return doc
+}
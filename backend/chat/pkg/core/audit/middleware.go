@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"chat/pkg/core/logging"
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// stateKey ใช้ทั้งกับ fiber Locals (fasthttp ctx ที่ controller ส่งเป็น ctx.Context()) และ c.UserContext()
+type stateKey struct{}
+
+// ActorResolver คืน user ID และ role ของผู้เรียก ("" ถ้าไม่มี token)
+type ActorResolver func(c *fiber.Ctx) (userID, role string)
+
+// requestState ข้อมูลของ request ที่ Record ใช้เติม entry
+type requestState struct {
+	actorID    string
+	actorRole  string
+	info       RequestInfo
+	privileged atomic.Bool
+	recorded   atomic.Bool
+}
+
+func stateFrom(ctx context.Context) *requestState {
+	state, _ := ctx.Value(stateKey{}).(*requestState)
+	return state
+}
+
+// MarkPrivileged เรียกโดย RBAC เมื่อ request ผ่านการตรวจ role (เป็น admin action)
+func MarkPrivileged(c *fiber.Ctx) {
+	if state, ok := c.Locals(stateKey{}).(*requestState); ok {
+		state.privileged.Store(true)
+	}
+}
+
+// Middleware ผูก actor และ request metadata กับ request ที่แก้ข้อมูล (POST/PUT/PATCH/DELETE)
+// ถ้า request ผ่าน RequireRoles แต่ไม่มี service บันทึก จะบันทึก entry แบบ generic ให้ (รวมกรณีล้ม)
+func Middleware(resolve ActorResolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !isMutating(c.Method()) {
+			return c.Next()
+		}
+
+		state := &requestState{
+			info: RequestInfo{
+				RequestID: logging.FromContext(c.UserContext()).RequestID,
+				Method:    c.Method(),
+				Path:      c.Path(),
+				IP:        c.IP(),
+				UserAgent: c.Get(fiber.HeaderUserAgent),
+			},
+		}
+		state.actorID, state.actorRole = resolve(c)
+		c.Locals(stateKey{}, state)
+		c.SetUserContext(context.WithValue(c.UserContext(), stateKey{}, state))
+
+		err := c.Next()
+
+		if !state.privileged.Load() || state.recorded.Load() {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			status = fiberErr.Code
+		}
+		state.info.Route = c.Route().Path
+		state.info.Status = status
+
+		entry := Entry{
+			Action:     "http." + strings.ToLower(c.Method()),
+			TargetType: TargetRoute,
+			TargetID:   c.Route().Path,
+			Details:    map[string]interface{}{"params": c.AllParams()},
+		}
+		if status >= fiber.StatusBadRequest {
+			entry.Outcome = OutcomeFailure
+		}
+		Record(c.UserContext(), entry)
+		return err
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,294 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// State ของ circuit breaker
+type State int
+
+const (
+	StateClosed   State = iota // ทำงานปกติ ส่ง request ผ่านทั้งหมด
+	StateOpen                  // ล้มเหลวต่อเนื่อง ปฏิเสธ request ทันทีจนครบ Timeout
+	StateHalfOpen              // ทดลองส่ง request จำนวนจำกัดเพื่อตรวจว่าฟื้นหรือยัง
+)
+
+var (
+	// ErrOpen ถูกคืนเมื่อ breaker เปิดอยู่ (caller ควร degrade เช่น ข้าม cache หรือ queue ไว้ retry)
+	ErrOpen = errors.New("circuit breaker is open")
+	// ErrTooManyCalls ถูกคืนเมื่อ half-open และมี request ทดลองครบจำนวนแล้ว
+	ErrTooManyCalls = errors.New("circuit breaker is half-open: too many calls")
+)
+
+type (
+	// Settings กำหนดพฤติกรรมของ breaker (มาจาก AsyncFlowConfig.CircuitBreaker)
+	Settings struct {
+		Enabled          bool
+		FailureThreshold int           // จำนวน failure ติดกันก่อนเปิด breaker
+		SuccessThreshold int           // จำนวน success ใน half-open ก่อนปิด breaker
+		Timeout          time.Duration // ระยะเวลาที่ breaker เปิดก่อนเข้าสู่ half-open
+		HalfOpenMaxCalls int           // จำนวน request ที่ยอมให้ผ่านพร้อมกันใน half-open
+	}
+
+	// Option ปรับแต่ง breaker ตอนสร้าง
+	Option func(*Breaker)
+
+	// Breaker คือ circuit breaker สำหรับ dependency ภายนอกหนึ่งตัว (Mongo, Redis, Kafka, HTTP)
+	Breaker struct {
+		name      string
+		settings  Settings
+		isFailure func(err error) bool
+
+		mu                sync.Mutex
+		state             State
+		consecutiveFails  int
+		halfOpenSuccesses int
+		halfOpenInFlight  int
+		openedAt          time.Time
+		lastError         string
+		lastStateChange   time.Time
+		totalRejected     int64
+		totalFailures     int64
+		totalSuccesses    int64
+	}
+
+	// Snapshot คือสถานะของ breaker สำหรับแสดงใน health endpoint
+	Snapshot struct {
+		Name             string    `json:"name"`
+		State            string    `json:"state"`
+		Enabled          bool      `json:"enabled"`
+		ConsecutiveFails int       `json:"consecutiveFailures"`
+		TotalFailures    int64     `json:"totalFailures"`
+		TotalSuccesses   int64     `json:"totalSuccesses"`
+		TotalRejected    int64     `json:"totalRejected"`
+		LastError        string    `json:"lastError,omitempty"`
+		LastStateChange  time.Time `json:"lastStateChange"`
+		RetryAfter       string    `json:"retryAfter,omitempty"`
+	}
+)
+
+// String คืนชื่อ state
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// WithFailurePredicate กำหนดว่า error แบบไหนนับเป็น failure (เช่นไม่นับ duplicate key)
+func WithFailurePredicate(isFailure func(err error) bool) Option {
+	return func(b *Breaker) {
+		b.isFailure = isFailure
+	}
+}
+
+// New creates a new circuit breaker
+func New(name string, settings Settings, opts ...Option) *Breaker {
+	b := &Breaker{
+		name:            name,
+		settings:        normalize(settings),
+		isFailure:       defaultIsFailure,
+		state:           StateClosed,
+		lastStateChange: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Name คืนชื่อ breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Execute เรียก fn ผ่าน breaker คืน ErrOpen/ErrTooManyCalls ทันทีถ้าไม่อนุญาต
+func (b *Breaker) Execute(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.Done(err)
+	return err
+}
+
+// Allow ตรวจว่าส่ง request ได้หรือไม่ ถ้าได้ต้องเรียก Done ตามหลังเสมอ
+func (b *Breaker) Allow() error {
+	if !b.settings.Enabled {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.settings.Timeout {
+			b.totalRejected++
+			return ErrOpen
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.halfOpenInFlight >= b.settings.HalfOpenMaxCalls {
+			b.totalRejected++
+			return ErrTooManyCalls
+		}
+		b.halfOpenInFlight++
+	}
+	return nil
+}
+
+// Done บันทึกผลของ request ที่ผ่าน Allow แล้ว
+func (b *Breaker) Done(err error) {
+	if !b.settings.Enabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen && b.halfOpenInFlight > 0 {
+		b.halfOpenInFlight--
+	}
+
+	if err != nil && b.isFailure(err) {
+		b.totalFailures++
+		b.consecutiveFails++
+		b.lastError = err.Error()
+
+		switch b.state {
+		case StateHalfOpen:
+			// ทดลองแล้วยังล้มเหลว เปิดใหม่
+			b.open()
+		case StateClosed:
+			if b.consecutiveFails >= b.settings.FailureThreshold {
+				b.open()
+			}
+		}
+		return
+	}
+
+	b.totalSuccesses++
+	b.consecutiveFails = 0
+
+	if b.state == StateHalfOpen {
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.settings.SuccessThreshold {
+			b.setState(StateClosed)
+			log.Printf("[CircuitBreaker] ✅ %s closed after %d successful trial calls", b.name, b.halfOpenSuccesses)
+		}
+	}
+}
+
+// State คืน state ปัจจุบัน (open ที่ครบ timeout แล้วจะแสดงเป็น half-open)
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// IsOpen ใช้ให้ caller ตัดสินใจ degrade ล่วงหน้าโดยไม่ต้องเรียก Allow
+func (b *Breaker) IsOpen() bool {
+	return b.settings.Enabled && b.State() == StateOpen
+}
+
+// RetryAfter คืนเวลาที่เหลือก่อน breaker จะยอมให้ request ทดลองผ่าน
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateOpen {
+		return 0
+	}
+	remaining := b.settings.Timeout - time.Since(b.openedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Snapshot คืนสถานะสำหรับ health/metrics
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snap := Snapshot{
+		Name:             b.name,
+		State:            b.currentState().String(),
+		Enabled:          b.settings.Enabled,
+		ConsecutiveFails: b.consecutiveFails,
+		TotalFailures:    b.totalFailures,
+		TotalSuccesses:   b.totalSuccesses,
+		TotalRejected:    b.totalRejected,
+		LastError:        b.lastError,
+		LastStateChange:  b.lastStateChange,
+	}
+	if b.state == StateOpen {
+		if remaining := b.settings.Timeout - time.Since(b.openedAt); remaining > 0 {
+			snap.RetryAfter = remaining.Round(time.Second).String()
+		}
+	}
+	return snap
+}
+
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.settings.Timeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(StateOpen)
+	log.Printf("[CircuitBreaker] 🔴 %s opened after %d consecutive failures (last error: %s)",
+		b.name, b.consecutiveFails, b.lastError)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	if state == StateHalfOpen {
+		log.Printf("[CircuitBreaker] 🟡 %s half-open, allowing up to %d trial calls", b.name, b.settings.HalfOpenMaxCalls)
+	}
+	b.state = state
+	b.lastStateChange = time.Now()
+	b.halfOpenSuccesses = 0
+	b.halfOpenInFlight = 0
+	if state == StateClosed {
+		b.consecutiveFails = 0
+	}
+}
+
+// defaultIsFailure ไม่นับการยกเลิกจาก caller เป็น failure ของ dependency
+func defaultIsFailure(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
+
+// normalize เติมค่า default ให้ field ที่ไม่ได้กำหนด (ตรงกับ envDefault ใน config)
+func normalize(s Settings) Settings {
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = 10
+	}
+	if s.SuccessThreshold <= 0 {
+		s.SuccessThreshold = 5
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = 30 * time.Second
+	}
+	if s.HalfOpenMaxCalls <= 0 {
+		s.HalfOpenMaxCalls = 3
+	}
+	return s
+}
@@ -0,0 +1,196 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errDependency = errors.New("dependency down")
+
+func testSettings() Settings {
+	return Settings{
+		Enabled:          true,
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		Timeout:          time.Minute,
+		HalfOpenMaxCalls: 2,
+	}
+}
+
+// expireOpen ทำให้ breaker ที่เปิดอยู่ครบ timeout โดยไม่ต้องรอจริง
+func expireOpen(b *Breaker) {
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-b.settings.Timeout)
+	b.mu.Unlock()
+}
+
+func TestBreakerStateMachine(t *testing.T) {
+	type step struct {
+		expire    bool  // ข้ามเวลาให้ครบ timeout ก่อนเรียก
+		err       error // ผลของ fn
+		wantErr   error // error ที่ Execute ควรคืน
+		wantState State // state หลังเรียก
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after consecutive failures",
+			steps: []step{
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateOpen},
+				{err: nil, wantErr: ErrOpen, wantState: StateOpen},
+			},
+		},
+		{
+			name: "success resets the failure streak",
+			steps: []step{
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: nil, wantErr: nil, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+			},
+		},
+		{
+			name: "half-open closes after enough successes",
+			steps: []step{
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateOpen},
+				{expire: true, err: nil, wantErr: nil, wantState: StateHalfOpen},
+				{err: nil, wantErr: nil, wantState: StateClosed},
+			},
+		},
+		{
+			name: "failure in half-open reopens",
+			steps: []step{
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateClosed},
+				{err: errDependency, wantErr: errDependency, wantState: StateOpen},
+				{expire: true, err: errDependency, wantErr: errDependency, wantState: StateOpen},
+				{err: nil, wantErr: ErrOpen, wantState: StateOpen},
+			},
+		},
+		{
+			name: "caller cancellation is not a failure",
+			steps: []step{
+				{err: context.Canceled, wantErr: context.Canceled, wantState: StateClosed},
+				{err: context.Canceled, wantErr: context.Canceled, wantState: StateClosed},
+				{err: context.Canceled, wantErr: context.Canceled, wantState: StateClosed},
+				{err: context.Canceled, wantErr: context.Canceled, wantState: StateClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New("test", testSettings())
+			for i, s := range tt.steps {
+				if s.expire {
+					expireOpen(b)
+				}
+				err := b.Execute(func() error { return s.err })
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: Execute() error = %v, want %v", i, err, s.wantErr)
+				}
+				if got := b.State(); got != s.wantState {
+					t.Fatalf("step %d: State() = %s, want %s", i, got, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenLimitsTrialCalls(t *testing.T) {
+	b := New("test", testSettings())
+	for i := 0; i < 3; i++ {
+		b.Done(errDependency)
+	}
+	expireOpen(b)
+
+	// HalfOpenMaxCalls = 2: สองตัวแรกผ่าน ตัวที่สามถูกปฏิเสธจนกว่าจะมีตัวที่เสร็จ
+	if err := b.Allow(); err != nil {
+		t.Fatalf("first trial call rejected: %v", err)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("second trial call rejected: %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrTooManyCalls) {
+		t.Fatalf("third trial call error = %v, want %v", err, ErrTooManyCalls)
+	}
+
+	b.Done(nil)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("trial call after one finished rejected: %v", err)
+	}
+	if got := b.Snapshot().TotalRejected; got != 1 {
+		t.Errorf("TotalRejected = %d, want 1", got)
+	}
+}
+
+func TestBreakerDisabledPassesEverything(t *testing.T) {
+	settings := testSettings()
+	settings.Enabled = false
+	b := New("test", settings)
+
+	for i := 0; i < 10; i++ {
+		if err := b.Execute(func() error { return errDependency }); !errors.Is(err, errDependency) {
+			t.Fatalf("call %d: error = %v, want %v", i, err, errDependency)
+		}
+	}
+	if b.IsOpen() {
+		t.Error("disabled breaker reports open")
+	}
+}
+
+func TestBreakerFailurePredicate(t *testing.T) {
+	ignored := errors.New("duplicate key")
+	b := New("test", testSettings(), WithFailurePredicate(func(err error) bool {
+		return !errors.Is(err, ignored)
+	}))
+
+	for i := 0; i < 5; i++ {
+		_ = b.Execute(func() error { return ignored })
+	}
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("State() = %s after ignored errors, want closed", got)
+	}
+}
+
+func TestNormalizeDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Settings
+		want Settings
+	}{
+		{
+			name: "zero values get defaults",
+			in:   Settings{Enabled: true},
+			want: Settings{Enabled: true, FailureThreshold: 10, SuccessThreshold: 5, Timeout: 30 * time.Second, HalfOpenMaxCalls: 3},
+		},
+		{
+			name: "negative values get defaults",
+			in:   Settings{FailureThreshold: -1, SuccessThreshold: -1, Timeout: -time.Second, HalfOpenMaxCalls: -1},
+			want: Settings{FailureThreshold: 10, SuccessThreshold: 5, Timeout: 30 * time.Second, HalfOpenMaxCalls: 3},
+		},
+		{
+			name: "explicit values are kept",
+			in:   testSettings(),
+			want: testSettings(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.in); got != tt.want {
+				t.Errorf("normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
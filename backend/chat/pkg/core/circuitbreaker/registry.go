@@ -0,0 +1,111 @@
+package circuitbreaker
+
+import (
+	"chat/pkg/config"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ชื่อ breaker ที่ใช้ร่วมกันในระบบ
+const (
+	MongoWrite          = "mongo-write"
+	RedisCache          = "redis-cache"
+	KafkaEmit           = "kafka-emit"
+	EvoucherClaimNestJS = "nestjs-evoucher-claim"
+)
+
+// breakerOptions options ของ breaker แต่ละตัว (ใช้ได้เฉพาะตอนสร้าง จึงลงทะเบียนไว้ที่เดียว ไม่ขึ้นกับว่าใครเรียก Get ก่อน)
+var breakerOptions = map[string][]Option{
+	// duplicate key (ข้อความถูกบันทึกไปแล้วจาก retry ก่อนหน้า) ไม่นับเป็น failure ของ database
+	MongoWrite: {WithFailurePredicate(func(err error) bool {
+		return !mongo.IsDuplicateKeyError(err) && !errors.Is(err, context.Canceled)
+	})},
+	// redis.Nil คือ cache miss ไม่ใช่ Redis ล่ม
+	RedisCache: {WithFailurePredicate(func(err error) bool {
+		return !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled)
+	})},
+	KafkaEmit:           nil,
+	EvoucherClaimNestJS: nil,
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = newRegistry(Settings{Enabled: false})
+)
+
+// SettingsFromConfig แปลง AsyncFlowConfig.CircuitBreaker เป็น Settings
+func SettingsFromConfig(cfg *config.Config) Settings {
+	cb := cfg.AsyncFlow.CircuitBreaker
+	return Settings{
+		Enabled:          cb.Enabled,
+		FailureThreshold: cb.FailureThreshold,
+		SuccessThreshold: cb.SuccessThreshold,
+		Timeout:          cb.Timeout,
+		HalfOpenMaxCalls: cb.HalfOpenMaxCalls,
+	}
+}
+
+// Configure สร้าง breaker ทุกตัวใน breakerOptions ด้วย settings (เรียกครั้งเดียวตอน startup ก่อนเริ่มใช้ breaker)
+func Configure(settings Settings) {
+	breakers := newRegistry(settings)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = breakers
+}
+
+func newRegistry(settings Settings) map[string]*Breaker {
+	breakers := make(map[string]*Breaker, len(breakerOptions))
+	for name, opts := range breakerOptions {
+		breakers[name] = New(name, settings, opts...)
+	}
+	return breakers
+}
+
+// Get คืน breaker ที่ลงทะเบียนไว้ (ชื่อที่ไม่อยู่ใน breakerOptions เป็น bug จึง panic)
+func Get(name string) *Breaker {
+	registryMu.RLock()
+	b, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("circuitbreaker: unknown breaker %q", name))
+	}
+	return b
+}
+
+// Snapshots คืนสถานะของ breaker ทุกตัว (เรียงตามชื่อ)
+func Snapshots() []Snapshot {
+	registryMu.RLock()
+	breakers := make([]*Breaker, 0, len(registry))
+	for _, b := range registry {
+		breakers = append(breakers, b)
+	}
+	registryMu.RUnlock()
+
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].name < breakers[j].name
+	})
+
+	snapshots := make([]Snapshot, len(breakers))
+	for i, b := range breakers {
+		snapshots[i] = b.Snapshot()
+	}
+	return snapshots
+}
+
+// AnyOpen ตรวจว่ามี breaker ตัวไหนเปิดอยู่หรือไม่
+func AnyOpen() bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, b := range registry {
+		if b.IsOpen() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package circuitbreaker
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRegistryAppliesOptionsRegardlessOfCaller(t *testing.T) {
+	Configure(testSettings())
+	t.Cleanup(func() { Configure(Settings{Enabled: false}) })
+
+	duplicate := mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}
+	for i := 0; i < 5; i++ {
+		_ = Get(MongoWrite).Execute(func() error { return duplicate })
+		_ = Get(RedisCache).Execute(func() error { return redis.Nil })
+	}
+	if got := Get(MongoWrite).State(); got != StateClosed {
+		t.Errorf("MongoWrite State() = %s after duplicate key errors, want closed", got)
+	}
+	if got := Get(RedisCache).State(); got != StateClosed {
+		t.Errorf("RedisCache State() = %s after cache misses, want closed", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = Get(MongoWrite).Execute(func() error { return errDependency })
+	}
+	if got := Get(MongoWrite).State(); got != StateOpen {
+		t.Errorf("MongoWrite State() = %s after dependency errors, want open", got)
+	}
+}
+
+func TestRegistryGetUnknownPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Get(unknown) did not panic")
+		}
+	}()
+	Get("unknown")
+}
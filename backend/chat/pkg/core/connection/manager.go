@@ -24,15 +24,18 @@ type (
 		PongWait          time.Duration // How long to wait for pong response
 		RateLimit         rate.Limit    // Rate limit for new connections (per second)
 		RateBurst         int           // Burst size for rate limiting
+		MaxRoomsPerSession int          // Maximum rooms a multiplexed session may subscribe to (0 = unlimited)
 	}
 
 	// ConnectionManager handles WebSocket connection management and limits
 	ConnectionManager struct {
-		config ConnectionConfig
+		config   ConnectionConfig
+		limitsMu sync.RWMutex // ป้องกัน limit ที่ปรับได้ตอน runtime (UpdateLimits)
 	
 		// Connection tracking
-		activeConnections int32
-		connectionsByIP   sync.Map // map[string]int32
+		activeConnections   int32
+		activeSubscriptions int32    // room subscriptions across all sessions
+		connectionsByIP     sync.Map // map[string]int32
 	
 		// Resource management
 		writeBufferPool sync.Pool
@@ -54,6 +57,7 @@ func DefaultConfig() ConnectionConfig {
 		PongWait:          60 * time.Second,  // Allow time for slow connections
 		RateLimit:         0,                 // **ปิด rate limiting สำหรับงานกิจกรรม**
 		RateBurst:         0,                 // **ปิด burst limiting**
+		MaxRoomsPerSession: 50,               // session เดียว subscribe ได้สูงสุด 50 ห้อง
 	}
 }
 
@@ -83,6 +87,9 @@ func (cm *ConnectionManager) GetWebSocketConfig() websocket.Config {
 
 // HandleNewConnection attempts to register a new connection
 func (cm *ConnectionManager) HandleNewConnection(ip string) error {
+	cm.limitsMu.RLock()
+	defer cm.limitsMu.RUnlock()
+
 	// **ข้าม rate limiting สำหรับงานกิจกรรม**
 	// Apply rate limiting
 	if cm.config.RateLimit > 0 && !cm.rateLimiter.Allow() {
@@ -116,9 +123,13 @@ func (cm *ConnectionManager) HandleNewConnection(ip string) error {
 // RemoveConnection removes a connection from tracking
 func (cm *ConnectionManager) RemoveConnection(ip string) {
 	atomic.AddInt32(&cm.activeConnections, -1)
-	
+
+	cm.limitsMu.RLock()
+	perIPLimit := cm.config.MaxConnectionsPerIP
+	cm.limitsMu.RUnlock()
+
 	// **จัดการ IP counting เฉพาะเมื่อมี IP limit**
-	if cm.config.MaxConnectionsPerIP > 0 {
+	if perIPLimit > 0 {
 		if ipConns, ok := cm.connectionsByIP.Load(ip); ok {
 			newCount := ipConns.(int32) - 1
 			if newCount <= 0 {
@@ -163,4 +174,51 @@ func (cm *ConnectionManager) GetConnectionsByIP(ip string) int32 {
 		return conns.(int32)
 	}
 	return 0
-} 
\ No newline at end of file
+} 
+
+// CanSubscribe checks whether a session holding currentRooms subscriptions may subscribe to another room.
+// A multiplexed session counts as a single connection; subscriptions are tracked separately.
+func (cm *ConnectionManager) CanSubscribe(currentRooms int) error {
+	cm.limitsMu.RLock()
+	defer cm.limitsMu.RUnlock()
+
+	if cm.config.MaxRoomsPerSession > 0 && currentRooms >= cm.config.MaxRoomsPerSession {
+		return errors.New("maximum rooms per session reached")
+	}
+	return nil
+}
+
+// AddSubscription records a room subscription on an existing session
+func (cm *ConnectionManager) AddSubscription() {
+	atomic.AddInt32(&cm.activeSubscriptions, 1)
+}
+
+// RemoveSubscription removes a room subscription from tracking
+func (cm *ConnectionManager) RemoveSubscription() {
+	atomic.AddInt32(&cm.activeSubscriptions, -1)
+}
+
+// GetActiveSubscriptions returns the current number of room subscriptions across all sessions
+func (cm *ConnectionManager) GetActiveSubscriptions() int32 {
+	return atomic.LoadInt32(&cm.activeSubscriptions)
+}
+
+// UpdateLimits ปรับ limit ที่เปลี่ยนได้ตอน runtime (จาก runtime settings)
+// buffer, timeout และ ping interval ยังคงใช้ค่าตอนสร้างเพราะผูกกับ connection ที่เปิดอยู่แล้ว
+func (cm *ConnectionManager) UpdateLimits(maxConnections, maxConnectionsPerIP int32, rateLimit rate.Limit, rateBurst, maxRoomsPerSession int) {
+	cm.limitsMu.Lock()
+	defer cm.limitsMu.Unlock()
+
+	cm.config.MaxConnections = maxConnections
+	cm.config.MaxConnectionsPerIP = maxConnectionsPerIP
+	cm.config.MaxRoomsPerSession = maxRoomsPerSession
+
+	if rateLimit != cm.config.RateLimit || rateBurst != cm.config.RateBurst {
+		cm.config.RateLimit = rateLimit
+		cm.config.RateBurst = rateBurst
+		cm.rateLimiter = nil
+		if rateLimit > 0 {
+			cm.rateLimiter = rate.NewLimiter(rateLimit, rateBurst)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package kafka
 
 import (
+	"chat/pkg/core/circuitbreaker"
+	"chat/pkg/core/metrics"
+	"chat/pkg/core/tracing"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,6 +12,8 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type (
@@ -39,6 +44,36 @@ type (
 	}
 )
 
+// headerCarrier ให้ propagator อ่าน/เขียน trace context ใน Kafka message header
+type headerCarrier []kafka.Header
+
+func (hc *headerCarrier) Get(key string) string {
+	for _, h := range *hc {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (hc *headerCarrier) Set(key, value string) {
+	for i, h := range *hc {
+		if h.Key == key {
+			(*hc)[i].Value = []byte(value)
+			return
+		}
+	}
+	*hc = append(*hc, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (hc *headerCarrier) Keys() []string {
+	keys := make([]string, len(*hc))
+	for i, h := range *hc {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
 func isEmptyKafkaMessage(value []byte) bool {
 	var data map[string]interface{}
 	if err := json.Unmarshal(value, &data); err != nil {
@@ -183,24 +218,64 @@ func (b *Bus) getWriter(topic string) (*kafka.Writer, error) {
 }
 
 // Emit ส่ง message ไปยัง topic
-func (b *Bus) Emit(ctx context.Context, topic, key string, payload any) error {
+func (b *Bus) Emit(ctx context.Context, topic, key string, payload any) (err error) {
 	// แปลง payload เป็น JSON ก่อนเพื่อตรวจสอบ empty
 	value, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	writer, err := b.getWriter(topic)
-	if err != nil {
-		return fmt.Errorf("failed to get writer for topic %s: %v", topic, err)
-	}
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "kafka.emit "+metrics.TopicKind(topic),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
+	defer func() {
+		metrics.ObserveKafkaEmit(topic, start, err)
+		tracing.End(span, err)
+	}()
+
+	// trace context ไปกับ header เพื่อให้ consumer (อาจเป็น instance อื่น) ต่อ trace เดียวกันได้
+	var headers []kafka.Header
+	tracing.Inject(ctx, (*headerCarrier)(&headers))
+
+	// ผ่าน circuit breaker เพื่อไม่ให้ broker ที่ล่มทำให้ทุก emit ค้างรอ timeout
+	return circuitbreaker.Get(circuitbreaker.KafkaEmit).Execute(func() error {
+		writer, err := b.getWriter(topic)
+		if err != nil {
+			return fmt.Errorf("failed to get writer for topic %s: %v", topic, err)
+		}
 
-	return writer.WriteMessages(ctx, kafka.Message{
-		Key:   []byte(key),
-		Value: value,
+		return writer.WriteMessages(ctx, kafka.Message{
+			Key:     []byte(key),
+			Value:   value,
+			Headers: headers,
+		})
 	})
 }
 
+// Ping ตรวจว่าเชื่อมต่อ broker ได้ (ใช้กับ readiness probe)
+func (b *Bus) Ping(ctx context.Context) error {
+	if len(b.brokers) == 0 {
+		return fmt.Errorf("no kafka brokers configured")
+	}
+
+	var lastErr error
+	for _, broker := range b.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn.Close()
+		return nil
+	}
+	return fmt.Errorf("no kafka broker reachable: %v", lastErr)
+}
+
 // เริ่มต้นการทำงาน
 func (b *Bus) Start() error {
 
@@ -286,6 +361,9 @@ func (b *Bus) consume(topic string, reader *kafka.Reader) {
 				continue
 			}
 
+			// ต่อ trace จาก producer (header ที่ Emit ใส่ไว้)
+			parentCtx := tracing.Extract(context.Background(), (*headerCarrier)(&msg.Headers))
+
 			// จับคู่ topic กับ handler
 			for _, h := range b.handlers[topic] {
 				go func(handler HandlerFunc) {
@@ -295,8 +373,19 @@ func (b *Bus) consume(topic string, reader *kafka.Reader) {
 						}
 					}()
 
+					ctx, span := tracing.Tracer().Start(parentCtx, "kafka.consume "+metrics.TopicKind(topic),
+						trace.WithSpanKind(trace.SpanKindConsumer),
+						trace.WithAttributes(
+							attribute.String("messaging.system", "kafka"),
+							attribute.String("messaging.destination.name", topic),
+							attribute.Int64("messaging.kafka.offset", msg.Offset),
+						),
+					)
+
 					// เรียกใช้งาน handler
-					if err := handler(context.Background(), wrapped); err != nil {
+					err := handler(ctx, wrapped)
+					tracing.End(span, err)
+					if err != nil {
 						log.Printf("[Kafka] Handler error (will not commit): %v", err)
 						// Optional: retry / push to DLQ
 						return
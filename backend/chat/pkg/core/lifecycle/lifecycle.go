@@ -0,0 +1,36 @@
+package lifecycle
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// สถานะของ instance ระหว่าง shutdown
+// เมื่อเริ่ม drain แล้ว health check จะตอบ 503 และไม่รับ WebSocket ใหม่ เพื่อให้ load balancer ย้าย traffic ออก
+
+var (
+	draining     atomic.Bool
+	drainStarted atomic.Int64 // unix nano
+)
+
+// BeginDrain เข้าสู่โหมด drain (เรียกซ้ำได้ คืน false ถ้าเคยเริ่มแล้ว)
+func BeginDrain() bool {
+	if !draining.CompareAndSwap(false, true) {
+		return false
+	}
+	drainStarted.Store(time.Now().UnixNano())
+	return true
+}
+
+// IsDraining ตรวจว่ากำลัง drain อยู่หรือไม่
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// DrainStartedAt เวลาที่เริ่ม drain (zero ถ้ายังไม่เริ่ม)
+func DrainStartedAt() time.Time {
+	if ns := drainStarted.Load(); ns > 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,154 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// สถานะของ dependency ใน readiness report
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded" // dependency ที่ไม่ critical ล่ม: ยัง ready แต่ควรแจ้งเตือน
+)
+
+type (
+	// CheckFunc ตรวจ dependency หนึ่งตัว คืน error ถ้าใช้งานไม่ได้
+	CheckFunc func(ctx context.Context) error
+
+	readinessCheck struct {
+		name     string
+		critical bool
+		fn       CheckFunc
+	}
+
+	// DependencyStatus ผลตรวจ dependency หนึ่งตัว
+	DependencyStatus struct {
+		Status    string  `json:"status"`
+		Critical  bool    `json:"critical"`
+		LatencyMs float64 `json:"latencyMs"`
+		Error     string  `json:"error,omitempty"`
+	}
+
+	// ReadinessReport ผลรวมของทุก check
+	ReadinessReport struct {
+		Ready        bool                        `json:"ready"`
+		Draining     bool                        `json:"draining"`
+		CheckedAt    time.Time                   `json:"checkedAt"`
+		Dependencies map[string]DependencyStatus `json:"dependencies"`
+	}
+
+	// Readiness รวม dependency check และ cache ผลไว้ช่วงสั้นๆ เพื่อไม่ให้ probe ยิง dependency ถี่เกินไป
+	Readiness struct {
+		mu       sync.Mutex
+		checks   []readinessCheck
+		cacheTTL time.Duration
+		timeout  time.Duration
+		last     *ReadinessReport
+	}
+)
+
+var startedAt = time.Now()
+
+// NewReadiness สร้าง readiness checker (cacheTTL = อายุผลตรวจ, timeout = เวลาสูงสุดต่อรอบ)
+func NewReadiness(cacheTTL, timeout time.Duration) *Readiness {
+	return &Readiness{cacheTTL: cacheTTL, timeout: timeout}
+}
+
+// AddCheck ลงทะเบียน check (critical = ล่มแล้ว instance ไม่ ready)
+func (r *Readiness) AddCheck(name string, critical bool, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, readinessCheck{name: name, critical: critical, fn: fn})
+}
+
+// Check คืนผลตรวจ (ใช้ cache ถ้ายังไม่หมดอายุ) การเรียกพร้อมกันจะรอผลรอบเดียวกัน
+func (r *Readiness) Check(ctx context.Context) ReadinessReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.last == nil || time.Since(r.last.CheckedAt) >= r.cacheTTL {
+		r.last = r.run(ctx)
+	}
+
+	report := *r.last
+	// drain state ไม่ cache เพื่อให้ไม่ ready ทันทีที่ได้ SIGTERM
+	report.Draining = IsDraining()
+	if report.Draining {
+		report.Ready = false
+	}
+	return report
+}
+
+func (r *Readiness) run(ctx context.Context) *ReadinessReport {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	report := &ReadinessReport{
+		Ready:        true,
+		Dependencies: make(map[string]DependencyStatus, len(r.checks)),
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, check := range r.checks {
+		wg.Add(1)
+		go func(check readinessCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.fn(ctx)
+			status := DependencyStatus{
+				Status:    StatusUp,
+				Critical:  check.critical,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				status.Status = StatusDegraded
+				if check.critical {
+					status.Status = StatusDown
+				}
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Dependencies[check.name] = status
+			if err != nil && check.critical {
+				report.Ready = false
+			}
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	report.CheckedAt = time.Now()
+	return report
+}
+
+// Handler คืน fiber handler ของ /readyz (200 = ready, 503 = not ready)
+func (r *Readiness) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := r.Check(c.Context())
+		status := fiber.StatusOK
+		if !report.Ready {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(report)
+	}
+}
+
+// LivenessHandler คืน fiber handler ของ /livez ตอบทันทีโดยไม่แตะ dependency
+// (dependency ล่มไม่ควรทำให้ orchestrator restart process)
+func LivenessHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status":        "ok",
+			"uptimeSeconds": int64(time.Since(startedAt).Seconds()),
+		})
+	}
+}
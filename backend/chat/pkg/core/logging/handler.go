@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// key ที่ค่าไม่ควรอยู่ใน log เลย
+	sensitiveKeys = []string{"token", "password", "secret", "authorization", "cookie", "jwt", "apikey", "api_key"}
+
+	jwtPattern         = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern      = regexp.MustCompile(`(?i)(bearer\s+)[^\s"']+`)
+	urlPasswordPattern = regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`)
+
+	// tag ของ log.Printf เดิมที่บอก level ไม่ใช่ module
+	levelTags = map[string]slog.Level{
+		"DEBUG":   slog.LevelDebug,
+		"TRACE":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"WARN":    slog.LevelWarn,
+		"WARNING": slog.LevelWarn,
+		"ALERT":   slog.LevelWarn,
+		"ERROR":   slog.LevelError,
+	}
+	legacyTagPattern = regexp.MustCompile(`\[([A-Za-z][A-Za-z0-9_-]*)\]`)
+)
+
+// handler ส่ง record ไปยัง sink ปัจจุบัน พร้อม module และ correlation field จาก context
+type handler struct {
+	module string
+	ops    []func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return enabled(h.module, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	sink := current.Load().sink
+	for _, op := range h.ops {
+		sink = op(sink)
+	}
+	if h.module != "" {
+		r.AddAttrs(slog.String("module", h.module))
+	}
+	r.AddAttrs(correlationAttrs(ctx)...)
+	return sink.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &handler{module: h.module, ops: append([]func(slog.Handler) slog.Handler{}, h.ops...)}
+	rest := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key == "module" {
+			next.module = strings.ToLower(a.Value.String())
+			continue
+		}
+		rest = append(rest, a)
+	}
+	if len(rest) > 0 {
+		next.ops = append(next.ops, func(s slog.Handler) slog.Handler { return s.WithAttrs(rest) })
+	}
+	return next
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	next := &handler{module: h.module, ops: append([]func(slog.Handler) slog.Handler{}, h.ops...)}
+	next.ops = append(next.ops, func(s slog.Handler) slog.Handler { return s.WithGroup(name) })
+	return next
+}
+
+// redactAttr ซ่อนค่าของ key ที่เป็นความลับ และ token/password ที่ฝังอยู่ในข้อความ
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	if a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, Redact(a.Value.String()))
+	}
+	return a
+}
+
+// Redact ซ่อน JWT, bearer token และ password ใน URL ที่อยู่ในข้อความ
+func Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.Contains(s, "eyJ") {
+		s = jwtPattern.ReplaceAllString(s, redacted)
+	}
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+	if strings.Contains(s, "://") {
+		s = urlPasswordPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+	}
+	return s
+}
+
+// legacyWriter รับ output ของ log package แล้วแปลงเป็น structured record
+type legacyWriter struct{}
+
+func (legacyWriter) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	module, level := parseLegacyLine(line)
+	if !enabled(module, level) {
+		return len(p), nil
+	}
+
+	r := slog.NewRecord(time.Now(), level, line, 0)
+	if module != "" {
+		r.AddAttrs(slog.String("module", module))
+	}
+	return len(p), current.Load().sink.Handle(context.Background(), r)
+}
+
+// parseLegacyLine เดา module จาก tag แรกที่ไม่ใช่ level ([ChatService] -> chatservice)
+// และ level จาก tag ([ERROR], [WARN]) หรือ emoji ที่ repo ใช้ (❌, ⚠️)
+func parseLegacyLine(line string) (string, slog.Level) {
+	module := ""
+	level := slog.LevelInfo
+	levelFound := false
+	for _, match := range legacyTagPattern.FindAllStringSubmatch(line, 3) {
+		tag := match[1]
+		if l, ok := levelTags[strings.ToUpper(tag)]; ok {
+			if !levelFound {
+				level, levelFound = l, true
+			}
+			continue
+		}
+		if module == "" {
+			module = strings.ToLower(tag)
+		}
+	}
+	if !levelFound {
+		switch {
+		case strings.Contains(line, "❌") || strings.Contains(line, "CRITICAL"):
+			level = slog.LevelError
+		case strings.Contains(line, "⚠️") || strings.Contains(line, "WARNING"):
+			level = slog.LevelWarn
+		}
+	}
+	return module, level
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader รับ request ID จาก gateway (ถ้ามี) และส่งกลับใน response
+const RequestIDHeader = "X-Request-Id"
+
+var httpLog = Module("http")
+
+// Middleware ผูก requestId กับ c.UserContext() และเขียน access log แบบ structured
+// (แทน logger middleware ของ fiber ที่เป็น text)
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		c.Set(RequestIDHeader, requestID)
+		c.SetUserContext(WithRequestID(c.UserContext(), requestID))
+
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			status = fiberErr.Code
+		}
+		httpLog.Log(c.UserContext(), levelForStatus(status), "request completed",
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"latencyMs", float64(time.Since(start).Microseconds())/1000,
+			"ip", c.IP(),
+		)
+		return err
+	}
+}
+
+func levelForStatus(status int) slog.Level {
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		return slog.LevelError
+	case status >= fiber.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,220 @@
+package logging
+
+import (
+	"chat/pkg/config"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// Structured logger ของ chat service (log/slog)
+//
+//	- output เป็น JSON (หรือ text ตอน dev) มี level และ module
+//	- requestId, userId, roomId, messageId และ traceId ถูกดึงจาก context ให้อัตโนมัติ (ใช้ *Context method)
+//	- token/secret ถูก redact ทั้งใน attribute และในข้อความ
+//	- level ตั้งแยกราย module ได้ (LOG_MODULE_LEVELS=ws=debug,cache=warn)
+//	- debug log ถูกจำกัดจำนวนต่อวินาทีต่อ module เพื่อไม่ให้ hot path ท่วม disk แม้เปิด debug
+//
+// log.Printf เดิมยังใช้ได้: ถูกส่งผ่าน logger นี้โดยเดา module จาก tag แรก ([WS] -> ws) และ level จาก [ERROR]/[WARN]/[DEBUG]
+
+type (
+	state struct {
+		sink         slog.Handler
+		level        slog.Level
+		moduleLevels map[string]slog.Level
+		debugLimit   rate.Limit
+	}
+
+	// Fields คือ correlation field ที่ผูกกับ context
+	Fields struct {
+		RequestID string
+		UserID    string
+		RoomID    string
+		MessageID string
+	}
+
+	fieldsKey struct{}
+)
+
+var (
+	current atomic.Pointer[state]
+
+	limitersMu sync.Mutex
+	limiters   = map[string]*rate.Limiter{}
+)
+
+func init() {
+	current.Store(&state{
+		sink:  slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr}),
+		level: slog.LevelInfo,
+	})
+}
+
+// Init ตั้งค่า logger จาก config และเปลี่ยน output ของ log package มาผ่าน logger นี้
+func Init(cfg config.LoggingConfig) error {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	moduleLevels, err := parseModuleLevels(cfg.ModuleLevels)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactAttr}
+	var sink slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		sink = slog.NewTextHandler(out, opts)
+	} else {
+		sink = slog.NewJSONHandler(out, opts)
+	}
+
+	limit := rate.Inf
+	if cfg.DebugPerSecond > 0 {
+		limit = rate.Limit(cfg.DebugPerSecond)
+	}
+	current.Store(&state{sink: sink, level: level, moduleLevels: moduleLevels, debugLimit: limit})
+
+	limitersMu.Lock()
+	limiters = map[string]*rate.Limiter{}
+	limitersMu.Unlock()
+
+	slog.SetDefault(slog.New(&handler{}))
+	// ต้องตั้งหลัง slog.SetDefault (ซึ่งจะผูก log package เข้ากับ handler ที่ไม่แยก module)
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(legacyWriter{})
+	return nil
+}
+
+// Module คืน logger ของ module (ใช้ได้ก่อน Init เพราะอ่าน config ตอนเขียน log)
+func Module(name string) *slog.Logger {
+	return slog.New(&handler{module: strings.ToLower(name)})
+}
+
+// ParseLevel แปลงชื่อ level (debug, info, warn, error)
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// parseModuleLevels แปลง "ws=debug,cache=warn"
+func parseModuleLevels(s string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		module, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid module level %q (expected module=level)", pair)
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.ToLower(strings.TrimSpace(module))] = level
+	}
+	return levels, nil
+}
+
+// enabled ตรวจ level ของ module และ rate limit ของ debug log
+func enabled(module string, level slog.Level) bool {
+	st := current.Load()
+	min := st.level
+	if moduleLevel, ok := st.moduleLevels[module]; ok {
+		min = moduleLevel
+	}
+	if level < min {
+		return false
+	}
+	if level > slog.LevelDebug || st.debugLimit == rate.Inf {
+		return true
+	}
+	return debugLimiter(module, st.debugLimit).Allow()
+}
+
+func debugLimiter(module string, limit rate.Limit) *rate.Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiter, ok := limiters[module]
+	if !ok {
+		limiter = rate.NewLimiter(limit, int(limit)+1)
+		limiters[module] = limiter
+	}
+	return limiter
+}
+
+// **Correlation fields**
+
+// FromContext คืน correlation field ใน ctx
+func FromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return Fields{}
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return fields
+}
+
+func withFields(ctx context.Context, update func(*Fields)) context.Context {
+	fields := FromContext(ctx)
+	update(&fields)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// WithRequestID ผูก requestId กับ ctx
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return withFields(ctx, func(f *Fields) { f.RequestID = requestID })
+}
+
+// WithUser ผูก userId กับ ctx
+func WithUser(ctx context.Context, userID string) context.Context {
+	return withFields(ctx, func(f *Fields) { f.UserID = userID })
+}
+
+// WithRoom ผูก roomId กับ ctx
+func WithRoom(ctx context.Context, roomID string) context.Context {
+	return withFields(ctx, func(f *Fields) { f.RoomID = roomID })
+}
+
+// WithMessage ผูก messageId กับ ctx
+func WithMessage(ctx context.Context, messageID string) context.Context {
+	return withFields(ctx, func(f *Fields) { f.MessageID = messageID })
+}
+
+// correlationAttrs คืน attribute จาก ctx (field ว่างไม่ใส่)
+func correlationAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	fields := FromContext(ctx)
+	attrs := make([]slog.Attr, 0, 5)
+	for _, f := range []struct{ key, value string }{
+		{"requestId", fields.RequestID},
+		{"userId", fields.UserID},
+		{"roomId", fields.RoomID},
+		{"messageId", fields.MessageID},
+	} {
+		if f.value != "" {
+			attrs = append(attrs, slog.String(f.key, f.value))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("traceId", sc.TraceID().String()))
+	}
+	return attrs
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// metric ของ chat service ที่ใช้ร่วมกันทุก module
+// label ทั้งหมดเป็นค่าจากชุดจำกัด (type, pool, cache, result, action) ยกเว้น room ที่ต้องเปิดเอง
+var (
+	MessagesSent = NewCounterVec("chat_messages_sent_total",
+		"Messages accepted and broadcast, by message type.", "type")
+	MessagesPersisted = NewCounterVec("chat_messages_persisted_total",
+		"Messages written to MongoDB, by message type.", "type")
+	RoomMessagesSent = NewCounterVec("chat_room_messages_sent_total",
+		"Messages broadcast per room (only when METRICS_ROOM_LABELS is enabled).", "room")
+
+	WorkerRetries = NewCounterVec("chat_worker_retries_total",
+		"Jobs requeued for retry, by worker pool (outbox: stream entries reclaimed for redelivery).", "pool")
+	WorkerJobsFailed = NewCounterVec("chat_worker_jobs_failed_total",
+		"Jobs that failed permanently after all retries, by worker pool (outbox: entries moved to the dead-letter stream).", "pool")
+
+	PhantomMessagesFound = NewCounterVec("chat_phantom_messages_found_total",
+		"Messages found by the phantom detector with incomplete persistence.")
+	PhantomMessagesFixed = NewCounterVec("chat_phantom_messages_fixed_total",
+		"Phantom messages repaired by the phantom detector.")
+
+	KafkaEmitDuration = NewHistogramVec("chat_kafka_emit_duration_seconds",
+		"Latency of Kafka Bus.Emit, by topic kind and result.", nil, "topic", "result")
+	KafkaEmitErrors = NewCounterVec("chat_kafka_emit_errors_total",
+		"Kafka Bus.Emit errors, by topic kind.", "topic")
+
+	CacheRequests = NewCounterVec("chat_cache_requests_total",
+		"Cache lookups, by cache and result (hit, miss, error).", "cache", "result")
+	CacheStampedesAvoided = NewCounterVec("chat_cache_stampedes_avoided_total",
+		"Cache misses served by another in-flight loader instead of MongoDB, by cache and scope (local, remote).", "cache", "scope")
+	CacheWarmupRooms = NewCounterVec("chat_cache_warmup_rooms_total",
+		"Rooms processed by the cache warm-up job, by result (warmed, skipped, error).", "result")
+
+	RetentionMessages = NewCounterVec("chat_retention_messages_total",
+		"Messages removed from chat-messages by the retention worker, by action (purged, archived).", "action")
+
+	RestrictionActions = NewCounterVec("chat_restriction_actions_total",
+		"Moderation actions applied, by action.", "action")
+)
+
+// ค่า label ของ cache
+const (
+	CacheChatMessages = "chat_messages"
+	CacheRoom         = "room"
+	CacheRoomMembers  = "room_members"
+
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+var (
+	enabled    atomic.Bool
+	roomLabels atomic.Bool
+
+	// objectIDPattern ใช้ตัด room/user ID ออกจากชื่อ topic (chat-room-<id> -> chat-room)
+	objectIDPattern = regexp.MustCompile(`-?[0-9a-f]{24}$`)
+)
+
+func init() {
+	enabled.Store(true)
+}
+
+// Configure กำหนดว่าเปิด /metrics และ label ระดับห้องหรือไม่ (เรียกครั้งเดียวตอน startup)
+func Configure(metricsEnabled, roomLabelsEnabled bool) {
+	enabled.Store(metricsEnabled)
+	roomLabels.Store(roomLabelsEnabled)
+}
+
+// Enabled ตรวจว่าเปิด /metrics อยู่หรือไม่
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// RoomLabelsEnabled ตรวจว่าอนุญาตให้ใช้ room ID เป็น label หรือไม่ (cardinality สูง)
+func RoomLabelsEnabled() bool {
+	return roomLabels.Load()
+}
+
+// ObserveRoomMessage นับข้อความรายห้อง เฉพาะเมื่อเปิด room labels
+func ObserveRoomMessage(roomID string) {
+	if RoomLabelsEnabled() {
+		RoomMessagesSent.Inc(roomID)
+	}
+}
+
+// TopicKind ตัด ID ท้ายชื่อ topic ออกเพื่อไม่ให้ label โตตามจำนวนห้อง
+func TopicKind(topic string) string {
+	return objectIDPattern.ReplaceAllString(topic, "")
+}
+
+// ObserveKafkaEmit บันทึก latency และ error ของการ emit
+func ObserveKafkaEmit(topic string, start time.Time, err error) {
+	kind := TopicKind(topic)
+	result := "ok"
+	if err != nil {
+		result = "error"
+		KafkaEmitErrors.Inc(kind)
+	}
+	KafkaEmitDuration.Observe(time.Since(start).Seconds(), kind, result)
+}
+
+// Handler คืน fiber handler ของ /metrics
+func Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+		return c.Send(DefaultRegistry.Render())
+	}
+}
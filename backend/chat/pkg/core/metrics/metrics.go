@@ -0,0 +1,387 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prometheus text exposition format (0.0.4) แบบเบาๆ ไม่ต้องพึ่ง client library
+// ทุก vec มีเพดานจำนวน series เพื่อคุม label cardinality (เกินแล้วจะรวมไว้ที่ label "other")
+
+const (
+	// DefaultMaxSeries จำนวน label set สูงสุดต่อ metric
+	DefaultMaxSeries = 500
+	// OverflowLabel ค่า label ที่ใช้เมื่อเกิน DefaultMaxSeries
+	OverflowLabel = "other"
+)
+
+// DefaultBuckets สำหรับ latency เป็นวินาที
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+type (
+	collector interface {
+		write(buf *bytes.Buffer)
+	}
+
+	// Registry เก็บ metric ทั้งหมดที่จะ expose ผ่าน /metrics
+	Registry struct {
+		mu         sync.RWMutex
+		collectors []collector
+		names      map[string]bool
+	}
+
+	metricDesc struct {
+		name       string
+		help       string
+		metricType string
+		labelNames []string
+	}
+
+	series struct {
+		labelValues []string
+		value       float64
+	}
+
+	// CounterVec คือ counter ที่แยกตาม label
+	CounterVec struct {
+		metricDesc
+		mu     sync.Mutex
+		series map[string]*series
+	}
+
+	// GaugeVec คือ gauge ที่แยกตาม label
+	GaugeVec struct {
+		metricDesc
+		mu     sync.Mutex
+		series map[string]*series
+	}
+
+	// GaugeFunc อ่านค่า ณ เวลาที่ scrape (เช่น ขนาด queue) ค่าที่คืนคือ label value -> ค่า
+	GaugeFunc struct {
+		metricDesc
+		fn func() map[string]float64
+	}
+
+	histogramSeries struct {
+		labelValues []string
+		counts      []uint64
+		sum         float64
+		count       uint64
+	}
+
+	// HistogramVec คือ histogram ที่แยกตาม label
+	HistogramVec struct {
+		metricDesc
+		buckets []float64
+		mu      sync.Mutex
+		series  map[string]*histogramSeries
+	}
+)
+
+// DefaultRegistry คือ registry กลางที่ /metrics ใช้
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates a new registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric name " + name)
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Render เขียน metric ทั้งหมดในรูปแบบ Prometheus text
+func (r *Registry) Render() []byte {
+	r.mu.RLock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.write(&buf)
+	}
+	return buf.Bytes()
+}
+
+// NewCounterVec สร้างและลงทะเบียน counter ใน DefaultRegistry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		metricDesc: metricDesc{name: name, help: help, metricType: "counter", labelNames: labelNames},
+		series:     make(map[string]*series),
+	}
+	DefaultRegistry.register(name, c)
+	return c
+}
+
+// Inc เพิ่ม counter ทีละ 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add เพิ่ม counter (ค่าติดลบจะถูกละเลย)
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lookupSeries(c.series, c.labelNames, labelValues).value += v
+}
+
+func (c *CounterVec) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(buf)
+	for _, s := range sortedSeries(c.series) {
+		writeSample(buf, c.name, c.labelNames, s.labelValues, "", "", s.value)
+	}
+}
+
+// NewGaugeVec สร้างและลงทะเบียน gauge ใน DefaultRegistry
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{
+		metricDesc: metricDesc{name: name, help: help, metricType: "gauge", labelNames: labelNames},
+		series:     make(map[string]*series),
+	}
+	DefaultRegistry.register(name, g)
+	return g
+}
+
+// Set กำหนดค่า gauge
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	lookupSeries(g.series, g.labelNames, labelValues).value = v
+}
+
+// Add เพิ่ม/ลดค่า gauge
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	lookupSeries(g.series, g.labelNames, labelValues).value += v
+}
+
+// Inc เพิ่ม gauge ทีละ 1
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec ลด gauge ทีละ 1
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *GaugeVec) write(buf *bytes.Buffer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(buf)
+	for _, s := range sortedSeries(g.series) {
+		writeSample(buf, g.name, g.labelNames, s.labelValues, "", "", s.value)
+	}
+}
+
+// NewGaugeFunc สร้าง gauge ที่อ่านค่าตอน scrape
+// ถ้าไม่มี label ให้ fn คืน map ที่มี key "" เพียงตัวเดียว
+func NewGaugeFunc(name, help, labelName string, fn func() map[string]float64) *GaugeFunc {
+	var labelNames []string
+	if labelName != "" {
+		labelNames = []string{labelName}
+	}
+	g := &GaugeFunc{
+		metricDesc: metricDesc{name: name, help: help, metricType: "gauge", labelNames: labelNames},
+		fn:         fn,
+	}
+	DefaultRegistry.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(buf *bytes.Buffer) {
+	values := g.fn()
+	g.writeHeader(buf)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > DefaultMaxSeries {
+		keys = keys[:DefaultMaxSeries]
+	}
+	for _, k := range keys {
+		if len(g.labelNames) == 0 {
+			writeSample(buf, g.name, nil, nil, "", "", values[k])
+			continue
+		}
+		writeSample(buf, g.name, g.labelNames, []string{k}, "", "", values[k])
+	}
+}
+
+// NewHistogramVec สร้างและลงทะเบียน histogram ใน DefaultRegistry (buckets = nil ใช้ DefaultBuckets)
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &HistogramVec{
+		metricDesc: metricDesc{name: name, help: help, metricType: "histogram", labelNames: labelNames},
+		buckets:    buckets,
+		series:     make(map[string]*histogramSeries),
+	}
+	DefaultRegistry.register(name, h)
+	return h
+}
+
+// Observe บันทึกค่า 1 ครั้ง
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key, values := seriesKey(len(h.series), func(k string) bool { _, ok := h.series[k]; return ok }, h.labelNames, labelValues)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labelValues: values, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(buf)
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+		for i, bound := range h.buckets {
+			writeSample(buf, h.name+"_bucket", h.labelNames, s.labelValues, "le", formatFloat(bound), float64(s.counts[i]))
+		}
+		writeSample(buf, h.name+"_bucket", h.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(buf, h.name+"_sum", h.labelNames, s.labelValues, "", "", s.sum)
+		writeSample(buf, h.name+"_count", h.labelNames, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+func (d *metricDesc) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", d.name, d.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", d.name, d.metricType)
+}
+
+// lookupSeries หา series ตาม label values (สร้างใหม่ถ้ายังไม่มี)
+func lookupSeries(m map[string]*series, labelNames, labelValues []string) *series {
+	key, values := seriesKey(len(m), func(k string) bool { _, ok := m[k]; return ok }, labelNames, labelValues)
+	if s, ok := m[key]; ok {
+		return s
+	}
+	s := &series{labelValues: values}
+	m[key] = s
+	return s
+}
+
+// seriesKey คืน key และ label values ที่จะใช้ ถ้า series เต็มแล้วจะรวมไว้ที่ label "other"
+func seriesKey(size int, exists func(string) bool, labelNames, labelValues []string) (string, []string) {
+	if len(labelValues) != len(labelNames) {
+		fixed := make([]string, len(labelNames))
+		copy(fixed, labelValues)
+		labelValues = fixed
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	if size < DefaultMaxSeries || exists(key) {
+		return key, labelValues
+	}
+	values := overflowValues(labelNames)
+	return strings.Join(values, "\xff"), values
+}
+
+func overflowValues(labelNames []string) []string {
+	values := make([]string, len(labelNames))
+	for i := range values {
+		values[i] = OverflowLabel
+	}
+	return values
+}
+
+func sortedSeries(m map[string]*series) []*series {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = m[k]
+	}
+	return out
+}
+
+func writeSample(buf *bytes.Buffer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	buf.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		buf.WriteByte('{')
+		first := true
+		for i, ln := range labelNames {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			fmt.Fprintf(buf, "%s=%q", ln, escapeLabel(labelValues[i]))
+		}
+		if extraName != "" {
+			if !first {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(buf, "%s=%q", extraName, extraValue)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+// escapeLabel ตัดอักขระที่ %q จะ escape เป็นรูปแบบที่ Prometheus ไม่รองรับ
+func escapeLabel(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDHeader ส่ง trace ID กลับให้ client เพื่อใช้อ้างอิงตอนแจ้งปัญหา
+const TraceIDHeader = "X-Trace-Id"
+
+// path ที่ไม่ต้อง trace (probe/scrape ถูกเรียกถี่และไม่มีประโยชน์ในการ debug)
+var skipPaths = map[string]bool{
+	"/metrics": true,
+	"/livez":   true,
+	"/readyz":  true,
+}
+
+// fiberCarrier อ่าน header ของ request เป็น TextMapCarrier
+type fiberCarrier struct {
+	c *fiber.Ctx
+}
+
+func (fc fiberCarrier) Get(key string) string { return fc.c.Get(key) }
+func (fc fiberCarrier) Set(key, value string) { fc.c.Request().Header.Set(key, value) }
+func (fc fiberCarrier) Keys() []string {
+	keys := make([]string, 0)
+	fc.c.Request().Header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
+
+// Middleware สร้าง server span ต่อ HTTP request (ต่อจาก traceparent ของ client ถ้ามี)
+// span ถูกเก็บใน c.UserContext() ให้ handler ส่งต่อไปยัง service
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if skipPaths[c.Path()] {
+			return c.Next()
+		}
+
+		ctx := Extract(c.UserContext(), fiberCarrier{c})
+		ctx, span := Tracer().Start(ctx, "HTTP "+c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+				attribute.String("client.address", c.IP()),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		if traceID := TraceID(ctx); traceID != "" {
+			c.Set(TraceIDHeader, traceID)
+		}
+
+		err := c.Next()
+
+		// ใช้ route pattern เป็นชื่อ span เพื่อไม่ให้ ID ใน path ทำให้ชื่อไม่ซ้ำกัน
+		span.SetName(fmt.Sprintf("HTTP %s %s", c.Method(), c.Route().Path))
+		status := c.Response().StatusCode()
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			status = fiberErr.Code
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError || (err != nil && status < fiber.StatusBadRequest) {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		return err
+	}
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"chat/pkg/config"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Distributed tracing ของ chat service
+// trace เริ่มที่ HTTP request หรือ WebSocket frame แล้วไหลต่อผ่าน Kafka header ไปจนถึง DB/notification job
+// ถ้า TRACING_EXPORTER=none จะใช้ no-op provider ของ otel (ไม่มี overhead นอกจากสร้าง context)
+
+// ชนิด exporter ที่รองรับ
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const tracerName = "chat"
+
+// Init ตั้งค่า global tracer provider และ propagator คืนฟังก์ชัน shutdown สำหรับ flush span ที่ค้าง
+func Init(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	// propagator ตั้งเสมอ เพื่อให้ trace จาก upstream ไหลผ่าน Kafka ได้แม้ instance นี้ไม่ export
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlpOptions(cfg)...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Printf("[Tracing] ✅ Exporting traces via %s (service=%s, sampleRatio=%.2f)", cfg.Exporter, cfg.ServiceName, cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// otlpOptions รับ endpoint ได้ทั้งแบบ host:port และ URL เต็ม (ว่าง = ใช้ OTEL_EXPORTER_OTLP_* ของ SDK)
+func otlpOptions(cfg config.TracingConfig) []otlptracehttp.Option {
+	var opts []otlptracehttp.Option
+	switch {
+	case strings.Contains(cfg.Endpoint, "://"):
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	case cfg.Endpoint != "":
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return opts
+}
+
+// Tracer คืน tracer ของ service (อ่านจาก global provider ทุกครั้ง จึงใช้ได้ก่อน Init)
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start เริ่ม span ใหม่เป็นลูกของ span ใน ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ปิด span และบันทึก error (ถ้ามี) ใช้คู่กับ defer: defer func() { tracing.End(span, err) }()
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject เขียน trace context ลง carrier (Kafka header, stream field)
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract อ่าน trace context จาก carrier
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// LinkFrom คืน link ไปยัง span ใน ctx ใช้กับ batch ที่รวมหลาย trace ไว้ใน span เดียว
+func LinkFrom(ctx context.Context) (trace.Link, bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	return trace.Link{SpanContext: sc}, sc.IsValid()
+}
+
+// TraceID คืน trace ID ของ ctx (ว่างถ้าไม่มี span ที่ valid) ใช้ใส่ใน log/response header
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// **NEW: Versioned schema migrations**
+// ทุก migration มี version ไม่ซ้ำ (เรียงตามลำดับที่ต้องรัน) และถูกบันทึกใน collection schema-migrations เมื่อรันสำเร็จ
+// migration แบบ index ประกาศ Indexes อย่างเดียว Up/Down จะสร้าง/ลบให้ และ Verify ใช้ตรวจว่า index ยังอยู่ครบ
+// migration แบบข้อมูลเขียน Up เอง (Down = nil คือย้อนกลับไม่ได้)
+
+type (
+	Migration struct {
+		Version     int
+		Name        string
+		Description string
+		Indexes     []IndexSpec
+		Up          func(ctx context.Context, db *mongo.Database) error
+		Down        func(ctx context.Context, db *mongo.Database) error
+	}
+
+	// IndexSpec คือ index ที่ migration ต้องสร้าง (ต้องตั้งชื่อเสมอ เพื่อให้ Down ลบได้ถูกตัว)
+	IndexSpec struct {
+		Collection string
+		Model      mongo.IndexModel
+	}
+)
+
+// รหัส error ของ Mongo ที่เกี่ยวกับ index
+const (
+	mongoIndexNotFound        = 27
+	mongoIndexOptionsConflict = 85
+)
+
+// apply รัน Up ของ migration (สร้าง index ก่อนแล้วค่อยรัน Up ถ้ามี)
+func (m Migration) apply(ctx context.Context, db *mongo.Database) error {
+	for _, spec := range m.Indexes {
+		if err := createIndex(ctx, db, spec); err != nil {
+			return err
+		}
+	}
+	if m.Up != nil {
+		return m.Up(ctx, db)
+	}
+	return nil
+}
+
+// revert รัน Down ของ migration (รัน Down ก่อนแล้วค่อยลบ index)
+func (m Migration) revert(ctx context.Context, db *mongo.Database) error {
+	if !m.Reversible() {
+		return fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+	}
+	if m.Down != nil {
+		if err := m.Down(ctx, db); err != nil {
+			return err
+		}
+	}
+	for _, spec := range m.Indexes {
+		name := indexName(spec)
+		_, err := db.Collection(spec.Collection).Indexes().DropOne(ctx, name)
+		if err != nil && !isMongoCode(err, mongoIndexNotFound) {
+			return fmt.Errorf("failed to drop index %s on %s: %w", name, spec.Collection, err)
+		}
+	}
+	return nil
+}
+
+// Reversible ตรวจว่า migration ย้อนกลับได้ (index ลบได้เสมอ, ข้อมูลต้องมี Down)
+func (m Migration) Reversible() bool {
+	return m.Up == nil || m.Down != nil
+}
+
+func createIndex(ctx context.Context, db *mongo.Database, spec IndexSpec) error {
+	name := indexName(spec)
+	_, err := db.Collection(spec.Collection).Indexes().CreateOne(ctx, spec.Model)
+	if err == nil {
+		return nil
+	}
+	// มี index key เดียวกันอยู่แล้วในชื่ออื่น (เช่นสร้างมือไว้ก่อน) ถือว่าใช้ได้ Verify จะเทียบจาก key
+	if isMongoCode(err, mongoIndexOptionsConflict) {
+		log.Printf("[Migrate] ⚠️ Index %s on %s already exists with different name/options: %v", name, spec.Collection, err)
+		return nil
+	}
+	return fmt.Errorf("failed to create index %s on %s: %w", name, spec.Collection, err)
+}
+
+func indexName(spec IndexSpec) string {
+	if spec.Model.Options != nil && spec.Model.Options.Name != nil {
+		return *spec.Model.Options.Name
+	}
+	return ""
+}
+
+// verifyIndex ตรวจว่ามี index ชื่อเดียวกันหรือ key เดียวกันอยู่ใน collection
+func verifyIndex(ctx context.Context, db *mongo.Database, spec IndexSpec) (bool, error) {
+	cursor, err := db.Collection(spec.Collection).Indexes().List(ctx)
+	if err != nil {
+		return false, err
+	}
+	var existing []struct {
+		Name string `bson:"name"`
+		Key  bson.D `bson:"key"`
+	}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return false, err
+	}
+
+	wantKey, err := bson.Marshal(spec.Model.Keys)
+	if err != nil {
+		return false, err
+	}
+	for _, index := range existing {
+		if index.Name == indexName(spec) {
+			return true, nil
+		}
+		if key, err := bson.Marshal(index.Key); err == nil && sameKey(key, wantKey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sameKey เทียบ key document ทีละ field (ตัวเลขอาจเป็น int32/int64/double ตามที่ server เก็บ)
+func sameKey(a, b bson.Raw) bool {
+	aElems, errA := a.Elements()
+	bElems, errB := b.Elements()
+	if errA != nil || errB != nil || len(aElems) != len(bElems) {
+		return false
+	}
+	for i := range aElems {
+		if aElems[i].Key() != bElems[i].Key() {
+			return false
+		}
+		av, bv := aElems[i].Value(), bElems[i].Value()
+		if an, ok := av.AsInt64OK(); ok {
+			if bn, ok := bv.AsInt64OK(); ok && an == bn {
+				continue
+			}
+		}
+		if av.String() != bv.String() {
+			return false
+		}
+	}
+	return true
+}
+
+func isMongoCode(err error, code int) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return int(cmdErr.Code) == code
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorCode(code)
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MessageStatusRetention อายุของ document ใน message-status (TTL index)
+// phantom detector ใช้แค่ช่วง ASYNC_PHANTOM_MAX_AGE ที่เหลือเก็บไว้ให้ admin ตรวจย้อนหลัง
+const MessageStatusRetention = 7 * 24 * time.Hour
+
+// All คืน migration ทั้งหมด เพิ่ม migration ใหม่ต่อท้ายด้วย version ถัดไปเสมอ ห้ามแก้ตัวที่ deploy ไปแล้ว
+func All() []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Name:        "chat_message_indexes",
+			Description: "history by room (timestamp / seq) and messages by user",
+			Indexes: []IndexSpec{
+				index("chat-messages", "room_id_timestamp", bson.D{{Key: "room_id", Value: 1}, {Key: "timestamp", Value: -1}}),
+				index("chat-messages", "room_id_seq", bson.D{{Key: "room_id", Value: 1}, {Key: "seq", Value: 1}}),
+				index("chat-messages", "user_id_timestamp", bson.D{{Key: "user_id", Value: 1}, {Key: "timestamp", Value: -1}}),
+				index("chat-messages", "timestamp", bson.D{{Key: "timestamp", Value: -1}}),
+			},
+		},
+		{
+			Version:     2,
+			Name:        "user_restriction_indexes",
+			Description: "active ban/mute lookups and temporary restriction expiry",
+			Indexes: []IndexSpec{
+				index("user-restrictions", "user_id_room_id_type_status", bson.D{
+					{Key: "user_id", Value: 1}, {Key: "room_id", Value: 1}, {Key: "type", Value: 1}, {Key: "status", Value: 1},
+				}),
+				index("user-restrictions", "room_id_status", bson.D{{Key: "room_id", Value: 1}, {Key: "status", Value: 1}}),
+				index("user-restrictions", "status_duration_end_time", bson.D{
+					{Key: "status", Value: 1}, {Key: "duration", Value: 1}, {Key: "end_time", Value: 1},
+				}),
+			},
+		},
+		{
+			Version:     3,
+			Name:        "evoucher_claim_indexes",
+			Description: "claim lookups by user and evoucher",
+			Indexes: []IndexSpec{
+				// ไม่ใช้ unique เพราะข้อมูลเดิมอาจมี claim ซ้ำ ซึ่งจะทำให้ migration ล้มตอน startup
+				index("evoucher-claims", "user_id_evoucher_id", bson.D{{Key: "user_id", Value: 1}, {Key: "evoucher_id", Value: 1}}),
+			},
+		},
+		{
+			Version:     4,
+			Name:        "room_indexes",
+			Description: "rooms by status, type and member",
+			Indexes: []IndexSpec{
+				index("rooms", "status", bson.D{{Key: "status", Value: 1}}),
+				index("rooms", "type", bson.D{{Key: "type", Value: 1}}),
+				index("rooms", "members", bson.D{{Key: "members", Value: 1}}),
+			},
+		},
+		{
+			Version:     5,
+			Name:        "message_status_indexes",
+			Description: "status lookup by message and TTL expiry of old status documents",
+			Indexes: []IndexSpec{
+				index("message-status", "message_id", bson.D{{Key: "message_id", Value: 1}}),
+				index("message-status", "status_created_at", bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}),
+				{
+					Collection: "message-status",
+					Model: mongo.IndexModel{
+						Keys: bson.D{{Key: "updated_at", Value: 1}},
+						Options: options.Index().
+							SetName("updated_at_ttl").
+							SetExpireAfterSeconds(int32(MessageStatusRetention / time.Second)),
+					},
+				},
+			},
+		},
+		{
+			Version:     6,
+			Name:        "backfill_room_status",
+			Description: "rooms created before the status field are active (room service defaults empty status to active)",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("rooms").UpdateMany(ctx,
+					bson.M{"$or": []bson.M{{"status": bson.M{"$exists": false}}, {"status": ""}}},
+					bson.M{"$set": bson.M{"status": "active"}},
+				)
+				return err
+			},
+			// ย้อนกลับไม่ได้: หลังรันแล้วแยกไม่ออกว่าห้องไหนเคยไม่มี status
+		},
+		{
+			Version:     7,
+			Name:        "chat_message_archive_indexes",
+			Description: "archived history by room (timestamp / seq) and retention audit by room",
+			Indexes: []IndexSpec{
+				index("chat-messages-archive", "room_id_timestamp", bson.D{{Key: "room_id", Value: 1}, {Key: "timestamp", Value: -1}}),
+				index("chat-messages-archive", "room_id_seq", bson.D{{Key: "room_id", Value: 1}, {Key: "seq", Value: 1}}),
+				index("retention-audit", "room_id_started_at", bson.D{{Key: "room_id", Value: 1}, {Key: "started_at", Value: -1}}),
+			},
+		},
+		{
+			Version:     8,
+			Name:        "privacy_audit_indexes",
+			Description: "data export / erasure audit by user",
+			Indexes: []IndexSpec{
+				index("privacy-audit", "user_id_created_at", bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}),
+			},
+		},
+		{
+			Version:     9,
+			Name:        "admin_audit_indexes",
+			Description: "admin audit log by time, actor, action and target",
+			Indexes: []IndexSpec{
+				index("admin-audit", "timestamp", bson.D{{Key: "timestamp", Value: -1}}),
+				index("admin-audit", "actor_id_timestamp", bson.D{{Key: "actor_id", Value: 1}, {Key: "timestamp", Value: -1}}),
+				index("admin-audit", "action_timestamp", bson.D{{Key: "action", Value: 1}, {Key: "timestamp", Value: -1}}),
+				index("admin-audit", "target_type_target_id_timestamp", bson.D{
+					{Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}, {Key: "timestamp", Value: -1},
+				}),
+			},
+		},
+		{
+			Version:     10,
+			Name:        "room_invite_indexes",
+			Description: "invite lookup by token / join code, invites by room and redemptions by room and invite",
+			Indexes: []IndexSpec{
+				uniqueIndex("room-invites", "token_unique", bson.D{{Key: "token", Value: 1}}),
+				uniqueIndex("room-invites", "code_unique", bson.D{{Key: "code", Value: 1}}),
+				index("room-invites", "room_id_created_at", bson.D{{Key: "room_id", Value: 1}, {Key: "created_at", Value: -1}}),
+				index("room-invite-redemptions", "room_id_redeemed_at", bson.D{{Key: "room_id", Value: 1}, {Key: "redeemed_at", Value: -1}}),
+				index("room-invite-redemptions", "invite_id_redeemed_at", bson.D{{Key: "invite_id", Value: 1}, {Key: "redeemed_at", Value: -1}}),
+			},
+		},
+	}
+}
+
+func index(collection, name string, keys bson.D) IndexSpec {
+	return IndexSpec{
+		Collection: collection,
+		Model: mongo.IndexModel{
+			Keys:    keys,
+			Options: options.Index().SetName(name),
+		},
+	}
+}
+
+func uniqueIndex(collection, name string, keys bson.D) IndexSpec {
+	spec := index(collection, name, keys)
+	spec.Model.Options.SetUnique(true)
+	return spec
+}
@@ -0,0 +1,243 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	historyCollection = "schema-migrations"
+	lockCollection    = "schema-migrations-lock"
+	lockID            = "migrate"
+
+	lockPollInterval = time.Second
+)
+
+type (
+	Runner struct {
+		db         *mongo.Database
+		migrations []Migration
+		lockTTL    time.Duration
+		lockWait   time.Duration
+		owner      string
+	}
+
+	// Status คือสถานะของ migration หนึ่งตัว (สำหรับ migrate status)
+	Status struct {
+		Version    int        `json:"version"`
+		Name       string     `json:"name"`
+		Applied    bool       `json:"applied"`
+		AppliedAt  *time.Time `json:"appliedAt,omitempty"`
+		Reversible bool       `json:"reversible"`
+		MissingIdx []string   `json:"missingIndexes,omitempty"` // index ที่ควรมีแต่หายไป (เฉพาะที่ applied แล้ว)
+	}
+
+	appliedRecord struct {
+		Version    int       `bson:"_id"`
+		Name       string    `bson:"name"`
+		AppliedAt  time.Time `bson:"applied_at"`
+		DurationMs int64     `bson:"duration_ms"`
+		AppliedBy  string    `bson:"applied_by"`
+	}
+)
+
+// NewRunner สร้าง runner ของ migration ทั้งหมดใน All()
+// lockTTL = อายุ lock (กันกรณี instance ตายระหว่างรัน), lockWait = เวลาที่รอ instance อื่นรันให้เสร็จ
+func NewRunner(db *mongo.Database, lockTTL, lockWait time.Duration) *Runner {
+	host, _ := os.Hostname()
+	migrations := All()
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return &Runner{
+		db:         db,
+		migrations: migrations,
+		lockTTL:    lockTTL,
+		lockWait:   lockWait,
+		owner:      fmt.Sprintf("%s-%s", host, uuid.NewString()[:8]),
+	}
+}
+
+// Up รัน migration ที่ยังไม่เคยรันทั้งหมดตามลำดับ version (หยุดที่ตัวแรกที่ล้ม)
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	release, err := r.acquireLock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	applied, err := r.appliedVersions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range r.migrations {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+
+		log.Printf("[Migrate] ⬆️ Applying %d_%s", m.Version, m.Name)
+		start := time.Now()
+		if err := m.apply(ctx, r.db); err != nil {
+			return count, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		record := appliedRecord{
+			Version:    m.Version,
+			Name:       m.Name,
+			AppliedAt:  time.Now(),
+			DurationMs: time.Since(start).Milliseconds(),
+			AppliedBy:  r.owner,
+		}
+		if _, err := r.db.Collection(historyCollection).InsertOne(ctx, record); err != nil {
+			return count, fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("[Migrate] ✅ Applied %d_%s in %dms", m.Version, m.Name, record.DurationMs)
+		count++
+	}
+	return count, nil
+}
+
+// Down ย้อน migration ที่รันล่าสุด steps ตัว (หยุดที่ตัวที่ย้อนกลับไม่ได้)
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	release, err := r.acquireLock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	applied, err := r.appliedVersions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(r.migrations) - 1; i >= 0 && count < steps; i-- {
+		m := r.migrations[i]
+		if _, done := applied[m.Version]; !done {
+			continue
+		}
+
+		log.Printf("[Migrate] ⬇️ Reverting %d_%s", m.Version, m.Name)
+		if err := m.revert(ctx, r.db); err != nil {
+			return count, err
+		}
+		if _, err := r.db.Collection(historyCollection).DeleteOne(ctx, bson.M{"_id": m.Version}); err != nil {
+			return count, fmt.Errorf("failed to remove migration record %d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("[Migrate] ✅ Reverted %d_%s", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Status คืนสถานะของทุก migration พร้อมตรวจ index ของตัวที่รันแล้ว
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		status := Status{
+			Version:    m.Version,
+			Name:       m.Name,
+			Reversible: m.Reversible(),
+		}
+		if record, ok := applied[m.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+
+			for _, spec := range m.Indexes {
+				ok, err := verifyIndex(ctx, r.db, spec)
+				if err != nil {
+					return nil, fmt.Errorf("failed to verify index %s on %s: %w", indexName(spec), spec.Collection, err)
+				}
+				if !ok {
+					status.MissingIdx = append(status.MissingIdx, spec.Collection+"."+indexName(spec))
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Verify คืนรายชื่อ index ที่ migration รันไปแล้วแต่หายไปจาก collection (เช่นถูกลบมือ)
+func (r *Runner) Verify(ctx context.Context) ([]string, error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, status := range statuses {
+		missing = append(missing, status.MissingIdx...)
+	}
+	return missing, nil
+}
+
+func (r *Runner) appliedVersions(ctx context.Context) (map[int]appliedRecord, error) {
+	cursor, err := r.db.Collection(historyCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration history: %w", err)
+	}
+	var records []appliedRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode migration history: %w", err)
+	}
+
+	applied := make(map[int]appliedRecord, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// acquireLock ถือ lock ใน Mongo ให้มี instance เดียวที่รัน migration (รอได้ไม่เกิน lockWait)
+// lock ที่หมดอายุแล้ว (instance ตายระหว่างรัน) ถูกแย่งต่อได้
+func (r *Runner) acquireLock(ctx context.Context) (func(), error) {
+	collection := r.db.Collection(lockCollection)
+	deadline := time.Now().Add(r.lockWait)
+
+	for {
+		now := time.Now()
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": r.owner, "locked_at": now, "expires_at": now.Add(r.lockTTL)}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			release := func() {
+				if _, err := collection.DeleteOne(context.Background(), bson.M{"_id": lockID, "owner": r.owner}); err != nil {
+					log.Printf("[Migrate] ⚠️ Failed to release migration lock: %v", err)
+				}
+			}
+			return release, nil
+		}
+		// lock ยังไม่หมดอายุ: upsert ชน _id เดิม
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for migration lock held by another instance", r.lockWait)
+		}
+
+		log.Printf("[Migrate] ⏳ Migration lock held by another instance, waiting...")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
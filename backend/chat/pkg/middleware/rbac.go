@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
+	"chat/pkg/core/audit"
+	"chat/pkg/core/logging"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -40,6 +42,9 @@ func NewRBACMiddleware(db *mongo.Database) *RBACMiddleware {
 	return &RBACMiddleware{db: db}
 }
 
+// rbacLog ถูกเรียกทุก request: รายละเอียดการตรวจสิทธิ์อยู่ที่ debug และห้าม log ค่า token
+var rbacLog = logging.Module("rbac")
+
 // JWT Claims structure matching NestJS JwtPayload
 type JwtClaims struct {
 	Sub      string `json:"sub"`
@@ -60,15 +65,15 @@ func (r *RBACMiddleware) getUserIDFromToken(ctx *fiber.Ctx) (string, error) {
 
 // getUserRoleFromToken is a helper method to extract user role from JWT token directly from payload
 func (r *RBACMiddleware) getUserRoleFromToken(ctx *fiber.Ctx) (string, error) {
-	log.Printf("[RBAC] Starting getUserRoleFromToken")
+	rbacLog.Debug("resolving user role from token")
 
 	// Extract userID using the improved context extraction method
 	userID, err := r.ExtractUserIDFromContext(ctx)
 	if err != nil {
-		log.Printf("[RBAC] Failed to extract userID from context: %v", err)
+		rbacLog.Warn("failed to extract userID from context", "error", err)
 		return "", err
 	}
-	log.Printf("[RBAC] UserID extracted successfully: %s", userID)
+	rbacLog.Debug("userID extracted", "userId", userID)
 
 	tokenString, err := r.extractToken(ctx)
 	if err != nil {
@@ -79,89 +84,86 @@ func (r *RBACMiddleware) getUserRoleFromToken(ctx *fiber.Ctx) (string, error) {
 		return "", err
 	}
 
-	log.Printf("[RBAC] User role retrieved successfully: %s", role)
+	rbacLog.Debug("user role resolved", "role", role)
 
 	return role, nil
 }
 
 // FindUserByID finds a user by their userID (sub from JWT)
 func (r *RBACMiddleware) FindUserByID(userID string) (bson.M, error) {
-	log.Printf("[RBAC] Looking for user with ID: %s", userID)
+	rbacLog.Debug("looking up user", "userId", userID)
 
 	// Convert string userID to ObjectID
 	objectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		log.Printf("[RBAC] Invalid user ID format: %s - %v", userID, err)
+		rbacLog.Warn("invalid user ID format", "userId", userID, "error", err)
 		return nil, fmt.Errorf("invalid user ID format: %w", err)
 	}
-	log.Printf("[RBAC] User ID converted to ObjectID: %s", objectID.Hex())
 
 	// Find user by userID
 	var user bson.M
 	err = r.db.Collection("users").FindOne(context.TODO(), bson.D{{Key: "_id", Value: objectID}}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			log.Printf("[RBAC] User not found in database: %s", userID)
+			rbacLog.Warn("user not found", "userId", userID)
 			return nil, errors.New("user not found")
 		}
-		log.Printf("[RBAC] Database error while finding user: %s - %v", userID, err)
+		rbacLog.Error("database error while finding user", "userId", userID, "error", err)
 		return nil, err
 	}
 
-	log.Printf("[RBAC] User found successfully - ID: %s, Username: %s", userID, user["username"])
+	rbacLog.Debug("user found", "userId", userID, "username", user["username"])
 	return user, nil
 }
 
 // GetRoleNameByID finds a role name by role ID
 func (r *RBACMiddleware) GetRoleNameByID(roleID primitive.ObjectID) (string, error) {
-	log.Printf("[RBAC] Looking for role with ID: %s", roleID.Hex())
+	rbacLog.Debug("looking up role", "roleId", roleID.Hex())
 
 	// Find role by role ID in roles collection
 	var role bson.M
 	err := r.db.Collection("roles").FindOne(context.TODO(), bson.D{{Key: "_id", Value: roleID}}).Decode(&role)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			log.Printf("[RBAC] Role not found in database: %s", roleID.Hex())
+			rbacLog.Warn("role not found", "roleId", roleID.Hex())
 			return "", errors.New("role not found")
 		}
-		log.Printf("[RBAC] Database error while finding role: %s - %v", roleID.Hex(), err)
+		rbacLog.Error("database error while finding role", "roleId", roleID.Hex(), "error", err)
 		return "", err
 	}
 
 	// Get role name from role document
 	roleName, ok := role["name"].(string)
 	if !ok {
-		log.Printf("[RBAC] Role name field not found in role document: %s", roleID.Hex())
+		rbacLog.Warn("role document has no name", "roleId", roleID.Hex())
 		return "", errors.New("role name not found")
 	}
 
-	log.Printf("[RBAC] Role found successfully - ID: %s, Name: %s", roleID.Hex(), roleName)
+	rbacLog.Debug("role found", "roleId", roleID.Hex(), "role", roleName)
 	return roleName, nil
 }
 
 // RequireWritePermission checks if user has write permission in read-only rooms
 func (r *RBACMiddleware) RequireWritePermission() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		log.Printf("[RBAC] RequireWritePermission - Checking permissions for path: %s", ctx.Path())
+		rbacLog.Debug("checking write permission", "path", ctx.Path())
 
 		role, err := r.getUserRoleFromToken(ctx)
 		if err != nil {
-			log.Printf("[RBAC] RequireWritePermission - Authentication failed: %v", err)
+			rbacLog.Info("write permission: authentication failed", "path", ctx.Path(), "error", err)
 			return ctx.Status(401).JSON(fiber.Map{
 				"error":   "UNAUTHORIZED",
 				"message": "Authentication required",
 			})
 		}
 
-		log.Printf("[RBAC] RequireWritePermission - User role: %s, Required roles: [%s, %s]", role, RoleAdministrator, RoleStaff)
-
 		// Only Administrator and Staff can write in read-only rooms
 		if role == RoleAdministrator || role == RoleStaff {
-			log.Printf("[RBAC] RequireWritePermission - Access GRANTED for role: %s", role)
+			rbacLog.Debug("write permission granted", "role", role)
 			return ctx.Next()
 		}
 
-		log.Printf("[RBAC] RequireWritePermission - Access DENIED for role: %s", role)
+		rbacLog.Info("write permission denied", "path", ctx.Path(), "role", role)
 		return ctx.Status(403).JSON(fiber.Map{
 			"error":   "INSUFFICIENT_PERMISSIONS",
 			"message": "You don't have permission to write in this room",
@@ -171,26 +173,24 @@ func (r *RBACMiddleware) RequireWritePermission() fiber.Handler {
 
 func (r *RBACMiddleware) RequireWritePermissionForEvoucher() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		log.Printf("[RBAC] RequireWritePermissionForEvoucher - Checking permissions for path: %s", ctx.Path())
+		rbacLog.Debug("checking evoucher write permission", "path", ctx.Path())
 
 		role, err := r.getUserRoleFromToken(ctx)
 		if err != nil {
-			log.Printf("[RBAC] RequireWritePermissionForEvoucher - Authentication failed: %v", err)
+			rbacLog.Info("evoucher write permission: authentication failed", "path", ctx.Path(), "error", err)
 			return ctx.Status(401).JSON(fiber.Map{
 				"error":   "UNAUTHORIZED",
 				"message": "Authentication required",
 			})
 		}
 
-		log.Printf("[RBAC] RequireWritePermissionForEvoucher - User role: %s, Required roles: [%s, %s, %s]", role, RoleAdministrator, RoleStaff, RoleAE)
-
 		// Only Administrator and Staff and AE can write in read-only rooms
 		if role == RoleAdministrator || role == RoleStaff || role == RoleAE {
-			log.Printf("[RBAC] RequireWritePermissionForEvoucher - Access GRANTED for role: %s", role)
+			rbacLog.Debug("evoucher write permission granted", "role", role)
 			return ctx.Next()
 		}
 
-		log.Printf("[RBAC] RequireWritePermissionForEvoucher - Access DENIED for role: %s", role)
+		rbacLog.Info("evoucher write permission denied", "path", ctx.Path(), "role", role)
 		return ctx.Status(403).JSON(fiber.Map{
 			"error":   "INSUFFICIENT_PERMISSIONS",
 			"message": "You don't have permission to write in this room",
@@ -220,56 +220,53 @@ func (r *RBACMiddleware) RequireReadOnlyAccess() fiber.Handler {
 
 // GetUserRole retrieves the role of a user by their userID (sub from JWT)
 func (r *RBACMiddleware) GetUserRole(userID string) (string, error) {
-	log.Printf("[RBAC] Getting role for user ID: %s", userID)
+	rbacLog.Debug("getting role for user", "userId", userID)
 
 	// Find user by userID using FindUserByID
 	user, err := r.FindUserByID(userID)
 	if err != nil {
-		log.Printf("[RBAC] Failed to find user: %v", err)
+		rbacLog.Warn("failed to find user", "userId", userID, "error", err)
 		return "", err
 	}
 
 	// Get role ID from user document (user.role field)
 	roleID, ok := user["role"].(primitive.ObjectID)
 	if !ok {
-		log.Printf("[RBAC] User has no role assigned - userID: %s", userID)
+		rbacLog.Warn("user has no role assigned", "userId", userID)
 		return "", errors.New("user has no role assigned")
 	}
-	log.Printf("[RBAC] User role ID extracted: %s", roleID.Hex())
 
 	// Get role name by role ID
 	roleName, err := r.GetRoleNameByID(roleID)
 	if err != nil {
-		log.Printf("[RBAC] Failed to get role name: %v", err)
+		rbacLog.Warn("failed to get role name", "roleId", roleID.Hex(), "error", err)
 		return "", err
 	}
 
-	log.Printf("[RBAC] Role lookup completed - User: %s, Role: %s, RoleID: %s", userID, roleName, roleID.Hex())
+	rbacLog.Debug("role lookup completed", "userId", userID, "role", roleName, "roleId", roleID.Hex())
 	return roleName, nil
 }
 
 // RequireRoles (refactored)
 func (r *RBACMiddleware) RequireRoles(allowedRoles ...string) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		log.Printf("[RBAC] RequireRoles - Checking permissions for path: %s", ctx.Path())
-		log.Printf("[RBAC] RequireRoles - Allowed roles: %v", allowedRoles)
+		rbacLog.Debug("checking roles", "path", ctx.Path(), "allowedRoles", allowedRoles)
 
 		role, err := r.getUserRoleFromToken(ctx)
 		if err != nil {
-			log.Printf("[RBAC] RequireRoles - Authentication failed: %v", err)
+			rbacLog.Info("require roles: authentication failed", "path", ctx.Path(), "error", err)
 			return ctx.Status(401).JSON(fiber.Map{"error": "UNAUTHORIZED", "message": "Authentication required"})
 		}
 
-		log.Printf("[RBAC] RequireRoles - User role: %s", role)
-
 		for _, allowedRole := range allowedRoles {
 			if role == allowedRole {
-				log.Printf("[RBAC] RequireRoles - Access GRANTED for role: %s (matches: %s)", role, allowedRole)
+				rbacLog.Debug("access granted", "role", role, "matched", allowedRole)
+				audit.MarkPrivileged(ctx)
 				return ctx.Next()
 			}
 		}
 
-		log.Printf("[RBAC] RequireRoles - Access DENIED for role: %s (no match found)", role)
+		rbacLog.Info("access denied", "path", ctx.Path(), "role", role, "allowedRoles", allowedRoles)
 		return ctx.Status(403).JSON(fiber.Map{
 			"error":       "INSUFFICIENT_PERMISSIONS",
 			"message":     "You don't have the required role",
@@ -280,7 +277,7 @@ func (r *RBACMiddleware) RequireRoles(allowedRoles ...string) fiber.Handler {
 
 // RequireAdministrator is a convenient method for Administrator-only endpoints
 func (r *RBACMiddleware) RequireAdministrator() fiber.Handler {
-	log.Printf("[RBAC] RequireAdministrator middleware created, requiring role: %s", RoleAdministrator)
+	rbacLog.Debug("RequireAdministrator middleware created", "role", RoleAdministrator)
 	return r.RequireRoles(RoleAdministrator)
 }
 
@@ -308,6 +305,19 @@ func (r *RBACMiddleware) SetUserRoleInContext() fiber.Handler {
 	}
 }
 
+// AuditActor คืน user ID และ role จาก token สำหรับ audit.Middleware (ไม่มี token คืนค่าว่าง)
+func (r *RBACMiddleware) AuditActor(ctx *fiber.Ctx) (string, string) {
+	tokenString, err := r.extractToken(ctx)
+	if err != nil {
+		return "", ""
+	}
+	userID, _, role, err := r.parseToken(tokenString)
+	if err != nil {
+		return "", ""
+	}
+	return userID, role
+}
+
 // Helper methods
 
 // Extract JWT token from query parameter first (for WebSocket), then Authorization header, then cookie
@@ -317,7 +327,7 @@ func (r *RBACMiddleware) extractToken(c *fiber.Ctx) (string, error) {
 	if authHeader != "" {
 		parts := strings.Split(authHeader, " ")
 		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-			log.Printf("[RBAC] JWT token extracted from Authorization header: %s", parts[1])
+			rbacLog.Debug("token extracted", "source", "authorization_header")
 			return parts[1], nil
 		}
 	}
@@ -325,51 +335,48 @@ func (r *RBACMiddleware) extractToken(c *fiber.Ctx) (string, error) {
 	// Try cookie as fallback
 	token := c.Cookies("accessToken")
 	if token != "" {
-		log.Printf("[RBAC] JWT token extracted from cookie: %s", token)
+		rbacLog.Debug("token extracted", "source", "cookie")
 		return token, nil
 	}
 	// Try query parameter (for WebSocket connections)
 	token = c.Query("token")
 	if token != "" {
-		log.Printf("[RBAC] JWT token extracted from query param: %s", token)
+		rbacLog.Debug("token extracted", "source", "query")
 		return token, nil
 	}
-	log.Printf("[RBAC] No JWT token found in header, cookie, or query param")
+	rbacLog.Debug("no token found in header, cookie, or query param")
 	return "", errors.New("no token found")
 }
 
 // Parse JWT token and extract user ID, username, and role
 func (r *RBACMiddleware) parseToken(tokenString string) (string, string, string, error) {
-	log.Printf("[RBAC] Parsing JWT token...")
 
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
-		log.Printf("[RBAC] WARNING: JWT_SECRET environment variable is not set")
+		rbacLog.Error("JWT_SECRET environment variable is not set")
 		return "", "", "", errors.New("JWT_SECRET not configured")
 	}
-	log.Printf("[RBAC] JWT_SECRET is configured (length: %d)", len(jwtSecret))
 
 	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			log.Printf("[RBAC] Unexpected signing method: %v", token.Method.Alg())
+			rbacLog.Warn("unexpected JWT signing method", "alg", token.Method.Alg())
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(jwtSecret), nil
 	})
 
 	if err != nil {
-		log.Printf("[RBAC] JWT parse error: %v", err)
+		rbacLog.Info("JWT parse error", "error", err)
 		return "", "", "", err
 	}
 
 	if claims, ok := token.Claims.(*JwtClaims); ok && token.Valid {
-		log.Printf("[RBAC] JWT claims parsed successfully - sub: %s, username: %s, role: %s, exp: %v",
-			claims.Sub, claims.Username, claims.Role, claims.ExpiresAt)
+		rbacLog.Debug("JWT claims parsed", "userId", claims.Sub, "role", claims.Role, "expiresAt", claims.ExpiresAt)
 		return claims.Sub, claims.Username, claims.Role, nil
 	}
 
-	log.Printf("[RBAC] Invalid token claims or token not valid")
+	rbacLog.Info("invalid token claims")
 	return "", "", "", errors.New("invalid token claims")
 }
 
@@ -408,15 +415,15 @@ func (r *RBACMiddleware) ExtractUserIDFromContext(ctx any) (string, error) {
 
 	// Validate userID is a valid MongoDB ObjectID
 	if len(userID) != 24 {
-		log.Printf("[RBAC] Extracted userID from JWT is not a valid ObjectID: %s", userID)
+		rbacLog.Warn("userID in JWT is not a valid ObjectID", "userId", userID)
 		return "", fmt.Errorf("userID in JWT 'sub' claim is not a valid ObjectID: %s", userID)
 	}
 	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
-		log.Printf("[RBAC] Extracted userID from JWT is not a valid ObjectID: %s", userID)
+		rbacLog.Warn("userID in JWT is not a valid ObjectID", "userId", userID)
 		return "", fmt.Errorf("userID in JWT 'sub' claim is not a valid ObjectID: %s", userID)
 	}
 
-	log.Printf("[DEBUG] Successfully extracted userID %s from JWT token", userID)
+	rbacLog.Debug("userID extracted from token", "userId", userID)
 	return userID, nil
 }
 
@@ -431,7 +438,7 @@ func (r *RBACMiddleware) ExtractUserIDFromToken(tokenString string) (string, err
 		return "", fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	log.Printf("[DEBUG] Successfully extracted userID %s from JWT token", userID)
+	rbacLog.Debug("userID extracted from token", "userId", userID)
 	return userID, nil
 }
 
@@ -446,7 +453,7 @@ func (r *RBACMiddleware) ExtractRoleNameFromToken(tokenString string) (string, e
 		return "", fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	log.Printf("[DEBUG] Successfully extracted roleName %s from JWT token", roleName)
+	rbacLog.Debug("role extracted from token", "role", roleName)
 	return roleName, nil
 }
 
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"chat/pkg/core/audit"
+	"chat/pkg/database/queries"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// **NEW: Room-scoped roles**
+// สิทธิ์ต่อห้อง = global role (Administrator ผ่านทุกห้อง) หรือ role ในห้องนั้น (เช่น owner / moderator)
+// role ในห้องมาจาก room service ผ่าน RoomRoleResolver เพื่อไม่ให้ pkg/middleware import module ของห้อง
+
+// Locals ที่ RequireRoomRole ตั้งไว้ให้ handler ใช้ต่อ
+const (
+	LocalsUserRole = "userRole"
+	LocalsRoomRole = "roomRole"
+	LocalsRoomID   = "authorizedRoomId" // ห้องที่ตรวจสิทธิ์แล้ว (ไม่ตั้งสำหรับ Administrator)
+)
+
+type (
+	// RoomRoleResolver คืน role ของ user ในห้อง ("" = ไม่ใช่สมาชิก, queries.ErrNotFound = ไม่มีห้อง)
+	RoomRoleResolver interface {
+		GetMemberRole(ctx context.Context, roomID, userID primitive.ObjectID) (string, error)
+	}
+
+	// RoomIDExtractor ดึง room ID ของ request (path, query หรือ body ตามแต่ route)
+	RoomIDExtractor func(ctx *fiber.Ctx) string
+)
+
+// RoomIDFromParam room ID จาก path parameter
+func RoomIDFromParam(name string) RoomIDExtractor {
+	return func(ctx *fiber.Ctx) string {
+		return ctx.Params(name)
+	}
+}
+
+// RoomIDFromQuery room ID จาก query string
+func RoomIDFromQuery(name string) RoomIDExtractor {
+	return func(ctx *fiber.Ctx) string {
+		return ctx.Query(name)
+	}
+}
+
+// RoomIDFromBody room ID จาก JSON body (หรือ form) โดยไม่กระทบ BodyParser ของ handler
+func RoomIDFromBody(field string) RoomIDExtractor {
+	return func(ctx *fiber.Ctx) string {
+		var body map[string]interface{}
+		if err := json.Unmarshal(ctx.Body(), &body); err == nil {
+			if roomID, ok := body[field].(string); ok {
+				return roomID
+			}
+		}
+		return ctx.FormValue(field)
+	}
+}
+
+// RequireRoomRole ผ่านเมื่อเป็น Administrator หรือมี role ในห้องตาม roomRoles
+func (r *RBACMiddleware) RequireRoomRole(resolver RoomRoleResolver, roomIDFrom RoomIDExtractor, roomRoles ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		tokenString, err := r.extractToken(ctx)
+		if err != nil {
+			return ctx.Status(401).JSON(fiber.Map{"error": "UNAUTHORIZED", "message": "Authentication required"})
+		}
+		userID, _, role, err := r.parseToken(tokenString)
+		if err != nil {
+			rbacLog.Info("require room role: authentication failed", "path", ctx.Path(), "error", err)
+			return ctx.Status(401).JSON(fiber.Map{"error": "UNAUTHORIZED", "message": "Authentication required"})
+		}
+		ctx.Locals(LocalsUserRole, role)
+
+		if role == RoleAdministrator {
+			audit.MarkPrivileged(ctx)
+			return ctx.Next()
+		}
+
+		roomObjID, err := primitive.ObjectIDFromHex(roomIDFrom(ctx))
+		if err != nil {
+			return ctx.Status(400).JSON(fiber.Map{
+				"error":   "INVALID_ROOM_ID",
+				"message": "A valid room ID is required for room-scoped permissions",
+			})
+		}
+		userObjID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return ctx.Status(401).JSON(fiber.Map{"error": "UNAUTHORIZED", "message": "Authentication required"})
+		}
+
+		roomRole, err := resolver.GetMemberRole(ctx.UserContext(), roomObjID, userObjID)
+		if errors.Is(err, queries.ErrNotFound) {
+			return ctx.Status(404).JSON(fiber.Map{"error": "ROOM_NOT_FOUND", "message": "Room not found"})
+		}
+		if err != nil {
+			rbacLog.Warn("require room role: failed to resolve room role", "roomId", roomObjID.Hex(), "userId", userID, "error", err)
+			return ctx.Status(500).JSON(fiber.Map{"error": "INTERNAL_ERROR", "message": "Failed to check room permissions"})
+		}
+
+		if roomRole != "" && slices.Contains(roomRoles, roomRole) {
+			rbacLog.Debug("room access granted", "roomId", roomObjID.Hex(), "role", role, "roomRole", roomRole)
+			ctx.Locals(LocalsRoomRole, roomRole)
+			ctx.Locals(LocalsRoomID, roomObjID.Hex())
+			audit.MarkPrivileged(ctx)
+			return ctx.Next()
+		}
+
+		rbacLog.Info("room access denied", "path", ctx.Path(), "roomId", roomObjID.Hex(), "role", role, "roomRole", roomRole)
+		return ctx.Status(403).JSON(fiber.Map{
+			"error":           "INSUFFICIENT_PERMISSIONS",
+			"message":         "You don't have the required role in this room",
+			"currentRole":     role,
+			"currentRoomRole": roomRole,
+		})
+	}
+}
+
+// IsGlobalAdministrator ใช้ใน handler หลัง RequireRoomRole เพื่อแยก Administrator ออกจาก moderator ของห้อง
+func IsGlobalAdministrator(ctx *fiber.Ctx) bool {
+	role, _ := ctx.Locals(LocalsUserRole).(string)
+	return role == RoleAdministrator
+}
+
+// IsAuthorizedRoom ใช้ใน handler หลัง RequireRoomRole ตรวจว่าห้องที่ handler parse ได้เป็นห้องเดียวกับที่ตรวจสิทธิ์
+// (extractor กับ BodyParser อาจอ่าน body ต่างกัน เช่น key "roomId" กับ "RoomId" ใน JSON เดียวกัน)
+func IsAuthorizedRoom(ctx *fiber.Ctx, roomID primitive.ObjectID) bool {
+	if IsGlobalAdministrator(ctx) {
+		return true
+	}
+	authorized, _ := ctx.Locals(LocalsRoomID).(string)
+	return authorized != "" && authorized == roomID.Hex()
+}
@@ -17,6 +17,7 @@ type IRBACMiddleware interface {
 	ExtractUserIDFromToken(tokenString string) (string, error)
 	ExtractRoleNameFromToken(tokenString string) (string, error)
 	RequireRoleParam() fiber.Handler
+	RequireRoomRole(resolver RoomRoleResolver, roomIDFrom RoomIDExtractor, roomRoles ...string) fiber.Handler
 }
 
 // Ensure RBACMiddleware implements IRBACMiddleware
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -56,6 +57,51 @@ func DefaultImageConfig() FileUploadConfig {
 
 type FileUploadHandler struct {
 	Config FileUploadConfig
+	module string // ชื่อ module สำหรับหา limit ที่ปรับตอน runtime ("" = default)
+}
+
+// UploadLimits ค่าที่ override MaxSize/AllowedTypes ตอน runtime
+type UploadLimits struct {
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+// DefaultUploadModule key ของ handler ที่สร้างจาก DefaultImageConfig
+const DefaultUploadModule = "default"
+
+var (
+	uploadLimitsMu sync.RWMutex
+	uploadLimits   = map[string]UploadLimits{}
+)
+
+// SetUploadLimits แทนที่ upload limit ทั้งหมด (key = ชื่อใน ModuleConfig หรือ "default")
+func SetUploadLimits(limits map[string]UploadLimits) {
+	copied := make(map[string]UploadLimits, len(limits))
+	for module, l := range limits {
+		copied[module] = UploadLimits{MaxSize: l.MaxSize, AllowedTypes: append([]string(nil), l.AllowedTypes...)}
+	}
+
+	uploadLimitsMu.Lock()
+	uploadLimits = copied
+	uploadLimitsMu.Unlock()
+}
+
+// applyUploadLimits ใช้ limit ที่ตั้งตอน runtime ทับค่าใน config (ถ้ามี)
+func applyUploadLimits(module string, config FileUploadConfig) FileUploadConfig {
+	uploadLimitsMu.RLock()
+	override, ok := uploadLimits[module]
+	uploadLimitsMu.RUnlock()
+	if !ok {
+		return config
+	}
+
+	if override.MaxSize > 0 {
+		config.MaxSize = override.MaxSize
+	}
+	if len(override.AllowedTypes) > 0 {
+		config.AllowedTypes = override.AllowedTypes
+	}
+	return config
 }
 
 func NewFileUploadHandler(config FileUploadConfig) *FileUploadHandler {
@@ -64,6 +110,15 @@ func NewFileUploadHandler(config FileUploadConfig) *FileUploadHandler {
 	}
 }
 
+// ValidateFormFile ตรวจไฟล์ใน form (ขนาด/ชนิด) โดยยังไม่บันทึก ใช้ก่อนงานที่ย้อนกลับไม่ได้ เช่นการเริ่ม slow mode cooldown
+func (h *FileUploadHandler) ValidateFormFile(ctx *fiber.Ctx, fieldName string) error {
+	file, err := ctx.FormFile(fieldName)
+	if err != nil {
+		return err
+	}
+	return h.validateFile(file)
+}
+
 func (h *FileUploadHandler) HandleFileUpload(ctx *fiber.Ctx, fieldName string) (string, error) {
 	file, err := ctx.FormFile(fieldName)
 	if err != nil {
@@ -104,21 +159,27 @@ func (h *FileUploadHandler) DeleteFile(filepath string) error {
 }
 
 func (h *FileUploadHandler) validateFile(file *multipart.FileHeader) error {
+	module := h.module
+	if module == "" {
+		module = DefaultUploadModule
+	}
+	config := applyUploadLimits(module, h.Config)
+
 	// Check file size first (faster than reading content type)
-	if file.Size > h.Config.MaxSize {
-		return fmt.Errorf("file size exceeds limit of %d bytes", h.Config.MaxSize)
+	if file.Size > config.MaxSize {
+		return fmt.Errorf("file size exceeds limit of %d bytes", config.MaxSize)
 	}
 
 	// Use a map for O(1) lookup of allowed types
 	contentType := file.Header.Get("Content-Type")
-	allowedTypes := make(map[string]struct{}, len(h.Config.AllowedTypes))
-	for _, t := range h.Config.AllowedTypes {
+	allowedTypes := make(map[string]struct{}, len(config.AllowedTypes))
+	for _, t := range config.AllowedTypes {
 		allowedTypes[t] = struct{}{}
 	}
 
 	if _, allowed := allowedTypes[contentType]; !allowed {
 		return fmt.Errorf("invalid file type: %s. Allowed types: %s", 
-			contentType, strings.Join(h.Config.AllowedTypes, ", "))
+			contentType, strings.Join(config.AllowedTypes, ", "))
 	}
 
 	return nil
@@ -151,12 +212,14 @@ func IsImage(contentType string) bool {
 
 func GetModuleConfig(module string) FileUploadConfig {
 	if config, exists := ModuleConfig[module]; exists {
-		return config
+		return applyUploadLimits(module, config)
 	}
-	return DefaultImageConfig()
+	return applyUploadLimits(DefaultUploadModule, DefaultImageConfig())
 }
 
 // NewModuleFileHandler creates a new handler for a specific module
 func NewModuleFileHandler(module string) *FileUploadHandler {
-	return NewFileUploadHandler(GetModuleConfig(module))
+	handler := NewFileUploadHandler(GetModuleConfig(module))
+	handler.module = module
+	return handler
 } 
\ No newline at end of file